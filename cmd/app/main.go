@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"os/signal"
+	"syscall"
 
 	nested "github.com/antonfisher/nested-logrus-formatter"
 	log "github.com/sirupsen/logrus"
@@ -13,6 +15,7 @@ import (
 	"github.com/codecrafters-io/redis-starter-go/internal/clients"
 	"github.com/codecrafters-io/redis-starter-go/internal/config"
 	"github.com/codecrafters-io/redis-starter-go/internal/master"
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
 	"github.com/codecrafters-io/redis-starter-go/internal/slave"
 	"github.com/codecrafters-io/redis-starter-go/internal/store"
 	"github.com/codecrafters-io/redis-starter-go/internal/transactions"
@@ -37,7 +40,75 @@ func main() {
 	port := flag.Int("port", 6379, "Port to listen on")
 	replicaOf := flag.String("replicaof", "", "Replica to another server")
 	dir := flag.String("dir", "", "Directory to store data")
-	dbFileName := flag.String("dbfilename", "", "Database file name")
+	dbFileName := flag.String("dbfilename", "dump.rdb", "Database file name")
+	save := flag.String("save", "3600 1 300 100 60 10000", "Snapshotting save points")
+	appendOnly := flag.Bool("appendonly", false, "Enable the append only file")
+	maxMemory := flag.Int64("maxmemory", 0, "Memory usage limit in bytes")
+	maxMemoryPolicy := flag.String("maxmemory-policy", "noeviction", "Eviction policy")
+	timeout := flag.Int("timeout", 0, "Close idle client connections after seconds")
+	tcpKeepalive := flag.Int("tcp-keepalive", 300, "TCP keepalive period in seconds")
+	databasesCount := flag.Int("databases", 16, "Number of databases")
+	lazyfreeLazyExpire := flag.Bool(
+		"lazyfree-lazy-expire",
+		false,
+		"Free large values of expired keys in the background",
+	)
+	lazyfreeLazyUserDel := flag.Bool(
+		"lazyfree-lazy-user-del",
+		false,
+		"Free large values removed by DEL in the background, like UNLINK",
+	)
+	listMaxListpackSize := flag.Int(
+		"list-max-listpack-size",
+		store.DefaultListMaxListpackSize,
+		"Maximum number of list elements in listpack encoding",
+	)
+	hashMaxListpackEntries := flag.Int(
+		"hash-max-listpack-entries",
+		store.DefaultHashMaxListpackEntries,
+		"Maximum number of hash fields in listpack encoding",
+	)
+	hashMaxListpackValue := flag.Int(
+		"hash-max-listpack-value",
+		store.DefaultHashMaxListpackValue,
+		"Maximum length of a hash field or value in listpack encoding",
+	)
+	setMaxIntsetEntries := flag.Int(
+		"set-max-intset-entries",
+		store.DefaultSetMaxIntsetEntries,
+		"Maximum number of integer set members in intset encoding",
+	)
+	setMaxListpackEntries := flag.Int(
+		"set-max-listpack-entries",
+		store.DefaultSetMaxListpackEntries,
+		"Maximum number of set members in listpack encoding",
+	)
+	setMaxListpackValue := flag.Int(
+		"set-max-listpack-value",
+		store.DefaultSetMaxListpackValue,
+		"Maximum length of a set member in listpack encoding",
+	)
+	resp3Keepalive := flag.Int(
+		"resp3-keepalive",
+		0,
+		"Send a keepalive push to idle RESP3 tracking clients every N seconds (0 disables)",
+	)
+
+	protoMaxBulkLen := flag.Int64(
+		"proto-max-bulk-len",
+		redis.DefaultProtoMaxBulkLen,
+		"Maximum size of a single bulk string in bytes",
+	)
+	readBufferSize := flag.Int(
+		"read-buffer-size",
+		redis.DefaultReadBufferSize,
+		"Size in bytes of the buffer used to read from client and master connections",
+	)
+	maxPipelineDepth := flag.Int(
+		"max-pipeline-depth",
+		redis.DefaultMaxPipelineDepth,
+		"Commands a connection may have in flight before the server stops reading from it",
+	)
 
 	flag.Parse()
 
@@ -45,19 +116,64 @@ func main() {
 		Port:            *port,
 		RedisDir:        *dir,
 		RedisDbFileName: *dbFileName,
+
+		Save:            *save,
+		AppendOnly:      *appendOnly,
+		MaxMemory:       *maxMemory,
+		MaxMemoryPolicy: *maxMemoryPolicy,
+		Timeout:         *timeout,
+		TcpKeepalive:    *tcpKeepalive,
+		Databases:       *databasesCount,
+
+		LazyfreeLazyExpire:  *lazyfreeLazyExpire,
+		LazyfreeLazyUserDel: *lazyfreeLazyUserDel,
+
+		ListMaxListpackSize:    *listMaxListpackSize,
+		HashMaxListpackEntries: *hashMaxListpackEntries,
+		HashMaxListpackValue:   *hashMaxListpackValue,
+		SetMaxIntsetEntries:    *setMaxIntsetEntries,
+		SetMaxListpackEntries:  *setMaxListpackEntries,
+		SetMaxListpackValue:    *setMaxListpackValue,
+		Resp3Keepalive:         *resp3Keepalive,
+		ProtoMaxBulkLen:        *protoMaxBulkLen,
+		ReadBufferSize:         *readBufferSize,
+		MaxPipelineDepth:       *maxPipelineDepth,
+	}
+
+	if err := validateConfig(cfg); err != nil {
+		log.Fatalln(err)
+	}
+
+	redis.SetProtoMaxBulkLen(cfg.ProtoMaxBulkLen)
+	clientsObj := clients.NewClients()
+	tracking := clients.NewTracking()
+
+	databases := store.NewDatabases(cfg.Databases)
+	for _, db := range databases.All() {
+		if err := db.SetListMaxListpackSize(cfg.ListMaxListpackSize); err != nil {
+			log.Fatalln(err)
+		}
+		db.SetHashMaxListpack(cfg.HashMaxListpackEntries, cfg.HashMaxListpackValue)
+		db.SetSetMaxEntries(cfg.SetMaxIntsetEntries, cfg.SetMaxListpackEntries, cfg.SetMaxListpackValue)
+		db.SetProtoMaxBulkLen(cfg.ProtoMaxBulkLen)
+		db.SetLazyFree(cfg.LazyfreeLazyExpire, cfg.LazyfreeLazyUserDel)
+		db.SetWriteHook(tracking.Invalidate)
 	}
+	// DB 0 is the store used outside of a client connection
+	storeObj, _ := databases.Get(0)
 
-	storeObj := store.NewStore()
-	expiredCollector := store.NewExpiredCollector(storeObj)
-	clients := clients.NewClients()
+	pubSub := clients.NewPubSub(utils.MatchGlob)
+	connections := clients.NewConnections()
 	transaction := transactions.NewTransaction()
-	blockCh := make(chan struct{})
 
 	ctx := context.Background()
 	ctx = context.WithValue(ctx, "store", storeObj)
-	ctx = context.WithValue(ctx, "clients", clients)
+	ctx = context.WithValue(ctx, "databases", databases)
+	ctx = context.WithValue(ctx, "clients", clientsObj)
+	ctx = context.WithValue(ctx, "tracking", tracking)
+	ctx = context.WithValue(ctx, "pubsub", pubSub)
+	ctx = context.WithValue(ctx, "connections", connections)
 	ctx = context.WithValue(ctx, "transactions", transaction)
-	ctx = context.WithValue(ctx, "blockCh", blockCh)
 
 	address := fmt.Sprintf("0.0.0.0:%d", cfg.Port)
 
@@ -71,11 +187,16 @@ func main() {
 	connChan := make(chan net.Conn)
 	errChan := make(chan error)
 
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
 	if *replicaOf == "" {
 		cfg.Role = "master"
 		cfg.Master = &config.Master{
 			MasterReplId: "8371b4fb1155b71f4a04d3e1bc3e18c4a990aeeb",
 		}
+
+		utils.LoadRDB(ctx, cfg.RedisDir, cfg.RedisDbFileName)
 	} else {
 		cfg.Role = "slave"
 		cfg.Slave = &config.Slave{
@@ -86,7 +207,7 @@ func main() {
 			log.Fatalln("Error connecting to master: ", err)
 		}
 
-		reader, err := slave.Handshakes(masterConn, cfg)
+		reader, err := slave.Handshakes(ctx, masterConn, cfg)
 		if err != nil {
 			log.Fatalln("There is was error in handshakes with master : ", err)
 		}
@@ -94,13 +215,35 @@ func main() {
 		go slave.ReadFromConnection(ctx, masterConn, reader, cfg)
 	}
 
-	utils.LoadRDB(ctx, cfg.RedisDir, cfg.RedisDbFileName)
 	go master.AcceptConnections(l, connChan, errChan)
-	go expiredCollector.Tick()
+	for _, db := range databases.All() {
+		db.StartExpiryReaper(ctx)
+	}
+
+	serve(ctx, l, cfg, connChan, errChan, sigChan)
+	os.Exit(0)
+}
+
+/*
+serve hands accepted connections to their readers until a signal arrives,
+then shuts the server down and returns.
+*/
+func serve(
+	ctx context.Context,
+	l net.Listener,
+	cfg config.Config,
+	connChan <-chan net.Conn,
+	errChan <-chan error,
+	sigChan <-chan os.Signal,
+) {
+	connections := utils.GetConnectionsObj(ctx)
 
 	for {
 		select {
 		case conn := <-connChan:
+			syncConn := clients.NewSyncConn(conn)
+			connections.Add(syncConn)
+			conn = syncConn
 
 			transcationObj := transactions.GetTransactionsObj(ctx)
 			transcationObj.AddConnection(conn)
@@ -110,6 +253,63 @@ func main() {
 		case err := <-errChan:
 			fmt.Println("Error accepting connection", err.Error())
 
+		case sig := <-sigChan:
+			log.WithFields(log.Fields{
+				"package":  "main",
+				"function": "serve",
+				"signal":   sig,
+			}).Info("Received signal, shutting down")
+
+			shutdown(ctx, l, cfg)
+			return
+		}
+	}
+}
+
+func shutdown(ctx context.Context, l net.Listener, cfg config.Config) {
+	l.Close()
+
+	if cfg.AppendOnly {
+		// -appendonly is accepted for CONFIG GET, but no AOF is written
+		log.WithFields(log.Fields{
+			"package":  "main",
+			"function": "shutdown",
+		}).Warn("The append only file is not supported, nothing to flush")
+	}
+
+	if cfg.Save != "" && cfg.RedisDbFileName != "" {
+		if err := utils.SaveRDB(ctx, cfg.RedisDir, cfg.RedisDbFileName); err != nil {
+			log.WithFields(log.Fields{
+				"package":  "main",
+				"function": "shutdown",
+				"error":    err,
+			}).Error("Error saving RDB on shutdown")
 		}
 	}
+
+	for _, replica := range utils.GetClientsObj(ctx).GetAll() {
+		replica.Close()
+	}
+}
+
+/*
+validateConfig rejects settings the server cannot run with, such as sizes
+that are later used as divisors or buffer lengths.
+*/
+func validateConfig(cfg config.Config) error {
+	for _, setting := range []struct {
+		name  string
+		value int
+	}{
+		{"databases", cfg.Databases},
+		{"read-buffer-size", cfg.ReadBufferSize},
+		{"max-pipeline-depth", cfg.MaxPipelineDepth},
+		{"list-max-listpack-size", cfg.ListMaxListpackSize},
+	} {
+		if setting.value < 1 {
+			return fmt.Errorf("%s must be at least 1", setting.name)
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/clients"
+	"github.com/codecrafters-io/redis-starter-go/internal/config"
+	"github.com/codecrafters-io/redis-starter-go/internal/store"
+	"github.com/codecrafters-io/redis-starter-go/internal/utils"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func newShutdownContext(databases *store.Databases) context.Context {
+	storeObj, _ := databases.Get(0)
+
+	ctx := context.WithValue(context.Background(), "store", storeObj)
+	ctx = context.WithValue(ctx, "databases", databases)
+	return context.WithValue(ctx, "clients", clients.NewClients())
+}
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return l
+}
+
+func TestShutdownSavesWhenSaveIsConfigured(t *testing.T) {
+	dir := t.TempDir()
+
+	databases := store.NewDatabases(16)
+	db0, _ := databases.Get(0)
+	db0.Set("k", "v", nil)
+	db3, _ := databases.Get(3)
+	db3.RPush("l", []string{"a", "b"})
+
+	cfg := config.Config{RedisDir: dir, RedisDbFileName: "dump.rdb", Save: "3600 1"}
+
+	shutdown(newShutdownContext(databases), listen(t), cfg)
+
+	loaded := store.NewDatabases(16)
+	utils.LoadRDB(newShutdownContext(loaded), dir, "dump.rdb")
+
+	db0, _ = loaded.Get(0)
+	if got, err := db0.Get("k"); err != nil || got != "v" {
+		t.Fatalf("k = %q, %v", got, err)
+	}
+	db3, _ = loaded.Get(3)
+	if got, _ := db3.LLen("l"); got != 2 {
+		t.Fatalf("LLEN l in DB 3 = %d, want 2", got)
+	}
+}
+
+func TestShutdownSkipsSaveWhenSaveIsDisabled(t *testing.T) {
+	dir := t.TempDir()
+
+	databases := store.NewDatabases(16)
+	db0, _ := databases.Get(0)
+	db0.Set("k", "v", nil)
+
+	cfg := config.Config{RedisDir: dir, RedisDbFileName: "dump.rdb", Save: ""}
+
+	shutdown(newShutdownContext(databases), listen(t), cfg)
+
+	if _, err := os.Stat(filepath.Join(dir, "dump.rdb")); !os.IsNotExist(err) {
+		t.Fatalf("dump.rdb written with save disabled: %v", err)
+	}
+}
+
+func TestServeSavesOnSignal(t *testing.T) {
+	dir := t.TempDir()
+
+	databases := store.NewDatabases(16)
+	db0, _ := databases.Get(0)
+	db0.Set("k", "v", nil)
+
+	ctx := context.WithValue(newShutdownContext(databases), "connections", clients.NewConnections())
+	cfg := config.Config{RedisDir: dir, RedisDbFileName: "dump.rdb", Save: "3600 1"}
+
+	sigChan := make(chan os.Signal, 1)
+	served := make(chan struct{})
+	go func() {
+		serve(ctx, listen(t), cfg, make(chan net.Conn), make(chan error), sigChan)
+		close(served)
+	}()
+
+	sigChan <- syscall.SIGTERM
+
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve kept running after SIGTERM")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "dump.rdb")); err != nil {
+		t.Fatalf("no RDB file after SIGTERM: %v", err)
+	}
+
+	loaded := store.NewDatabases(16)
+	utils.LoadRDB(newShutdownContext(loaded), dir, "dump.rdb")
+	db0, _ = loaded.Get(0)
+	if got, err := db0.Get("k"); err != nil || got != "v" {
+		t.Fatalf("k = %q, %v after loading the RDB saved on SIGTERM", got, err)
+	}
+}
+
+func TestShutdownWarnsAppendOnlyIsUnsupported(t *testing.T) {
+	hook := test.NewLocal(log.StandardLogger())
+	t.Cleanup(func() { log.StandardLogger().ReplaceHooks(make(log.LevelHooks)) })
+
+	shutdown(newShutdownContext(store.NewDatabases(1)), listen(t), config.Config{AppendOnly: true})
+
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == log.WarnLevel && strings.Contains(entry.Message, "append only") {
+			return
+		}
+	}
+	t.Fatal("shutdown with appendonly logged no warning that the AOF is unsupported")
+}
+
+func TestShutdownClosesListener(t *testing.T) {
+	l := listen(t)
+
+	shutdown(newShutdownContext(store.NewDatabases(1)), l, config.Config{})
+
+	if _, err := net.Dial("tcp", l.Addr().String()); err == nil {
+		t.Fatal("listener still accepts connections after shutdown")
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	valid := config.Config{
+		Databases:           16,
+		ReadBufferSize:      16 * 1024,
+		MaxPipelineDepth:    1024,
+		ListMaxListpackSize: store.DefaultListMaxListpackSize,
+	}
+
+	if err := validateConfig(valid); err != nil {
+		t.Fatalf("validateConfig(valid) = %v", err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		modify func(cfg *config.Config)
+	}{
+		{"databases", func(cfg *config.Config) { cfg.Databases = 0 }},
+		{"read-buffer-size", func(cfg *config.Config) { cfg.ReadBufferSize = 0 }},
+		{"max-pipeline-depth", func(cfg *config.Config) { cfg.MaxPipelineDepth = -1 }},
+		{"list-max-listpack-size", func(cfg *config.Config) { cfg.ListMaxListpackSize = 0 }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+
+			if err := validateConfig(cfg); err == nil || !strings.Contains(err.Error(), tt.name) {
+				t.Fatalf("validateConfig = %v, want an error about %s", err, tt.name)
+			}
+		})
+	}
+}
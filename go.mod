@@ -11,8 +11,11 @@ module github.com/codecrafters-io/redis-starter-go
 go 1.22
 
 require (
-	github.com/antonfisher/nested-logrus-formatter v1.3.1 // indirect
-	github.com/sirupsen/logrus v1.9.3 // indirect
+	github.com/antonfisher/nested-logrus-formatter v1.3.1
+	github.com/sirupsen/logrus v1.9.3
+)
+
+require (
 	github.com/zput/zxcTool v1.3.10 // indirect
 	golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
 )
@@ -1,10 +1,14 @@
 package clients
 
 import (
+	"bytes"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
 )
 
 type offset int64
@@ -13,15 +17,77 @@ type Clients struct {
 	Clients    map[net.Conn]offset
 	Mutex      sync.RWMutex
 	Subscriber func(conn net.Conn, offset int)
+
+	// syncing holds, for every replica still receiving its snapshot, the
+	// writes propagated since the snapshot was taken
+	syncing map[net.Conn]*bytes.Buffer
 }
 
 func NewClients() *Clients {
 	logrus.Info("Creating new clients")
 	return &Clients{
 		Clients: make(map[net.Conn]offset),
+		syncing: make(map[net.Conn]*bytes.Buffer),
 	}
 }
 
+/*
+StartSync registers a replica that is being sent a snapshot. The writes
+propagated from then on come after the snapshot, Backlog keeps them for
+the replica until FinishSync sends them after it.
+*/
+func (cl *Clients) StartSync(client net.Conn) {
+	cl.Mutex.Lock()
+	defer cl.Mutex.Unlock()
+
+	cl.syncing[client] = &bytes.Buffer{}
+}
+
+/*
+Backlog keeps cmd for every replica still receiving its snapshot.
+*/
+func (cl *Clients) Backlog(cmd string) {
+	cl.Mutex.Lock()
+	defer cl.Mutex.Unlock()
+
+	for _, backlog := range cl.syncing {
+		backlog.WriteString(cmd)
+	}
+}
+
+/*
+FinishSync sends a replica that received its snapshot the writes kept for
+it meanwhile and registers it, so the next writes are sent to it directly.
+The caller must hold the propagation lock, so that no write is propagated
+between the two.
+*/
+func (cl *Clients) FinishSync(client net.Conn) error {
+	cl.Mutex.Lock()
+	backlog := cl.syncing[client]
+	delete(cl.syncing, client)
+	cl.Mutex.Unlock()
+
+	if backlog != nil && backlog.Len() > 0 {
+		if _, err := client.Write(backlog.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	cl.Set(client)
+
+	return nil
+}
+
+/*
+AbortSync drops a replica whose snapshot could not be sent.
+*/
+func (cl *Clients) AbortSync(client net.Conn) {
+	cl.Mutex.Lock()
+	defer cl.Mutex.Unlock()
+
+	delete(cl.syncing, client)
+}
+
 func (cl *Clients) Set(client net.Conn) {
 	cl.Mutex.Lock()
 	defer cl.Mutex.Unlock()
@@ -53,6 +119,17 @@ func (cl *Clients) GetAll() []net.Conn {
 	return keys
 }
 
+/*
+Count returns the number of connected replicas. Only connections that
+completed PSYNC are registered, so regular clients are never counted.
+*/
+func (cl *Clients) Count() int {
+	cl.Mutex.RLock()
+	defer cl.Mutex.RUnlock()
+
+	return len(cl.Clients)
+}
+
 func (cl *Clients) SetOffset(conn net.Conn, n int) {
 	cl.Mutex.Lock()
 	defer cl.Mutex.Unlock()
@@ -94,15 +171,6 @@ func (cl *Clients) Notify(conn net.Conn, offset int) {
 	}
 }
 
-func (cl *Clients) NotifyAll(offset int) {
-	cl.Mutex.RLock()
-	defer cl.Mutex.RLock()
-
-	for _, client := range cl.GetAll() {
-		cl.Notify(client, offset)
-	}
-}
-
 func (cl *Clients) Subscribe(handler func(conn net.Conn, clientOffset int)) {
 	cl.Mutex.Lock()
 	defer cl.Mutex.Unlock()
@@ -114,3 +182,112 @@ func (cl *Clients) Subscribe(handler func(conn net.Conn, clientOffset int)) {
 
 	cl.Subscriber = handler
 }
+
+func (cl *Clients) Unsubscribe() {
+	cl.Mutex.Lock()
+	defer cl.Mutex.Unlock()
+
+	cl.Subscriber = nil
+}
+
+/*
+countAcked returns the number of replicas whose last acknowledged offset
+reached targetOffset.
+*/
+func (cl *Clients) countAcked(targetOffset int64) int {
+	cl.Mutex.RLock()
+	defer cl.Mutex.RUnlock()
+
+	var count int
+	for _, clientOffset := range cl.Clients {
+		if int64(clientOffset) >= targetOffset {
+			count++
+		}
+	}
+
+	return count
+}
+
+/*
+WaitForAcks asks every replica for its replication offset and waits until
+goal replicas acknowledged targetOffset or the timeout elapses. A zero
+timeout waits forever, a goal of 0 or less does not wait at all. It returns the number of replicas that reached
+targetOffset; a replica acknowledging more than once is counted once.
+*/
+func (cl *Clients) WaitForAcks(goal int, timeout time.Duration, targetOffset int64) int {
+	if targetOffset == 0 {
+		return cl.Count()
+	}
+
+	// nothing to wait for, answer with the acks already received
+	if goal <= 0 {
+		return cl.countAcked(targetOffset)
+	}
+
+	done := make(chan struct{})
+	var once sync.Once
+	var mu sync.Mutex
+	acked := make(map[net.Conn]struct{})
+
+	cl.Subscribe(func(conn net.Conn, clientOffset int) {
+		logrus.WithFields(logrus.Fields{
+			"package":      "clients",
+			"function":     "WaitForAcks",
+			"targetOffset": targetOffset,
+			"clientOffset": clientOffset,
+		}).Info("Notification alert")
+
+		if targetOffset > int64(clientOffset) {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		acked[conn] = struct{}{}
+		if len(acked) >= goal {
+			once.Do(func() { close(done) })
+		}
+	})
+	defer cl.Unsubscribe()
+
+	cmdReplConf := redis.ConvertToRESP([]string{"REPLCONF", "GETACK", "*"})
+
+	for _, client := range cl.GetAll() {
+		client.Write([]byte(cmdReplConf))
+	}
+
+	var timerCh <-chan time.Time
+	if timeout > 0 {
+		timerCh = time.After(timeout)
+	}
+
+	select {
+	case <-done:
+	case <-timerCh:
+	}
+
+	// read before taking mu, SetOffset calls the subscriber holding cl.Mutex
+	replicas := cl.Count()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	entry := logrus.WithFields(logrus.Fields{
+		"package":  "clients",
+		"function": "WaitForAcks",
+		"acked":    len(acked),
+		"goal":     goal,
+		"replicas": replicas,
+	})
+
+	// a short count means writes are not on as many replicas as asked,
+	// keep it visible apart from the normal case
+	if len(acked) < goal {
+		entry.WithField("outcome", "timed_out_below_goal").Warn("WAIT timed out below goal")
+	} else {
+		entry.WithField("outcome", "satisfied").Info("WAIT satisfied")
+	}
+
+	return len(acked)
+}
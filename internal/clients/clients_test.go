@@ -0,0 +1,204 @@
+package clients
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
+)
+
+func TestMain(m *testing.M) {
+	logrus.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+/*
+fakeReplica registers the server side of a pipe as a replica and answers
+every REPLCONF GETACK with ack, the offset it claims to have processed.
+Acks are sent as many times as repeat says.
+*/
+func fakeReplica(t *testing.T, cl *Clients, ack int, repeat int) {
+	t.Helper()
+
+	server, replica := net.Pipe()
+	t.Cleanup(func() {
+		server.Close()
+		replica.Close()
+	})
+	cl.Set(server)
+
+	go func() {
+		reader := bufio.NewReader(replica)
+		for {
+			args, _, err := redis.UnpackInput(reader)
+			if err != nil {
+				return
+			}
+			if len(args) != 3 || args[0] != "REPLCONF" || args[1] != "GETACK" {
+				t.Errorf("replica got %v, want REPLCONF GETACK *", args)
+				return
+			}
+
+			for i := 0; i < repeat; i++ {
+				cl.SetOffset(server, ack)
+			}
+		}
+	}()
+}
+
+func TestWaitForAcks(t *testing.T) {
+	tests := []struct {
+		name   string
+		acks   []int
+		repeat int
+		goal   int
+		target int64
+		want   int
+	}{
+		{name: "nothing written yet", acks: []int{0, 0}, goal: 5, target: 0, want: 2},
+		{name: "every replica caught up", acks: []int{100, 120}, goal: 2, target: 100, want: 2},
+		{name: "one replica behind", acks: []int{100, 40}, goal: 2, target: 100, want: 1},
+		{name: "no replica caught up", acks: []int{10}, goal: 1, target: 100, want: 0},
+		{name: "repeated acks count once", acks: []int{100}, repeat: 3, goal: 2, target: 100, want: 1},
+		{name: "no replicas", goal: 1, target: 100, want: 0},
+		{name: "goal 0 with no replicas", goal: 0, target: 100, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := NewClients()
+			repeat := tt.repeat
+			if repeat == 0 {
+				repeat = 1
+			}
+			for _, ack := range tt.acks {
+				fakeReplica(t, cl, ack, repeat)
+			}
+
+			start := time.Now()
+			if got := cl.WaitForAcks(tt.goal, 100*time.Millisecond, tt.target); got != tt.want {
+				t.Fatalf("WaitForAcks = %d, want %d", got, tt.want)
+			}
+
+			// reaching the goal returns without waiting for the timeout
+			if tt.want >= tt.goal && time.Since(start) >= 100*time.Millisecond {
+				t.Fatalf("WaitForAcks took %v although the goal was reached", time.Since(start))
+			}
+		})
+	}
+}
+
+func TestWaitForAcksGoalBelowReplicas(t *testing.T) {
+	cl := NewClients()
+	for i := 0; i < 3; i++ {
+		fakeReplica(t, cl, 100, 1)
+	}
+
+	// replicas acking before WAIT returns are counted too, like in Redis
+	if got := cl.WaitForAcks(1, time.Second, 100); got < 1 || got > 3 {
+		t.Fatalf("WaitForAcks = %d, want between 1 and 3", got)
+	}
+}
+
+func TestWaitForAcksUnsubscribes(t *testing.T) {
+	cl := NewClients()
+	fakeReplica(t, cl, 100, 1)
+
+	cl.WaitForAcks(1, 100*time.Millisecond, 100)
+
+	cl.Mutex.RLock()
+	defer cl.Mutex.RUnlock()
+	if cl.Subscriber != nil {
+		t.Fatal("WaitForAcks left its subscriber behind")
+	}
+}
+
+func TestWaitForAcksLogsOutcome(t *testing.T) {
+	hook := test.NewLocal(logrus.StandardLogger())
+	t.Cleanup(func() { logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks)) })
+
+	outcome := func() (logrus.Level, logrus.Fields) {
+		t.Helper()
+		for _, entry := range hook.AllEntries() {
+			if _, ok := entry.Data["outcome"]; ok {
+				return entry.Level, entry.Data
+			}
+		}
+		t.Fatal("WaitForAcks logged no outcome")
+		return 0, nil
+	}
+
+	cl := NewClients()
+	fakeReplica(t, cl, 100, 1)
+	fakeReplica(t, cl, 40, 1)
+
+	// asking for more replicas than there are runs into the timeout and
+	// returns what was really acknowledged
+	if got := cl.WaitForAcks(3, 50*time.Millisecond, 100); got != 1 {
+		t.Fatalf("WaitForAcks below its goal = %d, want 1", got)
+	}
+	level, fields := outcome()
+	if level != logrus.WarnLevel || fields["outcome"] != "timed_out_below_goal" ||
+		fields["acked"] != 1 || fields["goal"] != 3 || fields["replicas"] != 2 {
+		t.Fatalf("outcome logged as %s %v, want a warning timed_out_below_goal acked=1 goal=3 replicas=2", level, fields)
+	}
+
+	hook.Reset()
+	if got := cl.WaitForAcks(1, time.Second, 100); got != 1 {
+		t.Fatalf("WaitForAcks reaching its goal = %d, want 1", got)
+	}
+	level, fields = outcome()
+	if level != logrus.InfoLevel || fields["outcome"] != "satisfied" {
+		t.Fatalf("outcome logged as %s %v, want info satisfied", level, fields)
+	}
+}
+
+func TestSyncBacklogFollowsSnapshot(t *testing.T) {
+	cl := NewClients()
+
+	server, replica := net.Pipe()
+	t.Cleanup(func() {
+		server.Close()
+		replica.Close()
+	})
+
+	cl.StartSync(server)
+	cl.Backlog("first")
+	cl.Backlog("second")
+
+	// a replica still receiving its snapshot is not written to directly
+	if got := cl.Count(); got != 0 {
+		t.Fatalf("%d replicas registered during the sync, want 0", got)
+	}
+
+	received := make(chan string, 1)
+	go func() {
+		buf := make([]byte, 64)
+		n, _ := io.ReadAtLeast(replica, buf, len("firstsecond"))
+		received <- string(buf[:n])
+	}()
+
+	if err := cl.FinishSync(server); err != nil {
+		t.Fatalf("FinishSync: %v", err)
+	}
+
+	if got := <-received; got != "firstsecond" {
+		t.Fatalf("replica received %q after its snapshot, want %q", got, "firstsecond")
+	}
+	if got := cl.Count(); got != 1 {
+		t.Fatalf("%d replicas registered after the sync, want 1", got)
+	}
+
+	// once registered the replica has no backlog left to grow
+	cl.Backlog("third")
+	if len(cl.syncing) != 0 {
+		t.Fatalf("%d replicas still syncing, want 0", len(cl.syncing))
+	}
+}
@@ -0,0 +1,155 @@
+package clients
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var keepaliveMessage = []byte(">1\r\n$4\r\nping\r\n")
+
+var lastConnID atomic.Int64
+
+/*
+SyncConn serializes writes to the underlying connection so replies,
+propagated commands and published messages written from different
+goroutines never interleave within a frame. It also carries the
+per-connection protocol state.
+*/
+type SyncConn struct {
+	net.Conn
+	mu sync.Mutex
+
+	ID        int64
+	protocol  atomic.Int32
+	tracking  atomic.Bool
+	lastWrite atomic.Int64
+	db        atomic.Int32
+
+	createdAt       time.Time
+	lastInteraction atomic.Int64
+	totalCommands   atomic.Int64
+	lastCommand     atomic.Value
+}
+
+func NewSyncConn(conn net.Conn) *SyncConn {
+	c := &SyncConn{
+		Conn:      conn,
+		ID:        lastConnID.Add(1),
+		createdAt: time.Now(),
+	}
+	c.protocol.Store(2)
+	c.lastInteraction.Store(c.createdAt.UnixNano())
+	c.lastCommand.Store("NULL")
+
+	return c
+}
+
+func (c *SyncConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lastWrite.Store(time.Now().UnixNano())
+
+	return c.Conn.Write(b)
+}
+
+// writeKeepalive writes without refreshing lastWrite, so keepalives
+// keep flowing at a steady interval while the connection stays idle.
+func (c *SyncConn) writeKeepalive() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := c.Conn.Write(keepaliveMessage)
+	return err
+}
+
+func (c *SyncConn) Protocol() int {
+	return int(c.protocol.Load())
+}
+
+func (c *SyncConn) SetProtocol(protocol int) {
+	c.protocol.Store(int32(protocol))
+}
+
+/*
+DB returns the index of the database selected with SELECT.
+*/
+func (c *SyncConn) DB() int {
+	return int(c.db.Load())
+}
+
+func (c *SyncConn) SetDB(db int) {
+	c.db.Store(int32(db))
+}
+
+/*
+RecordCommand counts a command dispatched on the connection and
+remembers its name for CLIENT LIST and CLIENT INFO.
+*/
+func (c *SyncConn) RecordCommand(name string) {
+	c.totalCommands.Add(1)
+	c.lastCommand.Store(name)
+	c.lastInteraction.Store(time.Now().UnixNano())
+}
+
+func (c *SyncConn) TotalCommands() int64 {
+	return c.totalCommands.Load()
+}
+
+func (c *SyncConn) LastCommand() string {
+	return c.lastCommand.Load().(string)
+}
+
+/*
+Age returns how long the connection has been open.
+*/
+func (c *SyncConn) Age() time.Duration {
+	return time.Since(c.createdAt)
+}
+
+/*
+Idle returns the time elapsed since the last command.
+*/
+func (c *SyncConn) Idle() time.Duration {
+	return time.Since(time.Unix(0, c.lastInteraction.Load()))
+}
+
+func (c *SyncConn) IsTracking() bool {
+	return c.tracking.Load()
+}
+
+func (c *SyncConn) SetTracking(enabled bool) {
+	c.tracking.Store(enabled)
+}
+
+/*
+Keepalive sends a ping push whenever nothing has been written to the
+connection for interval, so NATs keep the idle flow open. Only RESP3
+connections with tracking enabled receive it, since only those expect
+out-of-band pushes. It returns when done is closed or a write fails.
+*/
+func (c *SyncConn) Keepalive(done <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			if c.Protocol() < 3 || !c.IsTracking() {
+				continue
+			}
+
+			if now.Sub(time.Unix(0, c.lastWrite.Load())) < interval {
+				continue
+			}
+
+			if err := c.writeKeepalive(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package clients
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+/*
+keepalivePings runs Keepalive on a pipe for d and returns how many pings
+reached the other end.
+*/
+func keepalivePings(t *testing.T, conn *SyncConn, peer net.Conn, interval time.Duration, d time.Duration) int {
+	t.Helper()
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		conn.Keepalive(done, interval)
+		close(stopped)
+	}()
+
+	// replies written meanwhile are +OK, everything else must be a ping
+	pings := make(chan int)
+	go func() {
+		var n int
+		r := bufio.NewReader(peer)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				pings <- n
+				return
+			}
+			if line == "+OK\r\n" {
+				continue
+			}
+
+			rest := make([]byte, len(keepaliveMessage)-len(line))
+			if _, err := io.ReadFull(r, rest); err != nil || line+string(rest) != string(keepaliveMessage) {
+				t.Errorf("peer got %q, want a ping push", line+string(rest))
+			}
+			n++
+		}
+	}()
+
+	time.Sleep(d)
+	close(done)
+	<-stopped
+	peer.Close()
+
+	return <-pings
+}
+
+func TestKeepaliveInterval(t *testing.T) {
+	server, peer := net.Pipe()
+	defer server.Close()
+
+	conn := NewSyncConn(server)
+	conn.SetProtocol(3)
+	conn.SetTracking(true)
+
+	// one ping per 20ms interval over 110ms, give or take a tick
+	if n := keepalivePings(t, conn, peer, 20*time.Millisecond, 110*time.Millisecond); n < 3 || n > 6 {
+		t.Fatalf("got %d pings in 110ms at a 20ms interval, want about 5", n)
+	}
+}
+
+func TestKeepaliveOnlyForTrackingRESP3(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		protocol int
+		tracking bool
+	}{
+		{"RESP2 tracking", 2, true},
+		{"RESP3 not tracking", 3, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			server, peer := net.Pipe()
+			defer server.Close()
+
+			conn := NewSyncConn(server)
+			conn.SetProtocol(tt.protocol)
+			conn.SetTracking(tt.tracking)
+
+			if n := keepalivePings(t, conn, peer, 10*time.Millisecond, 60*time.Millisecond); n != 0 {
+				t.Fatalf("got %d pings, want none", n)
+			}
+		})
+	}
+}
+
+func TestKeepaliveSkipsBusyConnection(t *testing.T) {
+	server, peer := net.Pipe()
+	defer server.Close()
+
+	conn := NewSyncConn(server)
+	conn.SetProtocol(3)
+	conn.SetTracking(true)
+
+	// replies refresh the last write, so no ping is due while they flow
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(5 * time.Millisecond):
+				conn.Write([]byte("+OK\r\n"))
+			}
+		}
+	}()
+
+	if n := keepalivePings(t, conn, peer, 50*time.Millisecond, 150*time.Millisecond); n != 0 {
+		t.Fatalf("got %d pings on a connection written to every 5ms", n)
+	}
+}
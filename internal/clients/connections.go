@@ -0,0 +1,55 @@
+package clients
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+/*
+Connections keeps every open client connection, replicas included, so
+CLIENT LIST can report on them.
+*/
+type Connections struct {
+	conns map[*SyncConn]struct{}
+	mu    sync.RWMutex
+}
+
+func NewConnections() *Connections {
+	logrus.Info("Creating new connections registry")
+	return &Connections{
+		conns: make(map[*SyncConn]struct{}),
+	}
+}
+
+func (cs *Connections) Add(conn *SyncConn) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.conns[conn] = struct{}{}
+}
+
+func (cs *Connections) Remove(conn *SyncConn) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	delete(cs.conns, conn)
+}
+
+/*
+All returns the open connections ordered by ID.
+*/
+func (cs *Connections) All() []*SyncConn {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	conns := make([]*SyncConn, 0, len(cs.conns))
+	for conn := range cs.conns {
+		conns = append(conns, conn)
+	}
+
+	sort.Slice(conns, func(i, j int) bool { return conns[i].ID < conns[j].ID })
+
+	return conns
+}
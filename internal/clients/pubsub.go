@@ -0,0 +1,245 @@
+package clients
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+/*
+PubSub keeps the channel and pattern subscriptions of every connection
+and delivers published messages to them.
+*/
+type PubSub struct {
+	channels map[string]map[*SyncConn]struct{}
+	patterns map[string]map[*SyncConn]struct{}
+
+	// per connection view, used for counts and unsubscribe-all
+	connChannels map[*SyncConn]map[string]struct{}
+	connPatterns map[*SyncConn]map[string]struct{}
+
+	match func(pattern string, channel string) bool
+	mu    sync.Mutex
+}
+
+/*
+NewPubSub creates the subscription registry. match decides whether a
+PSUBSCRIBE pattern matches a channel name.
+*/
+func NewPubSub(match func(pattern string, channel string) bool) *PubSub {
+	logrus.Info("Creating new pub/sub registry")
+	return &PubSub{
+		channels:     make(map[string]map[*SyncConn]struct{}),
+		patterns:     make(map[string]map[*SyncConn]struct{}),
+		connChannels: make(map[*SyncConn]map[string]struct{}),
+		connPatterns: make(map[*SyncConn]map[string]struct{}),
+		match:        match,
+	}
+}
+
+/*
+Subscribe adds conn to channel and returns the number of channels and
+patterns conn is subscribed to afterwards.
+*/
+func (ps *PubSub) Subscribe(conn *SyncConn, channel string) int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	add(ps.channels, channel, conn)
+	add(ps.connChannels, conn, channel)
+
+	return ps.count(conn)
+}
+
+/*
+PSubscribe adds conn to pattern and returns its subscription count.
+*/
+func (ps *PubSub) PSubscribe(conn *SyncConn, pattern string) int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	add(ps.patterns, pattern, conn)
+	add(ps.connPatterns, conn, pattern)
+
+	return ps.count(conn)
+}
+
+/*
+Unsubscribe removes conn from channel and returns its subscription count.
+*/
+func (ps *PubSub) Unsubscribe(conn *SyncConn, channel string) int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	remove(ps.channels, channel, conn)
+	remove(ps.connChannels, conn, channel)
+
+	return ps.count(conn)
+}
+
+/*
+PUnsubscribe removes conn from pattern and returns its subscription count.
+*/
+func (ps *PubSub) PUnsubscribe(conn *SyncConn, pattern string) int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	remove(ps.patterns, pattern, conn)
+	remove(ps.connPatterns, conn, pattern)
+
+	return ps.count(conn)
+}
+
+/*
+Channels returns the channels conn is subscribed to, sorted by name.
+*/
+func (ps *PubSub) Channels(conn *SyncConn) []string {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	return sortedNames(ps.connChannels[conn])
+}
+
+/*
+Patterns returns the patterns conn is subscribed to, sorted.
+*/
+func (ps *PubSub) Patterns(conn *SyncConn) []string {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	return sortedNames(ps.connPatterns[conn])
+}
+
+/*
+Count returns the number of channels and patterns conn is subscribed to.
+*/
+func (ps *PubSub) Count(conn *SyncConn) int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	return ps.count(conn)
+}
+
+/*
+Publish sends message to every subscriber of channel and to every
+connection with a matching pattern. It returns the number of deliveries.
+*/
+func (ps *PubSub) Publish(channel string, message string) int {
+	type delivery struct {
+		conn    *SyncConn
+		payload string
+	}
+
+	ps.mu.Lock()
+
+	var deliveries []delivery
+
+	for conn := range ps.channels[channel] {
+		deliveries = append(deliveries, delivery{
+			conn: conn,
+			payload: fmt.Sprintf(
+				"%c3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n",
+				framePrefix(conn), len(channel), channel, len(message), message,
+			),
+		})
+	}
+
+	for pattern, conns := range ps.patterns {
+		if !ps.match(pattern, channel) {
+			continue
+		}
+
+		for conn := range conns {
+			deliveries = append(deliveries, delivery{
+				conn: conn,
+				payload: fmt.Sprintf(
+					"%c4\r\n$8\r\npmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n",
+					framePrefix(conn), len(pattern), pattern, len(channel), channel, len(message), message,
+				),
+			})
+		}
+	}
+
+	ps.mu.Unlock()
+
+	for _, d := range deliveries {
+		if _, err := d.conn.Write([]byte(d.payload)); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"package":  "clients",
+				"function": "Publish",
+				"error":    err,
+			}).Error("Error delivering published message")
+		}
+	}
+
+	return len(deliveries)
+}
+
+/*
+framePrefix returns the type byte of a message frame for conn: a push on
+RESP3 connections, an array on RESP2 ones.
+*/
+func framePrefix(conn *SyncConn) byte {
+	if conn.Protocol() >= 3 {
+		return '>'
+	}
+
+	return '*'
+}
+
+/*
+RemoveConnection drops every subscription of the given connection.
+*/
+func (ps *PubSub) RemoveConnection(conn *SyncConn) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	for channel := range ps.connChannels[conn] {
+		remove(ps.channels, channel, conn)
+	}
+	for pattern := range ps.connPatterns[conn] {
+		remove(ps.patterns, pattern, conn)
+	}
+
+	delete(ps.connChannels, conn)
+	delete(ps.connPatterns, conn)
+}
+
+func (ps *PubSub) count(conn *SyncConn) int {
+	return len(ps.connChannels[conn]) + len(ps.connPatterns[conn])
+}
+
+func add[K comparable, V comparable](m map[K]map[V]struct{}, key K, value V) {
+	set, ok := m[key]
+	if !ok {
+		set = make(map[V]struct{})
+		m[key] = set
+	}
+
+	set[value] = struct{}{}
+}
+
+func remove[K comparable, V comparable](m map[K]map[V]struct{}, key K, value V) {
+	set, ok := m[key]
+	if !ok {
+		return
+	}
+
+	delete(set, value)
+
+	if len(set) == 0 {
+		delete(m, key)
+	}
+}
+
+func sortedNames(set map[string]struct{}) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,185 @@
+package clients
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+subscriberPair returns the server side of a loopback connection wrapped in
+a SyncConn, together with a reader on the client side.
+*/
+func subscriberPair(t *testing.T) (*SyncConn, *bufio.Reader) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn
+	}()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, ok := <-accepted
+	if !ok {
+		t.Fatal("accepting the subscriber failed")
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	return NewSyncConn(server), bufio.NewReader(client)
+}
+
+/*
+readBulk reads one bulk string, failing unless the whole frame is there.
+*/
+func readBulk(r *bufio.Reader) (string, error) {
+	header, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(header, "$") || !strings.HasSuffix(header, "\r\n") {
+		return "", fmt.Errorf("bad bulk header %q", header)
+	}
+
+	n, err := strconv.Atoi(strings.TrimSuffix(header[1:], "\r\n"))
+	if err != nil {
+		return "", fmt.Errorf("bad bulk header %q", header)
+	}
+
+	payload := make([]byte, n+2)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return "", err
+	}
+	if string(payload[n:]) != "\r\n" {
+		return "", fmt.Errorf("bulk of %d bytes not followed by CRLF", n)
+	}
+
+	return string(payload[:n]), nil
+}
+
+/*
+readMessage reads one message frame and returns its channel and payload.
+*/
+func readMessage(r *bufio.Reader) (string, string, error) {
+	header, err := r.ReadString('\n')
+	if err != nil {
+		return "", "", err
+	}
+	if header != "*3\r\n" {
+		return "", "", fmt.Errorf("bad frame header %q", header)
+	}
+
+	var parts [3]string
+	for i := range parts {
+		if parts[i], err = readBulk(r); err != nil {
+			return "", "", err
+		}
+	}
+	if parts[0] != "message" {
+		return "", "", fmt.Errorf("bad frame kind %q", parts[0])
+	}
+
+	return parts[1], parts[2], nil
+}
+
+func TestPublishFromManyGoroutines(t *testing.T) {
+	const (
+		subscribers = 3
+		publishers  = 8
+		messages    = 50
+	)
+
+	ps := NewPubSub(func(pattern, channel string) bool { return pattern == channel })
+
+	readers := make([]*bufio.Reader, subscribers)
+	for i := range readers {
+		var conn *SyncConn
+		conn, readers[i] = subscriberPair(t)
+		ps.Subscribe(conn, "news")
+	}
+
+	// each message is one repeated byte, large enough to span several
+	// socket writes, so an interleaved frame shows up as mixed bytes
+	var wg sync.WaitGroup
+	for p := 0; p < publishers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+
+			payload := strings.Repeat(string(rune('a'+p)), 32*1024+p)
+			for i := 0; i < messages; i++ {
+				if n := ps.Publish("news", payload); n != subscribers {
+					t.Errorf("Publish delivered to %d subscribers, want %d", n, subscribers)
+					return
+				}
+			}
+		}(p)
+	}
+
+	var readersDone sync.WaitGroup
+	for i, r := range readers {
+		readersDone.Add(1)
+		go func(i int, r *bufio.Reader) {
+			defer readersDone.Done()
+
+			received := make(map[byte]int)
+			for n := 0; n < publishers*messages; n++ {
+				channel, payload, err := readMessage(r)
+				if err != nil {
+					t.Errorf("subscriber %d, message %d: %v", i, n, err)
+					return
+				}
+				if channel != "news" || len(payload) < 32*1024 {
+					t.Errorf("subscriber %d got %d bytes on %q", i, len(payload), channel)
+					return
+				}
+				if want := 32*1024 + int(payload[0]-'a'); len(payload) != want || payload != strings.Repeat(payload[:1], want) {
+					t.Errorf("subscriber %d got a corrupted message from publisher %c", i, payload[0])
+					return
+				}
+				received[payload[0]]++
+			}
+
+			for p := 0; p < publishers; p++ {
+				if got := received[byte('a'+p)]; got != messages {
+					t.Errorf("subscriber %d got %d messages from publisher %d, want %d", i, got, p, messages)
+				}
+			}
+		}(i, r)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		readersDone.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("subscribers did not receive every message")
+	}
+}
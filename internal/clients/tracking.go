@@ -0,0 +1,89 @@
+package clients
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+/*
+Tracking remembers which connections read which keys so that they can
+be sent an invalidation push message once a key is modified.
+*/
+type Tracking struct {
+	keys map[string]map[*SyncConn]struct{}
+	mu   sync.Mutex
+}
+
+func NewTracking() *Tracking {
+	logrus.Info("Creating new tracking table")
+	return &Tracking{
+		keys: make(map[string]map[*SyncConn]struct{}),
+	}
+}
+
+func (t *Tracking) Track(conn *SyncConn, key string) {
+	if !conn.IsTracking() {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	conns, ok := t.keys[key]
+	if !ok {
+		conns = make(map[*SyncConn]struct{})
+		t.keys[key] = conns
+	}
+
+	conns[conn] = struct{}{}
+}
+
+/*
+Invalidate sends an invalidation push to every connection that read key.
+Like Redis, a key is forgotten after the first invalidation and is
+tracked again only when read again.
+*/
+func (t *Tracking) Invalidate(key string) {
+	t.mu.Lock()
+	conns := t.keys[key]
+	delete(t.keys, key)
+	t.mu.Unlock()
+
+	if len(conns) == 0 {
+		return
+	}
+
+	message := fmt.Sprintf(">2\r\n$10\r\ninvalidate\r\n*1\r\n$%d\r\n%s\r\n", len(key), key)
+
+	for conn := range conns {
+		if !conn.IsTracking() || conn.Protocol() < 3 {
+			continue
+		}
+
+		if _, err := conn.Write([]byte(message)); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"package":  "clients",
+				"function": "Invalidate",
+				"error":    err,
+			}).Error("Error sending invalidation message")
+		}
+	}
+}
+
+/*
+RemoveConnection forgets every key tracked for the given connection.
+*/
+func (t *Tracking) RemoveConnection(conn *SyncConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key, conns := range t.keys {
+		delete(conns, conn)
+
+		if len(conns) == 0 {
+			delete(t.keys, key)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/clients"
+	"github.com/codecrafters-io/redis-starter-go/internal/config"
+	"github.com/codecrafters-io/redis-starter-go/internal/utils"
+)
+
+func (c *ClientCommand) handleTracking(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 3 {
+		conn.Write([]byte(unknownSubcommandResp(args[0], args[1])))
+		return
+	}
+
+	syncConn, ok := conn.(*clients.SyncConn)
+	if !ok {
+		return
+	}
+
+	switch strings.ToUpper(args[2]) {
+	case "ON":
+		syncConn.SetTracking(true)
+	case "OFF":
+		syncConn.SetTracking(false)
+	default:
+		conn.Write([]byte("-ERR syntax error\r\n"))
+		return
+	}
+
+	conn.Write([]byte("+OK\r\n"))
+}
+
+/*
+handleList replies with one line per open connection.
+*/
+func (c *ClientCommand) handleList(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 2 {
+		conn.Write([]byte("-ERR syntax error\r\n"))
+		return
+	}
+
+	var sb strings.Builder
+	for _, clientConn := range utils.GetConnectionsObj(ctx).All() {
+		sb.WriteString(clientInfoLine(ctx, clientConn))
+	}
+
+	conn.Write([]byte(stringResp(sb.String())))
+}
+
+/*
+handleInfo replies with the CLIENT LIST line of the calling connection.
+*/
+func (c *ClientCommand) handleInfo(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 2 {
+		conn.Write([]byte(unknownSubcommandResp(args[0], args[1])))
+		return
+	}
+
+	syncConn, ok := conn.(*clients.SyncConn)
+	if !ok {
+		return
+	}
+
+	conn.Write([]byte(stringResp(clientInfoLine(ctx, syncConn))))
+}
+
+func clientInfoLine(ctx context.Context, conn *clients.SyncConn) string {
+	pubSub := utils.GetPubSubObj(ctx)
+
+	return fmt.Sprintf(
+		"id=%d addr=%s laddr=%s age=%d idle=%d db=%d sub=%d psub=%d resp=%d tot-cmds=%d cmd=%s\n",
+		conn.ID,
+		conn.RemoteAddr(),
+		conn.LocalAddr(),
+		int64(conn.Age().Seconds()),
+		int64(conn.Idle().Seconds()),
+		conn.DB(),
+		len(pubSub.Channels(conn)),
+		len(pubSub.Patterns(conn)),
+		conn.Protocol(),
+		conn.TotalCommands(),
+		conn.LastCommand(),
+	)
+}
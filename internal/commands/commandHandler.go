@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/config"
+)
+
+func (c *CommandCommand) handleDocs(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	names := make([]string, 0, len(Commands))
+
+	if len(args) > 2 {
+		for _, name := range args[2:] {
+			name = strings.ToUpper(name)
+			if _, exists := Commands[name]; exists {
+				names = append(names, name)
+			}
+		}
+	} else {
+		for name := range Commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	var bb bytes.Buffer
+
+	bb.WriteString(arrayResp(len(names) * 2))
+
+	for _, name := range names {
+		doc := Commands[name].Doc
+
+		bb.WriteString(stringResp(strings.ToLower(name)))
+		bb.WriteString(arrayResp(6))
+		bb.WriteString(stringResp("summary"))
+		bb.WriteString(stringResp(doc.Summary))
+		bb.WriteString(stringResp("since"))
+		bb.WriteString(stringResp(doc.Since))
+		bb.WriteString(stringResp("group"))
+		bb.WriteString(stringResp(doc.Group))
+	}
+
+	conn.Write(bb.Bytes())
+}
+
+func (c *CommandCommand) handleCount(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	conn.Write([]byte(integerResp(len(Commands))))
+}
+
+/*
+handleInfo describes the commands named in args, or every command when
+none is named. Unknown names get a null entry.
+*/
+func (c *CommandCommand) handleInfo(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	var names []string
+
+	if len(args) > 2 {
+		names = args[2:]
+	} else {
+		for name := range Commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	var bb bytes.Buffer
+
+	bb.WriteString(arrayResp(len(names)))
+
+	for _, name := range names {
+		entry, exists := Commands[strings.ToUpper(name)]
+		if !exists {
+			bb.WriteString("*-1\r\n")
+			continue
+		}
+
+		bb.WriteString(arrayResp(6))
+		bb.WriteString(stringResp(strings.ToLower(name)))
+		bb.WriteString(integerResp(entry.Arity))
+
+		bb.WriteString(arrayResp(len(entry.Flags)))
+		for _, flag := range entry.Flags {
+			bb.WriteString(fmt.Sprintf("+%s\r\n", flag))
+		}
+
+		bb.WriteString(integerResp(entry.FirstKey))
+		bb.WriteString(integerResp(entry.LastKey))
+		bb.WriteString(integerResp(entry.Step))
+	}
+
+	conn.Write(bb.Bytes())
+}
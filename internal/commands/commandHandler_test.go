@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEveryCommandEntryIsDescribed(t *testing.T) {
+	for name, entry := range Commands {
+		if entry.Command == nil {
+			t.Errorf("%s has no handler", name)
+		}
+		if entry.Arity == 0 {
+			t.Errorf("%s has no arity", name)
+		}
+		if entry.Doc.Summary == "" || entry.Doc.Since == "" || entry.Doc.Group == "" {
+			t.Errorf("%s is missing docs: %+v", name, entry.Doc)
+		}
+	}
+}
+
+func TestCommandDocsGet(t *testing.T) {
+	got := execute(newTestContext(t), "COMMAND", "DOCS", "GET")
+
+	want := "*2\r\n$3\r\nget\r\n*6\r\n" +
+		"$7\r\nsummary\r\n" + stringResp(Commands["GET"].Doc.Summary) +
+		"$5\r\nsince\r\n$5\r\n1.0.0\r\n" +
+		"$5\r\ngroup\r\n$6\r\nstring\r\n"
+	if got != want {
+		t.Fatalf("COMMAND DOCS GET = %q, want %q", got, want)
+	}
+	if Commands["GET"].Doc.Summary == "" {
+		t.Fatal("GET has an empty summary")
+	}
+}
+
+func TestCommandInfo(t *testing.T) {
+	ctx := newTestContext(t)
+
+	want := "*2\r\n" +
+		"*6\r\n$3\r\nget\r\n:2\r\n*2\r\n+readonly\r\n+fast\r\n:1\r\n:1\r\n:1\r\n" +
+		"*-1\r\n"
+	if got := execute(ctx, "COMMAND", "INFO", "get", "nosuchcommand"); got != want {
+		t.Fatalf("COMMAND INFO = %q, want %q", got, want)
+	}
+
+	if got := execute(ctx, "COMMAND"); !strings.HasPrefix(got, arrayResp(len(Commands))) {
+		t.Fatalf("COMMAND does not describe the %d commands: %.40q", len(Commands), got)
+	}
+}
+
+func TestCommandCount(t *testing.T) {
+	want := ":" + strconv.Itoa(len(Commands)) + "\r\n"
+	if got := execute(newTestContext(t), "COMMAND", "COUNT"); got != want {
+		t.Fatalf("COMMAND COUNT = %q, want %q", got, want)
+	}
+}
@@ -1,18 +1,18 @@
 package commands
 
 import (
+	"bufio"
 	"bytes"
 	"context"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"strconv"
 	"strings"
-	"sync/atomic"
 	"time"
 
-	"github.com/sirupsen/logrus"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/codecrafters-io/redis-starter-go/internal/clients"
@@ -34,31 +34,528 @@ type CommandHandler func(
 	args []string,
 )
 
-var Propagated = [3]string{"SET", "DEL"}
-
-var Commands = map[string]Command{
-	"PING": &PingCommand{},
-	"ECHO": &EchoCommand{},
-	"SET":  &SetCommand{},
-	"GET":  &GetCommand{},
-
-	"INFO":     &InfoCommand{},
-	"REPLCONF": &ReplConfCommand{},
-	"PSYNC":    &PsyncCommand{},
-	"WAIT":     &WaitCommand{},
-
-	"CONFIG": &ConfigCommand{},
-	"KEYS":   &KeysCommand{},
-	"INCR":   &IncrCommand{},
-
-	"MULTI":   &MultiCommand{},
-	"EXEC":    &ExecCommand{},
-	"DISCARD": &DiscardCommand{},
+// Propagated lists the writes forwarded to replicas as they were received,
+// once they report through Propagation that they changed the keyspace.
+// Writes replicas must replay differently, like XADD with a generated ID,
+// BLPOP, MIGRATE, EXEC or a relative expiration, rewrite what they forward
+// through Propagation. Whatever is forwarded advances the master
+// replication offset by its RESP encoded length.
+var Propagated = []string{
+	"SET", "SETNX", "DEL", "UNLINK", "GETDEL", "GETEX", "APPEND", "SETRANGE", "MSET",
+	"INCR", "DECR", "INCRBY", "DECRBY", "INCRBYFLOAT",
+	"LPUSH", "RPUSH", "LPUSHX", "RPUSHX", "LPOP", "RPOP", "LSET", "COPY", "RENAME", "RENAMENX", "RESTORE",
+	"SADD", "SREM", "SINTERSTORE", "SUNIONSTORE", "SDIFFSTORE",
+	"ZADD", "ZREM", "ZINCRBY", "ZUNIONSTORE", "ZINTERSTORE",
+	"XADD", "XGROUP",
+	"HSET", "HDEL", "HINCRBY",
+	"EXPIRE", "PEXPIRE", "PEXPIREAT", "HEXPIRE", "HPEXPIREAT", "PERSIST",
+}
 
-	"TYPE":   &TypeCommand{},
-	"XADD":   &XAddCommand{},
-	"XREAD":  &XReadCommand{},
-	"XRANGE": &XRangeCommand{},
+// Tracked lists read commands whose key is remembered for clients
+// with CLIENT TRACKING enabled.
+var Tracked = []string{"GET", "TYPE"}
+
+var Commands = map[string]*CommandEntry{
+	"PING": {
+		Command: &PingCommand{}, Arity: -1, Flags: []string{"fast"},
+		Doc: CommandDoc{Summary: "Returns the server's liveliness response.", Since: "1.0.0", Group: "connection"},
+	},
+	"ECHO": {
+		Command: &EchoCommand{}, Arity: 2, Flags: []string{"fast"},
+		Doc: CommandDoc{Summary: "Returns the given string.", Since: "1.0.0", Group: "connection"},
+	},
+	"HELLO": {
+		Command: &HelloCommand{}, Arity: -1, Flags: []string{"noscript", "loading", "stale", "fast", "no_auth", "allow_busy"},
+		Doc: CommandDoc{Summary: "Handshakes with the Redis server.", Since: "6.0.0", Group: "connection"},
+	},
+	"CLIENT": {
+		Command: &ClientCommand{}, Arity: -2,
+		Doc: CommandDoc{Summary: "A container for client connection commands.", Since: "2.4.0", Group: "connection"},
+	},
+	"SELECT": {
+		Command: &SelectCommand{}, Arity: 2, Flags: []string{"loading", "stale", "fast"},
+		Doc: CommandDoc{Summary: "Changes the selected database.", Since: "1.0.0", Group: "connection"},
+	},
+	"SET": {
+		Command: &SetCommand{}, Arity: -3, Flags: []string{"write", "denyoom"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Sets the string value of a key.", Since: "1.0.0", Group: "string"},
+	},
+	"SETNX": {
+		Command: &SetNxCommand{}, Arity: 3, Flags: []string{"write", "denyoom", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Set the string value of a key only when the key doesn't exist.", Since: "1.0.0", Group: "string"},
+	},
+	"GET": {
+		Command: &GetCommand{}, Arity: 2, Flags: []string{"readonly", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns the string value of a key.", Since: "1.0.0", Group: "string"},
+	},
+	"GETDEL": {
+		Command: &GetDelCommand{}, Arity: 2, Flags: []string{"write", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns the string value of a key after deleting the key.", Since: "6.2.0", Group: "string"},
+	},
+	"GETRANGE": {
+		Command: &GetRangeCommand{}, Arity: 4, Flags: []string{"readonly"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns a substring of the string stored at a key.", Since: "2.4.0", Group: "string"},
+	},
+	"SETRANGE": {
+		Command: &SetRangeCommand{}, Arity: 4, Flags: []string{"write", "denyoom"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Overwrites a part of a string value with another by an offset. Creates the key if it doesn't exist.", Since: "2.2.0", Group: "string"},
+	},
+	"GETEX": {
+		Command: &GetExCommand{}, Arity: -2, Flags: []string{"write", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns the string value of a key after setting its expiration time.", Since: "6.2.0", Group: "string"},
+	},
+	"APPEND": {
+		Command: &AppendCommand{}, Arity: 3, Flags: []string{"write", "denyoom", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Appends a string to the value of a key. Creates the key if it doesn't exist.", Since: "2.0.0", Group: "string"},
+	},
+	"MSET": {
+		Command: &MSetCommand{}, Arity: -3, Flags: []string{"write", "denyoom"},
+		FirstKey: 1, LastKey: -1, Step: 2,
+		Doc: CommandDoc{Summary: "Atomically creates or modifies the string values of one or more keys.", Since: "1.0.1", Group: "string"},
+	},
+	"MGET": {
+		Command: &MGetCommand{}, Arity: -2, Flags: []string{"readonly", "fast"},
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Doc: CommandDoc{Summary: "Atomically returns the string values of one or more keys.", Since: "1.0.0", Group: "string"},
+	},
+	"DEL": {
+		Command: &DelCommand{}, Arity: -2, Flags: []string{"write"},
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Doc: CommandDoc{Summary: "Deletes one or more keys.", Since: "1.0.0", Group: "generic"},
+	},
+	"UNLINK": {
+		Command: &UnlinkCommand{}, Arity: -2, Flags: []string{"write", "fast"},
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Doc: CommandDoc{Summary: "Asynchronously deletes one or more keys.", Since: "4.0.0", Group: "generic"},
+	},
+	"EXISTS": {
+		Command: &ExistsCommand{}, Arity: -2, Flags: []string{"readonly", "fast"},
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Doc: CommandDoc{Summary: "Determines whether one or more keys exist.", Since: "1.0.0", Group: "generic"},
+	},
+
+	"INFO": {
+		Command: &InfoCommand{}, Arity: -1, Flags: []string{"loading", "stale"},
+		Doc: CommandDoc{Summary: "Returns information and statistics about the server.", Since: "1.0.0", Group: "server"},
+	},
+	"REPLCONF": {
+		Command: &ReplConfCommand{}, Arity: -1, Flags: []string{"admin", "noscript", "loading", "stale", "allow_busy"},
+		Doc: CommandDoc{Summary: "An internal command for configuring the replication stream.", Since: "3.0.0", Group: "server"},
+	},
+	"PSYNC": {
+		Command: &PsyncCommand{}, Arity: -3, Flags: []string{"admin", "noscript", "no_async_loading", "no_multi"},
+		Doc: CommandDoc{Summary: "An internal command used in replication.", Since: "2.8.0", Group: "server"},
+	},
+	"WAIT": {
+		Command: &WaitCommand{}, Arity: 3,
+		Doc: CommandDoc{Summary: "Blocks until the asynchronous replication of all preceding write commands sent by the connection is completed.", Since: "3.0.0", Group: "generic"},
+	},
+
+	"CONFIG": {
+		Command: &ConfigCommand{}, Arity: -2,
+		Doc: CommandDoc{Summary: "A container for server configuration commands.", Since: "2.0.0", Group: "server"},
+	},
+	"KEYS": {
+		Command: &KeysCommand{}, Arity: 2, Flags: []string{"readonly"},
+		Doc: CommandDoc{Summary: "Returns all key names that match a pattern.", Since: "1.0.0", Group: "generic"},
+	},
+	"SCAN": {
+		Command: &ScanCommand{}, Arity: -2, Flags: []string{"readonly"},
+		Doc: CommandDoc{Summary: "Iterates over the key names in the database.", Since: "2.8.0", Group: "generic"},
+	},
+	"INCR": {
+		Command: &IncrCommand{}, Arity: 2, Flags: []string{"write", "denyoom", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Increments the integer value of a key by one.", Since: "1.0.0", Group: "string"},
+	},
+	"DECR": {
+		Command: &DecrCommand{}, Arity: 2, Flags: []string{"write", "denyoom", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Decrements the integer value of a key by one.", Since: "1.0.0", Group: "string"},
+	},
+	"INCRBY": {
+		Command: &IncrByCommand{}, Arity: 3, Flags: []string{"write", "denyoom", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Increments the integer value of a key by a number.", Since: "1.0.0", Group: "string"},
+	},
+	"DECRBY": {
+		Command: &DecrByCommand{}, Arity: 3, Flags: []string{"write", "denyoom", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Decrements a number from the integer value of a key.", Since: "1.0.0", Group: "string"},
+	},
+
+	"INCRBYFLOAT": {
+		Command: &IncrByFloatCommand{}, Arity: 3, Flags: []string{"write", "denyoom", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Increment the floating point value of a key by a number.", Since: "2.6.0", Group: "string"},
+	},
+
+	"COMMAND": {
+		Command: &CommandCommand{}, Arity: -1, Flags: []string{"loading", "stale"},
+		Doc: CommandDoc{Summary: "Returns detailed information about all commands.", Since: "2.8.13", Group: "server"},
+	},
+	"DEBUG": {
+		Command: &DebugCommand{}, Arity: -2, Flags: []string{"admin", "noscript", "loading", "stale"},
+		Doc: CommandDoc{Summary: "A container for debugging commands.", Since: "1.0.0", Group: "server"},
+	},
+
+	"MULTI": {
+		Command: &MultiCommand{}, Arity: 1, Flags: []string{"noscript", "loading", "stale", "fast", "allow_busy"},
+		Doc: CommandDoc{Summary: "Starts a transaction.", Since: "1.2.0", Group: "transactions"},
+	},
+	"EXEC": {
+		Command: &ExecCommand{}, Arity: 1, Flags: []string{"noscript", "loading", "stale", "skip_slowlog"},
+		Doc: CommandDoc{Summary: "Executes all commands in a transaction.", Since: "1.2.0", Group: "transactions"},
+	},
+	"DISCARD": {
+		Command: &DiscardCommand{}, Arity: 1, Flags: []string{"noscript", "loading", "stale", "fast", "allow_busy"},
+		Doc: CommandDoc{Summary: "Discards a transaction.", Since: "2.0.0", Group: "transactions"},
+	},
+
+	"TYPE": {
+		Command: &TypeCommand{}, Arity: 2, Flags: []string{"readonly", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Determines the type of value stored at a key.", Since: "1.0.0", Group: "generic"},
+	},
+	"EXPIRE": {
+		Command: &ExpireCommand{}, Arity: -3, Flags: []string{"write", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Sets the expiration time of a key in seconds.", Since: "1.0.0", Group: "generic"},
+	},
+	"PEXPIRE": {
+		Command: &PExpireCommand{}, Arity: -3, Flags: []string{"write", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Sets the expiration time of a key in milliseconds.", Since: "2.6.0", Group: "generic"},
+	},
+	"PEXPIREAT": {
+		Command: &PExpireAtCommand{}, Arity: -3, Flags: []string{"write", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Sets the expiration time of a key to a Unix milliseconds timestamp.", Since: "2.6.0", Group: "generic"},
+	},
+	"TTL": {
+		Command: &TtlCommand{}, Arity: 2, Flags: []string{"readonly", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns the expiration time in seconds of a key.", Since: "1.0.0", Group: "generic"},
+	},
+	"PTTL": {
+		Command: &PttlCommand{}, Arity: 2, Flags: []string{"readonly", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns the expiration time in milliseconds of a key.", Since: "2.6.0", Group: "generic"},
+	},
+	"PERSIST": {
+		Command: &PersistCommand{}, Arity: 2, Flags: []string{"write", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Removes the expiration time of a key.", Since: "2.2.0", Group: "generic"},
+	},
+	"COPY": {
+		Command: &CopyCommand{}, Arity: -3, Flags: []string{"write", "denyoom"},
+		FirstKey: 1, LastKey: 2, Step: 1,
+		Doc: CommandDoc{Summary: "Copies the value of a key to a new key.", Since: "6.2.0", Group: "generic"},
+	},
+	"RENAME": {
+		Command: &RenameCommand{}, Arity: 3, Flags: []string{"write"},
+		FirstKey: 1, LastKey: 2, Step: 1,
+		Doc: CommandDoc{Summary: "Renames a key and overwrites the destination.", Since: "1.0.0", Group: "generic"},
+	},
+	"RENAMENX": {
+		Command: &RenameNXCommand{}, Arity: 3, Flags: []string{"write", "fast"},
+		FirstKey: 1, LastKey: 2, Step: 1,
+		Doc: CommandDoc{Summary: "Renames a key only when the target key name doesn't exist.", Since: "1.0.0", Group: "generic"},
+	},
+	"OBJECT": {
+		Command: &ObjectCommand{}, Arity: -2,
+		Doc: CommandDoc{Summary: "A container for object introspection commands.", Since: "2.2.3", Group: "generic"},
+	},
+	"DUMP": {
+		Command: &DumpCommand{}, Arity: 2, Flags: []string{"readonly"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns a serialized representation of the value stored at a key.", Since: "2.6.0", Group: "generic"},
+	},
+	"RESTORE": {
+		Command: &RestoreCommand{}, Arity: -4, Flags: []string{"write", "denyoom"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Creates a key from the serialized representation of a value.", Since: "2.6.0", Group: "generic"},
+	},
+	"MIGRATE": {
+		Command: &MigrateCommand{}, Arity: -6, Flags: []string{"write", "movablekeys"},
+		FirstKey: 3, LastKey: 3, Step: 1,
+		Doc: CommandDoc{Summary: "Atomically transfers a key from one Redis instance to another.", Since: "2.6.0", Group: "generic"},
+	},
+	"XADD": {
+		Command: &XAddCommand{}, Arity: -5, Flags: []string{"write", "denyoom", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Appends a new message to a stream.", Since: "5.0.0", Group: "stream"},
+	},
+	"XREAD": {
+		Command: &XReadCommand{}, Arity: -4, Flags: []string{"readonly", "blocking", "movablekeys"},
+		Doc: CommandDoc{Summary: "Returns messages from multiple streams with IDs greater than the ones requested.", Since: "5.0.0", Group: "stream"},
+	},
+	"XRANGE": {
+		Command: &XRangeCommand{}, Arity: -4, Flags: []string{"readonly"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns the messages from a stream within a range of IDs.", Since: "5.0.0", Group: "stream"},
+	},
+	"XGROUP": {
+		Command: &XGroupCommand{}, Arity: -2,
+		Doc: CommandDoc{Summary: "A container for consumer groups commands.", Since: "5.0.0", Group: "stream"},
+	},
+	"XINFO": {
+		Command: &XInfoCommand{}, Arity: -2,
+		Doc: CommandDoc{Summary: "A container for stream introspection commands.", Since: "5.0.0", Group: "stream"},
+	},
+
+	"LPUSH": {
+		Command: &LPushCommand{}, Arity: -3, Flags: []string{"write", "denyoom", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Prepends one or more elements to a list. Creates the key if it doesn't exist.", Since: "1.0.0", Group: "list"},
+	},
+	"RPUSH": {
+		Command: &RPushCommand{}, Arity: -3, Flags: []string{"write", "denyoom", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Appends one or more elements to a list. Creates the key if it doesn't exist.", Since: "1.0.0", Group: "list"},
+	},
+	"LPUSHX": {
+		Command: &LPushXCommand{}, Arity: -3, Flags: []string{"write", "denyoom", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Prepends one or more elements to a list only when the list exists.", Since: "2.2.0", Group: "list"},
+	},
+	"RPUSHX": {
+		Command: &RPushXCommand{}, Arity: -3, Flags: []string{"write", "denyoom", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Appends an element to a list only when the list exists.", Since: "2.2.0", Group: "list"},
+	},
+	"LPOP": {
+		Command: &LPopCommand{}, Arity: -2, Flags: []string{"write", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns the first elements in a list after removing it. Deletes the list if the last element was popped.", Since: "1.0.0", Group: "list"},
+	},
+	"RPOP": {
+		Command: &RPopCommand{}, Arity: -2, Flags: []string{"write", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns and removes the last elements of the list. Deletes the list if the last element was popped.", Since: "1.0.0", Group: "list"},
+	},
+	"BLPOP": {
+		Command: &BLPopCommand{}, Arity: -3, Flags: []string{"write", "blocking"},
+		FirstKey: 1, LastKey: -2, Step: 1,
+		Doc: CommandDoc{Summary: "Removes and returns the first element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped.", Since: "2.0.0", Group: "list"},
+	},
+	"BRPOP": {
+		Command: &BRPopCommand{}, Arity: -3, Flags: []string{"write", "blocking"},
+		FirstKey: 1, LastKey: -2, Step: 1,
+		Doc: CommandDoc{Summary: "Removes and returns the last element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped.", Since: "2.0.0", Group: "list"},
+	},
+	"LRANGE": {
+		Command: &LRangeCommand{}, Arity: 4, Flags: []string{"readonly"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns a range of elements from a list.", Since: "1.0.0", Group: "list"},
+	},
+	"LLEN": {
+		Command: &LLenCommand{}, Arity: 2, Flags: []string{"readonly", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns the length of a list.", Since: "1.0.0", Group: "list"},
+	},
+	"LINDEX": {
+		Command: &LIndexCommand{}, Arity: 3, Flags: []string{"readonly"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns an element from a list by its index.", Since: "1.0.0", Group: "list"},
+	},
+	"LSET": {
+		Command: &LSetCommand{}, Arity: 4, Flags: []string{"write", "denyoom"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Sets the value of an element in a list by its index.", Since: "1.0.0", Group: "list"},
+	},
+
+	"SADD": {
+		Command: &SAddCommand{}, Arity: -3, Flags: []string{"write", "denyoom", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Adds one or more members to a set. Creates the key if it doesn't exist.", Since: "1.0.0", Group: "set"},
+	},
+	"SREM": {
+		Command: &SRemCommand{}, Arity: -3, Flags: []string{"write", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Removes one or more members from a set. Deletes the set if the last member was removed.", Since: "1.0.0", Group: "set"},
+	},
+	"SCARD": {
+		Command: &SCardCommand{}, Arity: 2, Flags: []string{"readonly", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns the number of members in a set.", Since: "1.0.0", Group: "set"},
+	},
+	"SISMEMBER": {
+		Command: &SIsMemberCommand{}, Arity: 3, Flags: []string{"readonly", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Determines whether a member belongs to a set.", Since: "1.0.0", Group: "set"},
+	},
+	"SMEMBERS": {
+		Command: &SMembersCommand{}, Arity: 2, Flags: []string{"readonly"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns all members of a set.", Since: "1.0.0", Group: "set"},
+	},
+	"SINTER": {
+		Command: &SInterCommand{}, Arity: -2, Flags: []string{"readonly"},
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns the intersect of multiple sets.", Since: "1.0.0", Group: "set"},
+	},
+	"SUNION": {
+		Command: &SUnionCommand{}, Arity: -2, Flags: []string{"readonly"},
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns the union of multiple sets.", Since: "1.0.0", Group: "set"},
+	},
+	"SDIFF": {
+		Command: &SDiffCommand{}, Arity: -2, Flags: []string{"readonly"},
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns the difference of multiple sets.", Since: "1.0.0", Group: "set"},
+	},
+	"SINTERSTORE": {
+		Command: &SInterStoreCommand{}, Arity: -3, Flags: []string{"write", "denyoom"},
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Doc: CommandDoc{Summary: "Stores the intersect of multiple sets in a key.", Since: "1.0.0", Group: "set"},
+	},
+	"SUNIONSTORE": {
+		Command: &SUnionStoreCommand{}, Arity: -3, Flags: []string{"write", "denyoom"},
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Doc: CommandDoc{Summary: "Stores the union of multiple sets in a key.", Since: "1.0.0", Group: "set"},
+	},
+	"SDIFFSTORE": {
+		Command: &SDiffStoreCommand{}, Arity: -3, Flags: []string{"write", "denyoom"},
+		FirstKey: 1, LastKey: -1, Step: 1,
+		Doc: CommandDoc{Summary: "Stores the difference of multiple sets in a key.", Since: "1.0.0", Group: "set"},
+	},
+
+	"ZADD": {
+		Command: &ZAddCommand{}, Arity: -4, Flags: []string{"write", "denyoom", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Adds one or more members to a sorted set, or updates their scores. Creates the key if it doesn't exist.", Since: "1.2.0", Group: "sorted-set"},
+	},
+	"ZREM": {
+		Command: &ZRemCommand{}, Arity: -3, Flags: []string{"write", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Removes one or more members from a sorted set. Deletes the sorted set if all members were removed.", Since: "1.2.0", Group: "sorted-set"},
+	},
+	"ZCARD": {
+		Command: &ZCardCommand{}, Arity: 2, Flags: []string{"readonly", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns the number of members in a sorted set.", Since: "1.2.0", Group: "sorted-set"},
+	},
+	"ZINCRBY": {
+		Command: &ZIncrByCommand{}, Arity: 4, Flags: []string{"write", "denyoom", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Increments the score of a member in a sorted set.", Since: "1.2.0", Group: "sorted-set"},
+	},
+	"ZSCORE": {
+		Command: &ZScoreCommand{}, Arity: 3, Flags: []string{"readonly", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns the score of a member in a sorted set.", Since: "1.2.0", Group: "sorted-set"},
+	},
+	"ZRANK": {
+		Command: &ZRankCommand{}, Arity: -3, Flags: []string{"readonly", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns the index of a member in a sorted set ordered by ascending scores.", Since: "2.0.0", Group: "sorted-set"},
+	},
+	"ZRANGEBYSCORE": {
+		Command: &ZRangeByScoreCommand{}, Arity: -4, Flags: []string{"readonly"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns members in a sorted set within a range of scores.", Since: "1.0.5", Group: "sorted-set"},
+	},
+	"ZUNIONSTORE": {
+		Command: &ZUnionStoreCommand{}, Arity: -4, Flags: []string{"write", "denyoom", "movablekeys"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Stores the union of multiple sorted sets in a key.", Since: "2.0.0", Group: "sorted-set"},
+	},
+	"ZINTERSTORE": {
+		Command: &ZInterStoreCommand{}, Arity: -4, Flags: []string{"write", "denyoom", "movablekeys"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Stores the intersect of multiple sorted sets in a key.", Since: "2.0.0", Group: "sorted-set"},
+	},
+
+	"HSET": {
+		Command: &HSetCommand{}, Arity: -4, Flags: []string{"write", "denyoom", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Creates or modifies the value of a field in a hash.", Since: "2.0.0", Group: "hash"},
+	},
+	"HGET": {
+		Command: &HGetCommand{}, Arity: 3, Flags: []string{"readonly", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns the value of a field in a hash.", Since: "2.0.0", Group: "hash"},
+	},
+	"HGETALL": {
+		Command: &HGetAllCommand{}, Arity: 2, Flags: []string{"readonly"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns all fields and values in a hash.", Since: "2.0.0", Group: "hash"},
+	},
+	"HKEYS": {
+		Command: &HKeysCommand{}, Arity: 2, Flags: []string{"readonly"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns all fields in a hash.", Since: "2.0.0", Group: "hash"},
+	},
+	"HVALS": {
+		Command: &HValsCommand{}, Arity: 2, Flags: []string{"readonly"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns all values in a hash.", Since: "2.0.0", Group: "hash"},
+	},
+	"HDEL": {
+		Command: &HDelCommand{}, Arity: -3, Flags: []string{"write", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Deletes one or more fields and their values from a hash. Deletes the hash if no fields remain.", Since: "2.0.0", Group: "hash"},
+	},
+	"HLEN": {
+		Command: &HLenCommand{}, Arity: 2, Flags: []string{"readonly", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns the number of fields in a hash.", Since: "2.0.0", Group: "hash"},
+	},
+	"HEXISTS": {
+		Command: &HExistsCommand{}, Arity: 3, Flags: []string{"readonly", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Determines whether a field exists in a hash.", Since: "2.0.0", Group: "hash"},
+	},
+	"HINCRBY": {
+		Command: &HIncrByCommand{}, Arity: 4, Flags: []string{"write", "denyoom", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Increments the integer value of a field in a hash by a number. Uses 0 as initial value if the field doesn't exist.", Since: "2.0.0", Group: "hash"},
+	},
+	"HEXPIRE": {
+		Command: &HExpireCommand{}, Arity: -6, Flags: []string{"write", "denyoom", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Set expiry for hash field using relative time to expire (seconds).", Since: "7.4.0", Group: "hash"},
+	},
+	"HPEXPIREAT": {
+		Command: &HPExpireAtCommand{}, Arity: -6, Flags: []string{"write", "denyoom", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Set expiry for hash field using an absolute Unix timestamp (milliseconds).", Since: "7.4.0", Group: "hash"},
+	},
+	"HTTL": {
+		Command: &HTtlCommand{}, Arity: -5, Flags: []string{"readonly", "fast"},
+		FirstKey: 1, LastKey: 1, Step: 1,
+		Doc: CommandDoc{Summary: "Returns the TTL in seconds of a hash field.", Since: "7.4.0", Group: "hash"},
+	},
+
+	"SUBSCRIBE": {
+		Command: &SubscribeCommand{}, Arity: -2, Flags: []string{"pubsub", "noscript", "loading", "stale"},
+		Doc: CommandDoc{Summary: "Listens for messages published to channels.", Since: "2.0.0", Group: "pubsub"},
+	},
+	"UNSUBSCRIBE": {
+		Command: &UnsubscribeCommand{}, Arity: -1, Flags: []string{"pubsub", "noscript", "loading", "stale"},
+		Doc: CommandDoc{Summary: "Stops listening to messages posted to channels.", Since: "2.0.0", Group: "pubsub"},
+	},
+	"PSUBSCRIBE": {
+		Command: &PSubscribeCommand{}, Arity: -2, Flags: []string{"pubsub", "noscript", "loading", "stale"},
+		Doc: CommandDoc{Summary: "Listens for messages published to channels that match one or more patterns.", Since: "2.0.0", Group: "pubsub"},
+	},
+	"PUNSUBSCRIBE": {
+		Command: &PUnsubscribeCommand{}, Arity: -1, Flags: []string{"pubsub", "noscript", "loading", "stale"},
+		Doc: CommandDoc{Summary: "Stops listening to messages published to channels that match one or more patterns.", Since: "2.0.0", Group: "pubsub"},
+	},
+	"PUBLISH": {
+		Command: &PublishCommand{}, Arity: 3, Flags: []string{"pubsub", "loading", "stale", "fast"},
+		Doc: CommandDoc{Summary: "Posts a message to a channel.", Since: "2.0.0", Group: "pubsub"},
+	},
 }
 
 /*
@@ -72,8 +569,8 @@ func (c *XAddCommand) Execute(
 	config config.Config,
 	args []string,
 ) {
-	if len(args) < 3 {
-		log.Error("Missing arguments")
+	if len(args) < 5 || len(args)%2 == 0 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
 		return
 	}
 
@@ -83,37 +580,39 @@ func (c *XAddCommand) Execute(
 
 	key := args[1]
 
-	id, err := store.FormID(key, args[2], storeObj)
-
-	fields := make(map[string]string)
+	fields := make([]store.StreamField, 0, (len(args)-3)/2)
 
 	for i := 3; i < len(args); i += 2 {
-		fields[args[i]] = args[i+1]
+		fields = append(fields, store.StreamField{Name: args[i], Value: args[i+1]})
 	}
 
-	if err != nil {
-		answerStr = fmt.Sprintf("-ERR %s\r\n", err.Error())
-	} else {
-		answerStr = fmt.Sprintf("$%d\r\n%s\r\n", len(id), id)
+	var id string
+	var err error
 
-		streamMessage := store.StreamMessage{
-			ID:     id,
-			Fields: fields,
+	if args[2] == "*" {
+		id, err = storeObj.XAddAuto(key, fields)
+	} else {
+		id, err = store.FormID(key, args[2], storeObj)
+		if err == nil {
+			err = storeObj.XAdd(key, store.StreamMessage{ID: id, Fields: fields})
 		}
-
-		storeObj.XAdd(key, streamMessage)
 	}
 
-	logrus.Debug("XADD BEFORE SELECT")
-
-	blockCh := utils.GetBlockChObj(ctx)
-
-	select {
-	case blockCh <- struct{}{}:
-	default:
+	if errors.Is(err, store.ErrWrongType) {
+		answerStr = fmt.Sprintf("-%s\r\n", err.Error())
+	} else if err != nil {
+		answerStr = fmt.Sprintf("-ERR %s\r\n", err.Error())
+	} else {
+		answerStr = fmt.Sprintf("$%d\r\n%s\r\n", len(id), id)
 	}
 
-	logrus.Debug("XADD AFTER SELECT")
+	// replicas must store the entry under the ID generated here
+	propagation := GetPropagationObj(ctx)
+	if err != nil {
+		propagation.Rewrite()
+	} else {
+		propagation.Rewrite(append([]string{args[0], key, id}, args[3:]...))
+	}
 
 	conn.Write([]byte(answerStr))
 }
@@ -126,11 +625,17 @@ func (c *XReadCommand) Execute(
 	config config.Config,
 	args []string,
 ) {
+	if len(args) < 4 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
 	var streamsIndex int
 	var numStreams int
 	var block bool
+	var timeout time.Duration
 
-	if args[1] == "block" {
+	if strings.ToLower(args[1]) == "block" {
 
 		streamsIndex = 4
 
@@ -140,23 +645,11 @@ func (c *XReadCommand) Execute(
 
 		timeSleep, err := strconv.Atoi(args[2])
 		if err != nil {
-			logrus.Error(err)
+			conn.Write([]byte("-ERR timeout is not an integer or out of range\r\n"))
 			return
 		}
 
-		time.Sleep(time.Duration(timeSleep) * time.Millisecond)
-
-		if args[2] == "0" {
-			blockCh := utils.GetBlockChObj(ctx)
-			<-blockCh
-
-			// блокируем горутину, пока не получим сообщения XADD из другого потока
-			// получаем из ctx канал и ждем из него сообщения
-			// в XADD добавляем значение в канал
-			// тем самым разблокируем эту горутину
-			// но как быть если XADD нужно не всегда что-то отправлять в канал ?
-			// select {} где case ch <- "XADD" либо default
-		}
+		timeout = time.Duration(timeSleep) * time.Millisecond
 
 	} else {
 		streamsIndex = 2
@@ -164,13 +657,78 @@ func (c *XReadCommand) Execute(
 		numStreams = (len(args) - 2) / 2
 	}
 
+	if numStreams == 0 || len(args)-streamsIndex != numStreams*2 {
+		conn.Write([]byte("-ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified.\r\n"))
+		return
+	}
+
 	streamKeys := args[streamsIndex : streamsIndex+numStreams]
-	IDs := args[streamsIndex+numStreams:]
+	IDs := make([]string, numStreams)
+	copy(IDs, args[streamsIndex+numStreams:])
 
 	storeObj := utils.GetStoreObj(ctx)
 
-	var bb bytes.Buffer
+	// "$" resolves to the top ID at the moment the waiter is registered,
+	// so an XADD that lands in between is delivered instead of skipped
+	topIDs, wait := storeObj.WatchStreams(streamKeys)
+	for i := range IDs {
+		if IDs[i] == "$" {
+			IDs[i] = topIDs[i]
+		}
+	}
+
+	var timerCh <-chan time.Time
+	if timeout > 0 {
+		timerCh = time.After(timeout)
+	}
+
+	for {
+		var bb bytes.Buffer
+
+		found, err := c.readStreams(&bb, storeObj, streamKeys, IDs)
+		if err != nil {
+			storeObj.Unwatch(wait)
+			conn.Write([]byte(fmt.Sprintf("-%s\r\n", err.Error())))
+			return
+		}
+
+		if found {
+			storeObj.Unwatch(wait)
+			conn.Write(bb.Bytes())
+			return
+		}
+
+		if !block {
+			storeObj.Unwatch(wait)
+			conn.Write([]byte("*-1\r\n"))
+			return
+		}
 
+		select {
+		case <-wait:
+			_, wait = storeObj.WatchStreams(streamKeys)
+		case <-timerCh:
+			storeObj.Unwatch(wait)
+			conn.Write([]byte("*-1\r\n"))
+			return
+		case <-ctx.Done():
+			// the client disconnected, nobody reads the reply
+			storeObj.Unwatch(wait)
+			return
+		}
+	}
+}
+
+/*
+readStreams writes the entries newer than the given IDs for every stream
+that has any and reports whether something was found.
+*/
+func (c *XReadCommand) readStreams(
+	bb *bytes.Buffer,
+	storeObj *store.Store,
+	streamKeys []string,
+	IDs []string,
+) (bool, error) {
 	type StreamPair struct {
 		streamKey string
 		id        string
@@ -180,38 +738,36 @@ func (c *XReadCommand) Execute(
 	streamPairs := make([]StreamPair, 0, len(streamKeys))
 
 	for i := range streamKeys {
+		messages, err := storeObj.GetStreamsExclusive(streamKeys[i], IDs[i])
+		if errors.Is(err, store.ErrWrongType) {
+			return false, err
+		}
+		if errors.Is(err, store.ErrInvalidStreamID) {
+			return false, fmt.Errorf("ERR %w", err)
+		}
+
+		if len(messages) == 0 {
+			continue
+		}
+
 		streamPairs = append(streamPairs, StreamPair{
 			streamKey: streamKeys[i],
 			id:        IDs[i],
-			messages:  make([]store.StreamMessage, 0, 8),
+			messages:  messages,
 		})
 	}
 
-	for index, streamPair := range streamPairs {
-
-		messages, err := storeObj.GetStreamsExclusive(streamPair.streamKey, streamPair.id)
-		if err != nil {
-			logrus.Error(err)
-			return
-		}
-
-		streamPairs[index].messages = messages
-
-		if block && len(messages) == 0 {
-			conn.Write([]byte("$-1\r\n"))
-			return
-		}
+	if len(streamPairs) == 0 {
+		return false, nil
 	}
 
-	bb.WriteString(arrayResp(len(streamKeys)))
+	bb.WriteString(arrayResp(len(streamPairs)))
 
 	for _, streamPair := range streamPairs {
-		writeStreamMessage(&bb, streamPair.streamKey, streamPair.messages)
+		writeStreamMessage(bb, streamPair.streamKey, streamPair.messages)
 	}
 
-	logrus.Error(bb.String())
-
-	conn.Write(bb.Bytes())
+	return true, nil
 }
 
 /*
@@ -225,8 +781,8 @@ func (c *XRangeCommand) Execute(
 	config config.Config,
 	args []string,
 ) {
-	if len(args) < 3 {
-		log.Error("Missing arguments")
+	if len(args) < 4 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
 		return
 	}
 
@@ -235,8 +791,12 @@ func (c *XRangeCommand) Execute(
 
 	storeObj := utils.GetStoreObj(ctx)
 	res, err := storeObj.GetStreamsRange(key, [2]string{IDs[0], IDs[1]})
-	if err != nil {
-		logrus.Error(err)
+	if errors.Is(err, store.ErrWrongType) {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+	if errors.Is(err, store.ErrInvalidStreamID) {
+		conn.Write([]byte(fmt.Sprintf("-ERR %s\r\n", err)))
 		return
 	}
 
@@ -244,15 +804,14 @@ func (c *XRangeCommand) Execute(
 
 	bb.Write([]byte(fmt.Sprintf("*%d\r\n", len(res))))
 
-	for _, v := range res {
-		bb.Write([]byte(fmt.Sprintf("*2\r\n")))
-		bb.Write([]byte(fmt.Sprintf("$%d\r\n%s\r\n", len(v.ID), v.ID)))
-		bb.Write([]byte(fmt.Sprintf("*%d\r\n", len(v.Fields)*2)))
+	for _, msg := range res {
+		bb.WriteString(arrayResp(2))
+		bb.WriteString(stringResp(msg.ID))
+		bb.WriteString(arrayResp(len(msg.Fields) * 2))
 
-		for k, v := range v.Fields {
-			logrus.Error(k + ": " + v)
-			bb.Write([]byte(fmt.Sprintf("$%d\r\n%s\r\n", len(k), k)))
-			bb.Write([]byte(fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)))
+		for _, field := range msg.Fields {
+			bb.WriteString(stringResp(field.Name))
+			bb.WriteString(stringResp(field.Value))
 		}
 	}
 
@@ -260,521 +819,3266 @@ func (c *XRangeCommand) Execute(
 }
 
 /*
-The TYPE command returns the type of value stored at a given key.
+The XGROUP command manages the consumer groups of a stream.
 */
-type TypeCommand struct{}
+type XGroupCommand struct{}
 
-func (c *TypeCommand) Execute(
+func (c *XGroupCommand) Execute(
 	ctx context.Context,
 	conn io.Writer,
 	config config.Config,
 	args []string,
 ) {
 	if len(args) < 2 {
-		log.Error("Missing arguments")
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
 		return
 	}
 
-	key := args[1]
-
-	storeObj := utils.GetStoreObj(ctx)
+	commands := map[string]CommandHandler{
+		"CREATE":         c.handleCreate,
+		"CREATECONSUMER": c.handleCreateConsumer,
+	}
 
-	keyType, err := storeObj.GetType(key)
-	if err != nil {
-		conn.Write([]byte("+none\r\n"))
+	if handler, exists := commands[strings.ToUpper(args[1])]; exists {
+		handler(ctx, conn, config, args)
 		return
 	}
 
-	conn.Write([]byte(fmt.Sprintf("+%s\r\n", keyType)))
+	conn.Write([]byte(unknownSubcommandResp(args[0], args[1])))
 }
 
 /*
-The DISCARD command discards all commands issued after MULTI.
+The XINFO command reports the consumer groups of a stream and their consumers.
 */
-type DiscardCommand struct{}
+type XInfoCommand struct{}
 
-func (c *DiscardCommand) Execute(
+func (c *XInfoCommand) Execute(
 	ctx context.Context,
 	conn io.Writer,
 	config config.Config,
 	args []string,
 ) {
-	transactionsObj := transactions.GetTransactionsObj(ctx)
-
-	if conn, ok := conn.(net.Conn); ok {
-		transactionBufferObj := transactionsObj.GetTransactionBuffer(conn)
+	if len(args) < 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
 
-		if !transactionBufferObj.IsTransactionActive() {
-			conn.Write([]byte("-ERR DISCARD without MULTI\r\n"))
-			return
-		}
+	commands := map[string]CommandHandler{
+		"GROUPS":    c.handleGroups,
+		"CONSUMERS": c.handleConsumers,
+	}
 
-		transactionBufferObj.DiscardTransaction()
-		conn.Write([]byte("+OK\r\n"))
+	if handler, exists := commands[strings.ToUpper(args[1])]; exists {
+		handler(ctx, conn, config, args)
+		return
 	}
+
+	conn.Write([]byte(unknownSubcommandResp(args[0], args[1])))
 }
 
 /*
-The EXEC command executes all the previously queued commands issued with MULTI.
+The LPUSH command prepends elements to a list, creating it when the key does not exist.
 */
-type ExecCommand struct{}
+type LPushCommand struct{}
 
-func (c *ExecCommand) Execute(
+func (c *LPushCommand) Execute(
 	ctx context.Context,
 	conn io.Writer,
 	config config.Config,
 	args []string,
 ) {
-	transactionsObj := transactions.GetTransactionsObj(ctx)
-
-	var buffer bytes.Buffer
-	buffer.Grow(8)
-
-	var lenCommands int
+	if len(args) < 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
 
-	if conn, ok := conn.(net.Conn); ok {
-		transactionBufferObj := transactionsObj.Values[conn]
+	storeObj := utils.GetStoreObj(ctx)
 
-		if !transactionBufferObj.IsTransactionActive() {
-			conn.Write([]byte("-ERR EXEC without MULTI\r\n"))
-			return
-		}
+	length, err := storeObj.LPush(args[1], args[2:])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err.Error())))
+		return
+	}
 
-		if transactionBufferObj.IsBufferEmpty() {
-			conn.Write([]byte("*0\r\n"))
+	GetPropagationObj(ctx).Changed()
 
-			transactionBufferObj.InActivateTransaction()
-			return
-		}
+	conn.Write([]byte(integerResp(length)))
+}
 
-		commands := transactionBufferObj.PopCommands()
-		lenCommands = len(commands)
+/*
+The RPUSH command appends elements to a list, creating it when the key does not exist.
+*/
+type RPushCommand struct{}
 
-		for _, command := range commands {
-			args := command.Args
-			cmd := command.CMD
+func (c *RPushCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
 
-			if _, ok := conn.(net.Conn); ok {
-				cmd.Execute(ctx, &buffer, config, args)
-			}
-		}
+	storeObj := utils.GetStoreObj(ctx)
 
-		transactionBufferObj.InActivateTransaction()
+	length, err := storeObj.RPush(args[1], args[2:])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err.Error())))
+		return
 	}
 
-	result := fmt.Sprintf("*%d\r\n%s", lenCommands, buffer.String())
-	conn.Write([]byte(result))
-	return
+	GetPropagationObj(ctx).Changed()
+
+	conn.Write([]byte(integerResp(length)))
 }
 
 /*
-The MULTI command marks the start of a transaction block.
+The LPUSHX command prepends elements to a list only when the list exists.
 */
-type MultiCommand struct{}
+type LPushXCommand struct{}
 
-func (c *MultiCommand) Execute(
+func (c *LPushXCommand) Execute(
 	ctx context.Context,
 	conn io.Writer,
 	config config.Config,
 	args []string,
 ) {
-	transactionsObj := transactions.GetTransactionsObj(ctx)
+	if len(args) < 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
 
-	if conn, ok := conn.(net.Conn); ok {
-		transactionBufferObj := transactionsObj.Values[conn]
-		transactionBufferObj.StartTransaction()
+	storeObj := utils.GetStoreObj(ctx)
+
+	length, err := storeObj.LPushX(args[1], args[2:])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err.Error())))
+		return
 	}
 
-	conn.Write([]byte("+OK\r\n"))
+	if length > 0 {
+		GetPropagationObj(ctx).Changed()
+	}
+
+	conn.Write([]byte(integerResp(length)))
 }
 
 /*
-The INCR command increments the number stored at key by one.
+The RPUSHX command appends elements to a list only when the list exists.
 */
-type IncrCommand struct{}
+type RPushXCommand struct{}
 
-func (c *IncrCommand) Execute(
+func (c *RPushXCommand) Execute(
 	ctx context.Context,
 	conn io.Writer,
 	config config.Config,
 	args []string,
 ) {
-	if len(args) < 2 {
-		log.Error("Missing arguments")
+	if len(args) < 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
 		return
 	}
-	key := args[1]
 
 	storeObj := utils.GetStoreObj(ctx)
 
-	value, err := storeObj.Incr(key)
+	length, err := storeObj.RPushX(args[1], args[2:])
 	if err != nil {
-		conn.Write([]byte("-ERR value is not an integer or out of range\r\n"))
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err.Error())))
 		return
 	}
 
-	conn.Write([]byte(fmt.Sprintf(":%d\r\n", value)))
+	if length > 0 {
+		GetPropagationObj(ctx).Changed()
+	}
+
+	conn.Write([]byte(integerResp(length)))
 }
 
 /*
-The ECHO command returns a line of text to the client.
+The LPOP command removes and returns the first elements of a list.
 */
-type EchoCommand struct{}
+type LPopCommand struct{}
 
-func (c *EchoCommand) Execute(
+func (c *LPopCommand) Execute(
 	ctx context.Context,
 	conn io.Writer,
 	config config.Config,
 	args []string,
 ) {
-	msg := args[1]
-	conn.Write([]byte(fmt.Sprintf("$%d\r\n%s\r\n", len(msg), msg)))
+	pop(ctx, conn, args, true)
 }
 
 /*
-The PING command returns PONG.
+The RPOP command removes and returns the last elements of a list.
 */
-type PingCommand struct{}
+type RPopCommand struct{}
 
-func (c *PingCommand) Execute(
+func (c *RPopCommand) Execute(
 	ctx context.Context,
 	conn io.Writer,
 	config config.Config,
 	args []string,
 ) {
-	switch config.Role {
-	case "master":
-		conn.Write([]byte("+PONG\r\n"))
-	}
+	pop(ctx, conn, args, false)
 }
 
 /*
-The SET command sets the string value of a key.
+The BLPOP command pops the first element of the first non-empty list,
+blocking until one is available or the timeout elapses.
 */
-type SetCommand struct{}
+type BLPopCommand struct{}
 
-func (c *SetCommand) Execute(
+func (c *BLPopCommand) Execute(
 	ctx context.Context,
 	conn io.Writer,
 	config config.Config,
 	args []string,
 ) {
-	key, value := args[1], args[2]
-
-	var px *int
-
-	if len(args) > 3 {
-		switch strings.ToUpper(args[3]) {
-		case "PX":
-			parsedPx, err := strconv.Atoi(args[4])
-			if err != nil {
-				conn.Write([]byte("px arg in not valid"))
-				return
-			}
-			px = &parsedPx
-		}
-	}
-
-	storeFromContext := ctx.Value("store")
-
-	if storeFromContext != nil {
-		if store, ok := storeFromContext.(*store.Store); !ok {
-			log.Fatalf("Expected *store.Store, got %T", storeFromContext)
-		} else {
-			store.Set(key, value, px)
-		}
-	}
-
-	switch config.Role {
-	case "master":
-		conn.Write([]byte("+OK\r\n"))
-	}
+	blockingPop(ctx, conn, args, true)
 }
 
 /*
-The GET command returns the value associated with a key.
+The BRPOP command pops the last element of the first non-empty list,
+blocking until one is available or the timeout elapses.
 */
-type GetCommand struct{}
+type BRPopCommand struct{}
 
-func (c *GetCommand) Execute(
+func (c *BRPopCommand) Execute(
 	ctx context.Context,
 	conn io.Writer,
 	config config.Config,
 	args []string,
 ) {
-	key := args[1]
-
-	storeFromContext := ctx.Value("store")
-
-	if storeFromContext != nil {
-		if store, ok := storeFromContext.(*store.Store); !ok {
-			log.Fatalf("Expected *store.Store, got %T", storeFromContext)
-		} else {
-			value, err := store.Get(key)
-			if err != nil {
-				conn.Write([]byte("$-1\r\n"))
-			} else {
-				conn.Write([]byte(fmt.Sprintf("+%s\r\n", value)))
-			}
-		}
-	}
+	blockingPop(ctx, conn, args, false)
 }
 
 /*
-The INFO command returns information and statistics about the server.
+The LRANGE command returns a range of elements from a list.
 */
-type InfoCommand struct{}
+type LRangeCommand struct{}
 
-func (c *InfoCommand) Execute(
+func (c *LRangeCommand) Execute(
 	ctx context.Context,
 	conn io.Writer,
 	config config.Config,
 	args []string,
 ) {
-	switch args[1] {
-	case "replication":
-		var builder strings.Builder
-		builder.Grow(128)
-
-		role := fmt.Sprintf("role:%s", config.Role)
-		builder.WriteString(fmt.Sprintf("%s\n", role))
-
-		switch config.Role {
-		case "master":
-			master_replid := fmt.Sprintf("master_replid:%s", config.Master.MasterReplId)
-			builder.WriteString(fmt.Sprintf("%s\n", master_replid))
+	if len(args) != 4 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
 
-			master_repl_offset := fmt.Sprintf(
-				"master_repl_offset:%d",
-				config.Master.MasterReplOffset.Load(),
-			)
-			builder.WriteString(
-				fmt.Sprintf("%s\n", master_repl_offset),
-			)
-		}
+	start, err := strconv.Atoi(args[2])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", store.ErrNotInteger)))
+		return
+	}
 
-		result := builder.String()
+	end, err := strconv.Atoi(args[3])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", store.ErrNotInteger)))
+		return
+	}
 
-		finalResult := fmt.Sprintf("$%d\r\n%s\r\n", len(result), result)
+	elements, err := utils.GetStoreObj(ctx).LRange(args[1], start, end)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	writeStrings(conn, elements)
+}
+
+/*
+The LLEN command returns the length of a list.
+*/
+type LLenCommand struct{}
+
+func (c *LLenCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	length, err := utils.GetStoreObj(ctx).LLen(args[1])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	conn.Write([]byte(integerResp(length)))
+}
+
+/*
+The LINDEX command returns the element at an index of a list.
+*/
+type LIndexCommand struct{}
+
+func (c *LIndexCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	index, err := strconv.Atoi(args[2])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", store.ErrNotInteger)))
+		return
+	}
+
+	element, ok, err := utils.GetStoreObj(ctx).LIndex(args[1], index)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	if !ok {
+		conn.Write([]byte("$-1\r\n"))
+		return
+	}
+
+	conn.Write([]byte(stringResp(element)))
+}
+
+/*
+The LSET command sets the element at an index of a list.
+*/
+type LSetCommand struct{}
+
+func (c *LSetCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 4 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	index, err := strconv.Atoi(args[2])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", store.ErrNotInteger)))
+		return
+	}
+
+	if err := utils.GetStoreObj(ctx).LSet(args[1], index, args[3]); err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	GetPropagationObj(ctx).Changed()
+
+	conn.Write([]byte("+OK\r\n"))
+}
+
+/*
+The HSET command sets the values of fields in a hash.
+*/
+type HSetCommand struct{}
+
+func (c *HSetCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 4 || len(args)%2 != 0 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	added, err := utils.GetStoreObj(ctx).HSet(args[1], args[2:])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	GetPropagationObj(ctx).Changed()
+
+	conn.Write([]byte(integerResp(added)))
+}
+
+/*
+The HGET command returns the value of a field in a hash.
+*/
+type HGetCommand struct{}
+
+func (c *HGetCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	value, ok, err := utils.GetStoreObj(ctx).HGet(args[1], args[2])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	if !ok {
+		conn.Write([]byte("$-1\r\n"))
+		return
+	}
+
+	conn.Write([]byte(stringResp(value)))
+}
+
+/*
+The HGETALL command returns all fields and values of a hash.
+*/
+type HGetAllCommand struct{}
+
+func (c *HGetAllCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	pairs, err := utils.GetStoreObj(ctx).HGetAll(args[1])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	writeStrings(conn, pairs)
+}
+
+/*
+The HKEYS command returns all field names of a hash.
+*/
+type HKeysCommand struct{}
+
+func (c *HKeysCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	fields, err := utils.GetStoreObj(ctx).HKeys(args[1])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	writeStrings(conn, fields)
+}
+
+/*
+The HVALS command returns all values of a hash.
+*/
+type HValsCommand struct{}
+
+func (c *HValsCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	values, err := utils.GetStoreObj(ctx).HVals(args[1])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	writeStrings(conn, values)
+}
+
+/*
+The HDEL command deletes fields from a hash.
+*/
+type HDelCommand struct{}
+
+func (c *HDelCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	removed, err := utils.GetStoreObj(ctx).HDel(args[1], args[2:])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	if removed > 0 {
+		GetPropagationObj(ctx).Changed()
+	}
+
+	conn.Write([]byte(integerResp(removed)))
+}
+
+/*
+The HLEN command returns the number of fields in a hash.
+*/
+type HLenCommand struct{}
+
+func (c *HLenCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	length, err := utils.GetStoreObj(ctx).HLen(args[1])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	conn.Write([]byte(integerResp(length)))
+}
+
+/*
+The HEXISTS command determines whether a field exists in a hash.
+*/
+type HExistsCommand struct{}
+
+func (c *HExistsCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	exists, err := utils.GetStoreObj(ctx).HExists(args[1], args[2])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	if exists {
+		conn.Write([]byte(integerResp(1)))
+		return
+	}
+
+	conn.Write([]byte(integerResp(0)))
+}
+
+/*
+The HINCRBY command increments the integer value of a field in a hash.
+*/
+type HIncrByCommand struct{}
+
+func (c *HIncrByCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 4 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	delta, err := strconv.ParseInt(args[3], 10, 64)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", store.ErrNotInteger)))
+		return
+	}
+
+	value, err := utils.GetStoreObj(ctx).HIncrBy(args[1], args[2], delta)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	GetPropagationObj(ctx).Changed()
+
+	conn.Write([]byte(fmt.Sprintf(":%d\r\n", value)))
+}
+
+/*
+The HEXPIRE command sets a time to live in seconds for one or more hash fields.
+*/
+type HExpireCommand struct{}
+
+func (c *HExpireCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 6 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	key := args[1]
+
+	seconds, err := strconv.Atoi(args[2])
+	if err != nil {
+		conn.Write([]byte("-ERR value is not an integer or out of range\r\n"))
+		return
+	}
+
+	fields, err := parseFieldsArg(args, 3)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-ERR %s\r\n", err.Error())))
+		return
+	}
+
+	hExpireAt(ctx, conn, key, time.Now().Add(time.Duration(seconds)*time.Second), fields)
+}
+
+/*
+The HPEXPIREAT command sets the time hash fields expire at as a Unix time
+in milliseconds. Replicas get it for every field expiration set on the
+master.
+*/
+type HPExpireAtCommand struct{}
+
+func (c *HPExpireAtCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 6 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	ms, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		conn.Write([]byte("-ERR value is not an integer or out of range\r\n"))
+		return
+	}
+
+	fields, err := parseFieldsArg(args, 3)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-ERR %s\r\n", err.Error())))
+		return
+	}
+
+	hExpireAt(ctx, conn, args[1], time.UnixMilli(ms), fields)
+}
+
+/*
+The HTTL command returns the remaining time to live in seconds of hash fields.
+*/
+type HTtlCommand struct{}
+
+func (c *HTtlCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 5 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	key := args[1]
+
+	fields, err := parseFieldsArg(args, 2)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-ERR %s\r\n", err.Error())))
+		return
+	}
+
+	storeObj := utils.GetStoreObj(ctx)
+
+	result, err := storeObj.HTTL(key, fields)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err.Error())))
+		return
+	}
+
+	writeIntegers(conn, result)
+}
+
+/*
+The TYPE command returns the type of value stored at a given key.
+*/
+type TypeCommand struct{}
+
+func (c *TypeCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	key := args[1]
+
+	storeObj := utils.GetStoreObj(ctx)
+
+	keyType, err := storeObj.GetType(key)
+	if err != nil {
+		conn.Write([]byte("+none\r\n"))
+		return
+	}
+
+	conn.Write([]byte(fmt.Sprintf("+%s\r\n", keyType)))
+}
+
+/*
+The EXPIRE command sets the time to live of a key in seconds.
+*/
+type ExpireCommand struct{}
+
+func (c *ExpireCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	expire(ctx, conn, args, time.Second)
+}
+
+/*
+The PEXPIRE command sets the time to live of a key in milliseconds.
+*/
+type PExpireCommand struct{}
+
+func (c *PExpireCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	expire(ctx, conn, args, time.Millisecond)
+}
+
+/*
+The PEXPIREAT command sets the expiration time of a key to a Unix time in
+milliseconds. Replicas get it for every expiration set on the master.
+*/
+type PExpireAtCommand struct{}
+
+func (c *PExpireAtCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	ms, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", store.ErrNotInteger)))
+		return
+	}
+
+	expireAt(ctx, conn, args[1], time.UnixMilli(ms))
+}
+
+/*
+The TTL command returns the remaining time to live of a key in seconds.
+*/
+type TtlCommand struct{}
+
+func (c *TtlCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeTTL(ctx, conn, args, time.Second)
+}
+
+/*
+The PTTL command returns the remaining time to live of a key in milliseconds.
+*/
+type PttlCommand struct{}
+
+func (c *PttlCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeTTL(ctx, conn, args, time.Millisecond)
+}
+
+/*
+The PERSIST command removes the expiration of a key.
+*/
+type PersistCommand struct{}
+
+func (c *PersistCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	if utils.GetStoreObj(ctx).Persist(args[1]) {
+		GetPropagationObj(ctx).Changed()
+		conn.Write([]byte(integerResp(1)))
+		return
+	}
+
+	conn.Write([]byte(integerResp(0)))
+}
+
+/*
+The COPY command copies the value stored at the source key to the destination key.
+*/
+type CopyCommand struct{}
+
+func (c *CopyCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	source, destination := args[1], args[2]
+
+	storeObj := utils.GetStoreObj(ctx)
+	target := storeObj
+
+	var replace bool
+
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "REPLACE":
+			replace = true
+		case "DB":
+			if i+1 >= len(args) {
+				conn.Write([]byte("-ERR syntax error\r\n"))
+				return
+			}
+
+			db, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				conn.Write([]byte("-ERR value is not an integer or out of range\r\n"))
+				return
+			}
+
+			var ok bool
+			if target, ok = utils.GetDatabasesObj(ctx).Get(db); !ok {
+				conn.Write([]byte("-ERR DB index is out of range\r\n"))
+				return
+			}
+			i++
+		default:
+			conn.Write([]byte("-ERR syntax error\r\n"))
+			return
+		}
+	}
+
+	var copied bool
+	if target == storeObj {
+		copied = storeObj.Copy(source, destination, replace)
+	} else {
+		copied = storeObj.CopyTo(target, source, destination, replace)
+	}
+
+	if copied {
+		GetPropagationObj(ctx).Changed()
+		conn.Write([]byte(integerResp(1)))
+		return
+	}
+
+	conn.Write([]byte(integerResp(0)))
+}
+
+/*
+The RENAME command renames a key, overwriting the destination.
+*/
+type RenameCommand struct{}
+
+func (c *RenameCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	if err := utils.GetStoreObj(ctx).Rename(args[1], args[2]); err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	GetPropagationObj(ctx).Changed()
+
+	conn.Write([]byte("+OK\r\n"))
+}
+
+/*
+The RENAMENX command renames a key only when the new name does not exist.
+*/
+type RenameNXCommand struct{}
+
+func (c *RenameNXCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	renamed, err := utils.GetStoreObj(ctx).RenameNX(args[1], args[2])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	if renamed {
+		GetPropagationObj(ctx).Changed()
+		conn.Write([]byte(integerResp(1)))
+		return
+	}
+
+	conn.Write([]byte(integerResp(0)))
+}
+
+/*
+The DUMP command returns the serialized value of a key in the format used by
+RESTORE.
+*/
+type DumpCommand struct{}
+
+func (c *DumpCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	storeObj := utils.GetStoreObj(ctx)
+
+	payload, ok, err := storeObj.Dump(args[1])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	if !ok {
+		conn.Write([]byte("$-1\r\n"))
+		return
+	}
+
+	conn.Write([]byte(stringResp(string(payload))))
+}
+
+/*
+The RESTORE command creates a key from a DUMP payload.
+*/
+type RestoreCommand struct{}
+
+func (c *RestoreCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 4 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	ttl, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", store.ErrNotInteger)))
+		return
+	}
+
+	if ttl < 0 {
+		conn.Write([]byte("-ERR Invalid TTL value, must be >= 0\r\n"))
+		return
+	}
+
+	var replace, absTTL bool
+
+	for _, option := range args[4:] {
+		switch strings.ToUpper(option) {
+		case "REPLACE":
+			replace = true
+		case "ABSTTL":
+			absTTL = true
+		default:
+			conn.Write([]byte("-ERR syntax error\r\n"))
+			return
+		}
+	}
+
+	duration := time.Duration(ttl) * time.Millisecond
+	if absTTL && ttl > 0 {
+		duration = time.Until(time.UnixMilli(ttl))
+
+		// the deadline already passed, so the key is not created
+		if duration <= 0 {
+			conn.Write([]byte("+OK\r\n"))
+			return
+		}
+	}
+
+	if err := utils.GetStoreObj(ctx).Restore(args[1], []byte(args[3]), duration, replace); err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	if ttl > 0 && !absTTL {
+		// replicas get the deadline rather than the time to live
+		deadline := strconv.FormatInt(time.Now().Add(duration).UnixMilli(), 10)
+		rewritten := []string{args[0], args[1], deadline, args[3]}
+		if replace {
+			rewritten = append(rewritten, "REPLACE")
+		}
+		GetPropagationObj(ctx).Rewrite(append(rewritten, "ABSTTL"))
+	} else {
+		GetPropagationObj(ctx).Changed()
+	}
+
+	conn.Write([]byte("+OK\r\n"))
+}
+
+/*
+The MIGRATE command moves a key to another instance with DUMP and RESTORE.
+*/
+type MigrateCommand struct{}
+
+func (c *MigrateCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 6 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	host, port, key := args[1], args[2], args[3]
+
+	db, err := strconv.Atoi(args[4])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", store.ErrNotInteger)))
+		return
+	}
+
+	timeout, err := strconv.Atoi(args[5])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", store.ErrNotInteger)))
+		return
+	}
+
+	if timeout <= 0 {
+		timeout = 1000
+	}
+
+	var copyKey, replace bool
+
+	for _, option := range args[6:] {
+		switch strings.ToUpper(option) {
+		case "COPY":
+			copyKey = true
+		case "REPLACE":
+			replace = true
+		default:
+			conn.Write([]byte("-ERR syntax error\r\n"))
+			return
+		}
+	}
+
+	storeObj := utils.GetStoreObj(ctx)
+
+	payload, ok, err := storeObj.Dump(key)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	if !ok {
+		conn.Write([]byte("+NOKEY\r\n"))
+		return
+	}
+
+	var ttl int64
+	if remaining, _, hasExpiry := storeObj.TTLRemaining(key); hasExpiry {
+		ttl = max(remaining.Milliseconds(), 1)
+	}
+
+	restore := []string{"RESTORE", key, strconv.FormatInt(ttl, 10), string(payload)}
+	if replace {
+		restore = append(restore, "REPLACE")
+	}
+
+	reply, err := c.send(
+		net.JoinHostPort(host, port),
+		time.Duration(timeout)*time.Millisecond,
+		[]string{"SELECT", strconv.Itoa(db)},
+		restore,
+	)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-IOERR error or timeout reading to target instance: %s\r\n", err)))
+		return
+	}
+
+	if strings.HasPrefix(reply, "-") {
+		conn.Write([]byte(fmt.Sprintf("-ERR Target instance replied with error: %s\r\n", reply[1:])))
+		return
+	}
+
+	if !copyKey {
+		storeObj.Del(key)
+		GetPropagationObj(ctx).Rewrite([]string{"DEL", key})
+	}
+
+	conn.Write([]byte("+OK\r\n"))
+}
+
+/*
+send runs the commands on a fresh connection to addr and returns the first
+error reply, or the reply to the last command. Every reply is expected to
+fit on a single line, which holds for SELECT and RESTORE.
+*/
+func (c *MigrateCommand) send(addr string, timeout time.Duration, commands ...[]string) (string, error) {
+	target, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return "", err
+	}
+	defer target.Close()
+
+	target.SetDeadline(time.Now().Add(timeout))
+
+	r := bufio.NewReader(target)
+
+	var reply string
+
+	for _, command := range commands {
+		if _, err := target.Write([]byte(redis.ConvertToRESP(command))); err != nil {
+			return "", err
+		}
+
+		reply, err = r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+
+		reply = strings.TrimRight(reply, "\r\n")
+		if strings.HasPrefix(reply, "-") {
+			return reply, nil
+		}
+	}
+
+	return reply, nil
+}
+
+/*
+The OBJECT command inspects the internals of the value stored at a key.
+*/
+type ObjectCommand struct{}
+
+func (c *ObjectCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	commands := map[string]CommandHandler{
+		"ENCODING": c.handleEncoding,
+	}
+
+	if handler, exists := commands[strings.ToUpper(args[1])]; exists {
+		handler(ctx, conn, config, args)
+		return
+	}
+
+	conn.Write([]byte(unknownSubcommandResp(args[0], args[1])))
+}
+
+/*
+The DISCARD command discards all commands issued after MULTI.
+*/
+type DiscardCommand struct{}
+
+func (c *DiscardCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	transactionsObj := transactions.GetTransactionsObj(ctx)
+
+	if conn, ok := conn.(net.Conn); ok {
+		transactionBufferObj := transactionsObj.GetTransactionBuffer(conn)
+		if transactionBufferObj == nil {
+			conn.Write([]byte(NoTransactionStateResp))
+			return
+		}
+
+		if !transactionBufferObj.IsTransactionActive() {
+			conn.Write([]byte("-ERR DISCARD without MULTI\r\n"))
+			return
+		}
+
+		transactionBufferObj.DiscardTransaction()
+		conn.Write([]byte("+OK\r\n"))
+	}
+}
+
+/*
+The EXEC command executes all the previously queued commands issued with MULTI.
+*/
+type ExecCommand struct{}
+
+func (c *ExecCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	transactionsObj := transactions.GetTransactionsObj(ctx)
+
+	var buffer bytes.Buffer
+	buffer.Grow(8)
+
+	var lenCommands int
+
+	if conn, ok := conn.(net.Conn); ok {
+		transactionBufferObj := transactionsObj.GetTransactionBuffer(conn)
+		if transactionBufferObj == nil {
+			conn.Write([]byte(NoTransactionStateResp))
+			return
+		}
+
+		if !transactionBufferObj.IsTransactionActive() {
+			conn.Write([]byte("-ERR EXEC without MULTI\r\n"))
+			return
+		}
+
+		if transactionBufferObj.IsBufferEmpty() {
+			conn.Write([]byte("*0\r\n"))
+
+			transactionBufferObj.InActivateTransaction()
+			return
+		}
+
+		commands := transactionBufferObj.PopCommands()
+		lenCommands = len(commands)
+
+		// the writes are forwarded to replicas wrapped in MULTI and EXEC,
+		// so they are applied there as one block too
+		var writes [][]string
+
+		for _, command := range commands {
+			args := command.Args
+			cmd := command.CMD
+
+			cmdCtx, propagation := WithPropagation(ctx)
+
+			// every queued command must produce exactly one element of the
+			// EXEC array, errors included, so replies are collected one by one
+			var reply bytes.Buffer
+			cmd.Execute(cmdCtx, &reply, config, args)
+
+			writes = append(writes, propagation.Commands(args)...)
+
+			if reply.Len() == 0 {
+				reply.WriteString(fmt.Sprintf("-ERR no reply for '%s' command\r\n", args[0]))
+			}
+
+			buffer.Write(reply.Bytes())
+		}
+
+		transactionBufferObj.InActivateTransaction()
+
+		if len(writes) > 0 {
+			writes = append([][]string{{"MULTI"}}, writes...)
+			writes = append(writes, []string{"EXEC"})
+		}
+		GetPropagationObj(ctx).Rewrite(writes...)
+	}
+
+	result := fmt.Sprintf("*%d\r\n%s", lenCommands, buffer.String())
+	conn.Write([]byte(result))
+	return
+}
+
+/*
+The MULTI command marks the start of a transaction block.
+*/
+type MultiCommand struct{}
+
+func (c *MultiCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	transactionsObj := transactions.GetTransactionsObj(ctx)
+
+	if conn, ok := conn.(net.Conn); ok {
+		transactionBufferObj := transactionsObj.GetTransactionBuffer(conn)
+		if transactionBufferObj == nil {
+			conn.Write([]byte(NoTransactionStateResp))
+			return
+		}
+
+		if transactionBufferObj.IsTransactionActive() {
+			conn.Write([]byte("-ERR MULTI calls can not be nested\r\n"))
+			return
+		}
+
+		transactionBufferObj.StartTransaction()
+	}
+
+	conn.Write([]byte("+OK\r\n"))
+}
+
+/*
+The INCR command increments the number stored at key by one.
+*/
+type IncrCommand struct{}
+
+func (c *IncrCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	incrBy(ctx, conn, args[1], 1)
+}
+
+/*
+The DECR command decrements the integer value of a key by one.
+*/
+type DecrCommand struct{}
+
+func (c *DecrCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	incrBy(ctx, conn, args[1], -1)
+}
+
+/*
+The INCRBY command increments the integer value of a key by the given amount.
+*/
+type IncrByCommand struct{}
+
+func (c *IncrByCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	delta, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", store.ErrNotInteger)))
+		return
+	}
+
+	incrBy(ctx, conn, args[1], delta)
+}
+
+/*
+The INCRBYFLOAT command increments the value of a key by a floating point
+number and replies with the new value as a bulk string.
+*/
+type IncrByFloatCommand struct{}
+
+func (c *IncrByFloatCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	delta, err := strconv.ParseFloat(args[2], 64)
+	if err != nil || math.IsNaN(delta) {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", store.ErrNotFloat)))
+		return
+	}
+
+	value, err := utils.GetStoreObj(ctx).IncrByFloat(args[1], delta)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	// replicas store the result rather than adding again, float addition
+	// could round differently there
+	GetPropagationObj(ctx).Rewrite([]string{"SET", args[1], value, "KEEPTTL"})
+
+	conn.Write([]byte(stringResp(value)))
+}
+
+/*
+The DECRBY command decrements the integer value of a key by the given amount.
+*/
+type DecrByCommand struct{}
+
+func (c *DecrByCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	delta, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", store.ErrNotInteger)))
+		return
+	}
+
+	// -MinInt64 does not fit in an int64
+	if delta == math.MinInt64 {
+		conn.Write([]byte("-ERR decrement would overflow\r\n"))
+		return
+	}
+
+	incrBy(ctx, conn, args[1], -delta)
+}
+
+/*
+The ECHO command returns a line of text to the client.
+*/
+type EchoCommand struct{}
+
+func (c *EchoCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	conn.Write([]byte(stringResp(args[1])))
+}
+
+/*
+The HELLO command switches the connection protocol and returns server information.
+*/
+type HelloCommand struct{}
+
+func (c *HelloCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	syncConn, ok := conn.(*clients.SyncConn)
+	if !ok {
+		return
+	}
+
+	protocol := syncConn.Protocol()
+
+	if len(args) > 1 {
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			conn.Write([]byte("-ERR Protocol version is not an integer or out of range\r\n"))
+			return
+		}
+
+		if version != 2 && version != 3 {
+			conn.Write([]byte("-NOPROTO unsupported protocol version\r\n"))
+			return
+		}
+
+		protocol = version
+	}
+
+	syncConn.SetProtocol(protocol)
+
+	var bb bytes.Buffer
+
+	if protocol == 3 {
+		bb.WriteString("%7\r\n")
+	} else {
+		bb.WriteString(arrayResp(14))
+	}
+
+	bb.WriteString(stringResp("server"))
+	bb.WriteString(stringResp("redis"))
+	bb.WriteString(stringResp("version"))
+	bb.WriteString(stringResp("7.2.0"))
+	bb.WriteString(stringResp("proto"))
+	bb.WriteString(integerResp(protocol))
+	bb.WriteString(stringResp("id"))
+	bb.WriteString(integerResp(int(syncConn.ID)))
+	bb.WriteString(stringResp("mode"))
+	bb.WriteString(stringResp("standalone"))
+	bb.WriteString(stringResp("role"))
+	bb.WriteString(stringResp(config.Role))
+	bb.WriteString(stringResp("modules"))
+	bb.WriteString(arrayResp(0))
+
+	conn.Write(bb.Bytes())
+}
+
+/*
+The CLIENT command is a container for client connection commands.
+*/
+type ClientCommand struct{}
+
+func (c *ClientCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	commands := map[string]CommandHandler{
+		"TRACKING": c.handleTracking,
+		"LIST":     c.handleList,
+		"INFO":     c.handleInfo,
+	}
+
+	if handler, exists := commands[strings.ToUpper(args[1])]; exists {
+		handler(ctx, conn, config, args)
+		return
+	}
+
+	conn.Write([]byte(unknownSubcommandResp(args[0], args[1])))
+}
+
+/*
+The PING command returns PONG.
+*/
+type PingCommand struct{}
+
+func (c *PingCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	switch config.Role {
+	case "master":
+		conn.Write([]byte("+PONG\r\n"))
+	}
+}
+
+/*
+The SELECT command changes the database used by the connection.
+*/
+type SelectCommand struct{}
+
+func (c *SelectCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	db, err := strconv.Atoi(args[1])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", store.ErrNotInteger)))
+		return
+	}
+
+	if _, ok := utils.GetDatabasesObj(ctx).Get(db); !ok {
+		conn.Write([]byte("-ERR DB index is out of range\r\n"))
+		return
+	}
+
+	if syncConn, ok := conn.(*clients.SyncConn); ok {
+		syncConn.SetDB(db)
+	}
+
+	conn.Write([]byte("+OK\r\n"))
+}
+
+/*
+The SET command sets the string value of a key.
+*/
+type SetCommand struct{}
+
+func (c *SetCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	key, value := args[1], args[2]
+
+	var px *int
+	var keepTTL bool
+	cond := store.SetAlways
+
+	for i := 3; i < len(args); i++ {
+		option := strings.ToUpper(args[i])
+		switch option {
+		case "NX", "XX":
+			if cond != store.SetAlways {
+				conn.Write([]byte("-ERR syntax error\r\n"))
+				return
+			}
+			cond = store.SetIfNotExists
+			if option == "XX" {
+				cond = store.SetIfExists
+			}
+		case "KEEPTTL":
+			if px != nil || keepTTL {
+				conn.Write([]byte("-ERR syntax error\r\n"))
+				return
+			}
+			keepTTL = true
+		case "PX", "EX":
+			if px != nil || keepTTL || i+1 >= len(args) {
+				conn.Write([]byte("-ERR syntax error\r\n"))
+				return
+			}
+			parsed, err := parseExpiry(args[0], option, args[i+1])
+			if err != nil {
+				conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+				return
+			}
+			px = &parsed
+			i++
+		default:
+			conn.Write([]byte("-ERR syntax error\r\n"))
+			return
+		}
+	}
+
+	var expiredAt *time.Time
+	if px != nil {
+		t := time.Now().Add(time.Duration(*px) * time.Millisecond)
+		expiredAt = &t
+	}
+
+	storeFromContext := ctx.Value("store")
+
+	if storeFromContext != nil {
+		if store, ok := storeFromContext.(*store.Store); !ok {
+			log.Fatalf("Expected *store.Store, got %T", storeFromContext)
+		} else if !store.SetIf(key, value, expiredAt, keepTTL, cond) {
+			if config.Role == "master" {
+				conn.Write([]byte("$-1\r\n"))
+			}
+			return
+		}
+
+		if expiredAt != nil {
+			GetPropagationObj(ctx).Rewrite([]string{args[0], key, value}, pexpireAtArgs(key, *expiredAt))
+		} else {
+			GetPropagationObj(ctx).Changed()
+		}
+	}
+
+	switch config.Role {
+	case "master":
+		conn.Write([]byte("+OK\r\n"))
+	}
+}
+
+/*
+The SETNX command sets a key only when it does not exist yet.
+*/
+type SetNxCommand struct{}
+
+func (c *SetNxCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	if utils.GetStoreObj(ctx).SetIf(args[1], args[2], nil, false, store.SetIfNotExists) {
+		GetPropagationObj(ctx).Changed()
+		conn.Write([]byte(integerResp(1)))
+		return
+	}
+
+	conn.Write([]byte(integerResp(0)))
+}
+
+/*
+The GET command returns the value associated with a key.
+*/
+type GetCommand struct{}
+
+func (c *GetCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	key := args[1]
+
+	storeObj := utils.GetStoreObj(ctx)
+
+	value, err := storeObj.Get(key)
+	switch {
+	case err == nil:
+		storeObj.Stats.Hits.Add(1)
+		conn.Write([]byte(stringResp(value)))
+	case errors.Is(err, store.ErrNotFound), errors.Is(err, store.ErrExpired):
+		storeObj.Stats.Misses.Add(1)
+		conn.Write([]byte("$-1\r\n"))
+	default:
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+	}
+}
+
+/*
+The GETDEL command returns the value of a key and deletes it atomically.
+*/
+type GetDelCommand struct{}
+
+func (c *GetDelCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	storeObj := utils.GetStoreObj(ctx)
+
+	value, ok, err := storeObj.GetDel(args[1])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	if !ok {
+		conn.Write([]byte("$-1\r\n"))
+		return
+	}
+
+	GetPropagationObj(ctx).Changed()
+
+	conn.Write([]byte(stringResp(value)))
+}
+
+/*
+The GETRANGE command returns the substring of the string value stored at key,
+determined by the inclusive offsets start and end.
+*/
+type GetRangeCommand struct{}
+
+func (c *GetRangeCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 4 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	start, startErr := strconv.Atoi(args[2])
+	end, endErr := strconv.Atoi(args[3])
+	if startErr != nil || endErr != nil {
+		conn.Write([]byte("-ERR value is not an integer or out of range\r\n"))
+		return
+	}
+
+	storeObj := utils.GetStoreObj(ctx)
+
+	value, err := storeObj.Get(args[1])
+	if errors.Is(err, store.ErrWrongType) {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	start, end, ok := store.NormalizeRange(start, end, len(value))
+	if !ok {
+		conn.Write([]byte(stringResp("")))
+		return
+	}
+
+	conn.Write([]byte(stringResp(value[start : end+1])))
+}
+
+/*
+The SETRANGE command overwrites part of a string starting at offset and
+returns the length of the resulting string.
+*/
+type SetRangeCommand struct{}
+
+func (c *SetRangeCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 4 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	offset, err := strconv.Atoi(args[2])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", store.ErrNotInteger)))
+		return
+	}
+
+	if offset < 0 {
+		conn.Write([]byte("-ERR offset is out of range\r\n"))
+		return
+	}
+
+	length, err := utils.GetStoreObj(ctx).SetRange(args[1], offset, args[3])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	// an empty value leaves the string as it was
+	if args[3] != "" {
+		GetPropagationObj(ctx).Changed()
+	}
+
+	conn.Write([]byte(integerResp(length)))
+}
+
+/*
+The GETEX command returns the value of a key and optionally updates its
+expiration with EX, PX or PERSIST.
+*/
+type GetExCommand struct{}
+
+func (c *GetExCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	var expiredAt *time.Time
+	var persist bool
+
+	switch {
+	case len(args) == 2:
+	case len(args) == 3 && strings.ToUpper(args[2]) == "PERSIST":
+		persist = true
+	case len(args) == 4 && (strings.ToUpper(args[2]) == "EX" || strings.ToUpper(args[2]) == "PX"):
+		px, err := parseExpiry(args[0], args[2], args[3])
+		if err != nil {
+			conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+			return
+		}
+		at := time.Now().Add(time.Duration(px) * time.Millisecond)
+		expiredAt = &at
+	default:
+		conn.Write([]byte("-ERR syntax error\r\n"))
+		return
+	}
+
+	value, changed, err := utils.GetStoreObj(ctx).GetEx(args[1], expiredAt, persist)
+	if errors.Is(err, store.ErrWrongType) {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+	if err != nil {
+		conn.Write([]byte("$-1\r\n"))
+		return
+	}
+
+	switch {
+	case changed && expiredAt != nil:
+		GetPropagationObj(ctx).Rewrite(pexpireAtArgs(args[1], *expiredAt))
+	case changed:
+		GetPropagationObj(ctx).Rewrite([]string{"PERSIST", args[1]})
+	}
+
+	conn.Write([]byte(stringResp(value)))
+}
+
+/*
+The APPEND command appends a value to a string key and returns its new length.
+*/
+type AppendCommand struct{}
+
+func (c *AppendCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	storeObj := utils.GetStoreObj(ctx)
+
+	length, err := storeObj.Append(args[1], args[2])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	GetPropagationObj(ctx).Changed()
+
+	conn.Write([]byte(integerResp(length)))
+}
+
+/*
+The MSET command sets the given keys to their respective values atomically.
+*/
+type MSetCommand struct{}
+
+func (c *MSetCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 3 || len(args)%2 == 0 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	pairs := make(map[string]string, (len(args)-1)/2)
+	for i := 1; i < len(args); i += 2 {
+		pairs[args[i]] = args[i+1]
+	}
+
+	utils.GetStoreObj(ctx).MSet(pairs)
+	GetPropagationObj(ctx).Changed()
+
+	conn.Write([]byte("+OK\r\n"))
+}
+
+/*
+The MGET command returns the values of all given keys. Keys that do not
+exist or do not hold a string are reported as nil.
+*/
+type MGetCommand struct{}
+
+func (c *MGetCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	values := utils.GetStoreObj(ctx).MGet(args[1:])
+
+	var bb bytes.Buffer
+
+	bb.WriteString(arrayResp(len(values)))
+
+	for _, value := range values {
+		if value == nil {
+			bb.WriteString("$-1\r\n")
+			continue
+		}
+		bb.WriteString(stringResp(*value))
+	}
+
+	conn.Write(bb.Bytes())
+}
+
+/*
+The DEL command removes the specified keys. Large values are freed in the
+background when lazyfree-lazy-user-del is set.
+*/
+type DelCommand struct{}
+
+func (c *DelCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	del(ctx, conn, args, false)
+}
+
+/*
+The UNLINK command removes the specified keys like DEL, always leaving
+large values to be freed in the background.
+*/
+type UnlinkCommand struct{}
+
+func (c *UnlinkCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	del(ctx, conn, args, true)
+}
+
+/*
+The EXISTS command returns the number of the given keys that exist.
+*/
+type ExistsCommand struct{}
+
+func (c *ExistsCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	storeObj := utils.GetStoreObj(ctx)
+
+	var existing int
+
+	for _, key := range args[1:] {
+		if storeObj.Exists(key) {
+			existing++
+		}
+	}
+
+	conn.Write([]byte(integerResp(existing)))
+}
+
+/*
+The INFO command returns information and statistics about the server.
+Without a section, or with all, default or everything, it returns every
+section.
+*/
+type InfoCommand struct{}
+
+/*
+infoSections lists the sections INFO knows in the order it reports them.
+*/
+var infoSections = []string{"replication", "stats", "memory", "keyspace"}
+
+func (c *InfoCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	sections := args[1:]
+	if len(sections) == 0 {
+		sections = infoSections
+	}
+
+	var builder strings.Builder
+
+	for _, section := range sections {
+		switch strings.ToLower(section) {
+		case "all", "default", "everything":
+			for _, name := range infoSections {
+				c.writeSection(ctx, &builder, config, name)
+			}
+		default:
+			c.writeSection(ctx, &builder, config, strings.ToLower(section))
+		}
+	}
+
+	// Redis answers unknown sections with an empty reply
+	conn.Write([]byte(stringResp(builder.String())))
+}
+
+/*
+writeSection appends one section to builder, separated from the previous
+one by an empty line. Unknown sections are skipped.
+*/
+func (c *InfoCommand) writeSection(
+	ctx context.Context,
+	builder *strings.Builder,
+	config config.Config,
+	section string,
+) {
+	var body string
+
+	switch section {
+	case "replication":
+		body = c.replication(config)
+	case "stats":
+		body = c.stats(ctx)
+	case "memory":
+		body = c.memory(ctx, config)
+	case "keyspace":
+		body = c.keyspace(ctx)
+	default:
+		return
+	}
+
+	if builder.Len() > 0 {
+		builder.WriteString("\r\n")
+	}
+	builder.WriteString(body)
+}
+
+func (c *InfoCommand) replication(config config.Config) string {
+	var builder strings.Builder
+	builder.Grow(128)
+
+	builder.WriteString("# Replication\r\n")
+	builder.WriteString(fmt.Sprintf("role:%s\r\n", config.Role))
+
+	switch config.Role {
+	case "master":
+		builder.WriteString(fmt.Sprintf("master_replid:%s\r\n", config.Master.MasterReplId))
+		builder.WriteString(
+			fmt.Sprintf("master_repl_offset:%d\r\n", config.Master.MasterReplOffset.Load()),
+		)
+	case "slave":
+		linkStatus := "down"
+		if config.Slave.LinkUp.Load() {
+			linkStatus = "up"
+		}
+
+		syncInProgress := 0
+		if config.Slave.SyncInProgress.Load() {
+			syncInProgress = 1
+		}
+
+		builder.WriteString(fmt.Sprintf("master_host:%s\r\n", config.Slave.MasterHost))
+		builder.WriteString(fmt.Sprintf("master_port:%s\r\n", config.Slave.MasterPort))
+		builder.WriteString(fmt.Sprintf("master_link_status:%s\r\n", linkStatus))
+		builder.WriteString(fmt.Sprintf("master_sync_in_progress:%d\r\n", syncInProgress))
+		builder.WriteString(
+			fmt.Sprintf("slave_repl_offset:%d\r\n", config.Slave.Offset.Load()),
+		)
+	}
+
+	return builder.String()
+}
+
+func (c *InfoCommand) stats(ctx context.Context) string {
+	var hits, misses int64
+	for _, db := range utils.GetDatabasesObj(ctx).All() {
+		hits += db.Stats.Hits.Load()
+		misses += db.Stats.Misses.Load()
+	}
+
+	var builder strings.Builder
+
+	builder.WriteString("# Stats\r\n")
+	builder.WriteString(fmt.Sprintf("keyspace_hits:%d\r\n", hits))
+	builder.WriteString(fmt.Sprintf("keyspace_misses:%d\r\n", misses))
+
+	return builder.String()
+}
+
+func (c *InfoCommand) memory(ctx context.Context, config config.Config) string {
+	var usedMemory, lazyFreePending, lazyFreed int64
+	for _, db := range utils.GetDatabasesObj(ctx).All() {
+		usedMemory += db.UsedMemory()
+
+		pending, freed := db.LazyFreeStats()
+		lazyFreePending += pending
+		lazyFreed += freed
+	}
+
+	var builder strings.Builder
+
+	builder.WriteString("# Memory\r\n")
+	builder.WriteString(fmt.Sprintf("used_memory:%d\r\n", usedMemory))
+	builder.WriteString(fmt.Sprintf("used_memory_human:%s\r\n", bytesToHuman(usedMemory)))
+	builder.WriteString(fmt.Sprintf("maxmemory:%d\r\n", config.MaxMemory))
+	builder.WriteString(fmt.Sprintf("maxmemory_human:%s\r\n", bytesToHuman(config.MaxMemory)))
+	builder.WriteString(fmt.Sprintf("maxmemory_policy:%s\r\n", config.MaxMemoryPolicy))
+	builder.WriteString(fmt.Sprintf("lazyfree_pending_objects:%d\r\n", lazyFreePending))
+	builder.WriteString(fmt.Sprintf("lazyfreed_objects:%d\r\n", lazyFreed))
+
+	return builder.String()
+}
+
+func (c *InfoCommand) keyspace(ctx context.Context) string {
+	var builder strings.Builder
+
+	builder.WriteString("# Keyspace\r\n")
+
+	for i, db := range utils.GetDatabasesObj(ctx).All() {
+		keys, expires := db.Counts()
+		if keys == 0 {
+			continue
+		}
+
+		builder.WriteString(fmt.Sprintf("db%d:keys=%d,expires=%d,avg_ttl=0\r\n", i, keys, expires))
+	}
+
+	return builder.String()
+}
+
+/*
+The REPLCONF command sets the configuration of the replication link.
+*/
+type ReplConfCommand struct{}
+
+func (c *ReplConfCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	commands := map[string]CommandHandler{
+		"master": c.handleMaster,
+		"slave":  c.handleSlave,
+	}
+
+	if handler, exists := commands[config.Role]; exists {
+		handler(ctx, conn, config, args)
+	}
+}
+
+/*
+The PSYNC command is used to synchronize replication.
+*/
+type PsyncCommand struct{}
+
+func (c *PsyncCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	replica, isReplica := conn.(net.Conn)
+	clientsObj := utils.GetClientsObj(ctx)
+	register := isReplica && clientsObj != nil
+
+	// the snapshot, the offset it is at and the replica joining the stream
+	// must agree, so no write is propagated in between
+	config.Master.Propagation.Lock()
+	snapshots := utils.SnapshotDatabases(ctx)
+	data := fmt.Sprintf(
+		"+FULLRESYNC %s %d\r\n",
+		config.Master.MasterReplId,
+		config.Master.MasterReplOffset.Load(),
+	)
+	// the new replica starts in DB 0, force a SELECT before the next write
+	config.Master.SelectedDB.Store(-1)
+	if register {
+		clientsObj.StartSync(replica)
+	}
+	config.Master.Propagation.Unlock()
+
+	abort := func(err error) {
+		log.WithFields(log.Fields{
+			"package":  "commands",
+			"function": "PsyncCommand.Execute",
+		}).Error("Error sending the snapshot: ", err)
+
+		if register {
+			clientsObj.AbortSync(replica)
+		}
+	}
+
+	size, rdb, err := utils.NewRDBReader(snapshots)
+	if err != nil {
+		abort(err)
+		conn.Write([]byte(fmt.Sprintf("-ERR %s\r\n", err)))
+		return
+	}
+	defer rdb.Close()
+
+	data += fmt.Sprintf("$%d\r\n", size)
+
+	if _, err := conn.Write([]byte(data)); err != nil {
+		abort(err)
+		return
+	}
+
+	if _, err := io.Copy(conn, rdb); err != nil {
+		abort(err)
+		return
+	}
+
+	if !register {
+		return
+	}
+
+	// the writes made during the transfer follow the snapshot, then the
+	// replica gets the stream directly
+	config.Master.Propagation.Lock()
+	defer config.Master.Propagation.Unlock()
+
+	if err := clientsObj.FinishSync(replica); err != nil {
+		abort(err)
+	}
+}
+
+/*
+The WAIT command is used to wait for replication.
+*/
+type WaitCommand struct{}
+
+func (c *WaitCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	goal, err := strconv.Atoi(args[1])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", store.ErrNotInteger)))
+		return
+	}
+
+	timer, err := strconv.Atoi(args[2])
+	if err != nil {
+		conn.Write([]byte("-ERR timeout is not an integer or out of range\r\n"))
+		return
+	}
+
+	if timer < 0 {
+		conn.Write([]byte("-ERR timeout is negative\r\n"))
+		return
+	}
+
+	clientsObj := utils.GetClientsObj(ctx)
+
+	acked := clientsObj.WaitForAcks(
+		goal,
+		time.Duration(timer)*time.Millisecond,
+		config.Master.MasterReplOffset.Load(),
+	)
+
+	if _, err := conn.Write([]byte(integerResp(acked))); err != nil {
+		log.WithFields(log.Fields{
+			"package":  "commands",
+			"function": "WaitCommand.Execute",
+			"error":    err,
+		}).Error("Error writing to connection")
+	}
+}
+
+/*
+The CONFIG command is used to get or set configuration parameters.
+*/
+type ConfigCommand struct{}
+
+func (c *ConfigCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	commands := map[string]CommandHandler{
+		"GET": c.handleGet,
+	}
+
+	if handler, exists := commands[strings.ToUpper(args[1])]; exists {
+		handler(ctx, conn, config, args)
+		return
+	}
+
+	conn.Write([]byte(unknownSubcommandResp(args[0], args[1])))
+}
+
+/*
+The COMMAND command returns information about the supported commands.
+*/
+type CommandCommand struct{}
+
+func (c *CommandCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	// a bare COMMAND describes every command, like COMMAND INFO
+	if len(args) < 2 {
+		c.handleInfo(ctx, conn, config, args)
+		return
+	}
+
+	commands := map[string]CommandHandler{
+		"COUNT": c.handleCount,
+		"DOCS":  c.handleDocs,
+		"INFO":  c.handleInfo,
+	}
+
+	if handler, exists := commands[strings.ToUpper(args[1])]; exists {
+		handler(ctx, conn, config, args)
+		return
+	}
+
+	conn.Write([]byte(unknownSubcommandResp(args[0], args[1])))
+}
+
+/*
+The DEBUG command is a container for debugging and testing subcommands.
+*/
+type DebugCommand struct{}
+
+func (c *DebugCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	commands := map[string]CommandHandler{
+		"QUICKLIST-PACKED-THRESHOLD": c.handleQuicklistPackedThreshold,
+		"STRINGMATCH-LEN":            c.handleStringMatchLen,
+		"OBJECT":                     c.handleObject,
+		"RELOAD":                     c.handleReload,
+	}
+
+	if handler, exists := commands[strings.ToUpper(args[1])]; exists {
+		handler(ctx, conn, config, args)
+		return
+	}
+
+	conn.Write([]byte(unknownSubcommandResp(args[0], args[1])))
+}
+
+/*
+The KEYS command returns all keys that match the given pattern.
+*/
+type KeysCommand struct{}
+
+func (c *KeysCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	c.handlePattern(ctx, conn, config, args)
+}
+
+/*
+The SCAN command incrementally iterates over the keyspace. The cursor is an
+offset into the sorted key names; a cursor past the end yields an empty page
+with cursor 0.
+*/
+type ScanCommand struct{}
+
+func (c *ScanCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	c.handleScan(ctx, conn, config, args)
+}
+
+/*
+The SADD command adds members to a set.
+*/
+type SAddCommand struct{}
+
+func (c *SAddCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	added, err := utils.GetStoreObj(ctx).SAdd(args[1], args[2:])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	if added > 0 {
+		GetPropagationObj(ctx).Changed()
+	}
+
+	conn.Write([]byte(integerResp(added)))
+}
+
+/*
+The SREM command removes members from a set.
+*/
+type SRemCommand struct{}
+
+func (c *SRemCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	removed, err := utils.GetStoreObj(ctx).SRem(args[1], args[2:])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	if removed > 0 {
+		GetPropagationObj(ctx).Changed()
+	}
+
+	conn.Write([]byte(integerResp(removed)))
+}
+
+/*
+The SCARD command returns the number of members in a set.
+*/
+type SCardCommand struct{}
+
+func (c *SCardCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	cardinality, err := utils.GetStoreObj(ctx).SCard(args[1])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	conn.Write([]byte(integerResp(cardinality)))
+}
+
+/*
+The SISMEMBER command determines whether a member belongs to a set.
+*/
+type SIsMemberCommand struct{}
+
+func (c *SIsMemberCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	exists, err := utils.GetStoreObj(ctx).SIsMember(args[1], args[2])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	if !exists {
+		conn.Write([]byte(integerResp(0)))
+		return
+	}
+
+	conn.Write([]byte(integerResp(1)))
+}
+
+/*
+The SMEMBERS command returns all members of a set.
+*/
+type SMembersCommand struct{}
+
+func (c *SMembersCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	members, err := utils.GetStoreObj(ctx).SMembers(args[1])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	writeStrings(conn, members)
+}
+
+/*
+The SINTER command returns the intersection of sets.
+*/
+type SInterCommand struct{}
+
+func (c *SInterCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	setOp(ctx, conn, args, store.SetInter)
+}
+
+/*
+The SUNION command returns the union of sets.
+*/
+type SUnionCommand struct{}
+
+func (c *SUnionCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	setOp(ctx, conn, args, store.SetUnion)
+}
+
+/*
+The SDIFF command returns the difference of sets.
+*/
+type SDiffCommand struct{}
+
+func (c *SDiffCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	setOp(ctx, conn, args, store.SetDiff)
+}
+
+/*
+The SINTERSTORE command stores the intersection of sets in a key.
+*/
+type SInterStoreCommand struct{}
+
+func (c *SInterStoreCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	setOpStore(ctx, conn, args, store.SetInter)
+}
+
+/*
+The SUNIONSTORE command stores the union of sets in a key.
+*/
+type SUnionStoreCommand struct{}
 
-		conn.Write([]byte(finalResult))
+func (c *SUnionStoreCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	setOpStore(ctx, conn, args, store.SetUnion)
+}
 
-	default:
-		conn.Write([]byte("-Error\r\n"))
-	}
+/*
+The SDIFFSTORE command stores the difference of sets in a key.
+*/
+type SDiffStoreCommand struct{}
+
+func (c *SDiffStoreCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	setOpStore(ctx, conn, args, store.SetDiff)
 }
 
 /*
-The REPLCONF command sets the configuration of the replication link.
+The ZADD command adds members to a sorted set, or updates their scores.
 */
-type ReplConfCommand struct{}
+type ZAddCommand struct{}
 
-func (c *ReplConfCommand) Execute(
+func (c *ZAddCommand) Execute(
 	ctx context.Context,
 	conn io.Writer,
 	config config.Config,
 	args []string,
 ) {
-	commands := map[string]CommandHandler{
-		"master": c.handleMaster,
-		"slave":  c.handleSlave,
+	if len(args) < 4 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
 	}
 
-	if handler, exists := commands[config.Role]; exists {
-		handler(ctx, conn, config, args)
+	if len(args)%2 != 0 {
+		conn.Write([]byte("-ERR syntax error\r\n"))
+		return
+	}
+
+	members := make([]store.ZMember, 0, (len(args)-2)/2)
+
+	for i := 2; i < len(args); i += 2 {
+		score, err := strconv.ParseFloat(args[i], 64)
+		if err != nil || math.IsNaN(score) {
+			conn.Write([]byte(fmt.Sprintf("-%s\r\n", store.ErrNotFloat)))
+			return
+		}
+
+		members = append(members, store.ZMember{Member: args[i+1], Score: score})
+	}
+
+	added, updated, err := utils.GetStoreObj(ctx).ZAdd(args[1], members)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	if added > 0 || updated > 0 {
+		GetPropagationObj(ctx).Changed()
 	}
+
+	conn.Write([]byte(integerResp(added)))
 }
 
 /*
-The PSYNC command is used to synchronize replication.
+The ZREM command removes members from a sorted set.
 */
-type PsyncCommand struct{}
+type ZRemCommand struct{}
 
-func (c *PsyncCommand) Execute(
+func (c *ZRemCommand) Execute(
 	ctx context.Context,
 	conn io.Writer,
 	config config.Config,
 	args []string,
 ) {
-	data := fmt.Sprintf(
-		"+FULLRESYNC %s %d\r\n",
-		config.Master.MasterReplId,
-		config.Master.MasterReplOffset.Load(),
-	)
-	emptyRDB, _ := hex.DecodeString(redis.EMPTYRDBSTORE)
-	data += fmt.Sprintf("$%d\r\n%s", len(emptyRDB), emptyRDB)
+	if len(args) < 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
 
-	_, err := conn.Write([]byte(data))
+	removed, err := utils.GetStoreObj(ctx).ZRem(args[1], args[2:])
 	if err != nil {
-		fmt.Println("Error writing", err)
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
 	}
 
-	clientsFromContext := ctx.Value("clients")
-	if clientsFromContext != nil {
-		if clients, ok := clientsFromContext.(*clients.Clients); !ok {
-			log.Fatalf("Expected *master.Clients, got %T", clientsFromContext)
-		} else {
-			if conn, ok := conn.(net.Conn); ok {
-				clients.Set(conn)
-			}
-		}
+	if removed > 0 {
+		GetPropagationObj(ctx).Changed()
 	}
+
+	conn.Write([]byte(integerResp(removed)))
 }
 
 /*
-The WAIT command is used to wait for replication.
+The ZCARD command returns the number of members in a sorted set.
 */
-type WaitCommand struct{}
+type ZCardCommand struct{}
 
-func (c *WaitCommand) Execute(
+func (c *ZCardCommand) Execute(
 	ctx context.Context,
 	conn io.Writer,
 	config config.Config,
 	args []string,
 ) {
-	if len(args) < 3 {
-		fmt.Println("Not enough arguments")
+	if len(args) != 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
 		return
 	}
 
-	goal, err := strconv.Atoi(args[1])
+	count, err := utils.GetStoreObj(ctx).ZCard(args[1])
 	if err != nil {
-		fmt.Println("Error converting goal:", err)
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
 		return
 	}
 
-	timer, err := strconv.Atoi(args[2])
+	conn.Write([]byte(integerResp(count)))
+}
+
+/*
+The ZINCRBY command increments the score of a member in a sorted set and
+replies with the new score.
+*/
+type ZIncrByCommand struct{}
+
+func (c *ZIncrByCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 4 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	delta, err := strconv.ParseFloat(args[2], 64)
+	if err != nil || math.IsNaN(delta) {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", store.ErrNotFloat)))
+		return
+	}
+
+	score, err := utils.GetStoreObj(ctx).ZIncrBy(args[1], args[3], delta)
 	if err != nil {
-		fmt.Println("Erro converting timer:", err)
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
 		return
 	}
-	timerCh := time.After(time.Duration(timer) * time.Millisecond)
 
-	clientsObj := utils.GetClientsObj(ctx)
+	GetPropagationObj(ctx).Changed()
 
-	done := make(chan int, 1)
+	conn.Write([]byte(stringResp(formatScore(score))))
+}
 
-	var counter int64
+/*
+The ZSCORE command returns the score of a member in a sorted set.
+*/
+type ZScoreCommand struct{}
 
-	if config.Master.MasterReplOffset.Load() == 0 {
-		done <- len(clientsObj.Clients)
-	} else {
+func (c *ZScoreCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
 
-		cmdReplConf := redis.ConvertToRESP([]string{"REPLCONF", "GETACK", "*"})
+	score, ok, err := utils.GetStoreObj(ctx).ZScore(args[1], args[2])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
 
-		for _, client := range clientsObj.GetAll() {
-			client.Write([]byte(cmdReplConf))
-		}
+	if !ok {
+		conn.Write([]byte("$-1\r\n"))
+		return
+	}
 
-		clientsObj.Subscribe(func(conn net.Conn, clientOffset int) {
-			masterOffset := config.Master.MasterReplOffset.Load()
-			log.WithFields(log.Fields{
-				"package":      "commands",
-				"function":     "WaitCommand.Execute",
-				"masterOffset": masterOffset,
-				"clientOffset": clientOffset,
-				"conn":         conn,
-			}).Info("Notification alert")
+	conn.Write([]byte(stringResp(formatScore(score))))
+}
 
-			if masterOffset <= int64(clientOffset) {
-				atomic.AddInt64(&counter, 1)
+/*
+The ZRANK command returns the index of a member in a sorted set ordered by
+ascending scores.
+*/
+type ZRankCommand struct{}
 
-				log.WithFields(log.Fields{
-					"package":  "commands",
-					"function": "WaitCommand.Execute",
-					"value":    int(atomic.LoadInt64(&counter)),
-					"goal":     goal,
-				}).Info("Changing counter of acked clients")
+func (c *ZRankCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
 
-				if goal == int(atomic.LoadInt64(&counter)) {
-					done <- int(atomic.LoadInt64(&counter))
-				}
-			}
-		})
+	rank, ok, err := utils.GetStoreObj(ctx).ZRank(args[1], args[2])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
 	}
 
-	writeMessage := func(c int) {
-		message := fmt.Sprintf(":%d\r\n", c)
-		if _, err := conn.Write([]byte(message)); err != nil {
-			log.WithFields(log.Fields{
-				"package":  "commands",
-				"function": "WaitCommand.Execute",
-				"error":    err,
-			}).Error("Error writing to connection")
-		}
+	if !ok {
+		conn.Write([]byte("$-1\r\n"))
+		return
 	}
 
-	for {
-		select {
-		case c := <-done:
+	conn.Write([]byte(integerResp(rank)))
+}
 
-			log.WithFields(log.Fields{
-				"package":  "commands",
-				"function": "WaitCommand.Execute",
-				"value":    c,
-			}).Info("Returning")
-			writeMessage(c)
+/*
+The ZRANGEBYSCORE command returns the members of a sorted set within a
+range of scores.
+*/
+type ZRangeByScoreCommand struct{}
 
+func (c *ZRangeByScoreCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 4 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	var r store.ZScoreRange
+	var err error
+
+	if r.Min, r.MinExclusive, err = parseScoreBound(args[2]); err == nil {
+		r.Max, r.MaxExclusive, err = parseScoreBound(args[3])
+	}
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	var withScores bool
+
+	for _, arg := range args[4:] {
+		if strings.ToUpper(arg) != "WITHSCORES" {
+			conn.Write([]byte("-ERR syntax error\r\n"))
 			return
-		case <-timerCh:
-			writeMessage(int(atomic.LoadInt64(&counter)))
-
-			log.WithFields(log.Fields{
-				"package":  "commands",
-				"function": "WaitCommand.Execute",
-				"value":    atomic.LoadInt64(&counter),
-				"goal":     goal,
-			}).Info("Time is up!")
-			return
+		}
+		withScores = true
+	}
+
+	members, err := utils.GetStoreObj(ctx).ZRangeByScore(args[1], r)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	var bb bytes.Buffer
+
+	if withScores {
+		bb.WriteString(arrayResp(2 * len(members)))
+	} else {
+		bb.WriteString(arrayResp(len(members)))
+	}
+
+	for _, m := range members {
+		bb.WriteString(stringResp(m.Member))
+		if withScores {
+			bb.WriteString(stringResp(formatScore(m.Score)))
 		}
 	}
+
+	conn.Write(bb.Bytes())
 }
 
 /*
-The CONFIG command is used to get or set configuration parameters.
+The ZUNIONSTORE command stores the union of sorted sets in a key.
 */
-type ConfigCommand struct{}
+type ZUnionStoreCommand struct{}
 
-func (c *ConfigCommand) Execute(
+func (c *ZUnionStoreCommand) Execute(
 	ctx context.Context,
 	conn io.Writer,
 	config config.Config,
 	args []string,
 ) {
-	if len(args) < 3 {
-		log.Error("Missing arguments")
+	zSetOpStore(ctx, conn, args, store.SetUnion)
+}
+
+/*
+The ZINTERSTORE command stores the intersection of sorted sets in a key.
+*/
+type ZInterStoreCommand struct{}
+
+func (c *ZInterStoreCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	zSetOpStore(ctx, conn, args, store.SetInter)
+}
+
+/*
+The SUBSCRIBE command subscribes the connection to the given channels.
+*/
+type SubscribeCommand struct{}
+
+func (c *SubscribeCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
 		return
 	}
-	commands := map[string]CommandHandler{
-		"GET": c.handleGet,
+
+	syncConn, ok := conn.(*clients.SyncConn)
+	if !ok {
+		return
 	}
 
-	if handler, exists := commands[args[1]]; exists {
-		handler(ctx, conn, config, args)
+	pubSub := utils.GetPubSubObj(ctx)
+
+	var bb bytes.Buffer
+
+	for _, channel := range args[1:] {
+		count := pubSub.Subscribe(syncConn, channel)
+		bb.WriteString(subscriptionResp(syncConn.Protocol(), "subscribe", &channel, count))
 	}
+
+	conn.Write(bb.Bytes())
 }
 
 /*
-The KEYS command returns all keys that match the given pattern.
+The UNSUBSCRIBE command unsubscribes the connection from the given channels,
+or from all of its channels when none are given.
 */
-type KeysCommand struct{}
+type UnsubscribeCommand struct{}
 
-func (c *KeysCommand) Execute(
+func (c *UnsubscribeCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	syncConn, ok := conn.(*clients.SyncConn)
+	if !ok {
+		return
+	}
+
+	pubSub := utils.GetPubSubObj(ctx)
+
+	channels := args[1:]
+	if len(channels) == 0 {
+		channels = pubSub.Channels(syncConn)
+	}
+
+	if len(channels) == 0 {
+		conn.Write([]byte(subscriptionResp(syncConn.Protocol(), "unsubscribe", nil, pubSub.Count(syncConn))))
+		return
+	}
+
+	var bb bytes.Buffer
+
+	for _, channel := range channels {
+		count := pubSub.Unsubscribe(syncConn, channel)
+		bb.WriteString(subscriptionResp(syncConn.Protocol(), "unsubscribe", &channel, count))
+	}
+
+	conn.Write(bb.Bytes())
+}
+
+/*
+The PSUBSCRIBE command subscribes the connection to the given glob patterns.
+*/
+type PSubscribeCommand struct{}
+
+func (c *PSubscribeCommand) Execute(
 	ctx context.Context,
 	conn io.Writer,
 	config config.Config,
 	args []string,
 ) {
 	if len(args) < 2 {
-		log.Error("Missing arguments")
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
 		return
 	}
 
-	commands := map[string]CommandHandler{
-		"*": c.handleAll,
+	syncConn, ok := conn.(*clients.SyncConn)
+	if !ok {
+		return
 	}
 
-	if handler, exists := commands[args[1]]; exists {
-		handler(ctx, conn, config, args)
+	pubSub := utils.GetPubSubObj(ctx)
+
+	var bb bytes.Buffer
+
+	for _, pattern := range args[1:] {
+		count := pubSub.PSubscribe(syncConn, pattern)
+		bb.WriteString(subscriptionResp(syncConn.Protocol(), "psubscribe", &pattern, count))
+	}
+
+	conn.Write(bb.Bytes())
+}
+
+/*
+The PUNSUBSCRIBE command unsubscribes the connection from the given patterns,
+or from all of its patterns when none are given.
+*/
+type PUnsubscribeCommand struct{}
+
+func (c *PUnsubscribeCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	syncConn, ok := conn.(*clients.SyncConn)
+	if !ok {
+		return
+	}
+
+	pubSub := utils.GetPubSubObj(ctx)
+
+	patterns := args[1:]
+	if len(patterns) == 0 {
+		patterns = pubSub.Patterns(syncConn)
+	}
+
+	if len(patterns) == 0 {
+		conn.Write([]byte(subscriptionResp(syncConn.Protocol(), "punsubscribe", nil, pubSub.Count(syncConn))))
+		return
+	}
+
+	var bb bytes.Buffer
+
+	for _, pattern := range patterns {
+		count := pubSub.PUnsubscribe(syncConn, pattern)
+		bb.WriteString(subscriptionResp(syncConn.Protocol(), "punsubscribe", &pattern, count))
 	}
+
+	conn.Write(bb.Bytes())
+}
+
+/*
+The PUBLISH command posts a message to a channel and returns the number of
+clients that received it.
+*/
+type PublishCommand struct{}
+
+func (c *PublishCommand) Execute(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	receivers := utils.GetPubSubObj(ctx).Publish(args[1], args[2])
+
+	conn.Write([]byte(integerResp(receivers)))
 }
@@ -0,0 +1,1385 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/clients"
+	"github.com/codecrafters-io/redis-starter-go/internal/store"
+	"github.com/codecrafters-io/redis-starter-go/internal/utils"
+)
+
+func TestXReadWithoutEntriesRepliesNullArray(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "XADD", "s", "1-1", "f", "v")
+
+	for _, args := range [][]string{
+		{"XREAD", "STREAMS", "s", "1-1"},
+		{"XREAD", "STREAMS", "missing", "0-0"},
+		{"XREAD", "BLOCK", "10", "STREAMS", "s", "$"},
+	} {
+		if got := execute(ctx, args...); got != "*-1\r\n" {
+			t.Fatalf("%v = %q, want *-1", args, got)
+		}
+	}
+}
+
+func TestHExpireAndHTTL(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "HSET", "h", "f1", "v1", "f2", "v2", "f3", "v3")
+
+	assertReply(t, ctx, "*2\r\n:1\r\n:-2\r\n", "HEXPIRE", "h", "100", "FIELDS", "2", "f1", "missing")
+	assertReply(t, ctx, "*3\r\n:100\r\n:-1\r\n:-2\r\n", "HTTL", "h", "FIELDS", "3", "f1", "f2", "missing")
+
+	// a non-positive TTL deletes the field right away
+	assertReply(t, ctx, "*1\r\n:2\r\n", "HEXPIRE", "h", "0", "FIELDS", "1", "f3")
+	assertReply(t, ctx, ":0\r\n", "HEXISTS", "h", "f3")
+
+	// updating a field drops its TTL
+	execute(ctx, "HSET", "h", "f1", "new")
+	assertReply(t, ctx, "*1\r\n:-1\r\n", "HTTL", "h", "FIELDS", "1", "f1")
+
+	assertReply(t, ctx, "*1\r\n:-2\r\n", "HTTL", "missing", "FIELDS", "1", "f1")
+	assertReply(t, ctx, "-ERR Parameter `numFields` should be greater than 0\r\n", "HTTL", "h", "FIELDS", "0", "f1")
+}
+
+func TestObjectEncodingOfStrings(t *testing.T) {
+	ctx := newTestContext(t)
+
+	execute(ctx, "SET", "n", "123")
+	assertReply(t, ctx, "$3\r\nint\r\n", "OBJECT", "ENCODING", "n")
+
+	execute(ctx, "APPEND", "n", "x")
+	assertReply(t, ctx, "$3\r\nraw\r\n", "OBJECT", "ENCODING", "n")
+
+	execute(ctx, "INCR", "counter")
+	assertReply(t, ctx, "$3\r\nint\r\n", "OBJECT", "ENCODING", "counter")
+
+	execute(ctx, "SET", "short", "hello")
+	assertReply(t, ctx, "$6\r\nembstr\r\n", "OBJECT", "ENCODING", "short")
+
+	execute(ctx, "SET", "long", strings.Repeat("x", 45))
+	assertReply(t, ctx, "$3\r\nraw\r\n", "OBJECT", "ENCODING", "long")
+}
+
+func TestInfoFieldsEndWithCRLF(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "SET", "k", "v")
+
+	for _, args := range [][]string{{"INFO"}, {"INFO", "replication"}, {"INFO", "keyspace"}} {
+		assertCRLFInfo(t, execute(ctx, args...))
+	}
+}
+
+func assertCRLFInfo(t *testing.T, reply string) {
+	t.Helper()
+
+	header, payload, ok := strings.Cut(reply, "\r\n")
+	if !ok || header[0] != '$' {
+		t.Fatalf("INFO = %q, want a bulk string", reply)
+	}
+
+	length, _ := strconv.Atoi(header[1:])
+	if len(payload) != length+2 || !strings.HasSuffix(payload, "\r\n") {
+		t.Fatalf("INFO announces %d bytes, payload is %q", length, payload)
+	}
+	payload = payload[:length]
+
+	if !strings.HasSuffix(payload, "\r\n") {
+		t.Fatalf("INFO payload %q does not end with CRLF", payload)
+	}
+	for _, line := range strings.SplitAfter(payload, "\n") {
+		if line != "" && !strings.HasSuffix(line, "\r\n") {
+			t.Fatalf("INFO line %q does not end with CRLF", line)
+		}
+	}
+}
+
+func TestXAddMalformedID(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "XADD", "s", "5-3", "f", "v")
+
+	for _, id := range []string{"abc", "5-", "-3", "5-*-*", "18446744073709551616-*"} {
+		assertReply(t, ctx, "-ERR Invalid stream ID specified as stream command argument\r\n", "XADD", "s", id, "f", "v")
+	}
+	assertReply(t, ctx, "$3\r\n5-4\r\n", "XADD", "s", "05-*", "f", "v")
+}
+
+func TestUnknownSubcommand(t *testing.T) {
+	ctx := newTestContext(t)
+
+	assertReply(t, ctx, "-ERR Unknown subcommand or wrong number of arguments for 'FOO'. Try CONFIG HELP.\r\n", "CONFIG", "FOO")
+
+	for _, command := range []string{"CONFIG", "CLIENT", "OBJECT", "XINFO", "XGROUP", "DEBUG", "COMMAND"} {
+		t.Run(command, func(t *testing.T) {
+			want := "-ERR Unknown subcommand or wrong number of arguments for 'nosuch'. Try " + command + " HELP.\r\n"
+			assertReply(t, ctx, want, strings.ToLower(command), "nosuch", "arg")
+		})
+	}
+}
+
+func TestTypeMatchesWrites(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"SET", "k", "v"}, "string"},
+		{[]string{"INCR", "k"}, "string"},
+		{[]string{"APPEND", "k", "v"}, "string"},
+		{[]string{"RPUSH", "k", "a"}, "list"},
+		{[]string{"SADD", "k", "a"}, "set"},
+		{[]string{"ZADD", "k", "1", "a"}, "zset"},
+		{[]string{"HSET", "k", "f", "v"}, "hash"},
+		{[]string{"XADD", "k", "*", "f", "v"}, "stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.args[0], func(t *testing.T) {
+			ctx := newTestContext(t)
+			execute(ctx, tt.args...)
+
+			assertReply(t, ctx, "+"+tt.want+"\r\n", "TYPE", "k")
+			assertReply(t, ctx, "*2\r\n$1\r\n0\r\n*1\r\n$1\r\nk\r\n", "SCAN", "0", "TYPE", tt.want)
+		})
+	}
+}
+
+func TestPushXOnMissingKey(t *testing.T) {
+	ctx := newTestContext(t)
+
+	for _, command := range []string{"RPUSHX", "LPUSHX"} {
+		assertReply(t, ctx, ":0\r\n", command, "missing", "a", "b")
+		assertReply(t, ctx, ":0\r\n", "EXISTS", "missing")
+	}
+
+	execute(ctx, "RPUSH", "l", "a")
+	assertReply(t, ctx, ":3\r\n", "RPUSHX", "l", "b", "c")
+	assertReply(t, ctx, ":4\r\n", "LPUSHX", "l", "z")
+	assertReply(t, ctx, "*4\r\n$1\r\nz\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n", "LRANGE", "l", "0", "-1")
+}
+
+func TestCopyToAnotherDatabase(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "SET", "k", "v")
+	execute(ctx, "RPUSH", "l", "a", "b")
+
+	assertReply(t, ctx, ":1\r\n", "COPY", "k", "k", "DB", "1")
+	assertReply(t, ctx, ":1\r\n", "COPY", "l", "copy", "DB", "1")
+
+	db1, _ := utils.GetDatabasesObj(ctx).Get(1)
+	if got, err := db1.Get("k"); err != nil || got != "v" {
+		t.Fatalf("GET k in DB 1 = %q, %v, want v", got, err)
+	}
+	if got, err := db1.LRange("copy", 0, -1); err != nil || strings.Join(got, ",") != "a,b" {
+		t.Fatalf("LRANGE copy in DB 1 = %v, %v, want [a b]", got, err)
+	}
+
+	// the source stays in DB 0 and the destination is not overwritten
+	assertReply(t, ctx, "$1\r\nv\r\n", "GET", "k")
+	execute(ctx, "SET", "k", "new")
+	assertReply(t, ctx, ":0\r\n", "COPY", "k", "k", "DB", "1")
+	assertReply(t, ctx, ":1\r\n", "COPY", "k", "k", "DB", "1", "REPLACE")
+	if got, _ := db1.Get("k"); got != "new" {
+		t.Fatalf("GET k in DB 1 after REPLACE = %q, want new", got)
+	}
+
+	assertReply(t, ctx, "-ERR DB index is out of range\r\n", "COPY", "k", "k", "DB", "16")
+}
+
+func TestGetRepliesBulkString(t *testing.T) {
+	ctx := newTestContext(t)
+
+	execute(ctx, "SET", "k", "line one\r\nline two")
+	assertReply(t, ctx, "$18\r\nline one\r\nline two\r\n", "GET", "k")
+
+	execute(ctx, "SET", "empty", "")
+	assertReply(t, ctx, "$0\r\n\r\n", "GET", "empty")
+
+	assertReply(t, ctx, "$-1\r\n", "GET", "missing")
+}
+
+func TestDelManyKeys(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "SET", "a", "1")
+	execute(ctx, "RPUSH", "b", "x")
+	execute(ctx, "HSET", "c", "f", "v")
+
+	assertReply(t, ctx, ":3\r\n", "DEL", "a", "b", "missing", "c", "a")
+	assertReply(t, ctx, ":0\r\n", "EXISTS", "a", "b", "c")
+	assertReply(t, ctx, ":0\r\n", "DEL", "missing")
+	assertReply(t, ctx, "-ERR wrong number of arguments for 'del' command\r\n", "DEL")
+}
+
+func TestDelLargeCollectionIsPrompt(t *testing.T) {
+	ctx := newTestContext(t)
+	storeObj := utils.GetStoreObj(ctx)
+	storeObj.SetLazyFree(false, true)
+
+	members := make([]string, 500000)
+	for i := range members {
+		members[i] = strconv.Itoa(i) + ":member"
+	}
+	for _, key := range []string{"del", "unlink"} {
+		if _, err := storeObj.SAdd(key, members); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// with lazyfree-lazy-user-del DEL hands the value to the reclaim
+	// goroutine like UNLINK does, so neither waits for it to be freed
+	for _, command := range []string{"DEL", "UNLINK"} {
+		key := strings.ToLower(command)
+
+		start := time.Now()
+		assertReply(t, ctx, ":1\r\n", command, key)
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Fatalf("%s of a 500000 member set took %v", command, elapsed)
+		}
+		assertReply(t, ctx, ":0\r\n", "EXISTS", key)
+		assertReply(t, ctx, "*0\r\n", "SMEMBERS", key)
+	}
+}
+
+func TestExistsCountsRepeatedKeys(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "SET", "k", "v")
+
+	assertReply(t, ctx, ":2\r\n", "EXISTS", "k", "k")
+	assertReply(t, ctx, ":2\r\n", "EXISTS", "k", "missing", "k")
+	assertReply(t, ctx, ":0\r\n", "EXISTS", "missing")
+}
+
+func TestXReadDollarWhileAdding(t *testing.T) {
+	const (
+		readers = 4
+		reads   = 50
+	)
+
+	ctx := newTestContext(t)
+	storeObj := utils.GetStoreObj(ctx)
+	entryID := regexp.MustCompile(`\$\d+\r\n(\d+-\d+)\r\n`)
+
+	stop := make(chan struct{})
+	producerDone := make(chan struct{})
+	go func() {
+		defer close(producerDone)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				execute(ctx, "XADD", "s", "*", "f", "v")
+				runtime.Gosched()
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < reads; i++ {
+				before, _ := storeObj.GetLastStreamID("s", "0-0")
+
+				reply := execute(ctx, "XREAD", "BLOCK", "2000", "STREAMS", "s", "$")
+				matches := entryID.FindAllStringSubmatch(reply, -1)
+				if len(matches) == 0 {
+					t.Errorf("XREAD $ = %q while entries kept coming", reply)
+					return
+				}
+
+				previous := before
+				for _, match := range matches {
+					if !streamIDLess(t, previous, match[1]) {
+						t.Errorf("XREAD $ started after %s returned %s after %s", before, match[1], previous)
+						return
+					}
+					previous = match[1]
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(stop)
+	<-producerDone
+}
+
+func TestXReadInvalidID(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "XADD", "s", "1-1", "f", "v")
+
+	assertReply(t, ctx, "-ERR Invalid stream ID specified as stream command argument\r\n", "XREAD", "STREAMS", "s", "abc")
+}
+
+func TestSetPXExpires(t *testing.T) {
+	ctx := newTestContext(t)
+
+	assertReply(t, ctx, "+OK\r\n", "SET", "k", "v", "PX", "50")
+	assertReply(t, ctx, "$1\r\nv\r\n", "GET", "k")
+
+	time.Sleep(80 * time.Millisecond)
+
+	assertReply(t, ctx, "$-1\r\n", "GET", "k")
+	assertReply(t, ctx, ":0\r\n", "EXISTS", "k")
+}
+
+func TestSetEX(t *testing.T) {
+	ctx := newTestContext(t)
+
+	assertReply(t, ctx, "+OK\r\n", "SET", "k", "v", "EX", "10")
+	assertReply(t, ctx, ":10\r\n", "TTL", "k")
+
+	assertReply(t, ctx, "-ERR syntax error\r\n", "SET", "k", "v", "EX", "10", "PX", "100")
+	assertReply(t, ctx, "-ERR invalid expire time in 'set' command\r\n", "SET", "k", "v", "EX", "0")
+	assertReply(t, ctx, "-ERR value is not an integer or out of range\r\n", "SET", "k", "v", "EX", "ten")
+	assertReply(t, ctx, "-ERR syntax error\r\n", "SET", "k", "v", "EX")
+}
+
+func TestSetUnknownOption(t *testing.T) {
+	ctx := newTestContext(t)
+
+	assertReply(t, ctx, "-ERR syntax error\r\n", "SET", "k", "v", "FOO")
+	assertReply(t, ctx, ":0\r\n", "EXISTS", "k")
+}
+
+func TestSetNXAndXX(t *testing.T) {
+	ctx := newTestContext(t)
+
+	assertReply(t, ctx, "$-1\r\n", "SET", "k", "v1", "XX")
+	assertReply(t, ctx, ":0\r\n", "EXISTS", "k")
+
+	assertReply(t, ctx, "+OK\r\n", "SET", "k", "v1", "NX")
+	assertReply(t, ctx, "$-1\r\n", "SET", "k", "v2", "NX")
+	assertReply(t, ctx, "$2\r\nv1\r\n", "GET", "k")
+
+	assertReply(t, ctx, "+OK\r\n", "SET", "k", "v3", "XX", "PX", "10000")
+	assertReply(t, ctx, "$2\r\nv3\r\n", "GET", "k")
+
+	assertReply(t, ctx, "-ERR syntax error\r\n", "SET", "k", "v", "NX", "XX")
+	assertReply(t, ctx, "-ERR syntax error\r\n", "SET", "k", "v", "NX", "NX")
+}
+
+func TestGetDel(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "SET", "k", "v")
+	execute(ctx, "RPUSH", "l", "a")
+
+	assertReply(t, ctx, "$1\r\nv\r\n", "GETDEL", "k")
+	assertReply(t, ctx, ":0\r\n", "EXISTS", "k")
+	assertReply(t, ctx, "$-1\r\n", "GETDEL", "k")
+
+	assertReply(t, ctx, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", "GETDEL", "l")
+	assertReply(t, ctx, ":1\r\n", "EXISTS", "l")
+}
+
+func TestGetRangeBounds(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "SET", "k", "Hello World")
+
+	tests := []struct {
+		start, end string
+		want       string
+	}{
+		{"0", "-1", "Hello World"},
+		{"-3", "-1", "rld"},
+		{"0", "4", "Hello"},
+		{"6", "100", "World"},
+		{"-100", "4", "Hello"},
+		{"5", "3", ""},
+		{"-1", "-3", ""},
+		{"11", "20", ""},
+		{"-100", "-50", ""},
+		{"10", "10", "d"},
+	}
+
+	for _, tt := range tests {
+		want := stringResp(tt.want)
+		assertReply(t, ctx, want, "GETRANGE", "k", tt.start, tt.end)
+	}
+
+	assertReply(t, ctx, "$0\r\n\r\n", "GETRANGE", "missing", "0", "-1")
+	assertReply(t, ctx, "-ERR value is not an integer or out of range\r\n", "GETRANGE", "k", "a", "1")
+}
+
+func TestSetRangeBounds(t *testing.T) {
+	tests := []struct {
+		initial string
+		offset  string
+		value   string
+		want    string
+	}{
+		{"Hello World", "6", "Redis", "Hello Redis"},
+		{"Hello", "5", "!", "Hello!"},
+		{"Hello", "0", "J", "Jello"},
+		{"Hi", "4", "x", "Hi\x00\x00x"},
+		{"", "2", "ab", "\x00\x00ab"},
+		{"Hello", "1", "", "Hello"},
+	}
+
+	for _, tt := range tests {
+		ctx := newTestContext(t)
+		if tt.initial != "" {
+			execute(ctx, "SET", "k", tt.initial)
+		}
+
+		assertReply(t, ctx, integerResp(len(tt.want)), "SETRANGE", "k", tt.offset, tt.value)
+		if tt.want != "" {
+			assertReply(t, ctx, stringResp(tt.want), "GET", "k")
+		}
+	}
+
+	ctx := newTestContext(t)
+	assertReply(t, ctx, ":0\r\n", "SETRANGE", "missing", "3", "")
+	assertReply(t, ctx, ":0\r\n", "EXISTS", "missing")
+	assertReply(t, ctx, "-ERR offset is out of range\r\n", "SETRANGE", "k", "-1", "x")
+}
+
+func TestGetEx(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "SET", "k", "v")
+
+	assertReply(t, ctx, "$1\r\nv\r\n", "GETEX", "k")
+	assertReply(t, ctx, ":-1\r\n", "TTL", "k")
+
+	assertReply(t, ctx, "$1\r\nv\r\n", "GETEX", "k", "EX", "100")
+	assertReply(t, ctx, ":100\r\n", "TTL", "k")
+
+	assertReply(t, ctx, "$1\r\nv\r\n", "GETEX", "k", "PX", "50000")
+	assertReply(t, ctx, ":50\r\n", "TTL", "k")
+
+	assertReply(t, ctx, "$1\r\nv\r\n", "GETEX", "k", "PERSIST")
+	assertReply(t, ctx, ":-1\r\n", "TTL", "k")
+	assertReply(t, ctx, "$1\r\nv\r\n", "GET", "k")
+
+	assertReply(t, ctx, "$-1\r\n", "GETEX", "missing", "EX", "10")
+	assertReply(t, ctx, ":0\r\n", "EXISTS", "missing")
+
+	assertReply(t, ctx, "-ERR syntax error\r\n", "GETEX", "k", "EX")
+	assertReply(t, ctx, "-ERR syntax error\r\n", "GETEX", "k", "EX", "10", "PERSIST")
+	assertReply(t, ctx, "-ERR invalid expire time in 'getex' command\r\n", "GETEX", "k", "EX", "0")
+}
+
+func TestAppend(t *testing.T) {
+	ctx := newTestContext(t)
+
+	assertReply(t, ctx, ":5\r\n", "APPEND", "k", "Hello")
+	assertReply(t, ctx, ":11\r\n", "APPEND", "k", " World")
+	assertReply(t, ctx, "$11\r\nHello World\r\n", "GET", "k")
+	assertReply(t, ctx, ":11\r\n", "APPEND", "k", "")
+
+	execute(ctx, "XADD", "s", "*", "f", "v")
+	assertReply(t, ctx, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", "APPEND", "s", "x")
+}
+
+func TestIncrByAndDecrBy(t *testing.T) {
+	ctx := newTestContext(t)
+
+	assertReply(t, ctx, ":5\r\n", "INCRBY", "n", "5")
+	assertReply(t, ctx, ":-5\r\n", "INCRBY", "n", "-10")
+	assertReply(t, ctx, ":-8\r\n", "DECRBY", "n", "3")
+	assertReply(t, ctx, ":2\r\n", "DECRBY", "n", "-10")
+	assertReply(t, ctx, "$1\r\n2\r\n", "GET", "n")
+
+	assertReply(t, ctx, "-ERR value is not an integer or out of range\r\n", "INCRBY", "n", "1.5")
+	assertReply(t, ctx, "-ERR value is not an integer or out of range\r\n", "DECRBY", "n", "x")
+
+	execute(ctx, "SET", "s", "abc")
+	assertReply(t, ctx, "-ERR value is not an integer or out of range\r\n", "INCRBY", "s", "1")
+
+	execute(ctx, "SET", "max", "9223372036854775807")
+	assertReply(t, ctx, "-ERR increment or decrement would overflow\r\n", "INCRBY", "max", "1")
+	execute(ctx, "SET", "min", "-9223372036854775808")
+	assertReply(t, ctx, "-ERR increment or decrement would overflow\r\n", "DECRBY", "min", "1")
+	assertReply(t, ctx, "-ERR decrement would overflow\r\n", "DECRBY", "n", "-9223372036854775808")
+}
+
+func TestDecr(t *testing.T) {
+	ctx := newTestContext(t)
+
+	assertReply(t, ctx, ":-1\r\n", "DECR", "n")
+	assertReply(t, ctx, ":-2\r\n", "DECR", "n")
+
+	execute(ctx, "SET", "n", "10")
+	assertReply(t, ctx, ":9\r\n", "DECR", "n")
+
+	execute(ctx, "SET", "s", "ten")
+	assertReply(t, ctx, "-ERR value is not an integer or out of range\r\n", "DECR", "s")
+
+	execute(ctx, "SET", "min", "-9223372036854775808")
+	assertReply(t, ctx, "-ERR increment or decrement would overflow\r\n", "DECR", "min")
+
+	execute(ctx, "RPUSH", "l", "a")
+	assertReply(t, ctx, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", "DECR", "l")
+}
+
+func TestDelStream(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "XADD", "s", "1-1", "f", "v")
+	execute(ctx, "XGROUP", "CREATE", "s", "g", "0")
+
+	assertReply(t, ctx, ":1\r\n", "DEL", "s")
+	assertReply(t, ctx, "+none\r\n", "TYPE", "s")
+	assertReply(t, ctx, "*0\r\n", "XRANGE", "s", "-", "+")
+
+	// a new stream under the same key starts over
+	assertReply(t, ctx, "$3\r\n1-1\r\n", "XADD", "s", "1-1", "f", "v")
+}
+
+func TestXRangeErrors(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "SET", "k", "v")
+	execute(ctx, "XADD", "s", "1-1", "f", "v")
+
+	assertReply(t, ctx, "*0\r\n", "XRANGE", "missing", "-", "+")
+	assertReply(t, ctx, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", "XRANGE", "k", "-", "+")
+	assertReply(t, ctx, "-ERR Invalid stream ID specified as stream command argument\r\n", "XRANGE", "s", "x", "+")
+	assertReply(t, ctx, "*0\r\n", "XRANGE", "s", "0", "1-0")
+}
+
+func TestXRangeReplyKeepsFieldOrder(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "XADD", "s", "1-1", "zeta", "1", "alpha", "2")
+
+	assertReply(t, ctx,
+		"*1\r\n*2\r\n$3\r\n1-1\r\n*4\r\n$4\r\nzeta\r\n$1\r\n1\r\n$5\r\nalpha\r\n$1\r\n2\r\n",
+		"XRANGE", "s", "-", "+")
+}
+
+func TestIncrByFloat(t *testing.T) {
+	ctx := newTestContext(t)
+
+	execute(ctx, "SET", "n", "10.50")
+	assertReply(t, ctx, "$4\r\n10.6\r\n", "INCRBYFLOAT", "n", "0.1")
+	assertReply(t, ctx, stringResp("5.6"), "INCRBYFLOAT", "n", "-5")
+
+	execute(ctx, "SET", "e", "5.0e3")
+	assertReply(t, ctx, "$4\r\n5200\r\n", "INCRBYFLOAT", "e", "2.0e2")
+	assertReply(t, ctx, "$4\r\n3000\r\n", "INCRBYFLOAT", "new", "3.0e3")
+
+	execute(ctx, "SET", "p", "0.1")
+	assertReply(t, ctx, "$3\r\n0.3\r\n", "INCRBYFLOAT", "p", "0.2")
+
+	assertReply(t, ctx, "-ERR value is not a valid float\r\n", "INCRBYFLOAT", "n", "abc")
+	execute(ctx, "SET", "s", "abc")
+	assertReply(t, ctx, "-ERR value is not a valid float\r\n", "INCRBYFLOAT", "s", "1")
+	assertReply(t, ctx, "-ERR increment would produce NaN or Infinity\r\n", "INCRBYFLOAT", "n", "inf")
+}
+
+func TestXInfoGroups(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "XADD", "s", "1-1", "f", "v")
+	execute(ctx, "XGROUP", "CREATE", "s", "g", "$")
+
+	assertReply(t, ctx, "$6\r\nstream\r\n", "OBJECT", "ENCODING", "s")
+	assertReply(t, ctx,
+		"*1\r\n*8\r\n"+
+			"$4\r\nname\r\n$1\r\ng\r\n"+
+			"$9\r\nconsumers\r\n:0\r\n"+
+			"$7\r\npending\r\n:0\r\n"+
+			"$17\r\nlast-delivered-id\r\n$3\r\n1-1\r\n",
+		"XINFO", "GROUPS", "s")
+	assertReply(t, ctx, "*0\r\n", "XINFO", "CONSUMERS", "s", "g")
+	assertReply(t, ctx, "-ERR no such key\r\n", "XINFO", "GROUPS", "missing")
+}
+
+func TestAppendOverProtoMaxBulkLen(t *testing.T) {
+	ctx := newTestContext(t)
+	utils.GetStoreObj(ctx).SetProtoMaxBulkLen(8)
+
+	const tooLarge = "-ERR string exceeds maximum allowed size (proto-max-bulk-len)\r\n"
+
+	assertReply(t, ctx, ":5\r\n", "APPEND", "k", "Hello")
+	assertReply(t, ctx, ":8\r\n", "APPEND", "k", "abc")
+	assertReply(t, ctx, tooLarge, "APPEND", "k", "d")
+	assertReply(t, ctx, "$8\r\nHelloabc\r\n", "GET", "k")
+
+	assertReply(t, ctx, tooLarge, "SETRANGE", "k", "6", "xyz")
+	assertReply(t, ctx, ":8\r\n", "SETRANGE", "k", "5", "xyz")
+	assertReply(t, ctx, tooLarge, "SETRANGE", "new", "8", "x")
+	assertReply(t, ctx, ":0\r\n", "EXISTS", "new")
+}
+
+/*
+infoField returns the integer value of field in the given INFO section.
+*/
+func infoField(t *testing.T, ctx context.Context, section, field string) int64 {
+	t.Helper()
+
+	reply := execute(ctx, "INFO", section)
+	for _, line := range strings.Split(reply, "\r\n") {
+		if value, ok := strings.CutPrefix(line, field+":"); ok {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				t.Fatalf("INFO %s has %s:%s, want an integer", section, field, value)
+			}
+			return n
+		}
+	}
+
+	t.Fatalf("INFO %s = %q, want a %s field", section, reply, field)
+	return 0
+}
+
+func TestUsedMemoryFollowsSetAndDel(t *testing.T) {
+	ctx := newTestContext(t)
+	empty := infoField(t, ctx, "memory", "used_memory")
+
+	for i := 0; i < 10; i++ {
+		execute(ctx, "SET", "k"+strconv.Itoa(i), strings.Repeat("x", 10000))
+	}
+	full := infoField(t, ctx, "memory", "used_memory")
+	if full < empty+10*10000 {
+		t.Fatalf("used_memory = %d after 100KB of SETs, was %d", full, empty)
+	}
+
+	execute(ctx, "DEL", "k0", "k1", "k2", "k3", "k4")
+	half := infoField(t, ctx, "memory", "used_memory")
+	if half >= full || half < empty+5*10000 {
+		t.Fatalf("used_memory = %d after deleting half the keys, was %d", half, full)
+	}
+
+	execute(ctx, "DEL", "k5", "k6", "k7", "k8", "k9")
+	if got := infoField(t, ctx, "memory", "used_memory"); got != empty {
+		t.Fatalf("used_memory = %d after deleting every key, want %d", got, empty)
+	}
+}
+
+func TestSetNX(t *testing.T) {
+	ctx := newTestContext(t)
+
+	assertReply(t, ctx, ":1\r\n", "SETNX", "k", "first")
+	assertReply(t, ctx, ":0\r\n", "SETNX", "k", "second")
+	assertReply(t, ctx, "$5\r\nfirst\r\n", "GET", "k")
+
+	// only one of many racing SETNX calls wins
+	var (
+		wg  sync.WaitGroup
+		won atomic.Int32
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if execute(ctx, "SETNX", "race", "v") == ":1\r\n" {
+				won.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := won.Load(); got != 1 {
+		t.Fatalf("%d racing SETNX calls succeeded, want 1", got)
+	}
+}
+
+func TestGetRangeAndSetRangeErrors(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "RPUSH", "l", "a")
+
+	const wrongType = "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+
+	assertReply(t, ctx, "-ERR value is not an integer or out of range\r\n", "GETRANGE", "k", "a", "1")
+	assertReply(t, ctx, "-ERR value is not an integer or out of range\r\n", "SETRANGE", "k", "a", "x")
+	assertReply(t, ctx, "-ERR offset is out of range\r\n", "SETRANGE", "k", "-1", "x")
+	assertReply(t, ctx, wrongType, "GETRANGE", "l", "0", "-1")
+	assertReply(t, ctx, wrongType, "SETRANGE", "l", "0", "x")
+
+	// an empty value reports the length without creating the key
+	assertReply(t, ctx, ":0\r\n", "SETRANGE", "k", "5", "")
+	assertReply(t, ctx, ":0\r\n", "EXISTS", "k")
+}
+
+func TestKeyspaceHitsAndMisses(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "SET", "k", "v")
+	execute(ctx, "SET", "expired", "v", "PX", "1")
+	execute(ctx, "RPUSH", "l", "a")
+	time.Sleep(5 * time.Millisecond)
+
+	execute(ctx, "GET", "missing")
+	if got := infoField(t, ctx, "stats", "keyspace_misses"); got != 1 {
+		t.Fatalf("keyspace_misses = %d after a GET of a missing key, want 1", got)
+	}
+
+	execute(ctx, "GET", "expired")
+	execute(ctx, "GET", "k")
+	execute(ctx, "GET", "l")
+
+	if got := infoField(t, ctx, "stats", "keyspace_misses"); got != 2 {
+		t.Fatalf("keyspace_misses = %d, want 2", got)
+	}
+	if got := infoField(t, ctx, "stats", "keyspace_hits"); got != 1 {
+		t.Fatalf("keyspace_hits = %d, want 1", got)
+	}
+}
+
+func TestTTLAndPTTL(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "SET", "plain", "v")
+	execute(ctx, "SET", "volatile", "v", "EX", "100")
+
+	for _, command := range []string{"TTL", "PTTL"} {
+		assertReply(t, ctx, ":-2\r\n", command, "missing")
+		assertReply(t, ctx, ":-1\r\n", command, "plain")
+	}
+
+	assertReply(t, ctx, ":100\r\n", "TTL", "volatile")
+
+	reply := execute(ctx, "PTTL", "volatile")
+	if ms, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(reply, ":"), "\r\n")); err != nil || ms <= 99000 || ms > 100000 {
+		t.Fatalf("PTTL volatile = %q, want just under 100000", reply)
+	}
+
+	// an expired key no longer exists
+	execute(ctx, "SET", "gone", "v", "PX", "1")
+	time.Sleep(5 * time.Millisecond)
+	assertReply(t, ctx, ":-2\r\n", "TTL", "gone")
+	assertReply(t, ctx, ":-2\r\n", "PTTL", "gone")
+}
+
+func TestPersist(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "SET", "k", "v", "PX", "50")
+
+	assertReply(t, ctx, ":1\r\n", "PERSIST", "k")
+	assertReply(t, ctx, ":-1\r\n", "TTL", "k")
+	assertReply(t, ctx, ":0\r\n", "PERSIST", "k")
+	assertReply(t, ctx, ":0\r\n", "PERSIST", "missing")
+
+	// the key outlives its former deadline
+	time.Sleep(60 * time.Millisecond)
+	assertReply(t, ctx, "$1\r\nv\r\n", "GET", "k")
+}
+
+func TestXReadBlockedOnTenStreams(t *testing.T) {
+	ctx := newTestContext(t)
+
+	replies := make([]chan string, 10)
+	for i := range replies {
+		replies[i] = make(chan string, 1)
+		go func(i int) {
+			replies[i] <- execute(ctx, "XREAD", "BLOCK", "300", "STREAMS", "s"+strconv.Itoa(i), "$")
+		}(i)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	execute(ctx, "XADD", "s3", "1-1", "f", "v")
+
+	select {
+	case reply := <-replies[3]:
+		if !strings.Contains(reply, "1-1") {
+			t.Fatalf("XREAD on s3 = %q, want the new entry", reply)
+		}
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("the XADD to s3 did not wake its reader")
+	}
+
+	for i, reply := range replies {
+		if i == 3 {
+			continue
+		}
+		if got := <-reply; got != "*-1\r\n" {
+			t.Errorf("XREAD on s%d = %q, want it to time out", i, got)
+		}
+	}
+}
+
+func TestXReadBlockedGivesUpWithItsClient(t *testing.T) {
+	ctx, cancel := context.WithCancel(newTestContext(t))
+
+	reply := make(chan string, 1)
+	go func() { reply <- execute(ctx, "XREAD", "BLOCK", "0", "STREAMS", "s", "$") }()
+
+	// the connection goes away while XREAD waits for an entry
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case got := <-reply:
+		if got != "" {
+			t.Fatalf("XREAD replied %q to a client that is gone", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("XREAD BLOCK 0 kept waiting after its client disconnected")
+	}
+}
+
+func TestSelectWithFourDatabases(t *testing.T) {
+	databases := store.NewDatabases(4)
+	db0, _ := databases.Get(0)
+	ctx := context.WithValue(newTestContext(t), "databases", databases)
+	ctx = context.WithValue(ctx, "store", db0)
+
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	conn := clients.NewSyncConn(server)
+	r := bufio.NewReader(client)
+
+	if conn.DB() != 0 {
+		t.Fatalf("a new connection starts on DB %d, want 0", conn.DB())
+	}
+
+	selectDB := func(index string) string {
+		go Commands["SELECT"].Execute(ctx, conn, newTestConfig(), []string{"SELECT", index})
+		reply, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+
+	if got := selectDB("3"); got != "+OK\r\n" || conn.DB() != 3 {
+		t.Fatalf("SELECT 3 = %q on DB %d, want +OK on DB 3", got, conn.DB())
+	}
+	if got := selectDB("4"); got != "-ERR DB index is out of range\r\n" || conn.DB() != 3 {
+		t.Fatalf("SELECT 4 = %q on DB %d, want an error leaving DB 3", got, conn.DB())
+	}
+
+	db3, _ := databases.Get(3)
+	db3.Set("a", "v", nil)
+	px := 100000
+	db3.Set("b", "v", &px)
+
+	reply := execute(ctx, "INFO", "keyspace")
+	if !strings.Contains(reply, "db3:keys=2,expires=1,avg_ttl=0\r\n") || strings.Contains(reply, "db0:") {
+		t.Fatalf("INFO keyspace = %q, want only db3 with 2 keys and 1 expiry", reply)
+	}
+}
+
+func TestPushToNewAndExistingList(t *testing.T) {
+	ctx := newTestContext(t)
+
+	assertReply(t, ctx, ":2\r\n", "RPUSH", "l", "b", "c")
+	assertReply(t, ctx, ":3\r\n", "LPUSH", "l", "a")
+	assertReply(t, ctx, ":5\r\n", "RPUSH", "l", "d", "e")
+	assertReply(t, ctx, "*5\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n$1\r\nd\r\n$1\r\ne\r\n", "LRANGE", "l", "0", "-1")
+
+	execute(ctx, "SET", "k", "v")
+	assertReply(t, ctx, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", "LPUSH", "k", "a")
+	assertReply(t, ctx, "-ERR wrong number of arguments for 'rpush' command\r\n", "RPUSH", "l")
+}
+
+func TestPopWithAndWithoutCount(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "RPUSH", "l", "a", "b", "c", "d", "e")
+
+	assertReply(t, ctx, "$1\r\na\r\n", "LPOP", "l")
+	assertReply(t, ctx, "$1\r\ne\r\n", "RPOP", "l")
+	assertReply(t, ctx, "*2\r\n$1\r\nb\r\n$1\r\nc\r\n", "LPOP", "l", "2")
+	assertReply(t, ctx, "*0\r\n", "RPOP", "l", "0")
+
+	// a count past the length pops what is there, and the last element
+	// takes the key with it
+	assertReply(t, ctx, "*1\r\n$1\r\nd\r\n", "RPOP", "l", "10")
+	assertReply(t, ctx, ":0\r\n", "EXISTS", "l")
+
+	assertReply(t, ctx, "$-1\r\n", "LPOP", "l")
+	assertReply(t, ctx, "$-1\r\n", "RPOP", "l")
+	assertReply(t, ctx, "*-1\r\n", "LPOP", "l", "2")
+
+	assertReply(t, ctx, "-ERR value is out of range, must be positive\r\n", "LPOP", "l", "-1")
+	execute(ctx, "SET", "k", "v")
+	assertReply(t, ctx, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", "RPOP", "k")
+}
+
+func TestLRange(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "RPUSH", "l", "a", "b", "c", "d", "e")
+
+	tests := []struct {
+		start, stop string
+		want        []string
+	}{
+		{"0", "-1", []string{"a", "b", "c", "d", "e"}},
+		{"-2", "-1", []string{"d", "e"}},
+		{"1", "-2", []string{"b", "c", "d"}},
+		{"-100", "1", []string{"a", "b"}},
+		{"3", "100", []string{"d", "e"}},
+		{"-100", "100", []string{"a", "b", "c", "d", "e"}},
+		{"2", "2", []string{"c"}},
+		{"3", "1", nil},
+		{"5", "10", nil},
+		{"-100", "-50", nil},
+	}
+
+	for _, tt := range tests {
+		want := arrayResp(len(tt.want))
+		for _, element := range tt.want {
+			want += stringResp(element)
+		}
+		assertReply(t, ctx, want, "LRANGE", "l", tt.start, tt.stop)
+	}
+
+	assertReply(t, ctx, "*0\r\n", "LRANGE", "missing", "0", "-1")
+	assertReply(t, ctx, "-ERR value is not an integer or out of range\r\n", "LRANGE", "l", "a", "1")
+}
+
+func TestExpireNonPositiveDeletes(t *testing.T) {
+	for _, args := range [][]string{
+		{"EXPIRE", "k", "-1"},
+		{"EXPIRE", "k", "0"},
+		{"PEXPIRE", "k", "-100"},
+	} {
+		ctx := newTestContext(t)
+		execute(ctx, "SET", "k", "v")
+
+		assertReply(t, ctx, ":1\r\n", args...)
+		assertReply(t, ctx, "+none\r\n", "TYPE", "k")
+		if keys, _ := utils.GetStoreObj(ctx).Counts(); keys != 0 {
+			t.Fatalf("%v left the key in the keyspace", args)
+		}
+
+		assertReply(t, ctx, ":0\r\n", args...)
+	}
+}
+
+func TestLLen(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "RPUSH", "l", "a", "b", "c")
+	execute(ctx, "SET", "k", "v")
+	execute(ctx, "XADD", "s", "1-1", "f", "v")
+
+	const wrongType = "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+
+	assertReply(t, ctx, ":3\r\n", "LLEN", "l")
+	assertReply(t, ctx, ":0\r\n", "LLEN", "missing")
+	assertReply(t, ctx, wrongType, "LLEN", "k")
+	assertReply(t, ctx, wrongType, "LLEN", "s")
+}
+
+func TestLIndexAndLSet(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "RPUSH", "l", "a", "b", "c")
+
+	assertReply(t, ctx, "$1\r\na\r\n", "LINDEX", "l", "0")
+	assertReply(t, ctx, "$1\r\nc\r\n", "LINDEX", "l", "-1")
+	assertReply(t, ctx, "$1\r\na\r\n", "LINDEX", "l", "-3")
+	assertReply(t, ctx, "$-1\r\n", "LINDEX", "l", "3")
+	assertReply(t, ctx, "$-1\r\n", "LINDEX", "l", "-4")
+	assertReply(t, ctx, "$-1\r\n", "LINDEX", "missing", "0")
+
+	assertReply(t, ctx, "+OK\r\n", "LSET", "l", "-1", "z")
+	assertReply(t, ctx, "+OK\r\n", "LSET", "l", "0", "y")
+	assertReply(t, ctx, "*3\r\n$1\r\ny\r\n$1\r\nb\r\n$1\r\nz\r\n", "LRANGE", "l", "0", "-1")
+
+	assertReply(t, ctx, "-ERR index out of range\r\n", "LSET", "l", "3", "x")
+	assertReply(t, ctx, "-ERR index out of range\r\n", "LSET", "l", "-4", "x")
+	assertReply(t, ctx, "-ERR no such key\r\n", "LSET", "missing", "0", "x")
+	assertReply(t, ctx, "-ERR value is not an integer or out of range\r\n", "LINDEX", "l", "x")
+}
+
+func TestHashEncodingPastDefaultEntries(t *testing.T) {
+	ctx := newTestContext(t)
+
+	for i := 0; i < 128; i++ {
+		execute(ctx, "HSET", "h", "f"+strconv.Itoa(i), "v")
+	}
+	assertReply(t, ctx, "$8\r\nlistpack\r\n", "OBJECT", "ENCODING", "h")
+
+	execute(ctx, "HSET", "h", "f128", "v")
+	assertReply(t, ctx, "$9\r\nhashtable\r\n", "OBJECT", "ENCODING", "h")
+}
+
+func TestBlockingPopReleasedByPush(t *testing.T) {
+	ctx := newTestContext(t)
+
+	reply := make(chan string, 1)
+	go func() { reply <- execute(ctx, "BLPOP", "l1", "l2", "0") }()
+
+	time.Sleep(20 * time.Millisecond)
+	execute(ctx, "RPUSH", "l2", "a", "b")
+
+	select {
+	case got := <-reply:
+		if want := "*2\r\n$2\r\nl2\r\n$1\r\na\r\n"; got != want {
+			t.Fatalf("BLPOP = %q, want %q", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the push did not release BLPOP")
+	}
+
+	// an element already there is popped without blocking
+	assertReply(t, ctx, "*2\r\n$2\r\nl2\r\n$1\r\nb\r\n", "BRPOP", "l1", "l2", "0")
+	assertReply(t, ctx, ":0\r\n", "EXISTS", "l2")
+
+	start := time.Now()
+	assertReply(t, ctx, "*-1\r\n", "BRPOP", "l1", "0.05")
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("BRPOP timed out after %v, before its timeout", elapsed)
+	}
+
+	assertReply(t, ctx, "-ERR timeout is negative\r\n", "BLPOP", "l1", "-1")
+}
+
+func TestSAdd(t *testing.T) {
+	ctx := newTestContext(t)
+
+	assertReply(t, ctx, ":2\r\n", "SADD", "s", "a", "b", "a")
+	assertReply(t, ctx, ":0\r\n", "SADD", "s", "a", "b")
+	assertReply(t, ctx, ":1\r\n", "SADD", "s", "c")
+
+	execute(ctx, "SET", "k", "v")
+	assertReply(t, ctx, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", "SADD", "k", "a")
+	assertReply(t, ctx, "-ERR wrong number of arguments for 'sadd' command\r\n", "SADD", "s")
+}
+
+func TestSInterStore(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "SADD", "a", "1", "2", "3", "x")
+	execute(ctx, "SADD", "b", "2", "3", "x", "y")
+	execute(ctx, "SET", "dest", "overwritten")
+
+	assertReply(t, ctx, ":3\r\n", "SINTERSTORE", "dest", "a", "b")
+	if got := sortedMembers(t, ctx, "SMEMBERS", "dest"); !reflect.DeepEqual(got, []string{"2", "3", "x"}) {
+		t.Fatalf("SMEMBERS dest = %v, want [2 3 x]", got)
+	}
+
+	assertReply(t, ctx, ":5\r\n", "SUNIONSTORE", "dest", "a", "b")
+	assertReply(t, ctx, ":1\r\n", "SDIFFSTORE", "dest", "a", "b")
+	assertReply(t, ctx, "*1\r\n$1\r\n1\r\n", "SMEMBERS", "dest")
+
+	// an empty result deletes the destination
+	assertReply(t, ctx, ":0\r\n", "SINTERSTORE", "dest", "a", "missing")
+	assertReply(t, ctx, ":0\r\n", "EXISTS", "dest")
+}
+
+func TestSRemSCardSIsMember(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "SADD", "s", "a", "b", "c")
+
+	assertReply(t, ctx, ":3\r\n", "SCARD", "s")
+	assertReply(t, ctx, ":1\r\n", "SISMEMBER", "s", "a")
+	assertReply(t, ctx, ":2\r\n", "SREM", "s", "a", "b", "z")
+	assertReply(t, ctx, ":0\r\n", "SISMEMBER", "s", "a")
+	assertReply(t, ctx, ":1\r\n", "SREM", "s", "c")
+	assertReply(t, ctx, ":0\r\n", "EXISTS", "s")
+
+	for _, args := range [][]string{
+		{"SREM", "missing", "a"},
+		{"SCARD", "missing"},
+		{"SISMEMBER", "missing", "a"},
+	} {
+		assertReply(t, ctx, ":0\r\n", args...)
+	}
+}
+
+/*
+zscores returns the members of a sorted set with their scores, in order.
+*/
+func zscores(ctx context.Context, key string) string {
+	return zscoresBetween(ctx, key, "-inf", "+inf")
+}
+
+func zscoresBetween(ctx context.Context, key string, min string, max string) string {
+	reply := execute(ctx, "ZRANGEBYSCORE", key, min, max, "WITHSCORES")
+
+	var pairs []string
+	parts := strings.Split(reply, "\r\n")
+	for i := 2; i+2 < len(parts); i += 4 {
+		pairs = append(pairs, parts[i]+"="+parts[i+2])
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+func TestZUnionStoreAndZInterStore(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "ZADD", "a", "1", "x", "2", "y", "3", "z")
+	execute(ctx, "ZADD", "b", "10", "y", "20", "z", "30", "w")
+	execute(ctx, "SADD", "s", "x", "w")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"ZUNIONSTORE", "d", "2", "a", "b"}, "x=1 y=12 z=23 w=30"},
+		{[]string{"ZUNIONSTORE", "d", "2", "a", "b", "AGGREGATE", "MIN"}, "x=1 y=2 z=3 w=30"},
+		{[]string{"ZUNIONSTORE", "d", "2", "a", "b", "AGGREGATE", "MAX"}, "x=1 y=10 z=20 w=30"},
+		{[]string{"ZUNIONSTORE", "d", "2", "a", "b", "WEIGHTS", "2", "0.5"}, "x=2 y=9 w=15 z=16"},
+		{[]string{"ZINTERSTORE", "d", "2", "a", "b"}, "y=12 z=23"},
+		{[]string{"ZINTERSTORE", "d", "2", "a", "b", "AGGREGATE", "MIN"}, "y=2 z=3"},
+		{[]string{"ZINTERSTORE", "d", "2", "a", "b", "WEIGHTS", "3", "-1", "AGGREGATE", "MAX"}, "y=6 z=9"},
+		// a plain set takes part with scores of 1
+		{[]string{"ZUNIONSTORE", "d", "2", "a", "s", "WEIGHTS", "1", "5"}, "y=2 z=3 w=5 x=6"},
+	}
+
+	for _, tt := range tests {
+		wantCount := strings.Count(tt.want, "=")
+		assertReply(t, ctx, integerResp(wantCount), tt.args...)
+		if got := zscores(ctx, "d"); got != tt.want {
+			t.Errorf("%s stored %s, want %s", strings.Join(tt.args, " "), got, tt.want)
+		}
+	}
+
+	// an empty result deletes the destination
+	assertReply(t, ctx, ":0\r\n", "ZINTERSTORE", "d", "2", "a", "missing")
+	assertReply(t, ctx, ":0\r\n", "EXISTS", "d")
+
+	assertReply(t, ctx, "-ERR syntax error\r\n", "ZUNIONSTORE", "d", "2", "a", "b", "WEIGHTS", "1")
+	assertReply(t, ctx, "-ERR syntax error\r\n", "ZUNIONSTORE", "d", "2", "a", "b", "AGGREGATE", "AVG")
+}
+
+func TestSMembers(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "SADD", "s", "c", "a", "b", "1")
+	execute(ctx, "SET", "k", "v")
+
+	if got := sortedMembers(t, ctx, "SMEMBERS", "s"); !reflect.DeepEqual(got, []string{"1", "a", "b", "c"}) {
+		t.Fatalf("SMEMBERS = %v, want [1 a b c]", got)
+	}
+
+	assertReply(t, ctx, "*0\r\n", "SMEMBERS", "missing")
+	assertReply(t, ctx, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", "SMEMBERS", "k")
+}
+
+func TestSInterSUnionSDiff(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "SADD", "a", "1", "2", "3", "4")
+	execute(ctx, "SADD", "b", "2", "3", "5")
+	execute(ctx, "SADD", "c", "3", "4", "5", "6")
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"SINTER", "a", "b", "c"}, []string{"3"}},
+		{[]string{"SUNION", "a", "b", "c"}, []string{"1", "2", "3", "4", "5", "6"}},
+		{[]string{"SDIFF", "a", "b", "c"}, []string{"1"}},
+		{[]string{"SINTER", "a", "missing"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := sortedMembers(t, ctx, tt.args...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", strings.Join(tt.args, " "), got, tt.want)
+		}
+	}
+}
+
+func TestHSetAndHGet(t *testing.T) {
+	ctx := newTestContext(t)
+
+	assertReply(t, ctx, ":2\r\n", "HSET", "h", "f1", "a", "f2", "b")
+	assertReply(t, ctx, ":0\r\n", "HSET", "h", "f1", "c")
+	assertReply(t, ctx, "$1\r\nc\r\n", "HGET", "h", "f1")
+	assertReply(t, ctx, "$-1\r\n", "HGET", "h", "missing")
+	assertReply(t, ctx, "$-1\r\n", "HGET", "missing", "f1")
+
+	assertReply(t, ctx, "-ERR wrong number of arguments for 'hset' command\r\n", "HSET", "h", "f1")
+	execute(ctx, "SET", "k", "v")
+	assertReply(t, ctx, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", "HGET", "k", "f")
+}
+
+func TestHGetAllHKeysAndHVals(t *testing.T) {
+	ctx := newTestContext(t)
+
+	execute(ctx, "HSET", "h", "f1", "a", "f2", "b", "f3", "c")
+
+	if got := sortedMembers(t, ctx, "HGETALL", "h"); !reflect.DeepEqual(got, []string{"a", "b", "c", "f1", "f2", "f3"}) {
+		t.Fatalf("HGETALL h = %q, want the three fields and values", got)
+	}
+	if got := sortedMembers(t, ctx, "HKEYS", "h"); !reflect.DeepEqual(got, []string{"f1", "f2", "f3"}) {
+		t.Fatalf("HKEYS h = %q, want the three fields", got)
+	}
+	if got := sortedMembers(t, ctx, "HVALS", "h"); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Fatalf("HVALS h = %q, want the three values", got)
+	}
+
+	assertReply(t, ctx, "*0\r\n", "HGETALL", "missing")
+	assertReply(t, ctx, "*0\r\n", "HKEYS", "missing")
+	assertReply(t, ctx, "*0\r\n", "HVALS", "missing")
+}
+
+func TestHDelHLenAndHExists(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "HSET", "h", "f1", "a", "f2", "b")
+
+	assertReply(t, ctx, ":2\r\n", "HLEN", "h")
+	assertReply(t, ctx, ":1\r\n", "HEXISTS", "h", "f1")
+	assertReply(t, ctx, ":0\r\n", "HEXISTS", "h", "missing")
+	assertReply(t, ctx, ":0\r\n", "HEXISTS", "missing", "f1")
+
+	assertReply(t, ctx, ":1\r\n", "HDEL", "h", "f1", "missing")
+	assertReply(t, ctx, ":1\r\n", "HLEN", "h")
+
+	// deleting the last field deletes the key
+	assertReply(t, ctx, ":1\r\n", "HDEL", "h", "f2")
+	assertReply(t, ctx, ":0\r\n", "EXISTS", "h")
+	assertReply(t, ctx, ":0\r\n", "HLEN", "h")
+	assertReply(t, ctx, ":0\r\n", "HDEL", "h", "f2")
+}
+
+func TestHIncrBy(t *testing.T) {
+	ctx := newTestContext(t)
+
+	assertReply(t, ctx, ":-3\r\n", "HINCRBY", "h", "n", "-3")
+	assertReply(t, ctx, ":-10\r\n", "HINCRBY", "h", "n", "-7")
+	assertReply(t, ctx, ":0\r\n", "HINCRBY", "h", "n", "10")
+	assertReply(t, ctx, "$1\r\n0\r\n", "HGET", "h", "n")
+
+	execute(ctx, "HSET", "h", "s", "abc")
+	assertReply(t, ctx, "-ERR hash value is not an integer\r\n", "HINCRBY", "h", "s", "1")
+	assertReply(t, ctx, "-ERR value is not an integer or out of range\r\n", "HINCRBY", "h", "n", "x")
+}
+
+func TestZAddAndRescore(t *testing.T) {
+	ctx := newTestContext(t)
+
+	assertReply(t, ctx, ":2\r\n", "ZADD", "z", "2", "a", "1", "b")
+	assertReply(t, ctx, ":0\r\n", "ZADD", "z", "0.5", "a")
+	assertReply(t, ctx, ":1\r\n", "ZADD", "z", "3", "b", "3", "c")
+
+	if got := zscores(ctx, "z"); got != "a=0.5 b=3 c=3" {
+		t.Fatalf("z = %s, want a=0.5 b=3 c=3", got)
+	}
+
+	assertReply(t, ctx, "-ERR value is not a valid float\r\n", "ZADD", "z", "x", "a")
+	assertReply(t, ctx, "-ERR syntax error\r\n", "ZADD", "z", "1", "a", "2")
+}
+
+func TestSetObjectEncodingLeavesIntset(t *testing.T) {
+	ctx := newTestContext(t)
+
+	execute(ctx, "SADD", "s", "1", "2", "3")
+	assertReply(t, ctx, "$6\r\nintset\r\n", "OBJECT", "ENCODING", "s")
+
+	execute(ctx, "SADD", "s", "a")
+	assertReply(t, ctx, "$8\r\nlistpack\r\n", "OBJECT", "ENCODING", "s")
+}
+
+func TestZScoreAndZRank(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "ZADD", "z", "3", "c")
+	execute(ctx, "ZADD", "z", "1.5", "a", "-2", "d")
+	execute(ctx, "ZADD", "z", "1.5", "b")
+
+	for rank, member := range []string{"d", "a", "b", "c"} {
+		assertReply(t, ctx, fmt.Sprintf(":%d\r\n", rank), "ZRANK", "z", member)
+	}
+	assertReply(t, ctx, "$3\r\n1.5\r\n", "ZSCORE", "z", "a")
+	assertReply(t, ctx, "$2\r\n-2\r\n", "ZSCORE", "z", "d")
+
+	assertReply(t, ctx, "$-1\r\n", "ZRANK", "z", "missing")
+	assertReply(t, ctx, "$-1\r\n", "ZSCORE", "z", "missing")
+	assertReply(t, ctx, "$-1\r\n", "ZRANK", "missing", "a")
+
+	execute(ctx, "SET", "k", "v")
+	assertReply(t, ctx, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", "ZSCORE", "k", "a")
+}
+
+func TestZRemZCardAndZIncrBy(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "ZADD", "z", "1", "a", "2", "b", "3", "c")
+
+	assertReply(t, ctx, "$1\r\n5\r\n", "ZINCRBY", "z", "4", "a")
+	assertReply(t, ctx, "$3\r\n1.5\r\n", "ZINCRBY", "z", "-1.5", "c")
+	if got := zscores(ctx, "z"); got != "c=1.5 b=2 a=5" {
+		t.Fatalf("z after ZINCRBY = %s, want c=1.5 b=2 a=5", got)
+	}
+	assertReply(t, ctx, ":0\r\n", "ZRANK", "z", "c")
+	assertReply(t, ctx, ":2\r\n", "ZRANK", "z", "a")
+
+	assertReply(t, ctx, ":3\r\n", "ZCARD", "z")
+	assertReply(t, ctx, ":2\r\n", "ZREM", "z", "a", "c", "missing")
+	assertReply(t, ctx, ":1\r\n", "ZCARD", "z")
+
+	// removing the last member deletes the key
+	assertReply(t, ctx, ":1\r\n", "ZREM", "z", "b")
+	assertReply(t, ctx, ":0\r\n", "EXISTS", "z")
+	assertReply(t, ctx, ":0\r\n", "ZCARD", "z")
+}
+
+func TestZRangeByScoreBounds(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "ZADD", "z", "3", "d", "1", "a", "2", "c", "2", "b", "-inf", "low")
+
+	tests := []struct {
+		min  string
+		max  string
+		want string
+	}{
+		{"1", "2", "a=1 b=2 c=2"},
+		{"(1", "2", "b=2 c=2"},
+		{"1", "(2", "a=1"},
+		{"(1", "(3", "b=2 c=2"},
+		{"-inf", "+inf", "low=-inf a=1 b=2 c=2 d=3"},
+		{"(-inf", "inf", "a=1 b=2 c=2 d=3"},
+		{"2", "+inf", "b=2 c=2 d=3"},
+		{"3", "1", ""},
+	}
+
+	for _, tt := range tests {
+		if got := zscoresBetween(ctx, "z", tt.min, tt.max); got != tt.want {
+			t.Errorf("ZRANGEBYSCORE z %s %s = %q, want %q", tt.min, tt.max, got, tt.want)
+		}
+	}
+
+	assertReply(t, ctx, "*2\r\n$1\r\nb\r\n$1\r\nc\r\n", "ZRANGEBYSCORE", "z", "(1", "(3")
+	assertReply(t, ctx, "-ERR min or max is not a float\r\n", "ZRANGEBYSCORE", "z", "x", "1")
+	assertReply(t, ctx, "-ERR min or max is not a float\r\n", "ZRANGEBYSCORE", "z", "1", "((2")
+	assertReply(t, ctx, "*0\r\n", "ZRANGEBYSCORE", "missing", "-inf", "+inf")
+}
+
+func TestEchoArity(t *testing.T) {
+	ctx := newTestContext(t)
+
+	assertReply(t, ctx, "$5\r\nhello\r\n", "ECHO", "hello")
+	assertReply(t, ctx, "$0\r\n\r\n", "ECHO", "")
+	assertReply(t, ctx, "-ERR wrong number of arguments for 'echo' command\r\n", "ECHO")
+	assertReply(t, ctx, "-ERR wrong number of arguments for 'echo' command\r\n", "ECHO", "a", "b")
+}
+
+func TestUnderArityRepliesWithError(t *testing.T) {
+	tests := [][]string{
+		{"SET"},
+		{"SET", "k"},
+		{"GET"},
+		{"XADD"},
+		{"XADD", "s"},
+		{"XADD", "s", "*"},
+		{"XADD", "s", "*", "f"},
+		{"TYPE"},
+		{"PSYNC", "?"},
+	}
+
+	for _, args := range tests {
+		ctx := newTestContext(t)
+		want := "-ERR wrong number of arguments for '" + strings.ToLower(args[0]) + "' command\r\n"
+		assertReply(t, ctx, want, args...)
+	}
+
+	// every command with more than one argument refuses a call one
+	// argument short instead of leaving the client without a reply
+	for name, entry := range Commands {
+		arity := entry.Arity
+		if arity < 0 {
+			arity = -arity
+		}
+		if arity <= 1 {
+			continue
+		}
+
+		args := []string{name}
+		for len(args) < arity-1 {
+			args = append(args, "x")
+		}
+		want := "-ERR wrong number of arguments for '" + strings.ToLower(name) + "' command\r\n"
+		assertReply(t, newTestContext(t), want, args...)
+	}
+}
@@ -2,8 +2,9 @@ package commands
 
 import (
 	"context"
-	"fmt"
 	"io"
+	"strconv"
+	"strings"
 
 	"github.com/codecrafters-io/redis-starter-go/internal/config"
 )
@@ -14,14 +15,44 @@ func (c *ConfigCommand) handleGet(
 	config config.Config,
 	args []string,
 ) {
+	if len(args) < 3 {
+		conn.Write([]byte(unknownSubcommandResp(args[0], args[1])))
+		return
+	}
+
 	commands := map[string]CommandHandler{
 		"dir":        c.handleGetDir,
 		"dbfilename": c.handleGetDbFile,
+
+		"save":             c.handleGetSave,
+		"appendonly":       c.handleGetAppendOnly,
+		"maxmemory":        c.handleGetMaxMemory,
+		"maxmemory-policy": c.handleGetMaxMemoryPolicy,
+		"timeout":          c.handleGetTimeout,
+		"tcp-keepalive":    c.handleGetTcpKeepalive,
+		"databases":        c.handleGetDatabases,
+
+		"lazyfree-lazy-expire":   c.handleGetLazyfreeLazyExpire,
+		"lazyfree-lazy-user-del": c.handleGetLazyfreeLazyUserDel,
+
+		"list-max-listpack-size":    c.handleGetListMaxListpackSize,
+		"hash-max-listpack-entries": c.handleGetHashMaxListpackEntries,
+		"hash-max-listpack-value":   c.handleGetHashMaxListpackValue,
+		"set-max-intset-entries":    c.handleGetSetMaxIntsetEntries,
+		"set-max-listpack-entries":  c.handleGetSetMaxListpackEntries,
+		"set-max-listpack-value":    c.handleGetSetMaxListpackValue,
+		"resp3-keepalive":           c.handleGetResp3Keepalive,
+		"proto-max-bulk-len":        c.handleGetProtoMaxBulkLen,
+		"read-buffer-size":          c.handleGetReadBufferSize,
+		"max-pipeline-depth":        c.handleGetMaxPipelineDepth,
 	}
 
-	if handler, exists := commands[args[2]]; exists {
+	if handler, exists := commands[strings.ToLower(args[2])]; exists {
 		handler(ctx, conn, config, args)
+		return
 	}
+
+	conn.Write([]byte(arrayResp(0)))
 }
 
 func (c *ConfigCommand) handleGetDir(
@@ -30,12 +61,7 @@ func (c *ConfigCommand) handleGetDir(
 	config config.Config,
 	args []string,
 ) {
-	dir := fmt.Sprintf(
-		"*2\r\n$3\r\ndir\r\n$%d\r\n%s\r\n",
-		len(config.RedisDir),
-		config.RedisDir,
-	)
-	conn.Write([]byte(dir))
+	writeConfigParam(conn, "dir", config.RedisDir)
 }
 
 func (c *ConfigCommand) handleGetDbFile(
@@ -44,10 +70,189 @@ func (c *ConfigCommand) handleGetDbFile(
 	config config.Config,
 	args []string,
 ) {
-	dir := fmt.Sprintf(
-		"*2\r\n$9\r\ndbfilename\r\n$%d\r\n%s\r\n",
-		len(config.RedisDbFileName),
-		config.RedisDbFileName,
-	)
-	conn.Write([]byte(dir))
+	writeConfigParam(conn, "dbfilename", config.RedisDbFileName)
+}
+
+func (c *ConfigCommand) handleGetSave(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeConfigParam(conn, "save", config.Save)
+}
+
+func (c *ConfigCommand) handleGetAppendOnly(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeConfigParam(conn, "appendonly", yesNo(config.AppendOnly))
+}
+
+func (c *ConfigCommand) handleGetMaxMemory(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeConfigParam(conn, "maxmemory", strconv.FormatInt(config.MaxMemory, 10))
+}
+
+func (c *ConfigCommand) handleGetMaxMemoryPolicy(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeConfigParam(conn, "maxmemory-policy", config.MaxMemoryPolicy)
+}
+
+func (c *ConfigCommand) handleGetTimeout(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeConfigParam(conn, "timeout", strconv.Itoa(config.Timeout))
+}
+
+func (c *ConfigCommand) handleGetTcpKeepalive(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeConfigParam(conn, "tcp-keepalive", strconv.Itoa(config.TcpKeepalive))
+}
+
+func (c *ConfigCommand) handleGetDatabases(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeConfigParam(conn, "databases", strconv.Itoa(config.Databases))
+}
+
+func (c *ConfigCommand) handleGetLazyfreeLazyExpire(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeConfigParam(conn, "lazyfree-lazy-expire", yesNo(config.LazyfreeLazyExpire))
+}
+
+func (c *ConfigCommand) handleGetLazyfreeLazyUserDel(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeConfigParam(conn, "lazyfree-lazy-user-del", yesNo(config.LazyfreeLazyUserDel))
+}
+
+func (c *ConfigCommand) handleGetListMaxListpackSize(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeConfigParam(conn, "list-max-listpack-size", strconv.Itoa(config.ListMaxListpackSize))
+}
+
+func (c *ConfigCommand) handleGetHashMaxListpackEntries(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeConfigParam(conn, "hash-max-listpack-entries", strconv.Itoa(config.HashMaxListpackEntries))
+}
+
+func (c *ConfigCommand) handleGetHashMaxListpackValue(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeConfigParam(conn, "hash-max-listpack-value", strconv.Itoa(config.HashMaxListpackValue))
+}
+
+func (c *ConfigCommand) handleGetSetMaxIntsetEntries(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeConfigParam(conn, "set-max-intset-entries", strconv.Itoa(config.SetMaxIntsetEntries))
+}
+
+func (c *ConfigCommand) handleGetSetMaxListpackEntries(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeConfigParam(conn, "set-max-listpack-entries", strconv.Itoa(config.SetMaxListpackEntries))
+}
+
+func (c *ConfigCommand) handleGetSetMaxListpackValue(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeConfigParam(conn, "set-max-listpack-value", strconv.Itoa(config.SetMaxListpackValue))
+}
+
+func (c *ConfigCommand) handleGetResp3Keepalive(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeConfigParam(conn, "resp3-keepalive", strconv.Itoa(config.Resp3Keepalive))
+}
+
+func writeConfigParam(conn io.Writer, name string, value string) {
+	conn.Write([]byte(arrayResp(2) + stringResp(name) + stringResp(value)))
+}
+
+// yesNo formats a boolean parameter the way redis.conf spells it.
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+
+	return "no"
+}
+
+func (c *ConfigCommand) handleGetProtoMaxBulkLen(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeConfigParam(conn, "proto-max-bulk-len", strconv.FormatInt(config.ProtoMaxBulkLen, 10))
+}
+
+func (c *ConfigCommand) handleGetReadBufferSize(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeConfigParam(conn, "read-buffer-size", strconv.Itoa(config.ReadBufferSize))
+}
+
+func (c *ConfigCommand) handleGetMaxPipelineDepth(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	writeConfigParam(conn, "max-pipeline-depth", strconv.Itoa(config.MaxPipelineDepth))
 }
@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConfigGet(t *testing.T) {
+	ctx := newTestContext(t)
+
+	cfg := newTestConfig()
+	cfg.RedisDir = "/var/lib/redis"
+	cfg.RedisDbFileName = "dump.rdb"
+	cfg.AppendOnly = true
+	cfg.MaxMemory = 1 << 20
+	cfg.MaxMemoryPolicy = "allkeys-lru"
+	cfg.Timeout = 30
+	cfg.TcpKeepalive = 300
+	cfg.LazyfreeLazyUserDel = true
+
+	tests := []struct {
+		param string
+		want  string
+	}{
+		{"maxmemory-policy", "allkeys-lru"},
+		{"MAXMEMORY-POLICY", "allkeys-lru"},
+		{"save", "3600 1 300 100 60 10000"},
+		{"appendonly", "yes"},
+		{"maxmemory", "1048576"},
+		{"dir", "/var/lib/redis"},
+		{"dbfilename", "dump.rdb"},
+		{"timeout", "30"},
+		{"tcp-keepalive", "300"},
+		{"databases", "16"},
+		{"lazyfree-lazy-user-del", "yes"},
+		{"lazyfree-lazy-expire", "no"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.param, func(t *testing.T) {
+			var bb bytes.Buffer
+			Commands["CONFIG"].Execute(ctx, &bb, cfg, []string{"CONFIG", "GET", tt.param})
+
+			want := arrayResp(2) + stringResp(strings.ToLower(tt.param)) + stringResp(tt.want)
+			if got := bb.String(); got != want {
+				t.Fatalf("CONFIG GET %s = %q, want %q", tt.param, got, want)
+			}
+		})
+	}
+}
+
+func TestConfigGetUnknownParameter(t *testing.T) {
+	ctx := newTestContext(t)
+
+	assertReply(t, ctx, "*0\r\n", "CONFIG", "GET", "no-such-parameter")
+}
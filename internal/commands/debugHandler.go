@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/config"
+	"github.com/codecrafters-io/redis-starter-go/internal/utils"
+)
+
+/*
+handleQuicklistPackedThreshold is accepted for compatibility with test
+suites and does nothing, lists here are never stored as plain nodes.
+*/
+func (c *DebugCommand) handleQuicklistPackedThreshold(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 3 {
+		conn.Write([]byte(unknownSubcommandResp(args[0], args[1])))
+		return
+	}
+
+	conn.Write([]byte("+OK\r\n"))
+}
+
+/*
+handleStringMatchLen reports whether the string matches the glob pattern,
+so the matcher used by KEYS can be checked directly.
+*/
+func (c *DebugCommand) handleStringMatchLen(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 4 {
+		conn.Write([]byte(unknownSubcommandResp(args[0], args[1])))
+		return
+	}
+
+	if utils.MatchGlob(args[2], args[3]) {
+		conn.Write([]byte(integerResp(1)))
+		return
+	}
+
+	conn.Write([]byte(integerResp(0)))
+}
+
+/*
+handleReload runs every database through the RDB encoding and back.
+*/
+func (c *DebugCommand) handleReload(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if err := utils.ReloadRDB(ctx); err != nil {
+		conn.Write([]byte(fmt.Sprintf("-ERR Error trying to load the RDB dump: %s\r\n", err)))
+		return
+	}
+
+	conn.Write([]byte("+OK\r\n"))
+}
+
+/*
+handleObject reports low level information about a key. The
+serializedlength field is the size of the DUMP payload of the key.
+Quicklist encoded lists also get the ql_* fields describing their nodes.
+*/
+func (c *DebugCommand) handleObject(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 3 {
+		conn.Write([]byte(unknownSubcommandResp(args[0], args[1])))
+		return
+	}
+
+	storeObj := utils.GetStoreObj(ctx)
+
+	payload, ok, err := storeObj.Dump(args[2])
+	if !ok {
+		conn.Write([]byte("-ERR no such key\r\n"))
+		return
+	}
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	encoding, err := storeObj.GetEncoding(args[2])
+	if err != nil {
+		// deleted or expired since the DUMP above
+		conn.Write([]byte("-ERR no such key\r\n"))
+		return
+	}
+
+	var extra string
+	if ql, ok := storeObj.Quicklist(args[2]); ok {
+		extra = fmt.Sprintf(
+			" ql_nodes:%d ql_avg_node:%.2f ql_listpack_max:%d ql_compressed:0 ql_uncompressed_size:%d",
+			ql.Nodes,
+			ql.AvgNode,
+			ql.ListpackMax,
+			ql.UncompressedSize,
+		)
+	}
+
+	conn.Write([]byte(fmt.Sprintf(
+		"+Value at:0x0 refcount:1 encoding:%s serializedlength:%d lru:0 lru_seconds_idle:0%s\r\n",
+		encoding,
+		len(payload),
+		extra,
+	)))
+}
@@ -0,0 +1,303 @@
+package commands
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+/*
+bulkString returns the payload of a bulk string reply.
+*/
+func bulkString(t *testing.T, reply string) string {
+	t.Helper()
+
+	header, payload, found := strings.Cut(reply, "\r\n")
+	if !found || !strings.HasPrefix(header, "$") {
+		t.Fatalf("reply %q is not a bulk string", reply)
+	}
+
+	return strings.TrimSuffix(payload, "\r\n")
+}
+
+func TestDebugReloadKeepsStreamLastID(t *testing.T) {
+	ctx := newTestContext(t)
+
+	// an explicit ID far in the future, so the auto ID can only beat it
+	// when the top ID survived the reload
+	execute(ctx, "XADD", "s", "9999999999999-5", "f", "v")
+
+	if got := execute(ctx, "DEBUG", "RELOAD"); got != "+OK\r\n" {
+		t.Fatalf("DEBUG RELOAD = %q", got)
+	}
+
+	if id := bulkString(t, execute(ctx, "XADD", "s", "*", "f", "v")); id != "9999999999999-6" {
+		t.Fatalf("XADD * after reload = %s, want 9999999999999-6", id)
+	}
+}
+
+func TestDebugReloadAutoIDGrows(t *testing.T) {
+	ctx := newTestContext(t)
+
+	before := bulkString(t, execute(ctx, "XADD", "s", "*", "f", "v"))
+
+	if got := execute(ctx, "DEBUG", "RELOAD"); got != "+OK\r\n" {
+		t.Fatalf("DEBUG RELOAD = %q", got)
+	}
+
+	after := bulkString(t, execute(ctx, "XADD", "s", "*", "f", "v"))
+
+	if !streamIDLess(t, before, after) {
+		t.Fatalf("auto ID %s after reload is not greater than %s", after, before)
+	}
+}
+
+func streamIDLess(t *testing.T, a string, b string) bool {
+	t.Helper()
+
+	var aMs, aSeq, bMs, bSeq uint64
+	if _, err := fmt.Sscanf(a, "%d-%d", &aMs, &aSeq); err != nil {
+		t.Fatalf("bad stream ID %q", a)
+	}
+	if _, err := fmt.Sscanf(b, "%d-%d", &bMs, &bSeq); err != nil {
+		t.Fatalf("bad stream ID %q", b)
+	}
+
+	return aMs < bMs || (aMs == bMs && aSeq < bSeq)
+}
+
+func TestDebugReloadKeepsEveryType(t *testing.T) {
+	ctx := newTestContext(t)
+
+	execute(ctx, "RPUSH", "l", "a", "b")
+	execute(ctx, "SADD", "s", "1", "2")
+	execute(ctx, "ZADD", "z", "1", "a")
+	execute(ctx, "HSET", "h", "f", "v")
+
+	if got := execute(ctx, "DEBUG", "RELOAD"); got != "+OK\r\n" {
+		t.Fatalf("DEBUG RELOAD = %q", got)
+	}
+
+	for _, tt := range []struct{ args, want string }{
+		{"LLEN l", ":2\r\n"},
+		{"SCARD s", ":2\r\n"},
+		{"ZCARD z", ":1\r\n"},
+		{"HGET h f", "$1\r\nv\r\n"},
+	} {
+		if got := execute(ctx, strings.Fields(tt.args)...); got != tt.want {
+			t.Fatalf("%s = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestDebugStringMatchLen(t *testing.T) {
+	ctx := newTestContext(t)
+
+	tests := []struct {
+		pattern string
+		str     string
+		want    bool
+	}{
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"[a-c]", "b", true},
+		{"[a-c]", "d", false},
+		{"[c-a]", "b", true},
+		{"[^a-c]", "d", true},
+		{"[^a-c]", "a", false},
+		{`[\]]`, "]", true},
+		{"[abc", "a", true},
+		{`\*`, "*", true},
+		{`\*`, "a", false},
+		{`a\?c`, "a?c", true},
+		{`a\?c`, "abc", false},
+		{`abc\`, `abc\`, true},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"*a*b*", "xxaxxbxx", true},
+		{"a**", "a", true},
+		{"*", "", true},
+		{"**", "", true},
+		{"*?", "", false},
+		{"?", "", false},
+		{"", "", true},
+		{"", "a", false},
+	}
+
+	for _, tt := range tests {
+		want := ":0\r\n"
+		if tt.want {
+			want = ":1\r\n"
+		}
+
+		if got := execute(ctx, "DEBUG", "STRINGMATCH-LEN", tt.pattern, tt.str); got != want {
+			t.Errorf("DEBUG STRINGMATCH-LEN %q %q = %q, want %q", tt.pattern, tt.str, got, want)
+		}
+	}
+}
+
+func TestDebugObjectSerializedLengthMatchesDump(t *testing.T) {
+	ctx := newTestContext(t)
+
+	execute(ctx, "SET", "string", "value")
+	execute(ctx, "SET", "int", "12345")
+	execute(ctx, "RPUSH", "list", "a", "b", "c")
+	execute(ctx, "SADD", "set", "x", "y")
+	execute(ctx, "SADD", "intset", "1", "2", "3")
+	execute(ctx, "ZADD", "zset", "1", "a", "2.5", "b")
+	execute(ctx, "HSET", "hash", "f1", "v1", "f2", "v2")
+	execute(ctx, "XADD", "stream", "1-1", "f", "v")
+
+	serializedLength := regexp.MustCompile(` serializedlength:(\d+) `)
+
+	for _, key := range []string{"string", "int", "list", "set", "intset", "zset", "hash", "stream"} {
+		t.Run(key, func(t *testing.T) {
+			dump := bulkString(t, execute(ctx, "DUMP", key))
+
+			object := execute(ctx, "DEBUG", "OBJECT", key)
+			match := serializedLength.FindStringSubmatch(object)
+			if match == nil {
+				t.Fatalf("DEBUG OBJECT %s = %q, no serializedlength", key, object)
+			}
+
+			if match[1] != strconv.Itoa(len(dump)) {
+				t.Fatalf("serializedlength of %s is %s, DUMP is %d bytes", key, match[1], len(dump))
+			}
+		})
+	}
+
+	assertReply(t, ctx, "-ERR no such key\r\n", "DEBUG", "OBJECT", "missing")
+}
+
+func TestTypeEncodingAndDebugObjectAgree(t *testing.T) {
+	encodings := map[string][]string{
+		"string": {"int", "embstr", "raw"},
+		"list":   {"listpack", "quicklist"},
+		"set":    {"intset", "listpack", "hashtable"},
+		"zset":   {"listpack", "skiplist"},
+		"hash":   {"listpack", "hashtable"},
+		"stream": {"stream"},
+	}
+
+	many := func(command string, key string, prefix []string, perMember func(i int) []string) []string {
+		args := append([]string{command, key}, prefix...)
+		for i := 0; i < 200; i++ {
+			args = append(args, perMember(i)...)
+		}
+		return args
+	}
+	long := strings.Repeat("x", 100)
+
+	tests := []struct {
+		name  string
+		steps [][]string
+		// empty empties the value, after which the key is gone
+		empty [][]string
+	}{
+		{
+			name: "string",
+			steps: [][]string{
+				{"SET", "k", "12"},
+				{"APPEND", "k", "a"},
+				{"SETRANGE", "k", "0", long},
+				{"SET", "k", "5"},
+				{"INCR", "k"},
+			},
+			empty: [][]string{{"GETDEL", "k"}},
+		},
+		{
+			name: "list",
+			steps: [][]string{
+				{"RPUSH", "k", "a", "b"},
+				{"LSET", "k", "0", long},
+				many("RPUSH", "k", nil, func(i int) []string { return []string{strconv.Itoa(i)} }),
+			},
+			empty: [][]string{{"LPOP", "k", "300"}},
+		},
+		{
+			name: "set",
+			steps: [][]string{
+				{"SADD", "k", "1", "2"},
+				{"SADD", "k", "a"},
+				many("SADD", "k", nil, func(i int) []string { return []string{"m" + strconv.Itoa(i)} }),
+				{"SREM", "k", "a"},
+			},
+			empty: [][]string{
+				many("SREM", "k", []string{"1", "2"}, func(i int) []string { return []string{"m" + strconv.Itoa(i)} }),
+			},
+		},
+		{
+			name: "zset",
+			steps: [][]string{
+				{"ZADD", "k", "1", "a"},
+				{"ZINCRBY", "k", "2", "a"},
+				{"ZADD", "k", "1", long},
+				many("ZADD", "k", nil, func(i int) []string { return []string{strconv.Itoa(i), "m" + strconv.Itoa(i)} }),
+			},
+			empty: [][]string{
+				many("ZREM", "k", []string{"a", long}, func(i int) []string { return []string{"m" + strconv.Itoa(i)} }),
+			},
+		},
+		{
+			name: "hash",
+			steps: [][]string{
+				{"HSET", "k", "f", "v"},
+				{"HINCRBY", "k", "n", "1"},
+				{"HSET", "k", "f", long},
+			},
+			empty: [][]string{{"HDEL", "k", "f", "n"}},
+		},
+		{
+			name: "stream",
+			steps: [][]string{
+				{"XADD", "k", "1-1", "f", "v"},
+				{"XADD", "k", "2-1", "f", "v"},
+				{"XGROUP", "CREATE", "k", "g", "0"},
+			},
+			empty: [][]string{{"DEL", "k"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+
+			for _, step := range tt.steps {
+				if reply := execute(ctx, step...); strings.HasPrefix(reply, "-") {
+					t.Fatalf("%s: %q", step[0], reply)
+				}
+
+				assertReply(t, ctx, "+"+tt.name+"\r\n", "TYPE", "k")
+
+				encoding := bulkString(t, execute(ctx, "OBJECT", "ENCODING", "k"))
+				if !slices.Contains(encodings[tt.name], encoding) {
+					t.Fatalf("OBJECT ENCODING after %s = %s, not an encoding of a %s", step[0], encoding, tt.name)
+				}
+
+				object := execute(ctx, "DEBUG", "OBJECT", "k")
+				if !strings.Contains(object, " encoding:"+encoding+" ") {
+					t.Fatalf("DEBUG OBJECT after %s = %q, want encoding:%s", step[0], object, encoding)
+				}
+			}
+
+			for _, step := range tt.empty {
+				if reply := execute(ctx, step...); strings.HasPrefix(reply, "-") {
+					t.Fatalf("%s: %q", step[0], reply)
+				}
+			}
+
+			assertReply(t, ctx, ":0\r\n", "EXISTS", "k")
+			assertReply(t, ctx, "+none\r\n", "TYPE", "k")
+			assertReply(t, ctx, "$-1\r\n", "OBJECT", "ENCODING", "k")
+			assertReply(t, ctx, "-ERR no such key\r\n", "DEBUG", "OBJECT", "k")
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package commands
+
+type CommandDoc struct {
+	Summary string
+	Since   string
+	Group   string
+}
+
+/*
+CommandEntry registers a command in Commands: its handler together with
+what COMMAND and COMMAND DOCS report about it. Arity counts the command
+name, a negative arity is a minimum. FirstKey, LastKey and Step locate the
+keys among the arguments, a negative LastKey counting from the end.
+*/
+type CommandEntry struct {
+	Command
+	Arity    int
+	Flags    []string
+	FirstKey int
+	LastKey  int
+	Step     int
+	Doc      CommandDoc
+}
@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/clients"
+	"github.com/codecrafters-io/redis-starter-go/internal/config"
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
+	"github.com/codecrafters-io/redis-starter-go/internal/store"
+	"github.com/codecrafters-io/redis-starter-go/internal/transactions"
+	"github.com/codecrafters-io/redis-starter-go/internal/utils"
+)
+
+func TestMain(m *testing.M) {
+	logrus.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+/*
+newTestContext builds the context of a fresh instance, wired the way
+main does it, with DB 0 selected.
+*/
+func newTestContext(t *testing.T) context.Context {
+	t.Helper()
+
+	databases := store.NewDatabases(16)
+	storeObj, _ := databases.Get(0)
+
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, "store", storeObj)
+	ctx = context.WithValue(ctx, "databases", databases)
+	ctx = context.WithValue(ctx, "clients", clients.NewClients())
+	ctx = context.WithValue(ctx, "tracking", clients.NewTracking())
+	ctx = context.WithValue(ctx, "pubsub", clients.NewPubSub(utils.MatchGlob))
+	ctx = context.WithValue(ctx, "connections", clients.NewConnections())
+	ctx = context.WithValue(ctx, "transactions", transactions.NewTransaction())
+
+	return ctx
+}
+
+func newTestConfig() config.Config {
+	return config.Config{
+		Role:             "master",
+		Master:           &config.Master{MasterReplId: "8371b4fb1155b71f4a04d3e1bc3e18c4a990aeeb"},
+		Databases:        16,
+		Save:             "3600 1 300 100 60 10000",
+		MaxMemoryPolicy:  "noeviction",
+		ReadBufferSize:   redis.DefaultReadBufferSize,
+		MaxPipelineDepth: redis.DefaultMaxPipelineDepth,
+	}
+}
+
+/*
+execute runs one command against ctx and returns the raw reply.
+*/
+func execute(ctx context.Context, args ...string) string {
+	var bb bytes.Buffer
+
+	Commands[strings.ToUpper(args[0])].Execute(ctx, &bb, newTestConfig(), args)
+
+	return bb.String()
+}
+
+/*
+assertReply runs one command against ctx and fails the test unless its
+raw reply is want.
+*/
+func assertReply(t *testing.T, ctx context.Context, want string, args ...string) {
+	t.Helper()
+
+	if got := execute(ctx, args...); got != want {
+		t.Fatalf("%s = %q, want %q", strings.Join(args, " "), got, want)
+	}
+}
+
+/*
+sortedMembers runs a command replying with an array of bulk strings, like
+SMEMBERS, and returns the elements sorted.
+*/
+func sortedMembers(t *testing.T, ctx context.Context, args ...string) []string {
+	t.Helper()
+
+	reply := execute(ctx, args...)
+	header, rest, ok := strings.Cut(reply, "\r\n")
+	if !ok || header[0] != '*' {
+		t.Fatalf("%s = %q, want an array", strings.Join(args, " "), reply)
+	}
+
+	n, _ := strconv.Atoi(header[1:])
+	members := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		var length string
+		length, rest, _ = strings.Cut(rest, "\r\n")
+		size, err := strconv.Atoi(strings.TrimPrefix(length, "$"))
+		if err != nil || len(rest) < size+2 {
+			t.Fatalf("%s = %q, want bulk strings", strings.Join(args, " "), reply)
+		}
+		members = append(members, rest[:size])
+		rest = rest[size+2:]
+	}
+
+	sort.Strings(members)
+	return members
+}
@@ -1,20 +1,125 @@
 package commands
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/codecrafters-io/redis-starter-go/internal/config"
+	"github.com/codecrafters-io/redis-starter-go/internal/store"
 	"github.com/codecrafters-io/redis-starter-go/internal/utils"
 )
 
-func (c *KeysCommand) handleAll(
+func (c *KeysCommand) handlePattern(
 	ctx context.Context,
 	conn io.Writer,
 	config config.Config,
 	args []string,
 ) {
-	fileContent := utils.ReadFile(config.RedisDir + "/" + config.RedisDbFileName)
-	conn.Write([]byte(fmt.Sprintf("*1\r\n$%d\r\n%s\r\n", len(fileContent), fileContent)))
+	pattern := args[1]
+
+	storeObj := utils.GetStoreObj(ctx)
+
+	var bb bytes.Buffer
+	var count int
+
+	storeObj.ForEach(func(key string, t store.ValueType) bool {
+		if pattern == "*" || utils.MatchGlob(pattern, key) {
+			bb.WriteString(stringResp(key))
+			count++
+		}
+		return true
+	})
+
+	conn.Write(append([]byte(arrayResp(count)), bb.Bytes()...))
+}
+
+const defaultScanCount = 10
+
+func (c *ScanCommand) handleScan(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	cursor, err := strconv.Atoi(args[1])
+	if err != nil || cursor < 0 {
+		conn.Write([]byte("-ERR invalid cursor\r\n"))
+		return
+	}
+
+	pattern := "*"
+	count := defaultScanCount
+	typeFilter := store.NoneType
+
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			conn.Write([]byte("-ERR syntax error\r\n"))
+			return
+		}
+
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			count, err = strconv.Atoi(args[i+1])
+			if err != nil {
+				conn.Write([]byte("-ERR value is not an integer or out of range\r\n"))
+				return
+			}
+			if count < 1 {
+				conn.Write([]byte("-ERR syntax error\r\n"))
+				return
+			}
+		case "TYPE":
+			t, ok := store.ParseValueType(strings.ToLower(args[i+1]))
+			if !ok {
+				conn.Write([]byte(fmt.Sprintf("-ERR unknown type name '%s'\r\n", args[i+1])))
+				return
+			}
+			typeFilter = t
+		default:
+			conn.Write([]byte("-ERR syntax error\r\n"))
+			return
+		}
+	}
+
+	storeObj := utils.GetStoreObj(ctx)
+
+	types := make(map[string]store.ValueType)
+	var keys []string
+	storeObj.ForEach(func(key string, t store.ValueType) bool {
+		keys = append(keys, key)
+		types[key] = t
+		return true
+	})
+	sort.Strings(keys)
+
+	var bb bytes.Buffer
+	var matched int
+
+	next := 0
+	if cursor < len(keys) {
+		end := min(cursor+count, len(keys))
+		for _, key := range keys[cursor:end] {
+			if typeFilter != store.NoneType && types[key] != typeFilter {
+				continue
+			}
+
+			if pattern == "*" || utils.MatchGlob(pattern, key) {
+				bb.WriteString(stringResp(key))
+				matched++
+			}
+		}
+		if end < len(keys) {
+			next = end
+		}
+	}
+
+	conn.Write([]byte(arrayResp(2) + stringResp(strconv.Itoa(next)) + arrayResp(matched)))
+	conn.Write(bb.Bytes())
 }
@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+/*
+scanAll walks the keyspace with SCAN from cursor 0 and returns every key
+it reported together with the number of calls it took.
+*/
+func scanAll(t *testing.T, ctx context.Context, args ...string) ([]string, int) {
+	t.Helper()
+
+	reply := regexp.MustCompile(`^\*2\r\n\$\d+\r\n(\d+)\r\n\*\d+\r\n`)
+	key := regexp.MustCompile(`\$\d+\r\n([^\r]*)\r\n`)
+
+	var keys []string
+	cursor := "0"
+	for calls := 1; ; calls++ {
+		got := execute(ctx, append([]string{"SCAN", cursor}, args...)...)
+
+		header := reply.FindStringSubmatch(got)
+		if header == nil {
+			t.Fatalf("SCAN %s = %q", cursor, got)
+		}
+		for _, match := range key.FindAllStringSubmatch(got[len(header[0]):], -1) {
+			keys = append(keys, match[1])
+		}
+
+		if cursor = header[1]; cursor == "0" {
+			sort.Strings(keys)
+			return keys, calls
+		}
+		if calls > 1000 {
+			t.Fatal("SCAN never returned to cursor 0")
+		}
+	}
+}
+
+func TestScanVisitsEveryKey(t *testing.T) {
+	ctx := newTestContext(t)
+	for i := 0; i < 25; i++ {
+		execute(ctx, "SET", "key:"+strconv.Itoa(i), "v")
+	}
+
+	keys, calls := scanAll(t, ctx, "COUNT", "10")
+	if len(keys) != 25 || calls != 3 {
+		t.Fatalf("SCAN COUNT 10 returned %d keys in %d calls, want 25 in 3", len(keys), calls)
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i] == keys[i-1] {
+			t.Fatalf("SCAN returned %s twice", keys[i])
+		}
+	}
+}
+
+func TestScanArguments(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "SET", "k", "v")
+
+	assertReply(t, ctx, "-ERR syntax error\r\n", "SCAN", "0", "COUNT", "0")
+	assertReply(t, ctx, "-ERR syntax error\r\n", "SCAN", "0", "COUNT", "-1")
+	assertReply(t, ctx, "-ERR value is not an integer or out of range\r\n", "SCAN", "0", "COUNT", "many")
+	assertReply(t, ctx, "-ERR invalid cursor\r\n", "SCAN", "garbage")
+	assertReply(t, ctx, "-ERR invalid cursor\r\n", "SCAN", "-1")
+	assertReply(t, ctx, "-ERR syntax error\r\n", "SCAN", "0", "COUNT")
+	assertReply(t, ctx, "-ERR syntax error\r\n", "SCAN", "0", "FOO", "bar")
+	assertReply(t, ctx, "-ERR unknown type name 'nosuch'\r\n", "SCAN", "0", "TYPE", "nosuch")
+
+	// a cursor past the end finishes the iteration
+	assertReply(t, ctx, "*2\r\n$1\r\n0\r\n*0\r\n", "SCAN", "1000")
+}
+
+func TestScanTypeHash(t *testing.T) {
+	ctx := newTestContext(t)
+	for i := 0; i < 5; i++ {
+		n := strconv.Itoa(i)
+		execute(ctx, "SET", "string:"+n, "v")
+		execute(ctx, "HSET", "hash:"+n, "f", "v")
+		execute(ctx, "RPUSH", "list:"+n, "a")
+		execute(ctx, "SADD", "set:"+n, "a")
+		execute(ctx, "ZADD", "zset:"+n, "1", "a")
+		execute(ctx, "XADD", "stream:"+n, "1-1", "f", "v")
+	}
+
+	keys, calls := scanAll(t, ctx, "TYPE", "hash", "COUNT", "1000")
+	want := []string{"hash:0", "hash:1", "hash:2", "hash:3", "hash:4"}
+	if !reflect.DeepEqual(keys, want) || calls != 1 {
+		t.Fatalf("SCAN 0 TYPE hash COUNT 1000 = %v in %d calls, want %v in one", keys, calls, want)
+	}
+
+	// the filter is case insensitive, like TYPE names in Redis
+	if keys, _ := scanAll(t, ctx, "TYPE", "STREAM", "COUNT", "1000"); len(keys) != 5 {
+		t.Fatalf("SCAN 0 TYPE STREAM = %v, want the five streams", keys)
+	}
+}
@@ -0,0 +1,31 @@
+package commands
+
+import (
+	"context"
+	"io"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/config"
+	"github.com/codecrafters-io/redis-starter-go/internal/utils"
+)
+
+func (c *ObjectCommand) handleEncoding(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) < 3 {
+		conn.Write([]byte(unknownSubcommandResp(args[0], args[1])))
+		return
+	}
+
+	storeObj := utils.GetStoreObj(ctx)
+
+	encoding, err := storeObj.GetEncoding(args[2])
+	if err != nil {
+		conn.Write([]byte("$-1\r\n"))
+		return
+	}
+
+	conn.Write([]byte(stringResp(string(encoding))))
+}
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"context"
+	"log"
+	"strings"
+)
+
+/*
+Propagation collects whether a write changed the keyspace and what it is
+forwarded to replicas as, when it is not the command itself. XADD forwards
+the ID it generated, a blocking pop forwards the pop it ended with, writes
+with a relative expiration forward the absolute time and EXEC forwards
+the writes it ran.
+*/
+type Propagation struct {
+	commands  [][]string
+	rewritten bool
+	changed   bool
+}
+
+/*
+WithPropagation returns a context holding a fresh Propagation for one
+command, together with that Propagation.
+*/
+func WithPropagation(ctx context.Context) (context.Context, *Propagation) {
+	propagation := &Propagation{}
+	return context.WithValue(ctx, "propagation", propagation), propagation
+}
+
+func GetPropagationObj(ctx context.Context) *Propagation {
+	propagationFromContext := ctx.Value("propagation")
+	if propagationFromContext != nil {
+		if propagation, ok := propagationFromContext.(*Propagation); !ok {
+			log.Fatalf("Expected *commands.Propagation, got %T", propagationFromContext)
+		} else {
+			return propagation
+		}
+	}
+	return nil
+}
+
+/*
+Rewrite replaces what the command is forwarded as. Calling it without
+commands forwards nothing. Commands run without a Propagation, like the
+ones a replica replays, ignore it.
+*/
+func (p *Propagation) Rewrite(commands ...[]string) {
+	if p == nil {
+		return
+	}
+
+	p.rewritten = true
+	p.commands = append(p.commands, commands...)
+}
+
+/*
+Changed records that the command modified the keyspace. A failed write or
+one that left the keyspace as it was, like SREM of a missing member, does
+not call it and is not forwarded.
+*/
+func (p *Propagation) Changed() {
+	if p == nil {
+		return
+	}
+
+	p.changed = true
+}
+
+/*
+Commands returns what the command run with args is forwarded to replicas
+as: its rewrite when it made one, args itself when it is Propagated and
+changed the keyspace, and nothing otherwise.
+*/
+func (p *Propagation) Commands(args []string) [][]string {
+	if p == nil {
+		return nil
+	}
+
+	if p.rewritten {
+		return p.commands
+	}
+
+	if !p.changed {
+		return nil
+	}
+
+	name := strings.ToUpper(args[0])
+	for _, command := range Propagated {
+		if command == name {
+			return [][]string{args}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,449 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/transactions"
+	"github.com/codecrafters-io/redis-starter-go/internal/utils"
+)
+
+/*
+propagated runs one command against ctx and returns what it is forwarded
+to replicas as.
+*/
+func propagated(ctx context.Context, args ...string) [][]string {
+	ctx, propagation := WithPropagation(ctx)
+
+	var bb bytes.Buffer
+	Commands[strings.ToUpper(args[0])].Execute(ctx, &bb, newTestConfig(), args)
+
+	return propagation.Commands(args)
+}
+
+func TestPropagatedCommandsExist(t *testing.T) {
+	for _, name := range Propagated {
+		if _, ok := Commands[name]; !ok {
+			t.Errorf("%s is propagated but not registered", name)
+		}
+	}
+}
+
+func TestEveryWriteIsPropagated(t *testing.T) {
+	// writes forwarded as something else, see Propagation.Rewrite
+	rewritten := map[string]bool{"BLPOP": true, "BRPOP": true, "MIGRATE": true}
+	// containers carry no flags, their write subcommands are forwarded
+	containers := map[string]bool{"XGROUP": true}
+
+	propagated := make(map[string]bool)
+	for _, name := range Propagated {
+		propagated[name] = true
+	}
+
+	for name, entry := range Commands {
+		write := false
+		for _, flag := range entry.Flags {
+			write = write || flag == "write"
+		}
+
+		switch {
+		case write && !propagated[name] && !rewritten[name]:
+			t.Errorf("%s writes but is never forwarded to replicas", name)
+		case !write && propagated[name] && !containers[name]:
+			t.Errorf("%s is propagated but not flagged as a write", name)
+		}
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup [][]string
+		args  []string
+		want  [][]string
+	}{
+		{
+			name: "write is forwarded as is",
+			args: []string{"SET", "k", "v"},
+			want: [][]string{{"SET", "k", "v"}},
+		},
+		{
+			name: "read is not forwarded",
+			args: []string{"GET", "k"},
+			want: nil,
+		},
+		{
+			name:  "XADD forwards the generated ID",
+			setup: [][]string{{"XADD", "s", "5-1", "f", "v"}},
+			args:  []string{"XADD", "s", "5-*", "f", "v"},
+			want:  [][]string{{"XADD", "s", "5-2", "f", "v"}},
+		},
+		{
+			name:  "failed XADD is not forwarded",
+			setup: [][]string{{"XADD", "s", "5-1", "f", "v"}},
+			args:  []string{"XADD", "s", "1-1", "f", "v"},
+			want:  nil,
+		},
+		{
+			name:  "BLPOP forwards LPOP",
+			setup: [][]string{{"RPUSH", "l2", "a"}},
+			args:  []string{"BLPOP", "l1", "l2", "0"},
+			want:  [][]string{{"LPOP", "l2"}},
+		},
+		{
+			name:  "BRPOP forwards RPOP",
+			setup: [][]string{{"RPUSH", "l", "a", "b"}},
+			args:  []string{"BRPOP", "l", "0"},
+			want:  [][]string{{"RPOP", "l"}},
+		},
+		{
+			name: "BLPOP timing out is not forwarded",
+			args: []string{"BLPOP", "l", "0.01"},
+			want: nil,
+		},
+		{
+			name:  "failed INCR is not forwarded",
+			setup: [][]string{{"SET", "k", "v"}},
+			args:  []string{"INCR", "k"},
+			want:  nil,
+		},
+		{
+			name:  "SADD on the wrong type is not forwarded",
+			setup: [][]string{{"SET", "k", "v"}},
+			args:  []string{"SADD", "k", "m"},
+			want:  nil,
+		},
+		{
+			name: "PERSIST of a missing key is not forwarded",
+			args: []string{"PERSIST", "k"},
+			want: nil,
+		},
+		{
+			name:  "SADD of existing members is not forwarded",
+			setup: [][]string{{"SADD", "k", "m"}},
+			args:  []string{"SADD", "k", "m"},
+			want:  nil,
+		},
+		{
+			name:  "ZADD of unchanged scores is not forwarded",
+			setup: [][]string{{"ZADD", "z", "1", "a"}},
+			args:  []string{"ZADD", "z", "1", "a"},
+			want:  nil,
+		},
+		{
+			name:  "ZADD re-scoring a member is forwarded",
+			setup: [][]string{{"ZADD", "z", "1", "a"}},
+			args:  []string{"ZADD", "z", "2", "a"},
+			want:  [][]string{{"ZADD", "z", "2", "a"}},
+		},
+		{
+			name: "SINTERSTORE of an empty result into a missing key is not forwarded",
+			args: []string{"SINTERSTORE", "dst", "a", "b"},
+			want: nil,
+		},
+		{
+			name:  "SINTERSTORE of an empty result deleting the destination is forwarded",
+			setup: [][]string{{"SADD", "dst", "m"}},
+			args:  []string{"SINTERSTORE", "dst", "a", "b"},
+			want:  [][]string{{"SINTERSTORE", "dst", "a", "b"}},
+		},
+		{
+			name: "ZUNIONSTORE of an empty result into a missing key is not forwarded",
+			args: []string{"ZUNIONSTORE", "dst", "1", "z"},
+			want: nil,
+		},
+		{
+			name: "DEL of a missing key is not forwarded",
+			args: []string{"DEL", "k"},
+			want: nil,
+		},
+		{
+			name:  "SET NX on an existing key is not forwarded",
+			setup: [][]string{{"SET", "k", "v"}},
+			args:  []string{"SET", "k", "w", "NX"},
+			want:  nil,
+		},
+		{
+			name:  "INCRBYFLOAT forwards the result",
+			setup: [][]string{{"SET", "k", "1.5"}},
+			args:  []string{"INCRBYFLOAT", "k", "0.1"},
+			want:  [][]string{{"SET", "k", "1.6", "KEEPTTL"}},
+		},
+		{
+			name:  "expiring in the past forwards DEL",
+			setup: [][]string{{"SET", "k", "v"}},
+			args:  []string{"EXPIRE", "k", "0"},
+			want:  [][]string{{"DEL", "k"}},
+		},
+		{
+			name: "EXPIRE of a missing key is not forwarded",
+			args: []string{"EXPIRE", "k", "10"},
+			want: nil,
+		},
+		{
+			name:  "GETEX PERSIST forwards PERSIST",
+			setup: [][]string{{"SET", "k", "v", "EX", "10"}},
+			args:  []string{"GETEX", "k", "PERSIST"},
+			want:  [][]string{{"PERSIST", "k"}},
+		},
+		{
+			name:  "GETEX PERSIST of a key without a deadline is not forwarded",
+			setup: [][]string{{"SET", "k", "v"}},
+			args:  []string{"GETEX", "k", "PERSIST"},
+			want:  nil,
+		},
+		{
+			name:  "GETEX without options is not forwarded",
+			setup: [][]string{{"SET", "k", "v"}},
+			args:  []string{"GETEX", "k"},
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			for _, args := range tt.setup {
+				execute(ctx, args...)
+			}
+
+			if got := propagated(ctx, tt.args...); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("%v forwarded as %v, want %v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+/*
+TestPropagationAbsoluteExpiry checks that an expiration relative to the
+time of the write reaches replicas as the absolute time the master keeps,
+so it does not move by the replication lag.
+*/
+func TestPropagationAbsoluteExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		leading [][]string
+		ttl     time.Duration
+	}{
+		{name: "EXPIRE", args: []string{"EXPIRE", "k", "100"}, ttl: 100 * time.Second},
+		{name: "PEXPIRE", args: []string{"PEXPIRE", "k", "5000"}, ttl: 5 * time.Second},
+		{name: "GETEX EX", args: []string{"GETEX", "k", "EX", "100"}, ttl: 100 * time.Second},
+		{name: "GETEX PX", args: []string{"GETEX", "k", "PX", "5000"}, ttl: 5 * time.Second},
+		{
+			name:    "SET EX",
+			args:    []string{"SET", "k", "w", "EX", "100"},
+			leading: [][]string{{"SET", "k", "w"}},
+			ttl:     100 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			execute(ctx, "SET", "k", "v")
+
+			before := time.Now()
+			got := propagated(ctx, tt.args...)
+
+			if len(got) != len(tt.leading)+1 || (tt.leading != nil && !reflect.DeepEqual(got[:len(tt.leading)], tt.leading)) {
+				t.Fatalf("%v forwarded as %v", tt.args, got)
+			}
+
+			last := got[len(got)-1]
+			if len(last) != 3 || last[0] != "PEXPIREAT" || last[1] != "k" {
+				t.Fatalf("%v forwarded as %v, want a PEXPIREAT", tt.args, got)
+			}
+
+			ms, err := strconv.ParseInt(last[2], 10, 64)
+			if err != nil {
+				t.Fatalf("PEXPIREAT time %q: %v", last[2], err)
+			}
+			if deadline := time.UnixMilli(ms); deadline.Before(before.Add(tt.ttl).Add(-time.Millisecond)) ||
+				deadline.After(time.Now().Add(tt.ttl)) {
+				t.Fatalf("PEXPIREAT %v, want %v from now", deadline, tt.ttl)
+			}
+
+			// the replica ends up with the deadline the master keeps
+			replica := newTestContext(t)
+			execute(replica, "SET", "k", "v")
+			for _, args := range got {
+				execute(replica, args...)
+			}
+			pttl := func(ctx context.Context) int64 {
+				n, _ := strconv.ParseInt(strings.Trim(execute(ctx, "PTTL", "k"), ":\r\n"), 10, 64)
+				return n
+			}
+			if master, copied := pttl(ctx), pttl(replica); copied > master || master-copied > 10 {
+				t.Fatalf("PTTL is %d on the master and %d on the replica", master, copied)
+			}
+		})
+	}
+}
+
+/*
+TestPropagationHashFieldExpiry checks that HEXPIRE reaches replicas as the
+absolute time of the changed fields only, and as an HDEL when it deletes
+them.
+*/
+func TestPropagationHashFieldExpiry(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "HSET", "h", "f1", "a", "f2", "b")
+
+	before := time.Now()
+	got := propagated(ctx, "HEXPIRE", "h", "100", "FIELDS", "2", "f1", "missing")
+	if len(got) != 1 || len(got[0]) != 6 || got[0][0] != "HPEXPIREAT" || got[0][1] != "h" ||
+		!reflect.DeepEqual(got[0][3:], []string{"FIELDS", "1", "f1"}) {
+		t.Fatalf("HEXPIRE forwarded as %v, want an HPEXPIREAT of f1", got)
+	}
+
+	ms, err := strconv.ParseInt(got[0][2], 10, 64)
+	if err != nil {
+		t.Fatalf("HPEXPIREAT time %q: %v", got[0][2], err)
+	}
+	if deadline := time.UnixMilli(ms); deadline.Before(before.Add(100*time.Second).Add(-time.Millisecond)) ||
+		deadline.After(time.Now().Add(100*time.Second)) {
+		t.Fatalf("HPEXPIREAT %v, want 100s from now", deadline)
+	}
+
+	// the replica ends up with the deadline the master keeps
+	replica := newTestContext(t)
+	execute(replica, "HSET", "h", "f1", "a", "f2", "b")
+	execute(replica, got[0]...)
+	if master, copied := execute(ctx, "HTTL", "h", "FIELDS", "2", "f1", "f2"),
+		execute(replica, "HTTL", "h", "FIELDS", "2", "f1", "f2"); copied != master {
+		t.Fatalf("HTTL is %q on the master and %q on the replica", master, copied)
+	}
+
+	if got := propagated(ctx, "HEXPIRE", "h", "100", "FIELDS", "1", "missing"); got != nil {
+		t.Fatalf("HEXPIRE of a missing field forwarded as %v", got)
+	}
+
+	got = propagated(ctx, "HEXPIRE", "h", "0", "FIELDS", "2", "f2", "missing")
+	if want := [][]string{{"HDEL", "h", "f2"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("HEXPIRE 0 forwarded as %v, want %v", got, want)
+	}
+}
+
+func TestPExpireAt(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "SET", "k", "v")
+
+	at := strconv.FormatInt(time.Now().Add(time.Minute).UnixMilli(), 10)
+	if got := execute(ctx, "PEXPIREAT", "k", at); got != ":1\r\n" {
+		t.Fatalf("PEXPIREAT = %q", got)
+	}
+	if got := execute(ctx, "TTL", "k"); got != ":60\r\n" {
+		t.Fatalf("TTL after PEXPIREAT = %q", got)
+	}
+
+	past := strconv.FormatInt(time.Now().Add(-time.Minute).UnixMilli(), 10)
+	if got := execute(ctx, "PEXPIREAT", "k", past); got != ":1\r\n" {
+		t.Fatalf("PEXPIREAT in the past = %q", got)
+	}
+	if got := execute(ctx, "EXISTS", "k"); got != ":0\r\n" {
+		t.Fatalf("key still exists after PEXPIREAT in the past: %q", got)
+	}
+	if got := execute(ctx, "PEXPIREAT", "k", at); got != ":0\r\n" {
+		t.Fatalf("PEXPIREAT of a missing key = %q", got)
+	}
+}
+
+func TestSetKeepTTL(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "SET", "k", "v", "EX", "100")
+
+	if got := execute(ctx, "SET", "k", "w", "KEEPTTL"); got != "+OK\r\n" {
+		t.Fatalf("SET KEEPTTL = %q", got)
+	}
+	if got := execute(ctx, "TTL", "k"); got != ":100\r\n" {
+		t.Fatalf("TTL after SET KEEPTTL = %q", got)
+	}
+	if got := execute(ctx, "SET", "k", "w", "KEEPTTL", "EX", "5"); got != "-ERR syntax error\r\n" {
+		t.Fatalf("SET KEEPTTL EX = %q", got)
+	}
+	if got := execute(ctx, "SET", "k", "w"); got != "+OK\r\n" {
+		t.Fatalf("SET = %q", got)
+	}
+	if got := execute(ctx, "TTL", "k"); got != ":-1\r\n" {
+		t.Fatalf("TTL after SET without KEEPTTL = %q", got)
+	}
+}
+
+func TestPropagationXAddAutoID(t *testing.T) {
+	ctx := newTestContext(t)
+
+	got := propagated(ctx, "XADD", "s", "*", "f", "v")
+	if len(got) != 1 || got[0][2] == "*" {
+		t.Fatalf("XADD * forwarded as %v", got)
+	}
+
+	if id, _ := utils.GetStoreObj(ctx).GetLastStreamID("s", ""); got[0][2] != id {
+		t.Fatalf("XADD * forwarded ID %s, stream holds %s", got[0][2], id)
+	}
+}
+
+func TestPropagationExec(t *testing.T) {
+	ctx := newTestContext(t)
+	execute(ctx, "RPUSH", "l", "a")
+
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	go io.Copy(io.Discard, peer)
+
+	transactionsObj := transactions.GetTransactionsObj(ctx)
+	transactionsObj.AddConnection(conn)
+	buffer := transactionsObj.GetTransactionBuffer(conn)
+	buffer.StartTransaction()
+	for _, args := range [][]string{
+		{"SET", "k", "v"},
+		{"GET", "k"},
+		{"XADD", "s", "7-*", "f", "v"},
+		{"BLPOP", "l", "0"},
+	} {
+		buffer.PutCommand(&transactions.BufferedCommand{CMD: Commands[args[0]].Command, Args: args})
+	}
+
+	ctx, propagation := WithPropagation(ctx)
+	Commands["EXEC"].Execute(ctx, conn, newTestConfig(), []string{"EXEC"})
+
+	want := [][]string{
+		{"MULTI"},
+		{"SET", "k", "v"},
+		{"XADD", "s", "7-0", "f", "v"},
+		{"LPOP", "l"},
+		{"EXEC"},
+	}
+	if got := propagation.Commands([]string{"EXEC"}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("EXEC forwarded as %v, want %v", got, want)
+	}
+}
+
+func TestPropagationBlockedPopForwardsPop(t *testing.T) {
+	for _, tt := range []struct{ blocking, pop string }{
+		{"BLPOP", "LPOP"},
+		{"BRPOP", "RPOP"},
+	} {
+		t.Run(tt.blocking, func(t *testing.T) {
+			ctx := newTestContext(t)
+
+			done := make(chan [][]string)
+			go func() { done <- propagated(ctx, tt.blocking, "l", "5") }()
+
+			// the pop blocks until the push below releases it
+			time.Sleep(20 * time.Millisecond)
+			execute(ctx, "RPUSH", "l", "a")
+
+			if got := <-done; !reflect.DeepEqual(got, [][]string{{tt.pop, "l"}}) {
+				t.Fatalf("%s forwarded as %v, want %s l", tt.blocking, got, tt.pop)
+			}
+		})
+	}
+}
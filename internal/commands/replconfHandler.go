@@ -6,6 +6,9 @@ import (
 	"io"
 	"net"
 	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
 
 	"github.com/codecrafters-io/redis-starter-go/internal/config"
 	"github.com/codecrafters-io/redis-starter-go/internal/utils"
@@ -19,13 +22,16 @@ func (c *ReplConfCommand) handleMaster(
 ) {
 	commands := map[string]CommandHandler{
 		"ACK":            c.handleAck,
-		"capa":           c.handleOk,
-		"listening-port": c.handleOk,
+		"CAPA":           c.handleOk,
+		"LISTENING-PORT": c.handleOk,
 	}
 
-	if handler, exists := commands[args[1]]; exists {
+	if handler, exists := commands[strings.ToUpper(args[1])]; exists {
 		handler(ctx, conn, config, args)
+		return
 	}
+
+	conn.Write([]byte(unknownSubcommandResp(args[0], args[1])))
 }
 
 func (c *ReplConfCommand) handleSlave(
@@ -34,7 +40,7 @@ func (c *ReplConfCommand) handleSlave(
 	config config.Config,
 	args []string,
 ) {
-	if args[1] == "GETACK" && args[2] == "*" {
+	if len(args) > 2 && strings.ToUpper(args[1]) == "GETACK" && args[2] == "*" {
 		offset := config.Slave.Offset.Load()
 		byteCount := len(strconv.Itoa(int(offset)))
 		conn.Write(
@@ -64,14 +70,29 @@ func (c *ReplConfCommand) handleAck(
 	config config.Config,
 	args []string,
 ) {
+	// ACK is never answered, the replica does not read replies to it
+	if len(args) != 3 {
+		return
+	}
+
+	offset, err := strconv.Atoi(args[2])
+	if err != nil || offset < 0 {
+		logrus.WithFields(logrus.Fields{
+			"package":  "commands",
+			"function": "handleAck",
+			"offset":   args[2],
+		}).Error("Invalid offset in REPLCONF ACK")
+		return
+	}
+
 	clients := utils.GetClientsObj(ctx)
 
 	if conn, ok := conn.(net.Conn); ok {
-
+		clients.Mutex.RLock()
 		_, ok := clients.Clients[conn]
+		clients.Mutex.RUnlock()
 
 		if ok {
-			offset, _ := strconv.Atoi(args[2])
 			clients.SetOffset(conn, offset)
 		}
 	}
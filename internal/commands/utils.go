@@ -2,9 +2,17 @@ package commands
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/codecrafters-io/redis-starter-go/internal/store"
+	"github.com/codecrafters-io/redis-starter-go/internal/utils"
 )
 
 func arrayResp(elements int) string {
@@ -25,12 +33,657 @@ func writeStreamMessage(bb *bytes.Buffer, streamKey string, streamMessages []sto
 		bb.WriteString(arrayResp(2))
 		bb.WriteString(stringResp(msg.ID))
 
-		fields := msg.Fields
-		bb.WriteString(arrayResp(len(fields) * 2))
+		bb.WriteString(arrayResp(len(msg.Fields) * 2))
 
-		for k, v := range fields {
-			bb.WriteString(stringResp(k))
-			bb.WriteString(stringResp(v))
+		for _, field := range msg.Fields {
+			bb.WriteString(stringResp(field.Name))
+			bb.WriteString(stringResp(field.Value))
 		}
 	}
 }
+
+/*
+formatScore writes a sorted set score the way Redis does: the shortest
+representation that round-trips, exponent notation only for very large or
+very small values, and inf or -inf for infinities.
+*/
+func formatScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	}
+
+	if abs := math.Abs(score); abs != 0 && (abs < 1e-4 || abs >= 1e21) {
+		return strconv.FormatFloat(score, 'g', -1, 64)
+	}
+
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+/*
+parseScoreBound parses a ZRANGEBYSCORE bound, a float or -inf/+inf,
+optionally prefixed with ( to make it exclusive.
+*/
+func parseScoreBound(bound string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(bound, "(")
+	if exclusive {
+		bound = bound[1:]
+	}
+
+	score, err := strconv.ParseFloat(bound, 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, false, errors.New("ERR min or max is not a float")
+	}
+
+	return score, exclusive, nil
+}
+
+func wrongArgumentsResp(command string) string {
+	return fmt.Sprintf(
+		"-ERR wrong number of arguments for '%s' command\r\n",
+		strings.ToLower(command),
+	)
+}
+
+/*
+NoTransactionStateResp is the reply to a command that needs the
+transaction buffer of a connection the transactions registry does not know.
+*/
+const NoTransactionStateResp = "-ERR no transaction state for this connection\r\n"
+
+/*
+UnknownCommandResp is the reply to a command name missing from Commands.
+Like Redis it quotes the name and the arguments, each cut to 128 bytes.
+*/
+func UnknownCommandResp(args []string) string {
+	var builder strings.Builder
+
+	for _, arg := range args[1:] {
+		builder.WriteString(fmt.Sprintf("'%.128s' ", arg))
+	}
+
+	return fmt.Sprintf(
+		"-ERR unknown command '%.128s', with args beginning with: %s\r\n",
+		args[0],
+		builder.String(),
+	)
+}
+
+func unknownSubcommandResp(command string, subcommand string) string {
+	return fmt.Sprintf(
+		"-ERR Unknown subcommand or wrong number of arguments for '%s'. Try %s HELP.\r\n",
+		subcommand,
+		strings.ToUpper(command),
+	)
+}
+
+func integerResp(value int) string {
+	return fmt.Sprintf(":%d\r\n", value)
+}
+
+/*
+parseExpiry converts the value of an EX or PX option of command to
+milliseconds. Like Redis, it rejects expiry times that are not positive.
+*/
+func parseExpiry(command string, option string, value string) (int, error) {
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.New("ERR value is not an integer or out of range")
+	}
+
+	if parsed <= 0 {
+		return 0, fmt.Errorf("ERR invalid expire time in '%s' command", strings.ToLower(command))
+	}
+
+	if strings.ToUpper(option) == "EX" {
+		parsed *= 1000
+	}
+
+	return parsed, nil
+}
+
+/*
+incrBy applies delta to the integer stored at key and writes the new value,
+or the store error, to conn.
+*/
+func incrBy(ctx context.Context, conn io.Writer, key string, delta int64) {
+	value, err := utils.GetStoreObj(ctx).IncrBy(key, delta)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	GetPropagationObj(ctx).Changed()
+
+	conn.Write([]byte(fmt.Sprintf(":%d\r\n", value)))
+}
+
+/*
+pop serves LPOP and RPOP. Without a count it replies with a single bulk
+string, with a count it replies with an array of up to count elements.
+*/
+func pop(ctx context.Context, conn io.Writer, args []string, left bool) {
+	if len(args) < 2 || len(args) > 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	count := 1
+	withCount := len(args) == 3
+
+	if withCount {
+		n, err := strconv.Atoi(args[2])
+		if err != nil || n < 0 {
+			conn.Write([]byte("-ERR value is out of range, must be positive\r\n"))
+			return
+		}
+		count = n
+	}
+
+	storeObj := utils.GetStoreObj(ctx)
+
+	popFn := storeObj.RPop
+	if left {
+		popFn = storeObj.LPop
+	}
+
+	elements, err := popFn(args[1], count)
+	if errors.Is(err, store.ErrNotFound) {
+		if withCount {
+			conn.Write([]byte("*-1\r\n"))
+		} else {
+			conn.Write([]byte("$-1\r\n"))
+		}
+		return
+	}
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	if len(elements) > 0 {
+		GetPropagationObj(ctx).Changed()
+	}
+
+	if !withCount {
+		conn.Write([]byte(stringResp(elements[0])))
+		return
+	}
+
+	writeStrings(conn, elements)
+}
+
+/*
+blockingPop serves BLPOP and BRPOP. It pops from the first non-empty list
+among the keys, blocking until a push to one of them or until the timeout
+in seconds elapses. A zero timeout blocks forever. It gives up without
+popping when ctx is cancelled, as it is when the client disconnects.
+*/
+func blockingPop(ctx context.Context, conn io.Writer, args []string, left bool) {
+	if len(args) < 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	seconds, err := strconv.ParseFloat(args[len(args)-1], 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		conn.Write([]byte("-ERR timeout is not a float or out of range\r\n"))
+		return
+	}
+
+	if seconds < 0 {
+		conn.Write([]byte("-ERR timeout is negative\r\n"))
+		return
+	}
+
+	keys := args[1 : len(args)-1]
+	storeObj := utils.GetStoreObj(ctx)
+
+	popFn := storeObj.RPop
+	if left {
+		popFn = storeObj.LPop
+	}
+
+	var timerCh <-chan time.Time
+	if seconds > 0 {
+		timerCh = time.After(time.Duration(seconds * float64(time.Second)))
+	}
+
+	for {
+		// a push may release the waiter of a client that is already gone
+		if ctx.Err() != nil {
+			return
+		}
+
+		// register before trying, so a push right after an empty pop
+		// still releases the waiter
+		wait := storeObj.WatchKeys(keys)
+
+		for _, key := range keys {
+			elements, err := popFn(key, 1)
+			if errors.Is(err, store.ErrNotFound) {
+				continue
+			}
+
+			storeObj.Unwatch(wait)
+
+			if err != nil {
+				conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+				return
+			}
+
+			// replicas replay the pop itself, they must never block
+			popCommand := "RPOP"
+			if left {
+				popCommand = "LPOP"
+			}
+			GetPropagationObj(ctx).Rewrite([]string{popCommand, key})
+
+			conn.Write([]byte(arrayResp(2) + stringResp(key) + stringResp(elements[0])))
+			return
+		}
+
+		select {
+		case <-wait:
+		case <-timerCh:
+			storeObj.Unwatch(wait)
+			conn.Write([]byte("*-1\r\n"))
+			return
+		case <-ctx.Done():
+			storeObj.Unwatch(wait)
+			return
+		}
+	}
+}
+
+/*
+setOp serves SINTER, SUNION and SDIFF, replying with the resulting members.
+*/
+func setOp(ctx context.Context, conn io.Writer, args []string, op store.SetOperation) {
+	if len(args) < 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	members, err := utils.GetStoreObj(ctx).SetOp(op, args[1:])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	writeStrings(conn, members)
+}
+
+/*
+setOpStore serves SINTERSTORE, SUNIONSTORE and SDIFFSTORE, replying with
+the cardinality of the stored result. It is forwarded only when it wrote
+or deleted destination.
+*/
+func setOpStore(ctx context.Context, conn io.Writer, args []string, op store.SetOperation) {
+	if len(args) < 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	cardinality, written, err := utils.GetStoreObj(ctx).SetOpStore(args[1], op, args[2:])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	if written {
+		GetPropagationObj(ctx).Changed()
+	}
+
+	conn.Write([]byte(integerResp(cardinality)))
+}
+
+/*
+zSetOpStore serves ZUNIONSTORE and ZINTERSTORE:
+destination numkeys key [key ...] [WEIGHTS weight ...] [AGGREGATE SUM|MIN|MAX].
+*/
+func zSetOpStore(ctx context.Context, conn io.Writer, args []string, op store.SetOperation) {
+	if len(args) < 4 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	numKeys, err := strconv.Atoi(args[2])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", store.ErrNotInteger)))
+		return
+	}
+
+	if numKeys < 1 {
+		conn.Write([]byte(fmt.Sprintf(
+			"-ERR at least 1 input key is needed for '%s' command\r\n",
+			strings.ToLower(args[0]),
+		)))
+		return
+	}
+
+	if 3+numKeys > len(args) {
+		conn.Write([]byte("-ERR syntax error\r\n"))
+		return
+	}
+
+	keys := args[3 : 3+numKeys]
+
+	weights := make([]float64, numKeys)
+	for i := range weights {
+		weights[i] = 1
+	}
+
+	aggregate := store.AggregateSum
+
+	for i := 3 + numKeys; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "WEIGHTS":
+			if i+numKeys >= len(args) {
+				conn.Write([]byte("-ERR syntax error\r\n"))
+				return
+			}
+
+			for j := range weights {
+				i++
+				weights[j], err = strconv.ParseFloat(args[i], 64)
+				if err != nil || math.IsNaN(weights[j]) {
+					conn.Write([]byte("-ERR weight value is not a float\r\n"))
+					return
+				}
+			}
+
+		case "AGGREGATE":
+			if i+1 >= len(args) {
+				conn.Write([]byte("-ERR syntax error\r\n"))
+				return
+			}
+
+			i++
+			switch strings.ToUpper(args[i]) {
+			case "SUM":
+				aggregate = store.AggregateSum
+			case "MIN":
+				aggregate = store.AggregateMin
+			case "MAX":
+				aggregate = store.AggregateMax
+			default:
+				conn.Write([]byte("-ERR syntax error\r\n"))
+				return
+			}
+
+		default:
+			conn.Write([]byte("-ERR syntax error\r\n"))
+			return
+		}
+	}
+
+	cardinality, written, err := utils.GetStoreObj(ctx).ZSetOpStore(args[1], op, keys, weights, aggregate)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	if written {
+		GetPropagationObj(ctx).Changed()
+	}
+
+	conn.Write([]byte(integerResp(cardinality)))
+}
+
+/*
+subscriptionResp builds a (un)subscribe confirmation frame, sent as a push
+on RESP3 connections. A nil name is used when an unsubscribe-all finds no
+subscriptions.
+*/
+func subscriptionResp(protocol int, kind string, name *string, count int) string {
+	resp := arrayResp(3) + stringResp(kind)
+	if protocol >= 3 {
+		resp = ">3\r\n" + stringResp(kind)
+	}
+
+	if name == nil {
+		resp += "$-1\r\n"
+	} else {
+		resp += stringResp(*name)
+	}
+
+	return resp + integerResp(count)
+}
+
+/*
+bytesToHuman formats a byte count the way INFO does, e.g. 1.50M.
+*/
+func bytesToHuman(n int64) string {
+	units := []string{"K", "M", "G", "T", "P"}
+
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	value := float64(n)
+	for _, unit := range units {
+		value /= 1024
+		if value < 1024 || unit == units[len(units)-1] {
+			return fmt.Sprintf("%.2f%s", value, unit)
+		}
+	}
+
+	return ""
+}
+
+/*
+writeTTL replies with the time to live of args[1] in the given unit,
+-2 when the key does not exist and -1 when it has no expiration.
+*/
+func writeTTL(ctx context.Context, conn io.Writer, args []string, unit time.Duration) {
+	if len(args) != 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	ttl, exists, hasExpiry := utils.GetStoreObj(ctx).TTLRemaining(args[1])
+
+	switch {
+	case !exists:
+		conn.Write([]byte(integerResp(-2)))
+	case !hasExpiry:
+		conn.Write([]byte(integerResp(-1)))
+	default:
+		// round to the nearest unit like Redis does
+		conn.Write([]byte(integerResp(int((ttl + unit/2) / unit))))
+	}
+}
+
+/*
+expire serves EXPIRE and PEXPIRE with args[2] given in unit.
+*/
+func expire(ctx context.Context, conn io.Writer, args []string, unit time.Duration) {
+	if len(args) != 3 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	n, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", store.ErrNotInteger)))
+		return
+	}
+
+	// the deadline is kept as a time.Duration, which counts nanoseconds
+	if n > math.MaxInt64/int64(unit) {
+		conn.Write([]byte(fmt.Sprintf(
+			"-ERR invalid expire time in '%s' command\r\n",
+			strings.ToLower(args[0]),
+		)))
+		return
+	}
+
+	deadline := time.Now()
+	if n > 0 {
+		deadline = deadline.Add(time.Duration(n) * unit)
+	}
+
+	expireAt(ctx, conn, args[1], deadline)
+}
+
+/*
+expireAt sets the deadline of key. A time that is not in the future
+deletes the key right away instead of storing a deadline that has already
+passed. Replicas get the deletion as a DEL and the deadline as a
+PEXPIREAT, so they expire the key at the same time as the master.
+*/
+func expireAt(ctx context.Context, conn io.Writer, key string, deadline time.Time) {
+	storeObj := utils.GetStoreObj(ctx)
+
+	if !deadline.After(time.Now()) {
+		if !storeObj.Del(key) {
+			conn.Write([]byte(integerResp(0)))
+			return
+		}
+
+		GetPropagationObj(ctx).Rewrite([]string{"DEL", key})
+		conn.Write([]byte(integerResp(1)))
+		return
+	}
+
+	if !storeObj.ExpireAt(key, deadline) {
+		conn.Write([]byte(integerResp(0)))
+		return
+	}
+
+	GetPropagationObj(ctx).Rewrite(pexpireAtArgs(key, deadline))
+	conn.Write([]byte(integerResp(1)))
+}
+
+/*
+hExpireAt sets the time fields of the hash at key expire at. Replicas get
+the fields it deleted as an HDEL and those it set a deadline for as an
+HPEXPIREAT, the fields it left alone are not forwarded.
+*/
+func hExpireAt(ctx context.Context, conn io.Writer, key string, deadline time.Time, fields []string) {
+	storeObj := utils.GetStoreObj(ctx)
+
+	result, err := storeObj.HExpireAt(key, deadline, fields)
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err.Error())))
+		return
+	}
+
+	var set, deleted []string
+	for i, code := range result {
+		switch code {
+		case store.HashFieldTTLSet:
+			set = append(set, fields[i])
+		case store.HashFieldDeleted:
+			deleted = append(deleted, fields[i])
+		}
+	}
+
+	if len(deleted) > 0 {
+		GetPropagationObj(ctx).Rewrite(append([]string{"HDEL", key}, deleted...))
+	}
+	if len(set) > 0 {
+		GetPropagationObj(ctx).Rewrite(hpexpireAtArgs(key, deadline, set))
+	}
+
+	writeIntegers(conn, result)
+}
+
+/*
+del serves DEL and UNLINK, which always frees large values in the
+background.
+*/
+func del(ctx context.Context, conn io.Writer, args []string, unlink bool) {
+	if len(args) < 2 {
+		conn.Write([]byte(wrongArgumentsResp(args[0])))
+		return
+	}
+
+	storeObj := utils.GetStoreObj(ctx)
+
+	delFn := storeObj.Del
+	if unlink {
+		delFn = storeObj.Unlink
+	}
+
+	var deleted int
+
+	for _, key := range args[1:] {
+		if delFn(key) {
+			deleted++
+		}
+	}
+
+	if deleted > 0 {
+		GetPropagationObj(ctx).Changed()
+	}
+
+	conn.Write([]byte(integerResp(deleted)))
+}
+
+/*
+pexpireAtArgs is the PEXPIREAT forwarded to replicas for an expiration set
+on the master, so they expire the key at the same time instead of counting
+the time to live from when the write reaches them.
+*/
+func pexpireAtArgs(key string, at time.Time) []string {
+	return []string{"PEXPIREAT", key, strconv.FormatInt(at.UnixMilli(), 10)}
+}
+
+/*
+hpexpireAtArgs is the HPEXPIREAT forwarded to replicas for a field
+expiration set on the master.
+*/
+func hpexpireAtArgs(key string, at time.Time, fields []string) []string {
+	args := []string{"HPEXPIREAT", key, strconv.FormatInt(at.UnixMilli(), 10), "FIELDS", strconv.Itoa(len(fields))}
+	return append(args, fields...)
+}
+
+func writeStrings(conn io.Writer, values []string) {
+	var bb bytes.Buffer
+
+	bb.WriteString(arrayResp(len(values)))
+
+	for _, value := range values {
+		bb.WriteString(stringResp(value))
+	}
+
+	conn.Write(bb.Bytes())
+}
+
+func writeIntegers(conn io.Writer, values []int) {
+	var bb bytes.Buffer
+
+	bb.WriteString(arrayResp(len(values)))
+
+	for _, value := range values {
+		bb.WriteString(integerResp(value))
+	}
+
+	conn.Write(bb.Bytes())
+}
+
+/*
+parseFieldsArg parses the "FIELDS numfields field [field ...]" block
+that starts at the given index of args.
+*/
+func parseFieldsArg(args []string, index int) ([]string, error) {
+	if strings.ToUpper(args[index]) != "FIELDS" {
+		return nil, errors.New("Mandatory argument FIELDS is missing or not at the right position")
+	}
+
+	numFields, err := strconv.Atoi(args[index+1])
+	if err != nil || numFields <= 0 {
+		return nil, errors.New("Parameter `numFields` should be greater than 0")
+	}
+
+	fields := args[index+2:]
+	if len(fields) != numFields {
+		return nil, errors.New("The `numfields` parameter must match the number of arguments")
+	}
+
+	return fields, nil
+}
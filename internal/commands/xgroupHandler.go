@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/config"
+	"github.com/codecrafters-io/redis-starter-go/internal/utils"
+)
+
+/*
+handleCreate implements XGROUP CREATE key group id [MKSTREAM].
+*/
+func (c *XGroupCommand) handleCreate(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 5 && len(args) != 6 {
+		conn.Write([]byte(unknownSubcommandResp(args[0], args[1])))
+		return
+	}
+
+	mkstream := false
+	if len(args) == 6 {
+		if strings.ToUpper(args[5]) != "MKSTREAM" {
+			conn.Write([]byte("-ERR syntax error\r\n"))
+			return
+		}
+		mkstream = true
+	}
+
+	storeObj := utils.GetStoreObj(ctx)
+
+	if err := storeObj.XGroupCreate(args[2], args[3], args[4], mkstream); err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	GetPropagationObj(ctx).Changed()
+
+	conn.Write([]byte("+OK\r\n"))
+}
+
+/*
+handleCreateConsumer implements XGROUP CREATECONSUMER key group consumer.
+*/
+func (c *XGroupCommand) handleCreateConsumer(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 5 {
+		conn.Write([]byte(unknownSubcommandResp(args[0], args[1])))
+		return
+	}
+
+	storeObj := utils.GetStoreObj(ctx)
+
+	created, err := storeObj.XGroupCreateConsumer(args[2], args[3], args[4])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	if created {
+		GetPropagationObj(ctx).Changed()
+		conn.Write([]byte(integerResp(1)))
+		return
+	}
+
+	conn.Write([]byte(integerResp(0)))
+}
@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/config"
+	"github.com/codecrafters-io/redis-starter-go/internal/utils"
+)
+
+/*
+handleGroups implements XINFO GROUPS key.
+*/
+func (c *XInfoCommand) handleGroups(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 3 {
+		conn.Write([]byte(unknownSubcommandResp(args[0], args[1])))
+		return
+	}
+
+	storeObj := utils.GetStoreObj(ctx)
+
+	groups, err := storeObj.XInfoGroups(args[2])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	var bb bytes.Buffer
+
+	bb.WriteString(arrayResp(len(groups)))
+
+	for _, group := range groups {
+		bb.WriteString(arrayResp(8))
+		bb.WriteString(stringResp("name"))
+		bb.WriteString(stringResp(group.Name))
+		bb.WriteString(stringResp("consumers"))
+		bb.WriteString(integerResp(group.Consumers))
+		bb.WriteString(stringResp("pending"))
+		bb.WriteString(integerResp(group.Pending))
+		bb.WriteString(stringResp("last-delivered-id"))
+		bb.WriteString(stringResp(group.LastDeliveredID))
+	}
+
+	conn.Write(bb.Bytes())
+}
+
+/*
+handleConsumers implements XINFO CONSUMERS key group.
+*/
+func (c *XInfoCommand) handleConsumers(
+	ctx context.Context,
+	conn io.Writer,
+	config config.Config,
+	args []string,
+) {
+	if len(args) != 4 {
+		conn.Write([]byte(unknownSubcommandResp(args[0], args[1])))
+		return
+	}
+
+	storeObj := utils.GetStoreObj(ctx)
+
+	consumers, err := storeObj.XInfoConsumers(args[2], args[3])
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		return
+	}
+
+	var bb bytes.Buffer
+
+	bb.WriteString(arrayResp(len(consumers)))
+
+	for _, consumer := range consumers {
+		bb.WriteString(arrayResp(6))
+		bb.WriteString(stringResp("name"))
+		bb.WriteString(stringResp(consumer.Name))
+		bb.WriteString(stringResp("pending"))
+		bb.WriteString(integerResp(consumer.Pending))
+		bb.WriteString(stringResp("idle"))
+		bb.WriteString(integerResp(int(consumer.Idle.Milliseconds())))
+	}
+
+	conn.Write(bb.Bytes())
+}
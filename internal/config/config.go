@@ -1,6 +1,9 @@
 package config
 
-import "sync/atomic"
+import (
+	"sync"
+	"sync/atomic"
+)
 
 type Config struct {
 	Port   int
@@ -10,14 +13,49 @@ type Config struct {
 
 	RedisDir        string
 	RedisDbFileName string
+
+	Save            string
+	AppendOnly      bool
+	MaxMemory       int64
+	MaxMemoryPolicy string
+	Timeout         int
+	TcpKeepalive    int
+	Databases       int
+
+	LazyfreeLazyExpire  bool
+	LazyfreeLazyUserDel bool
+
+	ListMaxListpackSize    int
+	HashMaxListpackEntries int
+	HashMaxListpackValue   int
+	SetMaxIntsetEntries    int
+	SetMaxListpackEntries  int
+	SetMaxListpackValue    int
+	Resp3Keepalive         int
+	ProtoMaxBulkLen        int64
+	ReadBufferSize         int
+	MaxPipelineDepth       int
 }
 
 type Slave struct {
-	Replicaof string
-	Offset    atomic.Int64
+	Replicaof  string
+	MasterHost string
+	MasterPort string
+	Offset     atomic.Int64
+
+	LinkUp         atomic.Bool
+	SyncInProgress atomic.Bool
 }
 
 type Master struct {
 	MasterReplId     string
 	MasterReplOffset atomic.Int64
+
+	// Propagation serializes the replication stream: the offset, the
+	// selected DB and the replicas writes are sent to change under it.
+	Propagation sync.Mutex
+
+	// SelectedDB is the database the replication stream last selected.
+	// It is reset to -1 on every full sync so the next write re-selects.
+	SelectedDB atomic.Int64
 }
@@ -87,8 +87,17 @@ func (b *QueuedConditionHandler) Handle(
 ) bool {
 	transactionsObj := transactions.GetTransactionsObj(ctx)
 	transactionBufferObj := transactionsObj.GetTransactionBuffer(conn.(net.Conn))
+	if transactionBufferObj == nil {
+		conn.Write([]byte(commands.NoTransactionStateResp))
+		return false
+	}
+
+	switch cmd.(type) {
+	case *commands.ExecCommand, *commands.MultiCommand:
+		return b.HandleNext(ctx, conn, config, args, cmd)
+	}
 
-	if _, ok := cmd.(*commands.ExecCommand); !ok && transactionBufferObj.IsTransactionActive() {
+	if transactionBufferObj.IsTransactionActive() {
 
 		transactionBufferObj.PutCommand(&transactions.BufferedCommand{
 			CMD:  cmd,
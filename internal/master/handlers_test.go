@@ -0,0 +1,166 @@
+package master
+
+import (
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/commands"
+	"github.com/codecrafters-io/redis-starter-go/internal/config"
+	"github.com/codecrafters-io/redis-starter-go/internal/transactions"
+)
+
+/*
+reply runs fn with one end of a pipe and returns what it wrote there.
+*/
+func reply(t *testing.T, fn func(conn net.Conn)) string {
+	t.Helper()
+
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	written := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(peer)
+		written <- string(b)
+	}()
+
+	fn(conn)
+	conn.Close()
+
+	return <-written
+}
+
+func TestUnknownConnectionGetsTransactionError(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "transactions", transactions.NewTransaction())
+
+	for _, name := range []string{"MULTI", "EXEC", "DISCARD"} {
+		got := reply(t, func(conn net.Conn) {
+			commands.Commands[name].Execute(ctx, conn, config.Config{}, []string{name})
+		})
+		if got != commands.NoTransactionStateResp {
+			t.Fatalf("%s = %q, want %q", name, got, commands.NoTransactionStateResp)
+		}
+	}
+
+	handler := &QueuedConditionHandler{}
+	var handled bool
+	got := reply(t, func(conn net.Conn) {
+		handled = handler.Handle(ctx, conn, config.Config{}, []string{"SET", "k", "v"}, commands.Commands["SET"].Command)
+	})
+	if handled || got != commands.NoTransactionStateResp {
+		t.Fatalf("QueuedConditionHandler = %v, %q, want false, %q", handled, got, commands.NoTransactionStateResp)
+	}
+}
+
+func TestNestedMulti(t *testing.T) {
+	c := dial(t, serve(t, newTestContext(t), newTestConfig()))
+
+	if got := c.do("MULTI"); got != "+OK\r\n" {
+		t.Fatalf("first MULTI = %q", got)
+	}
+	if got := c.do("MULTI"); got != "-ERR MULTI calls can not be nested\r\n" {
+		t.Fatalf("second MULTI = %q", got)
+	}
+
+	// the transaction opened by the first MULTI is still usable
+	if got := c.do("EXEC"); got != "*0\r\n" {
+		t.Fatalf("EXEC = %q", got)
+	}
+}
+
+func TestExecReportsErrorPerCommand(t *testing.T) {
+	c := dial(t, serve(t, newTestContext(t), newTestConfig()))
+
+	c.do("MULTI")
+	c.do("SET", "k", "abc")
+	c.do("INCR", "k")
+	c.do("GET", "k")
+
+	want := "*3\r\n+OK\r\n-ERR value is not an integer or out of range\r\n$3\r\nabc\r\n"
+	if got := c.do("EXEC"); got != want {
+		t.Fatalf("EXEC = %q, want %q", got, want)
+	}
+}
+
+func TestSubscribeDeliversPushFramesOverRESP3(t *testing.T) {
+	ctx := newTestContext(t)
+	srv := serve(t, ctx, newTestConfig())
+
+	resp3 := dial(t, srv)
+	resp3.do("HELLO", "3")
+	resp2 := dial(t, srv)
+
+	for _, tt := range []struct {
+		c    *client
+		kind string
+	}{{resp3, ">"}, {resp2, "*"}} {
+		if got, want := tt.c.do("SUBSCRIBE", "ch"), tt.kind+"3\r\n$9\r\nsubscribe\r\n$2\r\nch\r\n:1\r\n"; got != want {
+			t.Fatalf("SUBSCRIBE ch = %q, want %q", got, want)
+		}
+	}
+	if got, want := resp3.do("PSUBSCRIBE", "c*"), ">3\r\n$10\r\npsubscribe\r\n$2\r\nc*\r\n:2\r\n"; got != want {
+		t.Fatalf("PSUBSCRIBE c* = %q, want %q", got, want)
+	}
+
+	if got := dial(t, srv).do("PUBLISH", "ch", "hello"); got != ":3\r\n" {
+		t.Fatalf("PUBLISH = %q, want :3", got)
+	}
+
+	if got, want := resp2.read(), "*3\r\n$7\r\nmessage\r\n$2\r\nch\r\n$5\r\nhello\r\n"; got != want {
+		t.Fatalf("RESP2 subscriber got %q, want %q", got, want)
+	}
+
+	// a RESP3 subscriber gets push frames and keeps answering commands
+	// with regular replies
+	got := []string{resp3.read(), resp3.read()}
+	sort.Strings(got)
+	want := []string{
+		">3\r\n$7\r\nmessage\r\n$2\r\nch\r\n$5\r\nhello\r\n",
+		">4\r\n$8\r\npmessage\r\n$2\r\nc*\r\n$2\r\nch\r\n$5\r\nhello\r\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("RESP3 subscriber got %q, want %q", got, want)
+	}
+	if got := resp3.do("PING"); got != "+PONG\r\n" {
+		t.Fatalf("PING while subscribed over RESP3 = %q, want +PONG", got)
+	}
+}
+
+func TestBareUnsubscribe(t *testing.T) {
+	ctx := newTestContext(t)
+	srv := serve(t, ctx, newTestConfig())
+	c := dial(t, srv)
+
+	c.send("SUBSCRIBE", "a", "b")
+	for _, want := range []string{
+		"*3\r\n$9\r\nsubscribe\r\n$1\r\na\r\n:1\r\n",
+		"*3\r\n$9\r\nsubscribe\r\n$1\r\nb\r\n:2\r\n",
+	} {
+		if got := c.read(); got != want {
+			t.Fatalf("SUBSCRIBE reply = %q, want %q", got, want)
+		}
+	}
+
+	c.send("UNSUBSCRIBE")
+	for _, want := range []string{
+		"*3\r\n$11\r\nunsubscribe\r\n$1\r\na\r\n:1\r\n",
+		"*3\r\n$11\r\nunsubscribe\r\n$1\r\nb\r\n:0\r\n",
+	} {
+		if got := c.read(); got != want {
+			t.Fatalf("UNSUBSCRIBE reply = %q, want %q", got, want)
+		}
+	}
+
+	// out of subscribed mode, regular commands work again
+	if got := c.do("PING"); got != "+PONG\r\n" {
+		t.Fatalf("PING after UNSUBSCRIBE = %q", got)
+	}
+
+	if got := c.do("UNSUBSCRIBE"); got != "*3\r\n$11\r\nunsubscribe\r\n$-1\r\n:0\r\n" {
+		t.Fatalf("UNSUBSCRIBE without subscriptions = %q", got)
+	}
+}
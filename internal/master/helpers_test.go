@@ -0,0 +1,308 @@
+package master
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/clients"
+	"github.com/codecrafters-io/redis-starter-go/internal/config"
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
+	"github.com/codecrafters-io/redis-starter-go/internal/slave"
+	"github.com/codecrafters-io/redis-starter-go/internal/store"
+	"github.com/codecrafters-io/redis-starter-go/internal/transactions"
+	"github.com/codecrafters-io/redis-starter-go/internal/utils"
+)
+
+func TestMain(m *testing.M) {
+	logrus.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+/*
+newTestContext builds the context of a fresh instance, wired the way
+main does it.
+*/
+func newTestContext(t *testing.T) context.Context {
+	t.Helper()
+
+	databases := store.NewDatabases(16)
+	tracking := clients.NewTracking()
+	for _, db := range databases.All() {
+		db.SetWriteHook(tracking.Invalidate)
+	}
+	storeObj, _ := databases.Get(0)
+
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, "store", storeObj)
+	ctx = context.WithValue(ctx, "databases", databases)
+	ctx = context.WithValue(ctx, "clients", clients.NewClients())
+	ctx = context.WithValue(ctx, "tracking", tracking)
+	ctx = context.WithValue(ctx, "pubsub", clients.NewPubSub(utils.MatchGlob))
+	ctx = context.WithValue(ctx, "connections", clients.NewConnections())
+	ctx = context.WithValue(ctx, "transactions", transactions.NewTransaction())
+
+	return ctx
+}
+
+func newTestConfig() config.Config {
+	return config.Config{
+		Role:             "master",
+		Master:           &config.Master{MasterReplId: "8371b4fb1155b71f4a04d3e1bc3e18c4a990aeeb"},
+		Databases:        16,
+		MaxMemoryPolicy:  "noeviction",
+		ReadBufferSize:   redis.DefaultReadBufferSize,
+		MaxPipelineDepth: redis.DefaultMaxPipelineDepth,
+	}
+}
+
+/*
+server is an in-process instance accepting connections the way main does.
+Accepted receives the server side of every connection.
+*/
+type server struct {
+	addr     string
+	accepted chan *clients.SyncConn
+}
+
+func serve(t *testing.T, ctx context.Context, cfg config.Config) *server {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	srv := &server{addr: l.Addr().String(), accepted: make(chan *clients.SyncConn, 16)}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			syncConn := clients.NewSyncConn(conn)
+			utils.GetConnectionsObj(ctx).Add(syncConn)
+			transactions.GetTransactionsObj(ctx).AddConnection(syncConn)
+
+			select {
+			case srv.accepted <- syncConn:
+			default:
+			}
+
+			go ReadFromConnection(ctx, syncConn, cfg)
+		}
+	}()
+
+	return srv
+}
+
+/*
+client is a connection to a test server that reads one reply per command.
+*/
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, srv *server) *client {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", srv.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *client) send(args ...string) {
+	c.t.Helper()
+
+	if _, err := c.conn.Write([]byte(redis.ConvertToRESP(args))); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+/*
+do sends a command and returns its raw reply.
+*/
+func (c *client) do(args ...string) string {
+	c.t.Helper()
+
+	c.send(args...)
+	return c.read()
+}
+
+/*
+read returns the next raw reply, failing the test when none arrives in time.
+*/
+func (c *client) read() string {
+	c.t.Helper()
+
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	defer c.conn.SetReadDeadline(time.Time{})
+
+	reply, err := readReply(c.r)
+	if err != nil {
+		c.t.Fatalf("reading reply: %v", err)
+	}
+
+	return reply
+}
+
+/*
+readReply reads one complete RESP2 or RESP3 value and returns its raw bytes.
+*/
+func readReply(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	switch line[0] {
+	case '$', '=', '!':
+		n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil || n < 0 {
+			return line, err
+		}
+
+		payload := make([]byte, n+2)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return "", err
+		}
+
+		return line + string(payload), nil
+
+	case '*', '>', '~', '%', '|':
+		n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil || n < 0 {
+			return line, err
+		}
+		if line[0] == '%' || line[0] == '|' {
+			n *= 2
+		}
+
+		var sb strings.Builder
+		sb.WriteString(line)
+		for i := 0; i < n; i++ {
+			element, err := readReply(r)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(element)
+		}
+
+		return sb.String(), nil
+
+	case '+', '-', ':', '_', ',', '#', '(':
+		return line, nil
+	}
+
+	return "", fmt.Errorf("unexpected reply %q", line)
+}
+
+/*
+replicate turns c into a replica: it runs PSYNC and reads the full resync
+reply and the RDB that follows it, which is not terminated by CRLF.
+*/
+func (c *client) replicate() {
+	c.t.Helper()
+
+	c.send("PSYNC", "?", "-1")
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	defer c.conn.SetReadDeadline(time.Time{})
+
+	if line, err := c.r.ReadString('\n'); err != nil || !strings.HasPrefix(line, "+FULLRESYNC ") {
+		c.t.Fatalf("PSYNC = %q, %v", line, err)
+	}
+
+	header, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, "$")))
+	if err != nil {
+		c.t.Fatalf("RDB header = %q", header)
+	}
+	if _, err := io.CopyN(io.Discard, c.r, int64(size)); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+/*
+waitReplicas waits until n replicas are registered in ctx.
+*/
+func waitReplicas(t *testing.T, ctx context.Context, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for utils.GetClientsObj(ctx).Count() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d replicas registered, want %d", utils.GetClientsObj(ctx).Count(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+/*
+waitConnections waits until n client connections are registered in ctx.
+*/
+func waitConnections(t *testing.T, ctx context.Context, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(utils.GetConnectionsObj(ctx).All()) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d connections registered, want %d", len(utils.GetConnectionsObj(ctx).All()), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+/*
+startReplica connects a replica, run by the slave package the way main
+does it, to srv and returns the store it replicates into.
+*/
+func startReplica(t *testing.T, srv *server) *store.Store {
+	t.Helper()
+
+	databases := store.NewDatabases(16)
+	replicaStore, _ := databases.Get(0)
+	ctx := context.WithValue(context.Background(), "store", replicaStore)
+	ctx = context.WithValue(ctx, "databases", databases)
+
+	cfg := config.Config{
+		Role:           "slave",
+		Port:           6380,
+		Slave:          &config.Slave{},
+		ReadBufferSize: redis.DefaultReadBufferSize,
+	}
+
+	conn, err := slave.ConnectMaster(srv.addr, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	reader, err := slave.Handshakes(ctx, conn, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go slave.ReadFromConnection(ctx, conn, reader, cfg)
+
+	return replicaStore
+}
@@ -3,14 +3,20 @@ package master
 import (
 	"bufio"
 	"context"
+	"errors"
+	"fmt"
 	"net"
+	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/codecrafters-io/redis-starter-go/internal/clients"
 	"github.com/codecrafters-io/redis-starter-go/internal/commands"
 	"github.com/codecrafters-io/redis-starter-go/internal/config"
 	"github.com/codecrafters-io/redis-starter-go/internal/redis"
+	"github.com/codecrafters-io/redis-starter-go/internal/transactions"
 	"github.com/codecrafters-io/redis-starter-go/internal/utils"
 )
 
@@ -28,12 +34,53 @@ func AcceptConnections(l net.Listener, connChan chan<- net.Conn, errChan chan<-
 }
 
 func ReadFromConnection(ctx context.Context, conn net.Conn, config config.Config) {
-	defer conn.Close()
+	defer func() {
+		transactions.GetTransactionsObj(ctx).RemoveConnection(conn)
+		if syncConn, ok := conn.(*clients.SyncConn); ok {
+			utils.GetTrackingObj(ctx).RemoveConnection(syncConn)
+			utils.GetPubSubObj(ctx).RemoveConnection(syncConn)
+			utils.GetConnectionsObj(ctx).Remove(syncConn)
+		}
+		conn.Close()
+	}()
+
+	// commands blocked for the connection, like BLPOP, give up once it is
+	// gone instead of serving a client nobody reads for
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if syncConn, ok := conn.(*clients.SyncConn); ok && config.Resp3Keepalive > 0 {
+		done := make(chan struct{})
+		defer close(done)
+
+		go syncConn.Keepalive(done, time.Duration(config.Resp3Keepalive)*time.Second)
+	}
+
+	if tcpConn, ok := tcpConnOf(conn); ok && config.TcpKeepalive > 0 {
+		tcpConn.SetKeepAlive(true)
+		tcpConn.SetKeepAlivePeriod(time.Duration(config.TcpKeepalive) * time.Second)
+	}
+
+	// a single reader for the whole connection, so bytes it buffered past
+	// one command are still there for the next
+	r := bufio.NewReaderSize(conn, config.ReadBufferSize)
+
+	// each command holds a slot until its reply is written. Once all slots
+	// are taken the loop stops reading, so a client that pipelines without
+	// draining replies is held back by TCP flow control instead of piling
+	// up goroutines and replies in memory. A depth below 1 would leave no
+	// slot for even one command, so it still runs them one at a time.
+	pending := make(chan struct{}, max(config.MaxPipelineDepth, 1))
 
 	for {
-		r := bufio.NewReader(conn)
+		if config.Timeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(time.Duration(config.Timeout) * time.Second))
+		}
 
 		args, _, err := redis.UnpackInput(r)
+		if errors.Is(err, redis.ErrBulkTooLarge) {
+			conn.Write([]byte(fmt.Sprintf("-%s\r\n", err)))
+		}
 		if err != nil {
 			break
 		}
@@ -42,16 +89,35 @@ func ReadFromConnection(ctx context.Context, conn net.Conn, config config.Config
 			break
 		}
 
-		go HandleCommand(ctx, conn, config, args)
+		pending <- struct{}{}
+		go func() {
+			defer func() { <-pending }()
+
+			HandleCommand(ctx, conn, config, args)
+		}()
 	}
 }
 
+/*
+tcpConnOf returns the TCP connection under conn, which clients get
+wrapped in a SyncConn.
+*/
+func tcpConnOf(conn net.Conn) (*net.TCPConn, bool) {
+	if syncConn, ok := conn.(*clients.SyncConn); ok {
+		conn = syncConn.Conn
+	}
+
+	tcpConn, ok := conn.(*net.TCPConn)
+	return tcpConn, ok
+}
+
 func HandleCommand(ctx context.Context, conn net.Conn, config config.Config, args []string) {
-	cmd, exists := commands.Commands[strings.ToUpper(args[0])]
+	entry, exists := commands.Commands[strings.ToUpper(args[0])]
 	if !exists {
-		conn.Write([]byte("-Error\r\n"))
+		conn.Write([]byte(commands.UnknownCommandResp(args)))
 		return
 	}
+	cmd := entry.Command
 
 	baseCommandHandler := &BaseCommandHandler{}
 	discardConditionHandler := &DiscardConditionHandler{}
@@ -60,22 +126,74 @@ func HandleCommand(ctx context.Context, conn net.Conn, config config.Config, arg
 	baseCommandHandler.SetNext(discardConditionHandler)
 	discardConditionHandler.SetNext(queuedConditionHandler)
 
+	db := 0
+	if syncConn, ok := conn.(*clients.SyncConn); ok {
+		syncConn.RecordCommand(strings.ToLower(args[0]))
+		db = syncConn.DB()
+	}
+	ctx = withSelectedDB(ctx, db)
+
+	ctx, propagation := commands.WithPropagation(ctx)
+
 	if !baseCommandHandler.Handle(ctx, conn, config, args, cmd) {
 		return
 	}
 
 	cmd.Execute(ctx, conn, config, args)
 
-	for _, command := range commands.Propagated {
-		if command == args[0] {
-			cmd := redis.ConvertToRESP(
-				args,
-			)
-			config.Master.MasterReplOffset.Add(int64(len(cmd)))
-
-			SendCommandAllClients(ctx, conn, config, cmd)
+	if syncConn, ok := conn.(*clients.SyncConn); ok && syncConn.IsTracking() {
+		for _, command := range commands.Tracked {
+			if command == strings.ToUpper(args[0]) && len(args) > 1 {
+				utils.GetTrackingObj(ctx).Track(syncConn, args[1])
+			}
 		}
 	}
+
+	if writes := propagation.Commands(args); len(writes) > 0 {
+		propagate(ctx, conn, config, db, writes)
+	}
+}
+
+/*
+withSelectedDB points the "store" context value at the given database,
+so commands keep using utils.GetStoreObj.
+*/
+func withSelectedDB(ctx context.Context, db int) context.Context {
+	databases := utils.GetDatabasesObj(ctx)
+	if databases == nil {
+		return ctx
+	}
+
+	storeObj, ok := databases.Get(db)
+	if !ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, "store", storeObj)
+}
+
+/*
+propagate sends the commands a write is forwarded as to the replicas,
+preceded by a SELECT when the write ran in another database than the
+previous propagated one.
+*/
+func propagate(ctx context.Context, conn net.Conn, config config.Config, db int, writes [][]string) {
+	config.Master.Propagation.Lock()
+	defer config.Master.Propagation.Unlock()
+
+	var cmd string
+
+	if int64(db) != config.Master.SelectedDB.Load() {
+		cmd = redis.ConvertToRESP([]string{"SELECT", strconv.Itoa(db)})
+		config.Master.SelectedDB.Store(int64(db))
+	}
+
+	for _, args := range writes {
+		cmd += redis.ConvertToRESP(args)
+	}
+	config.Master.MasterReplOffset.Add(int64(len(cmd)))
+
+	SendCommandAllClients(ctx, conn, config, cmd)
 }
 
 func SendCommandAllClients(
@@ -94,4 +212,7 @@ func SendCommandAllClients(
 
 		clientConn.Write([]byte(cmd))
 	}
+
+	// replicas still receiving their snapshot get the write after it
+	clients.Backlog(cmd)
 }
@@ -0,0 +1,421 @@
+package master
+
+import (
+	"io"
+	"net"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/clients"
+	"github.com/codecrafters-io/redis-starter-go/internal/commands"
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
+	"github.com/codecrafters-io/redis-starter-go/internal/transactions"
+	"github.com/codecrafters-io/redis-starter-go/internal/utils"
+)
+
+func TestTCPConnOfUnwrapsSyncConn(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for name, wrapped := range map[string]net.Conn{
+		"TCPConn":  conn,
+		"SyncConn": clients.NewSyncConn(conn),
+	} {
+		if tcpConn, ok := tcpConnOf(wrapped); !ok || tcpConn != conn {
+			t.Fatalf("tcpConnOf(%s) = %v, %v", name, tcpConn, ok)
+		}
+	}
+
+	if _, ok := tcpConnOf(clients.NewSyncConn(&net.UnixConn{})); ok {
+		t.Fatal("tcpConnOf found a TCP connection under a Unix one")
+	}
+}
+
+func TestClosingConnectionDropsTransactionBuffer(t *testing.T) {
+	ctx := newTestContext(t)
+	srv := serve(t, ctx, newTestConfig())
+
+	c := dial(t, srv)
+	serverConn := <-srv.accepted
+
+	if got := c.do("MULTI"); got != "+OK\r\n" {
+		t.Fatalf("MULTI = %q", got)
+	}
+	if got := c.do("SET", "k", "v"); got != "+QUEUED\r\n" {
+		t.Fatalf("SET = %q", got)
+	}
+
+	c.conn.Close()
+
+	transactionsObj := transactions.GetTransactionsObj(ctx)
+	deadline := time.Now().Add(2 * time.Second)
+	for transactionsObj.GetTransactionBuffer(serverConn) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("the transaction buffer outlived its connection")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if utils.GetStoreObj(ctx).Exists("k") {
+		t.Fatal("the queued command ran although EXEC was never sent")
+	}
+}
+
+func TestWaitCountsOnlyReplicas(t *testing.T) {
+	ctx := newTestContext(t)
+	srv := serve(t, ctx, newTestConfig())
+
+	dial(t, srv).replicate()
+	waitReplicas(t, ctx, 1)
+
+	c := dial(t, srv)
+	if got := c.do("PING"); got != "+PONG\r\n" {
+		t.Fatalf("PING = %q", got)
+	}
+
+	if got := c.do("WAIT", "0", "100"); got != ":1\r\n" {
+		t.Fatalf("WAIT 0 100 = %q, want :1", got)
+	}
+	if got := c.do("WAIT", "2", "100"); got != ":1\r\n" {
+		t.Fatalf("WAIT 2 100 = %q, want :1", got)
+	}
+}
+
+func TestTrackingInvalidationPush(t *testing.T) {
+	ctx := newTestContext(t)
+	srv := serve(t, ctx, newTestConfig())
+
+	tracker := dial(t, srv)
+	tracker.do("HELLO", "3")
+	if got := tracker.do("CLIENT", "TRACKING", "ON"); got != "+OK\r\n" {
+		t.Fatalf("CLIENT TRACKING ON = %q", got)
+	}
+	tracker.do("GET", "k")
+
+	writer := dial(t, srv)
+	if got := writer.do("SET", "k", "v"); got != "+OK\r\n" {
+		t.Fatalf("SET = %q", got)
+	}
+
+	want := ">2\r\n$10\r\ninvalidate\r\n*1\r\n$1\r\nk\r\n"
+	if got := tracker.read(); got != want {
+		t.Fatalf("tracker got %q, want %q", got, want)
+	}
+
+	// the key is forgotten until it is read again
+	writer.do("SET", "k", "v2")
+	if got := tracker.do("PING"); got != "+PONG\r\n" {
+		t.Fatalf("tracker got %q before its PING reply", got)
+	}
+}
+
+func TestBulkHeaderOverProtoMaxBulkLen(t *testing.T) {
+	redis.SetProtoMaxBulkLen(16)
+	t.Cleanup(func() { redis.SetProtoMaxBulkLen(redis.DefaultProtoMaxBulkLen) })
+
+	srv := serve(t, newTestContext(t), newTestConfig())
+	c := dial(t, srv)
+
+	if got := c.do("SET", "k", "sixteen-bytes-ok"); got != "+OK\r\n" {
+		t.Fatalf("SET at the limit = %q", got)
+	}
+
+	// the header alone is refused, before any of the payload is sent
+	if _, err := c.conn.Write([]byte("*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$17\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.read(); got != "-ERR string exceeds maximum allowed size (proto-max-bulk-len)\r\n" {
+		t.Fatalf("oversized bulk header = %q", got)
+	}
+
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := c.r.ReadByte(); err != io.EOF {
+		t.Fatalf("read after the error = %v, want the connection closed", err)
+	}
+}
+
+func TestWaitForRealReplica(t *testing.T) {
+	ctx := newTestContext(t)
+	srv := serve(t, ctx, newTestConfig())
+
+	replicaStore := startReplica(t, srv)
+	waitReplicas(t, ctx, 1)
+
+	c := dial(t, srv)
+	if got := c.do("SET", "k", "v"); got != "+OK\r\n" {
+		t.Fatalf("SET = %q", got)
+	}
+
+	// WAIT returns once the replica acknowledged the SET, well before
+	// the timeout
+	start := time.Now()
+	if got := c.do("WAIT", "1", "2000"); got != ":1\r\n" {
+		t.Fatalf("WAIT 1 2000 = %q, want :1", got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("WAIT took %v, the replica's ACK was not counted", elapsed)
+	}
+
+	if got, err := replicaStore.Get("k"); err != nil || got != "v" {
+		t.Fatalf("GET k on the replica = %q, %v, want the propagated value", got, err)
+	}
+}
+
+func TestAppendReachesReplica(t *testing.T) {
+	ctx := newTestContext(t)
+	srv := serve(t, ctx, newTestConfig())
+
+	replicaStore := startReplica(t, srv)
+	waitReplicas(t, ctx, 1)
+
+	c := dial(t, srv)
+	for _, args := range [][]string{
+		{"APPEND", "k", "Hello"},
+		{"APPEND", "k", " World"},
+		{"SETRANGE", "k", "6", "Redis"},
+		{"APPEND", "k", "!"},
+	} {
+		c.do(args...)
+	}
+	if got := c.do("WAIT", "1", "2000"); got != ":1\r\n" {
+		t.Fatalf("WAIT 1 2000 = %q, want :1", got)
+	}
+
+	master, _ := utils.GetStoreObj(ctx).Get("k")
+	if got, err := replicaStore.Get("k"); err != nil || got != master || got != "Hello Redis!" {
+		t.Fatalf("GET k on the replica = %q, %v, master has %q", got, err, master)
+	}
+}
+
+func TestClientListReportsLastCommand(t *testing.T) {
+	srv := serve(t, newTestContext(t), newTestConfig())
+
+	a := dial(t, srv)
+	a.do("SET", "k", "v")
+	a.do("GET", "k")
+	a.do("ECHO", "hi")
+
+	list := dial(t, srv).do("CLIENT", "LIST")
+	var line string
+	for _, l := range strings.Split(list, "\n") {
+		if strings.Contains(l, " addr="+a.conn.LocalAddr().String()+" ") {
+			line = l
+		}
+	}
+	if !strings.Contains(line, " tot-cmds=3 cmd=echo") {
+		t.Fatalf("CLIENT LIST = %q, want a line for the first client ending in tot-cmds=3 cmd=echo", list)
+	}
+
+	// CLIENT INFO is a single line about the caller, counting itself
+	info := a.do("CLIENT", "INFO")
+	if strings.Count(info, "id=") != 1 || !strings.HasSuffix(info, " tot-cmds=4 cmd=client\n\r\n") {
+		t.Fatalf("CLIENT INFO = %q, want one line ending in tot-cmds=4 cmd=client", info)
+	}
+}
+
+func TestWaitFastPathCountsReplicas(t *testing.T) {
+	ctx := newTestContext(t)
+	srv := serve(t, ctx, newTestConfig())
+
+	dial(t, srv).replicate()
+	dial(t, srv).replicate()
+	waitReplicas(t, ctx, 2)
+
+	// ordinary connections are no replicas
+	for i := 0; i < 3; i++ {
+		dial(t, srv).do("PING")
+	}
+
+	// with nothing written, WAIT answers right away with the replica
+	// count, neither capped at the goal nor inflated by other clients
+	c := dial(t, srv)
+	for _, goal := range []string{"0", "1", "2", "5"} {
+		if got := c.do("WAIT", goal, "0"); got != ":2\r\n" {
+			t.Fatalf("WAIT %s 0 = %q, want :2", goal, got)
+		}
+	}
+}
+
+func TestPipelineDepthAppliesBackpressure(t *testing.T) {
+	ctx := newTestContext(t)
+	cfg := newTestConfig()
+	cfg.MaxPipelineDepth = 8
+	srv := serve(t, ctx, cfg)
+
+	c := dial(t, srv)
+	value := strings.Repeat("v", 64*1024)
+	if got := c.do("SET", "big", value); got != "+OK\r\n" {
+		t.Fatalf("SET big = %q", got)
+	}
+
+	// thousands of 64KB replies are far more than the socket buffers
+	// hold, so without backpressure every command would sit in its own
+	// goroutine holding its reply
+	const commands = 2000
+	baseline := runtime.NumGoroutine()
+	go func() {
+		pipeline := strings.Repeat(redis.ConvertToRESP([]string{"GET", "big"}), commands)
+		c.conn.Write([]byte(pipeline))
+	}()
+
+	peak := 0
+	for deadline := time.Now().Add(200 * time.Millisecond); time.Now().Before(deadline); {
+		peak = max(peak, runtime.NumGoroutine()-baseline)
+		runtime.Gosched()
+	}
+	if peak > cfg.MaxPipelineDepth+8 {
+		t.Fatalf("%d goroutines while the client does not read, want at most about %d", peak, cfg.MaxPipelineDepth)
+	}
+
+	// once the client drains, the server reads on and answers every command
+	want := "$65536\r\n" + value + "\r\n"
+	for i := 0; i < commands; i++ {
+		if got := c.read(); got != want {
+			t.Fatalf("reply %d = %.40q, want the value", i, got)
+		}
+	}
+}
+
+func TestPipelineDepthBelowOneServesCommands(t *testing.T) {
+	ctx := newTestContext(t)
+	cfg := newTestConfig()
+	cfg.MaxPipelineDepth = 0
+	c := dial(t, serve(t, ctx, cfg))
+
+	c.send("SET", "k", "v")
+	c.send("GET", "k")
+	if got := c.read(); got != "+OK\r\n" {
+		t.Fatalf("SET k v = %q", got)
+	}
+	if got := c.read(); got != "$1\r\nv\r\n" {
+		t.Fatalf("GET k = %q", got)
+	}
+}
+
+func TestUnknownCommandReply(t *testing.T) {
+	ctx := newTestContext(t)
+	c := dial(t, serve(t, ctx, newTestConfig()))
+
+	if got := c.do("BOGUS", "a", "b c"); got != "-ERR unknown command 'BOGUS', with args beginning with: 'a' 'b c' \r\n" {
+		t.Fatalf("BOGUS a \"b c\" = %q", got)
+	}
+	if got := c.do("bogus"); got != "-ERR unknown command 'bogus', with args beginning with: \r\n" {
+		t.Fatalf("bogus = %q", got)
+	}
+
+	// the connection stays usable
+	if got := c.do("PING"); got != "+PONG\r\n" {
+		t.Fatalf("PING after an unknown command = %q", got)
+	}
+}
+
+func TestMigrateHashBetweenInstances(t *testing.T) {
+	target := newTestContext(t)
+	host, port, _ := net.SplitHostPort(serve(t, target, newTestConfig()).addr)
+
+	c := dial(t, serve(t, newTestContext(t), newTestConfig()))
+	c.do("HSET", "h", "f1", "v1", "f2", "v2")
+	c.do("EXPIRE", "h", "100")
+
+	if got := c.do("MIGRATE", host, port, "h", "1", "1000"); got != "+OK\r\n" {
+		t.Fatalf("MIGRATE = %q", got)
+	}
+	if got := c.do("EXISTS", "h"); got != ":0\r\n" {
+		t.Fatalf("source EXISTS = %q, want :0", got)
+	}
+
+	db1, _ := utils.GetDatabasesObj(target).Get(1)
+	pairs, err := db1.HGetAll("h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(pairs)
+	if got := strings.Join(pairs, ","); got != "f1,f2,v1,v2" {
+		t.Fatalf("target HGETALL = %s", got)
+	}
+
+	if _, _, hasExpiry := db1.TTLRemaining("h"); !hasExpiry {
+		t.Fatal("target key lost its expiration")
+	}
+}
+
+func TestMigrateCopyKeepsSource(t *testing.T) {
+	target := serve(t, newTestContext(t), newTestConfig())
+	host, port, _ := net.SplitHostPort(target.addr)
+
+	c := dial(t, serve(t, newTestContext(t), newTestConfig()))
+	c.do("ZADD", "z", "1", "a", "2", "b")
+
+	if got := c.do("MIGRATE", host, port, "z", "0", "1000", "COPY"); got != "+OK\r\n" {
+		t.Fatalf("MIGRATE = %q", got)
+	}
+
+	if got := c.do("ZCARD", "z"); got != ":2\r\n" {
+		t.Fatalf("source ZCARD = %q", got)
+	}
+	if got := dial(t, target).do("ZCARD", "z"); got != ":2\r\n" {
+		t.Fatalf("target ZCARD = %q", got)
+	}
+}
+
+func TestMigrateForwardsDel(t *testing.T) {
+	host, port, _ := net.SplitHostPort(serve(t, newTestContext(t), newTestConfig()).addr)
+
+	source := newTestContext(t)
+	utils.GetStoreObj(source).Set("moved", "v", nil)
+	utils.GetStoreObj(source).Set("copied", "v", nil)
+
+	for _, tt := range []struct {
+		args []string
+		want [][]string
+	}{
+		{[]string{"MIGRATE", host, port, "moved", "0", "1000"}, [][]string{{"DEL", "moved"}}},
+		// a copy leaves the source as it was, so replicas get nothing
+		{[]string{"MIGRATE", host, port, "copied", "0", "1000", "COPY"}, nil},
+	} {
+		ctx, propagation := commands.WithPropagation(source)
+		commands.Commands["MIGRATE"].Execute(ctx, io.Discard, newTestConfig(), tt.args)
+
+		if got := propagation.Commands(tt.args); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("%v forwarded as %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestDisconnectedBlockedPopLeavesPushAlone(t *testing.T) {
+	for _, command := range []string{"BLPOP", "BRPOP"} {
+		t.Run(command, func(t *testing.T) {
+			ctx := newTestContext(t)
+			srv := serve(t, ctx, newTestConfig())
+
+			blocked := dial(t, srv)
+			blocked.send(command, "l", "0")
+			waitConnections(t, ctx, 1)
+			time.Sleep(20 * time.Millisecond)
+
+			blocked.conn.Close()
+			waitConnections(t, ctx, 0)
+
+			c := dial(t, srv)
+			if got := c.do("RPUSH", "l", "a"); got != ":1\r\n" {
+				t.Fatalf("RPUSH = %q", got)
+			}
+			if got := c.do("LLEN", "l"); got != ":1\r\n" {
+				t.Fatalf("LLEN after the blocked client left = %q, want :1", got)
+			}
+		})
+	}
+}
@@ -3,16 +3,38 @@ package redis
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
 	"strings"
+	"sync/atomic"
 )
 
-const (
-	DELIM         = "\r\n"
-	EMPTYRDBSTORE = "524544495330303131fa0972656469732d76657205372e322e30fa0a72656469732d62697473c040fa056374696d65c26d08bc65fa08757365642d6d656dc2b0c41000fa08616f662d62617365c000fff06e3bfec0ff5aa2"
-)
+const DELIM = "\r\n"
+
+const DefaultProtoMaxBulkLen = 512 * 1024 * 1024
+
+// DefaultReadBufferSize matches the 16KB query buffer chunk Redis reads with.
+const DefaultReadBufferSize = 16 * 1024
+
+// DefaultMaxPipelineDepth bounds the commands in flight for one connection.
+const DefaultMaxPipelineDepth = 1024
+
+var ErrBulkTooLarge = errors.New("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+
+var protoMaxBulkLen atomic.Int64
+
+func init() {
+	protoMaxBulkLen.Store(DefaultProtoMaxBulkLen)
+}
+
+/*
+SetProtoMaxBulkLen sets the largest bulk string UnpackInput accepts.
+*/
+func SetProtoMaxBulkLen(n int64) {
+	protoMaxBulkLen.Store(n)
+}
 
 func ConvertToRESP(cmd []string) string {
 	var buffer bytes.Buffer
@@ -66,6 +88,10 @@ func UnpackInput(r *bufio.Reader) ([]string, int, error) {
 				fmt.Println("Error: ", err)
 				return nil, 0, err
 			}
+
+			if int64(argLen) > protoMaxBulkLen.Load() {
+				return nil, 0, ErrBulkTooLarge
+			}
 		}
 
 		if argLen == 0 {
@@ -2,6 +2,7 @@ package slave
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -9,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/codecrafters-io/redis-starter-go/internal/config"
+	"github.com/codecrafters-io/redis-starter-go/internal/utils"
 )
 
 type MasterInfo struct {
@@ -56,19 +58,13 @@ func readAnswer(
 	// fmt.Println(message)
 }
 
-func readNBytes(reader io.Reader, n int) ([]byte, error) {
-	buf := make([]byte, n)
-	_, err := io.ReadFull(reader, buf)
-	if err != nil {
-		return nil, err
-	}
-	return buf, nil
-}
-
 func ConnectMaster(replicaof string, config config.Config) (net.Conn, error) {
 	masterInfo := masterInfoFromParam(replicaof)
 	addr := masterInfo.Address()
 
+	config.Slave.MasterHost = masterInfo.Host
+	config.Slave.MasterPort = masterInfo.Port
+
 	conn, err := net.Dial("tcp", addr)
 	if err != nil {
 		fmt.Println("Error connecting to master: ", err)
@@ -77,7 +73,12 @@ func ConnectMaster(replicaof string, config config.Config) (net.Conn, error) {
 	return conn, nil
 }
 
-func Handshakes(conn net.Conn, config config.Config) (*bufio.Reader, error) {
+/*
+Handshakes runs the replication handshake with the master and loads the
+RDB it sends into the store of ctx. The returned reader continues with the
+replication stream.
+*/
+func Handshakes(ctx context.Context, conn net.Conn, config config.Config) (*bufio.Reader, error) {
 	if err := sendMessage(conn, "*1\r\n$4\r\nPING\r\n"); err != nil {
 		return nil, err
 	}
@@ -93,10 +94,13 @@ func Handshakes(conn net.Conn, config config.Config) (*bufio.Reader, error) {
 		return nil, err
 	}
 	readAnswer(conn)
+	config.Slave.SyncInProgress.Store(true)
+	defer config.Slave.SyncInProgress.Store(false)
+
 	if err := sendMessage(conn, "*3\r\n$5\r\nPSYNC\r\n$1\r\n?\r\n$2\r\n-1\r\n"); err != nil {
 		return nil, err
 	}
-	reader := bufio.NewReader(conn)
+	reader := bufio.NewReaderSize(conn, config.ReadBufferSize)
 	line, err := reader.ReadBytes('\n')
 	if err != nil {
 		return nil, err
@@ -113,12 +117,11 @@ func Handshakes(conn net.Conn, config config.Config) (*bufio.Reader, error) {
 		return nil, err
 	}
 
-	_, err = readNBytes(reader, dataLen)
-	if err != nil {
+	if err := utils.LoadRDBFrom(ctx, io.LimitReader(reader, int64(dataLen))); err != nil {
 		return nil, err
 	}
 
-	// fmt.Println("Received RDB:", string(data))
+	config.Slave.LinkUp.Store(true)
 
 	return reader, nil
 }
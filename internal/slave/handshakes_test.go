@@ -0,0 +1,201 @@
+package slave
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/commands"
+	"github.com/codecrafters-io/redis-starter-go/internal/config"
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
+	"github.com/codecrafters-io/redis-starter-go/internal/store"
+	"github.com/codecrafters-io/redis-starter-go/internal/utils"
+)
+
+func newTestContext() context.Context {
+	databases := store.NewDatabases(16)
+	storeObj, _ := databases.Get(0)
+
+	ctx := context.WithValue(context.Background(), "store", storeObj)
+	return context.WithValue(ctx, "databases", databases)
+}
+
+/*
+fakeMaster accepts one replica, answers its handshake and sends it the
+dataset of ctx. The returned channel receives the master side of the
+connection once the dataset is sent.
+*/
+func fakeMaster(t testing.TB, ctx context.Context) (string, <-chan net.Conn) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	synced := make(chan net.Conn, 1)
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		reader := bufio.NewReader(conn)
+		for _, reply := range []string{"+PONG\r\n", "+OK\r\n", "+OK\r\n"} {
+			if _, _, err := redis.UnpackInput(reader); err != nil {
+				conn.Close()
+				return
+			}
+			conn.Write([]byte(reply))
+		}
+
+		if _, _, err := redis.UnpackInput(reader); err != nil {
+			conn.Close()
+			return
+		}
+
+		size, rdb, err := utils.NewRDBReader(utils.SnapshotDatabases(ctx))
+		if err != nil {
+			conn.Close()
+			return
+		}
+		defer rdb.Close()
+
+		fmt.Fprintf(conn, "+FULLRESYNC %s 0\r\n$%d\r\n", strings.Repeat("a", 40), size)
+		io.Copy(conn, rdb)
+
+		synced <- conn
+	}()
+
+	return l.Addr().String(), synced
+}
+
+func replicationInfo(ctx context.Context, cfg config.Config) string {
+	var bb bytes.Buffer
+	commands.Commands["INFO"].Execute(ctx, &bb, cfg, []string{"INFO", "replication"})
+
+	return bb.String()
+}
+
+func TestMasterLinkStatus(t *testing.T) {
+	masterCtx := newTestContext()
+	utils.GetStoreObj(masterCtx).Set("k", "v", nil)
+	addr, synced := fakeMaster(t, masterCtx)
+
+	cfg := config.Config{
+		Role:           "slave",
+		Port:           6380,
+		Slave:          &config.Slave{},
+		ReadBufferSize: redis.DefaultReadBufferSize,
+	}
+	ctx := newTestContext()
+
+	if info := replicationInfo(ctx, cfg); !strings.Contains(info, "master_link_status:down\r\n") {
+		t.Fatalf("INFO before the handshake = %q, want the link down", info)
+	}
+
+	conn, err := ConnectMaster(addr, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := Handshakes(ctx, conn, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := replicationInfo(ctx, cfg)
+	for _, field := range []string{
+		"master_host:127.0.0.1\r\n",
+		"master_link_status:up\r\n",
+		"master_sync_in_progress:0\r\n",
+	} {
+		if !strings.Contains(info, field) {
+			t.Fatalf("INFO after the handshake = %q, want %q", info, field)
+		}
+	}
+	if got, err := utils.GetStoreObj(ctx).Get("k"); err != nil || got != "v" {
+		t.Fatalf("GET k after the sync = %q, %v, want the master's value", got, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ReadFromConnection(ctx, conn, reader, cfg)
+		close(done)
+	}()
+
+	// losing the master takes the link down
+	(<-synced).Close()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("replica kept reading from a closed master")
+	}
+
+	if info := replicationInfo(ctx, cfg); !strings.Contains(info, "master_link_status:down\r\n") {
+		t.Fatalf("INFO after losing the master = %q, want the link down", info)
+	}
+}
+
+/*
+countingConn counts the reads the replica makes on the master link.
+*/
+type countingConn struct {
+	net.Conn
+	reads int
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	c.reads++
+	return c.Conn.Read(p)
+}
+
+func BenchmarkHandshakesReadBufferSize(b *testing.B) {
+	masterCtx := newTestContext()
+	value := strings.Repeat("v", 1024)
+	for i := 0; i < 4096; i++ {
+		utils.GetStoreObj(masterCtx).Set(fmt.Sprintf("key:%d", i), value, nil)
+	}
+
+	for _, size := range []int{4 * 1024, redis.DefaultReadBufferSize, 256 * 1024} {
+		b.Run(fmt.Sprintf("%dKB", size/1024), func(b *testing.B) {
+			cfg := config.Config{
+				Role:           "slave",
+				Port:           6380,
+				Slave:          &config.Slave{},
+				ReadBufferSize: size,
+			}
+
+			reads := 0
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				addr, synced := fakeMaster(b, masterCtx)
+				conn, err := ConnectMaster(addr, cfg)
+				if err != nil {
+					b.Fatal(err)
+				}
+				counted := &countingConn{Conn: conn}
+				b.StartTimer()
+
+				if _, err := Handshakes(newTestContext(), counted, cfg); err != nil {
+					b.Fatal(err)
+				}
+
+				b.StopTimer()
+				reads += counted.reads
+				conn.Close()
+				(<-synced).Close()
+				b.StartTimer()
+			}
+
+			b.ReportMetric(float64(reads)/float64(b.N), "reads/op")
+		})
+	}
+}
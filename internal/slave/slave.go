@@ -4,12 +4,17 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"net"
+	"strconv"
 	"strings"
 
+	"github.com/sirupsen/logrus"
+
 	"github.com/codecrafters-io/redis-starter-go/internal/commands"
 	"github.com/codecrafters-io/redis-starter-go/internal/config"
 	"github.com/codecrafters-io/redis-starter-go/internal/redis"
+	"github.com/codecrafters-io/redis-starter-go/internal/utils"
 )
 
 type CommandRequest struct {
@@ -23,7 +28,10 @@ func ReadFromConnection(
 	reader *bufio.Reader,
 	config config.Config,
 ) {
-	defer conn.Close()
+	defer func() {
+		config.Slave.LinkUp.Store(false)
+		conn.Close()
+	}()
 
 	commandChannel := make(chan CommandRequest, 64)
 	go HandleCommand(ctx, conn, config, commandChannel)
@@ -51,15 +59,55 @@ func HandleCommand(
 	config config.Config,
 	commandChannel <-chan CommandRequest,
 ) {
+	// the master selects databases in-band with SELECT, the selection
+	// belongs to this link only
+	db := 0
+
 	for cmdRequest := range commandChannel {
+		if strings.ToUpper(cmdRequest.args[0]) == "SELECT" && len(cmdRequest.args) == 2 {
+			if index, err := strconv.Atoi(cmdRequest.args[1]); err == nil {
+				db = index
+			}
+			config.Slave.Offset.Add(int64(cmdRequest.offset))
+			continue
+		}
+
+		// a transaction is forwarded as MULTI, its writes and EXEC. The
+		// writes are replayed one after the other on this link, so the
+		// markers only count for the offset
+		if name := strings.ToUpper(cmdRequest.args[0]); name == "MULTI" || name == "EXEC" {
+			config.Slave.Offset.Add(int64(cmdRequest.offset))
+			continue
+		}
 
-		cmd, exists := commands.Commands[strings.ToUpper(cmdRequest.args[0])]
+		entry, exists := commands.Commands[strings.ToUpper(cmdRequest.args[0])]
 		if !exists {
-			conn.Write([]byte("-Error\r\n"))
-			return
+			// never answered, see below. Skipping keeps the link and
+			// the offset in step with the master
+			logrus.WithFields(logrus.Fields{
+				"package":  "slave",
+				"function": "HandleCommand",
+				"command":  cmdRequest.args[0],
+			}).Warn("Unknown command from master")
+			config.Slave.Offset.Add(int64(cmdRequest.offset))
+			continue
 		}
+		cmd := entry.Command
 		fmt.Printf("Offset new command: %d\r\n", cmdRequest.offset)
-		cmd.Execute(ctx, conn, config, cmdRequest.args)
+
+		// only REPLCONF is answered, replies to propagated writes
+		// must never be sent back to the master
+		var writer io.Writer = conn
+		if _, ok := cmd.(*commands.ReplConfCommand); !ok {
+			writer = io.Discard
+		}
+
+		cmdCtx := ctx
+		if storeObj, ok := utils.GetDatabasesObj(ctx).Get(db); ok {
+			cmdCtx = context.WithValue(ctx, "store", storeObj)
+		}
+
+		cmd.Execute(cmdCtx, writer, config, cmdRequest.args)
 		config.Slave.Offset.Add(int64(cmdRequest.offset))
 
 		fmt.Printf("Total offset after command %d\r\n", config.Slave.Offset.Load())
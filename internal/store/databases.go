@@ -0,0 +1,38 @@
+package store
+
+/*
+Databases holds the numbered keyspaces selected with SELECT. Every
+database is an independent Store.
+*/
+type Databases struct {
+	dbs []*Store
+}
+
+func NewDatabases(count int) *Databases {
+	dbs := make([]*Store, count)
+	for i := range dbs {
+		dbs[i] = NewStore()
+	}
+
+	return &Databases{dbs: dbs}
+}
+
+/*
+Get returns the database with the given index, or false when the index
+is out of range.
+*/
+func (d *Databases) Get(index int) (*Store, bool) {
+	if index < 0 || index >= len(d.dbs) {
+		return nil, false
+	}
+
+	return d.dbs[index], true
+}
+
+func (d *Databases) Count() int {
+	return len(d.dbs)
+}
+
+func (d *Databases) All() []*Store {
+	return d.dbs
+}
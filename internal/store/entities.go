@@ -1,15 +1,124 @@
 package store
 
 import (
+	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-type Datatype string
+type ValueType int
 
 const (
-	StringType Datatype = "string"
-	StreamType Datatype = "stream"
+	NoneType ValueType = iota
+	StringType
+	StreamType
+	HashType
+	ListType
+	SetType
+	ZSetType
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case StringType:
+		return "string"
+	case StreamType:
+		return "stream"
+	case HashType:
+		return "hash"
+	case ListType:
+		return "list"
+	case SetType:
+		return "set"
+	case ZSetType:
+		return "zset"
+	}
+
+	return "none"
+}
+
+/*
+ParseValueType maps a type name as printed by TYPE back to its ValueType,
+so filters such as SCAN TYPE use the very same names.
+*/
+func ParseValueType(name string) (ValueType, bool) {
+	for t := StringType; t <= ZSetType; t++ {
+		if t.String() == name {
+			return t, true
+		}
+	}
+
+	return NoneType, false
+}
+
+var (
+	ErrWrongType       = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	ErrInvalidStreamID = errors.New("Invalid stream ID specified as stream command argument")
+	ErrNotFound        = errors.New("key does not exist")
+	ErrExpired         = errors.New("key has expired")
+	ErrNotInteger      = errors.New("ERR value is not an integer or out of range")
+	ErrOverflow        = errors.New("ERR increment or decrement would overflow")
+	ErrBusyGroup       = errors.New("BUSYGROUP Consumer Group name already exists")
+	ErrStringTooLong   = errors.New("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+	ErrNotFloat        = errors.New("ERR value is not a valid float")
+	ErrNaNOrInfinity   = errors.New("ERR increment would produce NaN or Infinity")
+	ErrNoSuchKey       = errors.New("ERR no such key")
+	ErrIndexOutOfRange = errors.New("ERR index out of range")
+	ErrHashNotInteger  = errors.New("ERR hash value is not an integer")
+	ErrScoreNaN        = errors.New("ERR resulting score is not a number (NaN)")
+	ErrListpackSize    = errors.New("list-max-listpack-size must be at least 1")
+)
+
+type Encoding string
+
+const (
+	IntEncoding       Encoding = "int"
+	EmbstrEncoding    Encoding = "embstr"
+	RawEncoding       Encoding = "raw"
+	ListpackEncoding  Encoding = "listpack"
+	QuicklistEncoding Encoding = "quicklist"
+	HashtableEncoding Encoding = "hashtable"
+	IntsetEncoding    Encoding = "intset"
+	SkiplistEncoding  Encoding = "skiplist"
+	StreamEncoding    Encoding = "stream"
+)
+
+// SetCondition restricts when SetIf writes a key (SET NX / XX).
+type SetCondition int
+
+const (
+	SetAlways SetCondition = iota
+	SetIfNotExists
+	SetIfExists
+)
+
+// SetOperation selects the algebra of SINTER, SUNION and SDIFF.
+type SetOperation int
+
+const (
+	SetInter SetOperation = iota
+	SetUnion
+	SetDiff
+)
+
+// ZAggregate selects how ZUNIONSTORE and ZINTERSTORE combine scores.
+type ZAggregate int
+
+const (
+	AggregateSum ZAggregate = iota
+	AggregateMin
+	AggregateMax
+)
+
+const (
+	embstrSizeLimit               = 44
+	DefaultListMaxListpackSize    = 128
+	DefaultHashMaxListpackEntries = 128
+	DefaultHashMaxListpackValue   = 64
+	DefaultSetMaxIntsetEntries    = 512
+	DefaultSetMaxListpackEntries  = 128
+	DefaultSetMaxListpackValue    = 64
 )
 
 type Storable interface {
@@ -22,30 +131,181 @@ func (s StringT) IsStorable() {}
 
 type StreamMessages struct {
 	Messages []StreamMessage
+	// LastID is the top ID ever added to the stream. It is kept apart from
+	// Messages so it survives entries being removed and can be persisted.
+	LastID string
+	// Groups holds the consumer groups of the stream by name.
+	Groups map[string]*ConsumerGroup
+}
+
+type ConsumerGroup struct {
+	LastDeliveredID string
+	Consumers       map[string]*Consumer
+}
+
+type Consumer struct {
+	Pending  int
+	SeenTime time.Time
+}
+
+/*
+GroupInfo and ConsumerInfo are point-in-time views of the consumer group
+state returned to XINFO, so callers never touch the live maps.
+*/
+type GroupInfo struct {
+	Name            string
+	Consumers       int
+	Pending         int
+	LastDeliveredID string
+}
+
+type ConsumerInfo struct {
+	Name    string
+	Pending int
+	Idle    time.Duration
 }
 
 type StreamMessage struct {
-	ID     string
-	Fields map[string]string
+	ID string
+	// Fields keeps the field/value pairs in the order they were added.
+	Fields []StreamField
+}
+
+type StreamField struct {
+	Name  string
+	Value string
 }
 
 func (s StreamMessages) IsStorable() {}
 
+type ListT struct {
+	Elements []string
+}
+
+func (l ListT) IsStorable() {}
+
+type SetT struct {
+	Members map[string]struct{}
+	// Encoding starts as intset and only ever moves on to listpack and
+	// then hashtable as members are added, like in Redis.
+	Encoding Encoding
+}
+
+func (s SetT) IsStorable() {}
+
+type ZSetT struct {
+	Scores map[string]float64
+	// Ranked holds the same members ordered by score, then by member, so
+	// range queries do not have to sort.
+	Ranked []ZMember
+}
+
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+/*
+ZScoreRange is a score interval of ZRANGEBYSCORE, each bound inclusive
+unless marked exclusive.
+*/
+type ZScoreRange struct {
+	Min          float64
+	Max          float64
+	MinExclusive bool
+	MaxExclusive bool
+}
+
+func (z ZSetT) IsStorable() {}
+
+type HashT struct {
+	Fields    map[string]string
+	ExpiredAt map[string]time.Time
+
+	// Hashtable is set once the hash outgrows the listpack limits. It is
+	// never cleared, a hash does not go back to listpack when it shrinks.
+	Hashtable bool
+}
+
+func (h HashT) IsStorable() {}
+
 type ValueWithType struct {
 	Data     Storable
-	DataType Datatype
+	DataType ValueType
 }
 
 type Value struct {
 	ValueData ValueWithType
 	ExpiredAt *time.Time
+	// Raw marks a string modified in place by APPEND or SETRANGE, which
+	// OBJECT ENCODING reports as raw whatever its content.
+	Raw bool
 }
 
 func (v Value) GetStorable() Storable {
 	return v.ValueData.Data
 }
 
+/*
+KeyspaceStats counts lookups for INFO stats. Commands record hits and
+misses themselves, based on the errors returned by the store.
+*/
+type KeyspaceStats struct {
+	Hits   atomic.Int64
+	Misses atomic.Int64
+}
+
+/*
+Store is one keyspace. Commands run on their own goroutines, so every
+exported method takes mutex itself: the read lock for pure lookups such
+as Exists, the write lock for anything that changes the map, including
+reads like Get and TTL that drop an expired key. Unexported helpers expect the
+caller to hold the lock.
+*/
 type Store struct {
 	store map[string]Value
 	mutex sync.RWMutex
+
+	listMaxListpackSize    int
+	hashMaxListpackEntries int
+	hashMaxListpackValue   int
+	setMaxIntsetEntries    int
+	setMaxListpackEntries  int
+	setMaxListpackValue    int
+	protoMaxBulkLen        int64
+
+	sizes      map[string]int64
+	usedMemory int64
+	// volatile holds the keys that have an expiration, for the reaper
+	volatile map[string]struct{}
+	// volatileFields holds the hashes that have fields with a time to
+	// live, for CollectExpiredFields
+	volatileFields map[string]struct{}
+	// fieldWrites holds the hashes that lost expired fields under the
+	// lock, for notifyExpiredFields
+	fieldWrites map[string]struct{}
+
+	Stats     KeyspaceStats
+	writeHook func(key string)
+
+	// lazyFreeExpire and lazyFreeUserDel hand large values removed by
+	// expiration and by DEL to the reclaim goroutine, like UNLINK does
+	lazyFreeExpire  bool
+	lazyFreeUserDel bool
+	reclaim         chan []Value
+	reclaimOnce     sync.Once
+	lazyFreePending atomic.Int64
+	lazyFreed       atomic.Int64
+	freeValue       func(value Value)
+
+	// waiters indexes blocked readers (XREAD, BLPOP) by the keys they
+	// watch, waiterChans finds a reader again from the channel handed
+	// out to it.
+	waiters     map[string]map[*keyWaiter]struct{}
+	waiterChans map[<-chan struct{}]*keyWaiter
+}
+
+type keyWaiter struct {
+	ch   chan struct{}
+	keys []string
 }
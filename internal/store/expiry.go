@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+/*
+The reaper follows the Redis active expiration cycle: every interval it
+samples keys that have an expiration and deletes the expired ones, and
+it keeps sampling while more than a quarter of a sample was expired.
+*/
+const (
+	expiryReaperInterval = 100 * time.Millisecond
+	expirySampleSize     = 20
+	expiryMaxRounds      = 16
+)
+
+/*
+StartExpiryReaper starts the background expiration of keys and hash
+fields. It stops when ctx is cancelled.
+*/
+func (s *Store) StartExpiryReaper(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(expiryReaperInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.reapExpired()
+				s.CollectExpiredFields()
+			}
+		}
+	}()
+}
+
+func (s *Store) reapExpired() {
+	for round := 0; round < expiryMaxRounds; round++ {
+		sampled, expired := s.reapBatch()
+		if sampled == 0 || expired*4 <= sampled {
+			return
+		}
+	}
+}
+
+/*
+reapBatch deletes the expired keys among one sample of volatile keys.
+The lock is held only for the sample.
+*/
+func (s *Store) reapBatch() (int, int) {
+	s.mutex.Lock()
+
+	var expired []string
+	sampled := 0
+
+	for key := range s.volatile {
+		if s.expireIfNeeded(key) {
+			expired = append(expired, key)
+		}
+
+		sampled++
+		if sampled >= expirySampleSize {
+			break
+		}
+	}
+
+	s.mutex.Unlock()
+
+	for _, key := range expired {
+		s.notifyWrite(key)
+	}
+
+	if len(expired) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"package":  "store",
+			"function": "reapBatch",
+			"expired":  len(expired),
+		}).Debug("Reaped expired keys")
+	}
+
+	return sampled, len(expired)
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestExpiryReaperDeletesWithoutReads(t *testing.T) {
+	s := NewStore()
+
+	// more than one sample, so the reaper has to keep going in a tick
+	px := 10
+	for i := 0; i < 3*expirySampleSize; i++ {
+		s.Set("k"+strconv.Itoa(i), "v", &px)
+	}
+	s.Set("persistent", "v", nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.StartExpiryReaper(ctx)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		keys, expires := s.Counts()
+		if keys == 1 && expires == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d keys, %d with an expiration left, want only the persistent key", keys, expires)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if s.UsedMemory() == 0 {
+		t.Fatal("the reaper dropped the accounting of the persistent key")
+	}
+}
+
+func TestExpiryReaperStopsWithContext(t *testing.T) {
+	s := NewStore()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.StartExpiryReaper(ctx)
+	cancel()
+
+	px := 1
+	s.Set("k", "v", &px)
+	time.Sleep(3 * expiryReaperInterval)
+
+	// Counts leaves out expired keys, look for the key itself
+	s.mutex.RLock()
+	_, ok := s.store["k"]
+	s.mutex.RUnlock()
+
+	if !ok {
+		t.Fatal("the reaper kept deleting keys after its context was cancelled")
+	}
+}
+
+func TestCountsLeavesOutExpiredKeys(t *testing.T) {
+	s := NewStore()
+
+	px := 100000
+	s.Set("expiring", "v", &px)
+	s.Set("expired", "v", &px)
+	s.Set("persistent", "v", nil)
+	expireNow(t, s, "expired")
+
+	// no read or reaper ran, the expired key is still in the map
+	if keys, expires := s.Counts(); keys != 2 || expires != 1 {
+		t.Fatalf("Counts = %d keys, %d expiring, want 2 and 1", keys, expires)
+	}
+}
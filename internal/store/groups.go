@@ -0,0 +1,191 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrGroupKeyMissing = errors.New(
+	"ERR The XGROUP subcommand requires the key to exist. " +
+		"Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.",
+)
+
+func noGroupError(key string, group string) error {
+	return fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s'", key, group)
+}
+
+/*
+XGroupCreate creates a consumer group that starts delivering after id.
+The special id "$" means the current top of the stream. With mkstream an
+empty stream is created when the key does not exist.
+*/
+func (s *Store) XGroupCreate(key string, group string, id string, mkstream bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	value, ok := s.store[key]
+	if !ok {
+		if !mkstream {
+			return ErrGroupKeyMissing
+		}
+
+		value = Value{
+			ValueData: ValueWithType{
+				Data:     StreamMessages{LastID: "0-0"},
+				DataType: StreamType,
+			},
+		}
+	}
+
+	if value.ValueData.DataType != StreamType {
+		return ErrWrongType
+	}
+
+	stream := value.ValueData.Data.(StreamMessages)
+
+	if _, exists := stream.Groups[group]; exists {
+		return ErrBusyGroup
+	}
+
+	switch {
+	case id == "$":
+		id = stream.LastID
+	case !strings.Contains(id, "-"):
+		// a bare millisecond time means the first sequence number
+		id += "-0"
+	}
+
+	if _, _, err := parseID(id); err != nil {
+		return err
+	}
+
+	if stream.Groups == nil {
+		stream.Groups = make(map[string]*ConsumerGroup)
+	}
+
+	stream.Groups[group] = &ConsumerGroup{
+		LastDeliveredID: id,
+		Consumers:       make(map[string]*Consumer),
+	}
+
+	value.ValueData.Data = stream
+	s.store[key] = value
+
+	if !ok {
+		s.measure(key)
+	}
+
+	return nil
+}
+
+/*
+XGroupCreateConsumer adds a consumer to a group. It reports whether the
+consumer was created, false meaning it already existed.
+*/
+func (s *Store) XGroupCreateConsumer(key string, group string, consumer string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	consumerGroup, err := s.getGroup(key, group)
+	if err != nil {
+		return false, err
+	}
+
+	if _, exists := consumerGroup.Consumers[consumer]; exists {
+		return false, nil
+	}
+
+	consumerGroup.Consumers[consumer] = &Consumer{SeenTime: time.Now()}
+
+	return true, nil
+}
+
+/*
+XInfoGroups returns the consumer groups of a stream sorted by name.
+*/
+func (s *Store) XInfoGroups(key string) ([]GroupInfo, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	value, ok := s.store[key]
+	if !ok {
+		return nil, ErrNoSuchKey
+	}
+
+	if value.ValueData.DataType != StreamType {
+		return nil, ErrWrongType
+	}
+
+	groups := value.ValueData.Data.(StreamMessages).Groups
+
+	infos := make([]GroupInfo, 0, len(groups))
+
+	for name, group := range groups {
+		info := GroupInfo{
+			Name:            name,
+			Consumers:       len(group.Consumers),
+			LastDeliveredID: group.LastDeliveredID,
+		}
+
+		for _, consumer := range group.Consumers {
+			info.Pending += consumer.Pending
+		}
+
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	return infos, nil
+}
+
+/*
+XInfoConsumers returns the consumers of a group sorted by name.
+*/
+func (s *Store) XInfoConsumers(key string, group string) ([]ConsumerInfo, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	consumerGroup, err := s.getGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]ConsumerInfo, 0, len(consumerGroup.Consumers))
+
+	for name, consumer := range consumerGroup.Consumers {
+		infos = append(infos, ConsumerInfo{
+			Name:    name,
+			Pending: consumer.Pending,
+			Idle:    time.Since(consumer.SeenTime),
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	return infos, nil
+}
+
+/*
+getGroup looks up a consumer group. The caller must hold the lock.
+*/
+func (s *Store) getGroup(key string, group string) (*ConsumerGroup, error) {
+	value, ok := s.store[key]
+	if !ok {
+		return nil, noGroupError(key, group)
+	}
+
+	if value.ValueData.DataType != StreamType {
+		return nil, ErrWrongType
+	}
+
+	consumerGroup, ok := value.ValueData.Data.(StreamMessages).Groups[group]
+	if !ok {
+		return nil, noGroupError(key, group)
+	}
+
+	return consumerGroup, nil
+}
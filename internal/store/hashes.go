@@ -0,0 +1,537 @@
+package store
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	HashFieldMissing = -2
+	HashFieldNoTTL   = -1
+	HashFieldTTLSet  = 1
+	HashFieldDeleted = 2
+)
+
+/*
+HSet sets fields of the hash stored at key from field/value pairs,
+creating the hash when the key does not exist. It returns the number of
+fields that were added rather than updated. Updating a field drops its
+time to live.
+*/
+func (s *Store) HSet(key string, pairs []string) (int, error) {
+	var changed bool
+	defer s.notifyWriteIf(&changed, key)
+	defer s.notifyExpiredFields()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	hash, ok, err := s.getHash(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if !ok {
+		hash = HashT{
+			Fields:    make(map[string]string),
+			ExpiredAt: make(map[string]time.Time),
+		}
+	}
+
+	var added int
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if _, exists := hash.Fields[pairs[i]]; !exists {
+			added++
+		}
+
+		delete(hash.ExpiredAt, pairs[i])
+		s.setHashField(key, &hash, pairs[i], pairs[i+1])
+	}
+
+	s.store[key] = Value{
+		ValueData: ValueWithType{Data: hash, DataType: HashType},
+		ExpiredAt: s.store[key].ExpiredAt,
+	}
+	changed = true
+
+	return added, nil
+}
+
+/*
+HGet returns the value of field in the hash stored at key. It reports
+false when the key or the field does not exist.
+*/
+func (s *Store) HGet(key string, field string) (string, bool, error) {
+	defer s.notifyExpiredFields()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	hash, ok, err := s.getHash(key)
+	if err != nil || !ok {
+		return "", false, err
+	}
+
+	value, exists := hash.Fields[field]
+
+	return value, exists, nil
+}
+
+/*
+HIncrBy adds delta to the integer stored in field of the hash at key and
+returns the new value. A missing key or field counts as 0 and the field
+keeps its time to live.
+*/
+func (s *Store) HIncrBy(key string, field string, delta int64) (int64, error) {
+	var changed bool
+	defer s.notifyWriteIf(&changed, key)
+	defer s.notifyExpiredFields()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	hash, ok, err := s.getHash(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if !ok {
+		hash = HashT{
+			Fields:    make(map[string]string),
+			ExpiredAt: make(map[string]time.Time),
+		}
+	}
+
+	var current int64
+	if value, exists := hash.Fields[field]; exists {
+		// only the canonical form counts, as for INCRBY
+		current, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || strconv.FormatInt(current, 10) != value {
+			return 0, ErrHashNotInteger
+		}
+	}
+
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+
+	current += delta
+	s.setHashField(key, &hash, field, strconv.FormatInt(current, 10))
+
+	s.store[key] = Value{
+		ValueData: ValueWithType{Data: hash, DataType: HashType},
+		ExpiredAt: s.store[key].ExpiredAt,
+	}
+	changed = true
+
+	return current, nil
+}
+
+/*
+HDel removes fields from the hash stored at key and returns how many
+existed. The key is deleted once the hash is empty.
+*/
+func (s *Store) HDel(key string, fields []string) (int, error) {
+	var changed bool
+	defer s.notifyWriteIf(&changed, key)
+	defer s.notifyExpiredFields()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	hash, ok, err := s.getHash(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	var removed int
+	for _, field := range fields {
+		if value, exists := hash.Fields[field]; exists {
+			s.removeHashField(key, &hash, field, value)
+			removed++
+		}
+	}
+
+	if len(hash.Fields) == 0 {
+		s.remove(key)
+	}
+	changed = removed > 0
+
+	return removed, nil
+}
+
+/*
+HLen returns the number of fields in the hash stored at key, 0 when it
+does not exist.
+*/
+func (s *Store) HLen(key string) (int, error) {
+	defer s.notifyExpiredFields()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	hash, _, err := s.getHash(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(hash.Fields), nil
+}
+
+/*
+HExists reports whether field exists in the hash stored at key.
+*/
+func (s *Store) HExists(key string, field string) (bool, error) {
+	defer s.notifyExpiredFields()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	hash, _, err := s.getHash(key)
+	if err != nil {
+		return false, err
+	}
+
+	_, exists := hash.Fields[field]
+
+	return exists, nil
+}
+
+/*
+HGetAll returns the fields and values of the hash stored at key as
+alternating field/value pairs, in no particular order. A missing key
+yields no pairs.
+*/
+func (s *Store) HGetAll(key string) ([]string, error) {
+	defer s.notifyExpiredFields()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	hash, _, err := s.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make([]string, 0, 2*len(hash.Fields))
+	for field, value := range hash.Fields {
+		pairs = append(pairs, field, value)
+	}
+
+	return pairs, nil
+}
+
+/*
+HKeys returns the field names of the hash stored at key.
+*/
+func (s *Store) HKeys(key string) ([]string, error) {
+	defer s.notifyExpiredFields()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	hash, _, err := s.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(hash.Fields))
+	for field := range hash.Fields {
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+/*
+HVals returns the values of the hash stored at key.
+*/
+func (s *Store) HVals(key string) ([]string, error) {
+	defer s.notifyExpiredFields()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	hash, _, err := s.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]string, 0, len(hash.Fields))
+	for _, value := range hash.Fields {
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+/*
+HExpire sets a time to live in seconds for the given fields of a hash.
+It returns a status code per field in the order they were passed.
+*/
+func (s *Store) HExpire(key string, seconds int, fields []string) ([]int, error) {
+	return s.HExpireAt(key, time.Now().Add(time.Duration(seconds)*time.Second), fields)
+}
+
+/*
+HExpireAt sets the time the given fields of a hash expire at, deleting
+them right away when it is not in the future. It returns a status code per
+field in the order they were passed.
+*/
+func (s *Store) HExpireAt(key string, expiredAt time.Time, fields []string) ([]int, error) {
+	var changed bool
+	defer s.notifyWriteIf(&changed, key)
+	defer s.notifyExpiredFields()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	result := make([]int, len(fields))
+
+	hash, ok, err := s.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		for i := range result {
+			result[i] = HashFieldMissing
+		}
+		return result, nil
+	}
+
+	expired := !expiredAt.After(time.Now())
+
+	for i, field := range fields {
+		value, exists := hash.Fields[field]
+		if !exists {
+			result[i] = HashFieldMissing
+			continue
+		}
+
+		if expired {
+			s.removeHashField(key, &hash, field, value)
+			result[i] = HashFieldDeleted
+			changed = true
+			continue
+		}
+
+		hash.ExpiredAt[field] = expiredAt
+		result[i] = HashFieldTTLSet
+		changed = true
+	}
+
+	if len(hash.Fields) == 0 {
+		s.remove(key)
+	}
+
+	return result, nil
+}
+
+/*
+HTTL returns the remaining time to live in seconds for the given fields of a hash.
+*/
+func (s *Store) HTTL(key string, fields []string) ([]int, error) {
+	defer s.notifyExpiredFields()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	result := make([]int, len(fields))
+
+	hash, ok, err := s.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, field := range fields {
+		if !ok {
+			result[i] = HashFieldMissing
+			continue
+		}
+
+		if _, exists := hash.Fields[field]; !exists {
+			result[i] = HashFieldMissing
+			continue
+		}
+
+		expiredAt, exists := hash.ExpiredAt[field]
+		if !exists {
+			result[i] = HashFieldNoTTL
+			continue
+		}
+
+		result[i] = int(time.Until(expiredAt).Round(time.Second) / time.Second)
+	}
+
+	return result, nil
+}
+
+/*
+CollectExpiredFields removes expired hash fields the way reapExpired
+removes expired keys: it samples hashes that have fields with a time to
+live and keeps sampling while more than a quarter of a sample had
+expired fields.
+*/
+func (s *Store) CollectExpiredFields() {
+	for round := 0; round < expiryMaxRounds; round++ {
+		sampled, expired := s.collectFieldsBatch()
+		if sampled == 0 || expired*4 <= sampled {
+			return
+		}
+	}
+}
+
+/*
+collectFieldsBatch removes the expired fields of one sample of hashes
+with field TTLs. The lock is held only for the sample.
+*/
+func (s *Store) collectFieldsBatch() (int, int) {
+	defer s.notifyExpiredFields()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var sampled, expired int
+
+	for key := range s.volatileFields {
+		// a key removed by a write is dropped from the set only once
+		// the write is accounted, after its lock was released
+		hash, ok := s.store[key].ValueData.Data.(HashT)
+		if !ok {
+			continue
+		}
+		fields := len(hash.Fields)
+
+		if s.expireHashFields(key) || len(hash.Fields) < fields {
+			expired++
+		}
+
+		sampled++
+		if sampled >= expirySampleSize {
+			break
+		}
+	}
+
+	return sampled, expired
+}
+
+/*
+setHashField sets field in the hash stored at key and accounts for it.
+The caller must hold the write lock and store the hash back.
+*/
+func (s *Store) setHashField(key string, hash *HashT, field string, value string) {
+	if current, exists := hash.Fields[field]; exists {
+		s.resize(key, int64(len(value)-len(current)))
+	} else {
+		s.resize(key, hashFieldSize(field, value))
+	}
+
+	hash.Fields[field] = value
+	s.convertHash(hash, field, value)
+}
+
+/*
+convertHash converts hash to the hashtable encoding when it now exceeds
+hash-max-listpack-entries or the field or value just set is longer than
+hash-max-listpack-value.
+*/
+func (s *Store) convertHash(hash *HashT, field string, value string) {
+	if hash.Hashtable {
+		return
+	}
+
+	if len(hash.Fields) > s.hashMaxListpackEntries ||
+		len(field) > s.hashMaxListpackValue ||
+		len(value) > s.hashMaxListpackValue {
+		hash.Hashtable = true
+	}
+}
+
+/*
+removeHashField deletes field, holding value, from the hash stored at
+key. The caller must hold the write lock.
+*/
+func (s *Store) removeHashField(key string, hash *HashT, field string, value string) {
+	delete(hash.Fields, field)
+	delete(hash.ExpiredAt, field)
+	s.resize(key, -hashFieldSize(field, value))
+}
+
+/*
+getHash returns the hash stored at key with its expired fields removed.
+The caller must hold the write lock.
+*/
+func (s *Store) getHash(key string) (HashT, bool, error) {
+	value, ok := s.store[key]
+	if !ok {
+		return HashT{}, false, nil
+	}
+
+	if value.ValueData.DataType != HashType {
+		return HashT{}, false, ErrWrongType
+	}
+
+	hash := value.ValueData.Data.(HashT)
+
+	if s.expireHashFields(key) {
+		return HashT{}, false, nil
+	}
+
+	return hash, true, nil
+}
+
+/*
+expireHashFields removes expired fields of the hash stored at key
+and reports whether the hash was deleted because it became empty.
+A hash that lost fields is accounted again and queued for
+notifyExpiredFields, so the caller must defer that call.
+*/
+func (s *Store) expireHashFields(key string) bool {
+	hash := s.store[key].ValueData.Data.(HashT)
+	now := time.Now()
+
+	var removed bool
+	for field, expiredAt := range hash.ExpiredAt {
+		if expiredAt.Before(now) {
+			s.removeHashField(key, &hash, field, hash.Fields[field])
+			removed = true
+		}
+	}
+
+	if !removed {
+		return false
+	}
+
+	if len(hash.Fields) == 0 {
+		delete(s.store, key)
+	}
+
+	s.account(key)
+	s.fieldWrites[key] = struct{}{}
+
+	return len(hash.Fields) == 0
+}
@@ -0,0 +1,317 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+/*
+expireFieldNow makes field of the hash at key expired, leaving it in
+place until something looks the hash up.
+*/
+func expireFieldNow(s *Store, key string, field string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.store[key].ValueData.Data.(HashT).ExpiredAt[field] = time.Now().Add(-time.Second)
+}
+
+func TestExpiredHashFieldNotifiesAndAccounts(t *testing.T) {
+	s := NewStore()
+
+	var written []string
+	s.SetWriteHook(func(key string) { written = append(written, key) })
+
+	s.HSet("h", []string{"keep", "v", "drop", "a long value that weighs on the memory estimate"})
+	before := s.UsedMemory()
+
+	s.HExpire("h", 100, []string{"drop"})
+	expireFieldNow(s, "h", "drop")
+	written = nil
+
+	if _, ok, _ := s.HGet("h", "drop"); ok {
+		t.Fatal("HGet returned an expired field")
+	}
+
+	if !reflect.DeepEqual(written, []string{"h"}) {
+		t.Fatalf("write hook called with %v, want [h]", written)
+	}
+	if after := s.UsedMemory(); after >= before {
+		t.Fatalf("UsedMemory = %d after dropping a field, was %d", after, before)
+	}
+}
+
+func TestExpiringLastHashFieldDeletesKey(t *testing.T) {
+	s := NewStore()
+	s.HSet("h", []string{"f", "v"})
+	s.HExpire("h", 100, []string{"f"})
+	expireFieldNow(s, "h", "f")
+
+	var written []string
+	s.SetWriteHook(func(key string) { written = append(written, key) })
+
+	s.CollectExpiredFields()
+
+	if s.Exists("h") {
+		t.Fatal("hash without fields left in the keyspace")
+	}
+	if !reflect.DeepEqual(written, []string{"h"}) {
+		t.Fatalf("write hook called with %v, want [h]", written)
+	}
+	if got := s.UsedMemory(); got != 0 {
+		t.Fatalf("UsedMemory = %d with an empty keyspace", got)
+	}
+}
+
+func TestExistsDropsHashWithAllFieldsExpired(t *testing.T) {
+	s := NewStore()
+	s.HSet("h", []string{"f1", "a", "f2", "b"})
+	s.HExpire("h", 100, []string{"f1", "f2"})
+	expireFieldNow(s, "h", "f1")
+
+	if !s.Exists("h") {
+		t.Fatal("hash with a live field reported missing")
+	}
+
+	expireFieldNow(s, "h", "f2")
+
+	if s.Exists("h") {
+		t.Fatal("hash whose fields all expired reported present")
+	}
+	if keys, _ := s.Counts(); keys != 0 {
+		t.Fatalf("%d keys after the hash was dropped, want 0", keys)
+	}
+}
+
+func TestHExpireAndHTTLOnExpiredKey(t *testing.T) {
+	s := NewStore()
+	s.HSet("h", []string{"f", "v"})
+	expireNow(t, s, "h")
+
+	if got, _ := s.HTTL("h", []string{"f"}); !reflect.DeepEqual(got, []int{HashFieldMissing}) {
+		t.Fatalf("HTTL = %v, want [%d]", got, HashFieldMissing)
+	}
+
+	s.HSet("h", []string{"f", "v"})
+	expireNow(t, s, "h")
+
+	if got, _ := s.HExpire("h", 100, []string{"f"}); !reflect.DeepEqual(got, []int{HashFieldMissing}) {
+		t.Fatalf("HExpire = %v, want [%d]", got, HashFieldMissing)
+	}
+	if s.Exists("h") {
+		t.Fatal("HExpire kept an expired key alive")
+	}
+}
+
+func TestVolatileFieldsTracksHashesWithFieldTTLs(t *testing.T) {
+	s := NewStore()
+	s.HSet("plain", []string{"f", "v"})
+	s.HSet("ttl", []string{"f1", "v", "f2", "v"})
+	s.HExpire("ttl", 100, []string{"f1"})
+
+	if _, ok := s.volatileFields["plain"]; ok {
+		t.Fatal("hash without field TTLs is tracked")
+	}
+	if _, ok := s.volatileFields["ttl"]; !ok {
+		t.Fatal("hash with a field TTL is not tracked")
+	}
+
+	// overwriting the field drops its TTL
+	s.HSet("ttl", []string{"f1", "w"})
+	if _, ok := s.volatileFields["ttl"]; ok {
+		t.Fatal("hash is still tracked after its last field TTL was dropped")
+	}
+}
+
+func TestCollectExpiredFieldsSamplesOnlyHashesWithFieldTTLs(t *testing.T) {
+	s := NewStore()
+	for i := 0; i < 1000; i++ {
+		s.HSet("plain"+strconv.Itoa(i), []string{"f", "v"})
+	}
+
+	for i := 0; i < 3*expirySampleSize; i++ {
+		key := "ttl" + strconv.Itoa(i)
+		s.HSet(key, []string{"keep", "v", "drop", "v"})
+		s.HExpire(key, 100, []string{"drop"})
+		expireFieldNow(s, key, "drop")
+	}
+
+	if sampled, expired := s.collectFieldsBatch(); sampled != expirySampleSize || expired != expirySampleSize {
+		t.Fatalf("collectFieldsBatch = %d sampled, %d expired, want %d both", sampled, expired, expirySampleSize)
+	}
+
+	s.CollectExpiredFields()
+
+	for i := 0; i < 3*expirySampleSize; i++ {
+		key := "ttl" + strconv.Itoa(i)
+		if fields, _ := s.HKeys(key); !reflect.DeepEqual(fields, []string{"keep"}) {
+			t.Fatalf("%s fields = %v, want [keep]", key, fields)
+		}
+	}
+	if len(s.volatileFields) != 0 {
+		t.Fatalf("%d hashes still tracked after their field TTLs expired", len(s.volatileFields))
+	}
+}
+
+func TestHashEncodingSwitchesPastListpackLimits(t *testing.T) {
+	encoding := func(s *Store, key string) Encoding {
+		t.Helper()
+		got, err := s.GetEncoding(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	s := NewStore()
+	s.SetHashMaxListpack(3, 8)
+
+	s.HSet("h", []string{"f1", "v", "f2", "v", "f3", "v"})
+	if got := encoding(s, "h"); got != ListpackEncoding {
+		t.Fatalf("encoding at the entry limit = %s, want listpack", got)
+	}
+
+	s.HIncrBy("h", "f4", 1)
+	if got := encoding(s, "h"); got != HashtableEncoding {
+		t.Fatalf("encoding past the entry limit = %s, want hashtable", got)
+	}
+
+	// like Redis, a hash never goes back to a listpack
+	s.HDel("h", []string{"f1", "f2", "f3"})
+	if got := encoding(s, "h"); got != HashtableEncoding {
+		t.Fatalf("encoding after shrinking = %s, want hashtable", got)
+	}
+
+	s.HSet("long", []string{"f", "12345678"})
+	if got := encoding(s, "long"); got != ListpackEncoding {
+		t.Fatalf("encoding at the value limit = %s, want listpack", got)
+	}
+	s.HSet("long", []string{"f", "123456789"})
+	if got := encoding(s, "long"); got != HashtableEncoding {
+		t.Fatalf("encoding past the value limit = %s, want hashtable", got)
+	}
+}
+
+func TestHSetCountsOnlyNewFields(t *testing.T) {
+	s := NewStore()
+
+	if got, err := s.HSet("h", []string{"f1", "a", "f2", "b"}); err != nil || got != 2 {
+		t.Fatalf("HSet of new fields = %d, %v, want 2", got, err)
+	}
+	if got, err := s.HSet("h", []string{"f1", "c", "f3", "d"}); err != nil || got != 1 {
+		t.Fatalf("HSet overwriting a field = %d, %v, want 1", got, err)
+	}
+
+	if got, ok, err := s.HGet("h", "f1"); err != nil || !ok || got != "c" {
+		t.Fatalf("HGet f1 = %q, %v, %v, want the overwritten value", got, ok, err)
+	}
+	if _, ok, err := s.HGet("h", "missing"); err != nil || ok {
+		t.Fatalf("HGet of a missing field = %v, %v, want not found", ok, err)
+	}
+	if _, ok, err := s.HGet("missing", "f1"); err != nil || ok {
+		t.Fatalf("HGet on a missing key = %v, %v, want not found", ok, err)
+	}
+
+	s.Set("k", "v", nil)
+	if _, err := s.HSet("k", []string{"f", "v"}); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("HSet on a string = %v, want ErrWrongType", err)
+	}
+	if _, _, err := s.HGet("k", "f"); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("HGet on a string = %v, want ErrWrongType", err)
+	}
+}
+
+func TestHGetAllRoundTripsHash(t *testing.T) {
+	s := NewStore()
+	want := map[string]string{"f1": "a", "f2": "b", "f3": "c"}
+
+	s.HSet("h", []string{"f1", "a", "f2", "b", "f3", "c"})
+
+	pairs, err := s.HGetAll("h")
+	if err != nil || len(pairs) != 6 {
+		t.Fatalf("HGetAll = %q, %v, want three field/value pairs", pairs, err)
+	}
+	got := make(map[string]string)
+	for i := 0; i < len(pairs); i += 2 {
+		got[pairs[i]] = pairs[i+1]
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("HGetAll = %v, want %v", got, want)
+	}
+
+	keys, _ := s.HKeys("h")
+	values, _ := s.HVals("h")
+	sort.Strings(keys)
+	sort.Strings(values)
+	if !reflect.DeepEqual(keys, []string{"f1", "f2", "f3"}) || !reflect.DeepEqual(values, []string{"a", "b", "c"}) {
+		t.Fatalf("HKeys = %q, HVals = %q, want the fields and values of the hash", keys, values)
+	}
+
+	if pairs, err := s.HGetAll("missing"); err != nil || len(pairs) != 0 {
+		t.Fatalf("HGetAll on a missing key = %q, %v, want nothing", pairs, err)
+	}
+}
+
+func TestHDelOfLastFieldDeletesKey(t *testing.T) {
+	s := NewStore()
+	s.HSet("h", []string{"f1", "a", "f2", "b"})
+
+	if got, err := s.HDel("h", []string{"f1", "missing"}); err != nil || got != 1 {
+		t.Fatalf("HDel f1 missing = %d, %v, want 1", got, err)
+	}
+	if got, _ := s.HLen("h"); got != 1 {
+		t.Fatalf("HLen after one HDel = %d, want 1", got)
+	}
+	if ok, err := s.HExists("h", "f1"); err != nil || ok {
+		t.Fatalf("HExists of a deleted field = %v, %v, want false", ok, err)
+	}
+	if ok, _ := s.HExists("h", "f2"); !ok {
+		t.Fatal("HExists of a remaining field = false, want true")
+	}
+
+	if got, _ := s.HDel("h", []string{"f2"}); got != 1 {
+		t.Fatalf("HDel of the last field = %d, want 1", got)
+	}
+	if s.Exists("h") {
+		t.Fatal("key exists after deleting the last field")
+	}
+	if got, err := s.HLen("h"); err != nil || got != 0 {
+		t.Fatalf("HLen on the deleted key = %d, %v, want 0", got, err)
+	}
+	if ok, err := s.HExists("h", "f2"); err != nil || ok {
+		t.Fatalf("HExists on the deleted key = %v, %v, want false", ok, err)
+	}
+}
+
+func TestHIncrByNegativeAndNonInteger(t *testing.T) {
+	s := NewStore()
+
+	if got, err := s.HIncrBy("h", "n", -5); err != nil || got != -5 {
+		t.Fatalf("HIncrBy of a missing field = %d, %v, want -5", got, err)
+	}
+	if got, err := s.HIncrBy("h", "n", -10); err != nil || got != -15 {
+		t.Fatalf("HIncrBy -10 = %d, %v, want -15", got, err)
+	}
+	if got, err := s.HIncrBy("h", "n", 20); err != nil || got != 5 {
+		t.Fatalf("HIncrBy 20 = %d, %v, want 5", got, err)
+	}
+	if got, _, _ := s.HGet("h", "n"); got != "5" {
+		t.Fatalf("stored value = %q, want 5", got)
+	}
+
+	for _, value := range []string{"abc", "1.5", "+5", "012", "-0", " 1"} {
+		s.HSet("h", []string{"f", value})
+		if _, err := s.HIncrBy("h", "f", 1); !errors.Is(err, ErrHashNotInteger) {
+			t.Fatalf("HIncrBy on %q = %v, want ErrHashNotInteger", value, err)
+		}
+	}
+
+	s.HSet("h", []string{"min", "-9223372036854775808"})
+	if _, err := s.HIncrBy("h", "min", -1); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("HIncrBy past the minimum = %v, want ErrOverflow", err)
+	}
+}
@@ -0,0 +1,27 @@
+package store
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	logrus.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+/*
+expireNow gives key an expiration in the past, leaving it in place until
+something looks it up.
+*/
+func expireNow(t *testing.T, s *Store, key string) {
+	t.Helper()
+
+	px := -1
+	if !s.SetExpiry(key, &px) {
+		t.Fatalf("key %q does not exist", key)
+	}
+}
@@ -0,0 +1,111 @@
+package store
+
+/*
+LazyFreeThreshold is the number of elements above which a removed value is
+freed by the reclaim goroutine rather than by the command removing it, the
+threshold Redis uses for lazy freeing. Smaller values are quicker to free
+than to hand over.
+*/
+const (
+	LazyFreeThreshold = 64
+	reclaimQueueSize  = 1024
+)
+
+/*
+SetLazyFree sets whether expired keys and keys removed by DEL have their
+values freed in the background like UNLINK does, the lazyfree-lazy-expire
+and lazyfree-lazy-user-del settings.
+*/
+func (s *Store) SetLazyFree(lazyExpire bool, lazyUserDel bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lazyFreeExpire = lazyExpire
+	s.lazyFreeUserDel = lazyUserDel
+}
+
+/*
+LazyFreeStats returns the number of removed values waiting for the reclaim
+goroutine and the number it has freed.
+*/
+func (s *Store) LazyFreeStats() (pending int64, freed int64) {
+	return s.lazyFreePending.Load(), s.lazyFreed.Load()
+}
+
+/*
+free releases a value that was just removed from the keyspace. With lazy
+set, a value above LazyFreeThreshold is handed to the reclaim goroutine and
+the caller returns at once, the key is gone from the keyspace either way.
+The caller must hold the write lock.
+*/
+func (s *Store) free(value Value, lazy bool) {
+	if !lazy || freeEffort(value) <= LazyFreeThreshold {
+		s.freeValue(value)
+		return
+	}
+
+	s.reclaimLater([]Value{value})
+}
+
+/*
+reclaimLater queues values for the reclaim goroutine, starting it on first
+use. The goroutine never takes the store lock, so the caller may hold it.
+*/
+func (s *Store) reclaimLater(values []Value) {
+	s.reclaimOnce.Do(func() {
+		s.reclaim = make(chan []Value, reclaimQueueSize)
+		go s.reclaimValues()
+	})
+
+	s.lazyFreePending.Add(int64(len(values)))
+	s.reclaim <- values
+}
+
+func (s *Store) reclaimValues() {
+	for values := range s.reclaim {
+		for _, value := range values {
+			s.freeValue(value)
+		}
+
+		s.lazyFreePending.Add(-int64(len(values)))
+		s.lazyFreed.Add(int64(len(values)))
+	}
+}
+
+/*
+freeEffort is the number of elements freeing value goes over.
+*/
+func freeEffort(value Value) int {
+	switch data := value.ValueData.Data.(type) {
+	case ListT:
+		return len(data.Elements)
+	case SetT:
+		return len(data.Members)
+	case ZSetT:
+		return len(data.Scores)
+	case HashT:
+		return len(data.Fields)
+	case StreamMessages:
+		return len(data.Messages)
+	}
+
+	return 1
+}
+
+/*
+clearValue empties the maps of a removed value, the part of freeing it
+that grows with its size, and leaves the rest to the garbage collector.
+Lists and streams are dropped whole since replies may still hold slices
+of them.
+*/
+func clearValue(value Value) {
+	switch data := value.ValueData.Data.(type) {
+	case SetT:
+		clear(data.Members)
+	case ZSetT:
+		clear(data.Scores)
+	case HashT:
+		clear(data.Fields)
+		clear(data.ExpiredAt)
+	}
+}
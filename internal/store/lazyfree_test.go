@@ -0,0 +1,137 @@
+package store
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLazyFree(t *testing.T) {
+	tests := []struct {
+		name        string
+		lazyExpire  bool
+		lazyUserDel bool
+		members     int
+		expired     bool
+		remove      func(s *Store)
+		lazy        bool
+	}{
+		{
+			name:    "UNLINK",
+			members: LazyFreeThreshold + 1,
+			remove:  func(s *Store) { s.Unlink("big") },
+			lazy:    true,
+		},
+		{
+			name:        "DEL with lazyfree-lazy-user-del",
+			lazyUserDel: true,
+			members:     LazyFreeThreshold + 1,
+			remove:      func(s *Store) { s.Del("big") },
+			lazy:        true,
+		},
+		{
+			name:    "DEL",
+			members: LazyFreeThreshold + 1,
+			remove:  func(s *Store) { s.Del("big") },
+		},
+		{
+			name:    "UNLINK of a small value",
+			members: LazyFreeThreshold,
+			remove:  func(s *Store) { s.Unlink("big") },
+		},
+		{
+			name:       "expiration with lazyfree-lazy-expire",
+			lazyExpire: true,
+			members:    LazyFreeThreshold + 1,
+			expired:    true,
+			remove:     func(s *Store) { s.reapExpired() },
+			lazy:       true,
+		},
+		{
+			name:    "lazy expiration",
+			members: LazyFreeThreshold + 1,
+			expired: true,
+			remove:  func(s *Store) { s.SCard("big") },
+		},
+		{
+			name:    "FLUSH",
+			members: 1,
+			remove:  func(s *Store) { s.Flush() },
+			lazy:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStore()
+			s.SetLazyFree(tt.lazyExpire, tt.lazyUserDel)
+
+			members := make([]string, tt.members)
+			for i := range members {
+				members[i] = strconv.Itoa(i)
+			}
+			s.SAdd("big", members)
+			if tt.expired {
+				expireNow(t, s, "big")
+			}
+
+			// a lazy free stays blocked until release, so the command
+			// can only return if the value is freed off its path
+			release := make(chan struct{})
+			if !tt.lazy {
+				close(release)
+			}
+
+			var freed atomic.Int64
+			s.freeValue = func(value Value) {
+				<-release
+				clearValue(value)
+				freed.Add(1)
+			}
+
+			removed := make(chan struct{})
+			go func() {
+				tt.remove(s)
+				close(removed)
+			}()
+
+			select {
+			case <-removed:
+			case <-time.After(time.Second):
+				t.Fatal("removing the key waited for its value to be freed")
+			}
+
+			if s.Exists("big") {
+				t.Fatal("the key is still in the keyspace")
+			}
+
+			if !tt.lazy {
+				if got := freed.Load(); got != 1 {
+					t.Fatalf("%d values freed by the command, want 1", got)
+				}
+				if pending, lazyFreed := s.LazyFreeStats(); pending != 0 || lazyFreed != 0 {
+					t.Fatalf("%d values pending and %d freed in the background, want none", pending, lazyFreed)
+				}
+				return
+			}
+
+			if pending, _ := s.LazyFreeStats(); pending != 1 || freed.Load() != 0 {
+				t.Fatalf("%d values pending and %d freed before the reclaim goroutine ran", pending, freed.Load())
+			}
+
+			close(release)
+
+			deadline := time.Now().Add(time.Second)
+			for {
+				if pending, lazyFreed := s.LazyFreeStats(); pending == 0 && lazyFreed == 1 {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("the reclaim goroutine never freed the value")
+				}
+				time.Sleep(time.Millisecond)
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package store
+
+/*
+LPush prepends values to the list stored at key, creating the list when
+the key does not exist. It returns the new length of the list.
+*/
+func (s *Store) LPush(key string, values []string) (int, error) {
+	return s.push(key, values, true, true)
+}
+
+/*
+RPush appends values to the list stored at key, creating the list when
+the key does not exist. It returns the new length of the list.
+*/
+func (s *Store) RPush(key string, values []string) (int, error) {
+	return s.push(key, values, false, true)
+}
+
+/*
+LPushX prepends values to the list stored at key only if the key
+already holds a list. It returns the new length of the list or 0
+when the key does not exist.
+*/
+func (s *Store) LPushX(key string, values []string) (int, error) {
+	return s.push(key, values, true, false)
+}
+
+/*
+RPushX appends values to the list stored at key only if the key
+already holds a list. It returns the new length of the list or 0
+when the key does not exist.
+*/
+func (s *Store) RPushX(key string, values []string) (int, error) {
+	return s.push(key, values, false, false)
+}
+
+func (s *Store) push(key string, values []string, left bool, create bool) (int, error) {
+	var changed bool
+	defer s.notifyWriteIf(&changed, key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	value, ok := s.store[key]
+	if !ok {
+		if !create {
+			return 0, nil
+		}
+
+		value = Value{
+			ValueData: ValueWithType{Data: ListT{}, DataType: ListType},
+		}
+	}
+
+	if value.ValueData.DataType != ListType {
+		return 0, ErrWrongType
+	}
+
+	list := value.ValueData.Data.(ListT)
+
+	for _, element := range values {
+		s.resize(key, elementSize(element))
+	}
+
+	if left {
+		elements := make([]string, 0, len(values)+len(list.Elements))
+		for i := len(values) - 1; i >= 0; i-- {
+			elements = append(elements, values[i])
+		}
+		list.Elements = append(elements, list.Elements...)
+	} else {
+		list.Elements = append(list.Elements, values...)
+	}
+
+	value.ValueData.Data = list
+	s.store[key] = value
+	changed = true
+
+	s.releaseWaiters(key)
+
+	return len(list.Elements), nil
+}
+
+/*
+LPop removes and returns up to count elements from the head of the list
+stored at key. The key is deleted once the list is empty. A missing key
+yields ErrNotFound.
+*/
+func (s *Store) LPop(key string, count int) ([]string, error) {
+	return s.pop(key, count, true)
+}
+
+/*
+RPop removes and returns up to count elements from the tail of the list
+stored at key, last element first.
+*/
+func (s *Store) RPop(key string, count int) ([]string, error) {
+	return s.pop(key, count, false)
+}
+
+func (s *Store) pop(key string, count int, left bool) ([]string, error) {
+	var changed bool
+	defer s.notifyWriteIf(&changed, key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.expireIfNeeded(key) {
+		return nil, ErrNotFound
+	}
+
+	value, ok := s.store[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	if value.ValueData.DataType != ListType {
+		return nil, ErrWrongType
+	}
+
+	list := value.ValueData.Data.(ListT)
+	count = min(count, len(list.Elements))
+
+	popped := make([]string, 0, count)
+	changed = count > 0
+
+	if left {
+		popped = append(popped, list.Elements[:count]...)
+		list.Elements = list.Elements[count:]
+	} else {
+		for i := len(list.Elements) - 1; i >= len(list.Elements)-count; i-- {
+			popped = append(popped, list.Elements[i])
+		}
+		list.Elements = list.Elements[:len(list.Elements)-count]
+	}
+
+	for _, element := range popped {
+		s.resize(key, -elementSize(element))
+	}
+
+	if len(list.Elements) == 0 {
+		s.remove(key)
+		return popped, nil
+	}
+
+	value.ValueData.Data = list
+	s.store[key] = value
+
+	return popped, nil
+}
+
+/*
+LRange returns the elements of the list stored at key between start and
+end inclusive. Negative indexes count from the tail and out of range
+indexes are clamped, so a missing key or an empty range yields no elements.
+*/
+func (s *Store) LRange(key string, start int, end int) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	value, ok := s.store[key]
+	if !ok {
+		return nil, nil
+	}
+
+	if value.ValueData.DataType != ListType {
+		return nil, ErrWrongType
+	}
+
+	elements := value.ValueData.Data.(ListT).Elements
+
+	start, end, ok = NormalizeRange(start, end, len(elements))
+	if !ok {
+		return nil, nil
+	}
+
+	return append([]string(nil), elements[start:end+1]...), nil
+}
+
+/*
+LLen returns the length of the list stored at key, 0 when it does not exist.
+*/
+func (s *Store) LLen(key string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	value, ok := s.store[key]
+	if !ok {
+		return 0, nil
+	}
+
+	if value.ValueData.DataType != ListType {
+		return 0, ErrWrongType
+	}
+
+	return len(value.ValueData.Data.(ListT).Elements), nil
+}
+
+/*
+LIndex returns the element at index in the list stored at key. Negative
+indexes count from the tail. It reports false when the key does not
+exist or the index is out of range.
+*/
+func (s *Store) LIndex(key string, index int) (string, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	value, ok := s.store[key]
+	if !ok {
+		return "", false, nil
+	}
+
+	if value.ValueData.DataType != ListType {
+		return "", false, ErrWrongType
+	}
+
+	elements := value.ValueData.Data.(ListT).Elements
+
+	if index < 0 {
+		index += len(elements)
+	}
+	if index < 0 || index >= len(elements) {
+		return "", false, nil
+	}
+
+	return elements[index], true, nil
+}
+
+/*
+LSet replaces the element at index in the list stored at key. Negative
+indexes count from the tail.
+*/
+func (s *Store) LSet(key string, index int, element string) error {
+	var changed bool
+	defer s.notifyWriteIf(&changed, key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	value, ok := s.store[key]
+	if !ok {
+		return ErrNoSuchKey
+	}
+
+	if value.ValueData.DataType != ListType {
+		return ErrWrongType
+	}
+
+	list := value.ValueData.Data.(ListT)
+
+	if index < 0 {
+		index += len(list.Elements)
+	}
+	if index < 0 || index >= len(list.Elements) {
+		return ErrIndexOutOfRange
+	}
+
+	// copy so snapshots sharing the old backing array stay untouched
+	list.Elements = append([]string(nil), list.Elements...)
+	s.resize(key, elementSize(element)-elementSize(list.Elements[index]))
+	list.Elements[index] = element
+
+	value.ValueData.Data = list
+	s.store[key] = value
+	changed = true
+
+	return nil
+}
+
+/*
+QuicklistInfo describes how a quicklist encoded list is split into
+listpack nodes of at most list-max-listpack-size elements.
+*/
+type QuicklistInfo struct {
+	Nodes            int
+	AvgNode          float64
+	ListpackMax      int
+	UncompressedSize int
+}
+
+/*
+Quicklist returns the node layout of the list stored at key. It reports
+false when the key does not hold a quicklist encoded list.
+*/
+func (s *Store) Quicklist(key string) (QuicklistInfo, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	value, ok := s.store[key]
+	if !ok || value.ValueData.DataType != ListType {
+		return QuicklistInfo{}, false
+	}
+
+	elements := value.ValueData.Data.(ListT).Elements
+	if s.listEncoding(len(elements)) != QuicklistEncoding {
+		return QuicklistInfo{}, false
+	}
+
+	info := QuicklistInfo{
+		Nodes:       (len(elements) + s.listMaxListpackSize - 1) / s.listMaxListpackSize,
+		ListpackMax: s.listMaxListpackSize,
+	}
+	info.AvgNode = float64(len(elements)) / float64(info.Nodes)
+
+	for _, element := range elements {
+		info.UncompressedSize += len(element)
+	}
+
+	return info, true
+}
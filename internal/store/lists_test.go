@@ -0,0 +1,162 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPushXOnExpiredKey(t *testing.T) {
+	s := NewStore()
+	s.RPush("l", []string{"old"})
+	expireNow(t, s, "l")
+
+	if got, err := s.LPushX("l", []string{"a"}); err != nil || got != 0 {
+		t.Fatalf("LPushX = %d, %v, want 0", got, err)
+	}
+	if got, err := s.RPushX("l", []string{"a"}); err != nil || got != 0 {
+		t.Fatalf("RPushX = %d, %v, want 0", got, err)
+	}
+	if s.Exists("l") {
+		t.Fatal("pushing onto an expired key with PUSHX created it")
+	}
+}
+
+func TestPushOnExpiredWrongTypeKey(t *testing.T) {
+	s := NewStore()
+	s.Set("k", "v", nil)
+	expireNow(t, s, "k")
+
+	if got, err := s.RPush("k", []string{"a"}); err != nil || got != 1 {
+		t.Fatalf("RPush = %d, %v, want 1", got, err)
+	}
+	if got, _ := s.LRange("k", 0, -1); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Fatalf("LRange = %v", got)
+	}
+}
+
+func TestPushOnExpiredKeyCreatesFreshList(t *testing.T) {
+	for _, push := range []struct {
+		name string
+		fn   func(s *Store, key string, values []string) (int, error)
+	}{
+		{"LPush", (*Store).LPush},
+		{"RPush", (*Store).RPush},
+	} {
+		t.Run(push.name, func(t *testing.T) {
+			s := NewStore()
+			s.RPush("l", []string{"old1", "old2"})
+			expireNow(t, s, "l")
+
+			if got, err := push.fn(s, "l", []string{"new"}); err != nil || got != 1 {
+				t.Fatalf("%s = %d, %v, want 1", push.name, got, err)
+			}
+			if got, _ := s.LRange("l", 0, -1); !reflect.DeepEqual(got, []string{"new"}) {
+				t.Fatalf("LRange = %v, want [new]", got)
+			}
+			if _, _, hasExpiry := s.TTLRemaining("l"); hasExpiry {
+				t.Fatal("the fresh list kept the expiration of the old one")
+			}
+		})
+	}
+}
+
+func TestListAccessorsOnExpiredKey(t *testing.T) {
+	s := NewStore()
+
+	expired := func() {
+		s.RPush("l", []string{"a", "b"})
+		expireNow(t, s, "l")
+	}
+
+	expired()
+	if got, err := s.LPop("l", 1); err != ErrNotFound {
+		t.Fatalf("LPop = %v, %v, want ErrNotFound", got, err)
+	}
+
+	expired()
+	if got, err := s.RPop("l", 1); err != ErrNotFound {
+		t.Fatalf("RPop = %v, %v, want ErrNotFound", got, err)
+	}
+
+	expired()
+	if got, _ := s.LRange("l", 0, -1); got != nil {
+		t.Fatalf("LRange = %v, want nothing", got)
+	}
+
+	expired()
+	if got, _ := s.LLen("l"); got != 0 {
+		t.Fatalf("LLen = %d, want 0", got)
+	}
+
+	expired()
+	if _, ok, _ := s.LIndex("l", 0); ok {
+		t.Fatal("LIndex found an element of an expired list")
+	}
+
+	expired()
+	if err := s.LSet("l", 0, "x"); err != ErrNoSuchKey {
+		t.Fatalf("LSet = %v, want ErrNoSuchKey", err)
+	}
+
+	expired()
+	if _, ok := s.Quicklist("l"); ok {
+		t.Fatal("Quicklist described an expired list")
+	}
+}
+
+func TestListEncodingSwitchesPastListpackSize(t *testing.T) {
+	s := NewStore()
+	s.SetListMaxListpackSize(3)
+
+	s.RPush("l", []string{"a", "b", "c"})
+	if encoding, _ := s.GetEncoding("l"); encoding != ListpackEncoding {
+		t.Fatalf("encoding at the threshold = %s, want %s", encoding, ListpackEncoding)
+	}
+
+	s.RPush("l", []string{"d"})
+	if encoding, _ := s.GetEncoding("l"); encoding != QuicklistEncoding {
+		t.Fatalf("encoding past the threshold = %s, want %s", encoding, QuicklistEncoding)
+	}
+
+	info, ok := s.Quicklist("l")
+	if !ok || info.Nodes != 2 || info.ListpackMax != 3 {
+		t.Fatalf("Quicklist = %+v, %v", info, ok)
+	}
+}
+
+func TestSetListMaxListpackSizeRejectsBelowOne(t *testing.T) {
+	s := NewStore()
+	s.RPush("l", make([]string, DefaultListMaxListpackSize+1))
+
+	for _, size := range []int{0, -1} {
+		if err := s.SetListMaxListpackSize(size); err != ErrListpackSize {
+			t.Fatalf("SetListMaxListpackSize(%d) = %v, want %v", size, err, ErrListpackSize)
+		}
+	}
+
+	// the rejected sizes never reached the divisor
+	info, ok := s.Quicklist("l")
+	if !ok || info.ListpackMax != DefaultListMaxListpackSize || info.Nodes != 2 {
+		t.Fatalf("Quicklist = %+v, %v", info, ok)
+	}
+}
+
+func TestPushOnNewAndExistingList(t *testing.T) {
+	s := NewStore()
+
+	if got, err := s.RPush("l", []string{"b", "c"}); err != nil || got != 2 {
+		t.Fatalf("RPush on a new list = %d, %v, want 2", got, err)
+	}
+	if got, err := s.LPush("l", []string{"a", "z"}); err != nil || got != 4 {
+		t.Fatalf("LPush on an existing list = %d, %v, want 4", got, err)
+	}
+	if got, _ := s.LRange("l", 0, -1); !reflect.DeepEqual(got, []string{"z", "a", "b", "c"}) {
+		t.Fatalf("LRange = %v, want [z a b c]", got)
+	}
+
+	s.Set("k", "v", nil)
+	if _, err := s.LPush("k", []string{"a"}); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("LPush on a string = %v, want ErrWrongType", err)
+	}
+}
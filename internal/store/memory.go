@@ -0,0 +1,160 @@
+package store
+
+/*
+keyOverhead approximates the fixed cost of a keyspace entry: the map
+bucket slot, the Value struct and the string headers.
+*/
+const (
+	keyOverhead     = 64
+	elementOverhead = 16
+)
+
+/*
+UsedMemory returns the running estimate of the memory used by the keyspace.
+*/
+func (s *Store) UsedMemory() int64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.usedMemory
+}
+
+/*
+account refreshes the membership of key in the sets of keys with an
+expiration and of hashes with field TTLs after it was written or removed,
+and drops the size of a removed key. The size of a live key is kept up to
+date by the writes themselves, through resize and measure, so account
+only measures a key whose size was never recorded. The caller must hold
+the write lock.
+*/
+func (s *Store) account(key string) {
+	value, ok := s.store[key]
+
+	if ok && value.ExpiredAt != nil {
+		s.volatile[key] = struct{}{}
+	} else {
+		delete(s.volatile, key)
+	}
+
+	if hash, isHash := value.ValueData.Data.(HashT); ok && isHash && len(hash.ExpiredAt) > 0 {
+		s.volatileFields[key] = struct{}{}
+	} else {
+		delete(s.volatileFields, key)
+	}
+
+	if !ok {
+		s.setSize(key, 0)
+		return
+	}
+
+	if _, known := s.sizes[key]; !known {
+		s.measure(key)
+	}
+}
+
+/*
+remove deletes key and accounts for it right away. The caller must hold
+the write lock.
+*/
+func (s *Store) remove(key string) {
+	delete(s.store, key)
+	s.account(key)
+}
+
+/*
+measure estimates the whole value stored at key. It costs as much as the
+value is large, so it is only meant for writes that replace the value as
+a whole. The caller must hold the write lock.
+*/
+func (s *Store) measure(key string) {
+	value, ok := s.store[key]
+	if !ok {
+		s.setSize(key, 0)
+		return
+	}
+
+	s.setSize(key, estimateSize(key, value))
+}
+
+/*
+resize adds delta to the size of key after elements were added to or
+removed from its value. A key without a recorded size was just created
+and starts from its fixed overhead. The caller must hold the write lock.
+*/
+func (s *Store) resize(key string, delta int64) {
+	size, ok := s.sizes[key]
+	if !ok {
+		size = int64(keyOverhead + len(key))
+	}
+
+	s.setSize(key, size+delta)
+}
+
+func (s *Store) setSize(key string, size int64) {
+	s.usedMemory += size - s.sizes[key]
+
+	if size == 0 {
+		delete(s.sizes, key)
+		return
+	}
+
+	s.sizes[key] = size
+}
+
+func estimateSize(key string, value Value) int64 {
+	size := int64(keyOverhead + len(key))
+
+	switch data := value.ValueData.Data.(type) {
+	case StringT:
+		size += int64(len(data))
+
+	case ListT:
+		for _, element := range data.Elements {
+			size += elementSize(element)
+		}
+
+	case SetT:
+		for member := range data.Members {
+			size += elementSize(member)
+		}
+
+	case ZSetT:
+		for member := range data.Scores {
+			size += zsetMemberSize(member)
+		}
+
+	case HashT:
+		for field, fieldValue := range data.Fields {
+			size += hashFieldSize(field, fieldValue)
+		}
+
+	case StreamMessages:
+		for _, message := range data.Messages {
+			size += streamMessageSize(message)
+		}
+	}
+
+	return size
+}
+
+// elementSize is the estimated size of a list element or a set member.
+func elementSize(element string) int64 {
+	return int64(elementOverhead + len(element))
+}
+
+func zsetMemberSize(member string) int64 {
+	return int64(elementOverhead + 8 + len(member))
+}
+
+func hashFieldSize(field string, value string) int64 {
+	return int64(2*elementOverhead + len(field) + len(value))
+}
+
+func streamMessageSize(message StreamMessage) int64 {
+	size := int64(elementOverhead + len(message.ID))
+	for _, field := range message.Fields {
+		size += hashFieldSize(field.Name, field.Value)
+	}
+
+	return size
+}
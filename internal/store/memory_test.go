@@ -0,0 +1,98 @@
+package store
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+/*
+measuredMemory estimates every value of s from scratch, for comparison
+with the running estimate kept by the writes.
+*/
+func measuredMemory(s *Store) int64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var total int64
+	for key, value := range s.store {
+		total += estimateSize(key, value)
+	}
+
+	return total
+}
+
+func TestUsedMemoryFollowsElementWrites(t *testing.T) {
+	s := NewStore()
+
+	s.Set("str", "abc", nil)
+	s.Append("str", "defgh")
+	s.SetRange("str", 10, "xyz")
+	s.SetRange("new", 2, "pad")
+	s.IncrBy("counter", 12345)
+	s.SAdd("set", []string{"a", "bb", "ccc", "a"})
+	s.SRem("set", []string{"bb", "missing"})
+	s.RPush("list", []string{"one", "two", "three"})
+	s.LPush("list", []string{"zero"})
+	s.LSet("list", 1, "a much longer element")
+	s.LPop("list", 2)
+	s.HSet("hash", []string{"f1", "v1", "f2", "v2", "f1", "longer value"})
+	s.HIncrBy("hash", "n", 100)
+	s.HDel("hash", []string{"f2"})
+	s.HExpire("hash", 0, []string{"n"})
+	s.ZAdd("zset", []ZMember{{Member: "a", Score: 1}, {Member: "b", Score: 2}, {Member: "a", Score: 3}})
+	s.ZIncrBy("zset", "c", 4)
+	s.ZRem("zset", []string{"b"})
+	s.XAdd("stream", StreamMessage{ID: "1-1", Fields: []StreamField{{Name: "f", Value: "v"}}})
+	s.XAddAuto("stream", []StreamField{{Name: "field", Value: "value"}})
+	s.Copy("set", "set2", false)
+	s.Rename("zset", "renamed")
+	s.SetOpStore("union", SetUnion, []string{"set", "set2"})
+	s.RPush("gone", []string{"x"})
+	s.RPop("gone", 1)
+
+	if got, want := s.UsedMemory(), measuredMemory(s); got != want {
+		t.Fatalf("UsedMemory = %d, measuring every value gives %d", got, want)
+	}
+
+	s.Flush()
+	if got := s.UsedMemory(); got != 0 {
+		t.Fatalf("UsedMemory = %d after FLUSH", got)
+	}
+}
+
+/*
+TestLargeCollectionKeepsElementWritesCheap checks that adding one member
+costs the same whatever the size of the set, so the memory estimate does
+not go over the whole value on every write.
+*/
+func TestLargeCollectionKeepsElementWritesCheap(t *testing.T) {
+	const writes = 2000
+
+	elapsed := func(s *Store, key string) time.Duration {
+		start := time.Now()
+		for i := 0; i < writes; i++ {
+			s.SAdd(key, []string{"new-" + strconv.Itoa(i)})
+		}
+		return time.Since(start)
+	}
+
+	s := NewStore()
+
+	members := make([]string, 200000)
+	for i := range members {
+		members[i] = strconv.Itoa(i)
+	}
+	s.SAdd("large", members)
+
+	small := elapsed(s, "small")
+	large := elapsed(s, "large")
+
+	if large > 10*small+50*time.Millisecond {
+		t.Fatalf("%d SADDs took %v on a large set and %v on a small one", writes, large, small)
+	}
+
+	if got, want := s.UsedMemory(), measuredMemory(s); got != want {
+		t.Fatalf("UsedMemory = %d, measuring every value gives %d", got, want)
+	}
+}
@@ -0,0 +1,632 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"math"
+	"time"
+)
+
+/*
+RDB value types. Lists, sets, sorted sets and hashes use the plain
+encodings every Redis version still loads, and hashes with field TTLs the
+metadata type of Redis 7.4. Redis keeps streams as listpacks inside a
+radix tree; RDBTypeStream is a simpler layout of this server's own under
+a type byte Redis does not assign, so a real Redis refuses such a file
+instead of misreading it.
+*/
+const (
+	RDBVersion          = 11
+	RDBTypeString       = 0
+	RDBTypeList         = 1
+	RDBTypeSet          = 2
+	RDBTypeHash         = 4
+	RDBTypeZSet2        = 5
+	RDBTypeHashMetadata = 24
+	RDBTypeStream       = 200
+)
+
+var (
+	ErrNotSerializable = errors.New("ERR value type is not serializable")
+	ErrBadPayload      = errors.New("ERR DUMP payload version or checksum are wrong")
+	ErrBadDataFormat   = errors.New("ERR Bad data format")
+	ErrBusyKey         = errors.New("BUSYKEY Target key name already exists.")
+)
+
+/*
+crc64Table is the Jones polynomial used by Redis for DUMP payloads, in the
+reversed form expected by hash/crc64.
+*/
+var crc64Table = crc64.MakeTable(0x95ac9329ac4bc9b5)
+
+func WriteRDBLength(w *bytes.Buffer, length int) {
+	switch {
+	case length < 1<<6:
+		w.WriteByte(byte(length))
+	case length < 1<<14:
+		w.WriteByte(byte(length>>8) | 0x40)
+		w.WriteByte(byte(length))
+	case length <= math.MaxUint32:
+		w.WriteByte(0x80)
+		binary.Write(w, binary.BigEndian, uint32(length))
+	default:
+		w.WriteByte(0x81)
+		binary.Write(w, binary.BigEndian, uint64(length))
+	}
+}
+
+func WriteRDBString(w *bytes.Buffer, value string) {
+	WriteRDBLength(w, len(value))
+	w.WriteString(value)
+}
+
+/*
+ReadRDBLength reads a length-encoded value. The second result reports
+whether the value uses the special string encoding, in which case the
+length holds the encoding format instead.
+*/
+func ReadRDBLength(r *bufio.Reader) (int, bool, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch first >> 6 {
+	case 0:
+		return int(first & 0x3f), false, nil
+	case 1:
+		next, err := r.ReadByte()
+		if err != nil {
+			return 0, false, err
+		}
+		return int(first&0x3f)<<8 | int(next), false, nil
+	case 2:
+		if first == 0x81 {
+			var length uint64
+			if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+				return 0, false, err
+			}
+			return int(length), false, nil
+		}
+
+		var length uint32
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return 0, false, err
+		}
+		return int(length), false, nil
+	default:
+		return int(first & 0x3f), true, nil
+	}
+}
+
+/*
+ReadRDBString reads a length prefixed string, including the integer
+encodings. LZF compressed strings are not supported.
+*/
+func ReadRDBString(r *bufio.Reader) (string, error) {
+	length, special, err := ReadRDBLength(r)
+	if err != nil {
+		return "", err
+	}
+
+	if special {
+		switch length {
+		case 0:
+			var v int8
+			err = binary.Read(r, binary.LittleEndian, &v)
+			return fmt.Sprint(v), err
+		case 1:
+			var v int16
+			err = binary.Read(r, binary.LittleEndian, &v)
+			return fmt.Sprint(v), err
+		case 2:
+			var v int32
+			err = binary.Read(r, binary.LittleEndian, &v)
+			return fmt.Sprint(v), err
+		default:
+			return "", errors.New("compressed RDB strings are not supported")
+		}
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+
+	return string(buf), nil
+}
+
+/*
+SerializeValue writes the RDB type byte followed by the encoded value.
+It is shared by the RDB writer and DUMP so both agree on the format.
+*/
+func SerializeValue(w *bytes.Buffer, value Value) error {
+	switch data := value.ValueData.Data.(type) {
+	case StringT:
+		w.WriteByte(RDBTypeString)
+		WriteRDBString(w, string(data))
+
+	case ListT:
+		w.WriteByte(RDBTypeList)
+		WriteRDBLength(w, len(data.Elements))
+		for _, element := range data.Elements {
+			WriteRDBString(w, element)
+		}
+
+	case SetT:
+		w.WriteByte(RDBTypeSet)
+		WriteRDBLength(w, len(data.Members))
+		for member := range data.Members {
+			WriteRDBString(w, member)
+		}
+
+	case ZSetT:
+		w.WriteByte(RDBTypeZSet2)
+		WriteRDBLength(w, len(data.Ranked))
+		for _, m := range data.Ranked {
+			WriteRDBString(w, m.Member)
+			binary.Write(w, binary.LittleEndian, math.Float64bits(m.Score))
+		}
+
+	case HashT:
+		serializeHash(w, data)
+
+	case StreamMessages:
+		serializeStream(w, data)
+
+	default:
+		return ErrNotSerializable
+	}
+
+	return nil
+}
+
+/*
+serializeHash writes a hash, with the field TTLs when any field has one.
+As in Redis, each TTL is stored relative to the earliest one, plus one so
+that 0 can mean no TTL.
+*/
+func serializeHash(w *bytes.Buffer, hash HashT) {
+	if len(hash.ExpiredAt) == 0 {
+		w.WriteByte(RDBTypeHash)
+		WriteRDBLength(w, len(hash.Fields))
+		for field, value := range hash.Fields {
+			WriteRDBString(w, field)
+			WriteRDBString(w, value)
+		}
+		return
+	}
+
+	var minExpire int64 = math.MaxInt64
+	for _, expiredAt := range hash.ExpiredAt {
+		minExpire = min(minExpire, expiredAt.UnixMilli())
+	}
+
+	w.WriteByte(RDBTypeHashMetadata)
+	binary.Write(w, binary.LittleEndian, minExpire)
+	WriteRDBLength(w, len(hash.Fields))
+
+	for field, value := range hash.Fields {
+		var ttl int
+		if expiredAt, ok := hash.ExpiredAt[field]; ok {
+			ttl = int(expiredAt.UnixMilli()-minExpire) + 1
+		}
+
+		WriteRDBLength(w, ttl)
+		WriteRDBString(w, field)
+		WriteRDBString(w, value)
+	}
+}
+
+/*
+serializeStream writes the entries of a stream, its last ID and its
+consumer groups. The last ID is kept even when entries were removed, so a
+loaded stream never hands out an ID it already used.
+*/
+func serializeStream(w *bytes.Buffer, stream StreamMessages) {
+	w.WriteByte(RDBTypeStream)
+	WriteRDBString(w, stream.LastID)
+
+	WriteRDBLength(w, len(stream.Messages))
+	for _, message := range stream.Messages {
+		WriteRDBString(w, message.ID)
+		WriteRDBLength(w, 2*len(message.Fields))
+		for _, field := range message.Fields {
+			WriteRDBString(w, field.Name)
+			WriteRDBString(w, field.Value)
+		}
+	}
+
+	WriteRDBLength(w, len(stream.Groups))
+	for name, group := range stream.Groups {
+		WriteRDBString(w, name)
+		WriteRDBString(w, group.LastDeliveredID)
+
+		WriteRDBLength(w, len(group.Consumers))
+		for consumerName, consumer := range group.Consumers {
+			WriteRDBString(w, consumerName)
+			WriteRDBLength(w, consumer.Pending)
+			binary.Write(w, binary.LittleEndian, consumer.SeenTime.UnixMilli())
+		}
+	}
+}
+
+/*
+DeserializeValue reads a value written by SerializeValue.
+*/
+func DeserializeValue(r *bufio.Reader) (Value, error) {
+	valueType, err := r.ReadByte()
+	if err != nil {
+		return Value{}, err
+	}
+
+	return ReadRDBValue(valueType, r)
+}
+
+/*
+ReadRDBValue reads a value of the given RDB type whose type byte was
+already consumed, as in an RDB file where the key sits in between. Sets
+and hashes come back without their encoding, the store that takes them
+sets it from its own limits.
+*/
+func ReadRDBValue(valueType byte, r *bufio.Reader) (Value, error) {
+	var data Storable
+	var dataType ValueType
+	var err error
+
+	switch valueType {
+	case RDBTypeString:
+		var str string
+		str, err = ReadRDBString(r)
+		data, dataType = StringT(str), StringType
+
+	case RDBTypeList:
+		var elements []string
+		elements, err = readRDBStrings(r)
+		data, dataType = ListT{Elements: elements}, ListType
+
+	case RDBTypeSet:
+		var members []string
+		members, err = readRDBStrings(r)
+		set := SetT{Members: make(map[string]struct{}, len(members))}
+		for _, member := range members {
+			set.Members[member] = struct{}{}
+		}
+		data, dataType = set, SetType
+
+	case RDBTypeZSet2:
+		data, err = readZSet(r)
+		dataType = ZSetType
+
+	case RDBTypeHash, RDBTypeHashMetadata:
+		data, err = readHash(r, valueType == RDBTypeHashMetadata)
+		dataType = HashType
+
+	case RDBTypeStream:
+		data, err = readStream(r)
+		dataType = StreamType
+
+	default:
+		return Value{}, ErrBadDataFormat
+	}
+
+	if err != nil {
+		return Value{}, err
+	}
+
+	return Value{ValueData: ValueWithType{Data: data, DataType: dataType}}, nil
+}
+
+func readRDBStrings(r *bufio.Reader) ([]string, error) {
+	length, _, err := ReadRDBLength(r)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]string, 0, length)
+	for i := 0; i < length; i++ {
+		value, err := ReadRDBString(r)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+func readZSet(r *bufio.Reader) (ZSetT, error) {
+	length, _, err := ReadRDBLength(r)
+	if err != nil {
+		return ZSetT{}, err
+	}
+
+	scores := make(map[string]float64, length)
+	for i := 0; i < length; i++ {
+		member, err := ReadRDBString(r)
+		if err != nil {
+			return ZSetT{}, err
+		}
+
+		var bits uint64
+		if err := binary.Read(r, binary.LittleEndian, &bits); err != nil {
+			return ZSetT{}, err
+		}
+		scores[member] = math.Float64frombits(bits)
+	}
+
+	return newZSet(scores), nil
+}
+
+func readHash(r *bufio.Reader, withTTLs bool) (HashT, error) {
+	var minExpire int64
+	if withTTLs {
+		if err := binary.Read(r, binary.LittleEndian, &minExpire); err != nil {
+			return HashT{}, err
+		}
+	}
+
+	length, _, err := ReadRDBLength(r)
+	if err != nil {
+		return HashT{}, err
+	}
+
+	hash := HashT{
+		Fields:    make(map[string]string, length),
+		ExpiredAt: make(map[string]time.Time),
+	}
+
+	for i := 0; i < length; i++ {
+		var ttl int
+		if withTTLs {
+			if ttl, _, err = ReadRDBLength(r); err != nil {
+				return HashT{}, err
+			}
+		}
+
+		field, err := ReadRDBString(r)
+		if err != nil {
+			return HashT{}, err
+		}
+
+		value, err := ReadRDBString(r)
+		if err != nil {
+			return HashT{}, err
+		}
+
+		hash.Fields[field] = value
+		if ttl > 0 {
+			hash.ExpiredAt[field] = time.UnixMilli(minExpire + int64(ttl) - 1)
+		}
+	}
+
+	return hash, nil
+}
+
+func readStream(r *bufio.Reader) (StreamMessages, error) {
+	lastID, err := ReadRDBString(r)
+	if err != nil {
+		return StreamMessages{}, err
+	}
+
+	count, _, err := ReadRDBLength(r)
+	if err != nil {
+		return StreamMessages{}, err
+	}
+
+	stream := StreamMessages{
+		Messages: make([]StreamMessage, 0, count),
+		LastID:   lastID,
+	}
+
+	for i := 0; i < count; i++ {
+		id, err := ReadRDBString(r)
+		if err != nil {
+			return StreamMessages{}, err
+		}
+
+		values, err := readRDBStrings(r)
+		if err != nil {
+			return StreamMessages{}, err
+		}
+		if len(values)%2 != 0 {
+			return StreamMessages{}, ErrBadDataFormat
+		}
+
+		message := StreamMessage{ID: id, Fields: make([]StreamField, 0, len(values)/2)}
+		for j := 0; j < len(values); j += 2 {
+			message.Fields = append(message.Fields, StreamField{Name: values[j], Value: values[j+1]})
+		}
+
+		stream.Messages = append(stream.Messages, message)
+	}
+
+	groups, _, err := ReadRDBLength(r)
+	if err != nil {
+		return StreamMessages{}, err
+	}
+
+	if groups > 0 {
+		stream.Groups = make(map[string]*ConsumerGroup, groups)
+	}
+
+	for i := 0; i < groups; i++ {
+		name, err := ReadRDBString(r)
+		if err != nil {
+			return StreamMessages{}, err
+		}
+
+		lastDeliveredID, err := ReadRDBString(r)
+		if err != nil {
+			return StreamMessages{}, err
+		}
+
+		consumers, _, err := ReadRDBLength(r)
+		if err != nil {
+			return StreamMessages{}, err
+		}
+
+		group := &ConsumerGroup{
+			LastDeliveredID: lastDeliveredID,
+			Consumers:       make(map[string]*Consumer, consumers),
+		}
+
+		for j := 0; j < consumers; j++ {
+			consumerName, err := ReadRDBString(r)
+			if err != nil {
+				return StreamMessages{}, err
+			}
+
+			pending, _, err := ReadRDBLength(r)
+			if err != nil {
+				return StreamMessages{}, err
+			}
+
+			var seenTime int64
+			if err := binary.Read(r, binary.LittleEndian, &seenTime); err != nil {
+				return StreamMessages{}, err
+			}
+
+			group.Consumers[consumerName] = &Consumer{
+				Pending:  pending,
+				SeenTime: time.UnixMilli(seenTime),
+			}
+		}
+
+		stream.Groups[name] = group
+	}
+
+	return stream, nil
+}
+
+/*
+Load stores a value read from an RDB file under key, replacing whatever
+was there. A value whose expiration already passed is not stored, as
+Redis does when loading. It reports whether the key was stored.
+*/
+func (s *Store) Load(key string, value Value) bool {
+	if value.ExpiredAt != nil && !value.ExpiredAt.After(time.Now()) {
+		return false
+	}
+
+	defer s.notifyWrite(key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.store[key] = s.withEncoding(value)
+	s.measure(key)
+
+	return true
+}
+
+/*
+withEncoding sets the encoding of a deserialized set or hash from the
+limits of this store, as if its members had been added one by one.
+*/
+func (s *Store) withEncoding(value Value) Value {
+	switch data := value.ValueData.Data.(type) {
+	case SetT:
+		set := newSet()
+		for member := range data.Members {
+			s.addSetMember(&set, member)
+		}
+		value.ValueData.Data = set
+
+	case HashT:
+		hash := HashT{
+			Fields:    make(map[string]string, len(data.Fields)),
+			ExpiredAt: data.ExpiredAt,
+		}
+		for field, fieldValue := range data.Fields {
+			hash.Fields[field] = fieldValue
+			s.convertHash(&hash, field, fieldValue)
+		}
+		value.ValueData.Data = hash
+	}
+
+	return value
+}
+
+/*
+Dump returns the DUMP payload of a key: the serialized value, the RDB
+version and a CRC64 checksum, both little endian. The bool result is
+false when the key does not exist.
+*/
+func (s *Store) Dump(key string) ([]byte, bool, error) {
+	defer s.notifyExpiredFields()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	value, ok := s.liveValue(key)
+	if !ok {
+		return nil, false, nil
+	}
+
+	var bb bytes.Buffer
+
+	if err := SerializeValue(&bb, value); err != nil {
+		return nil, true, err
+	}
+
+	binary.Write(&bb, binary.LittleEndian, uint16(RDBVersion))
+	checksum := ^crc64.Update(^uint64(0), crc64Table, bb.Bytes())
+	binary.Write(&bb, binary.LittleEndian, checksum)
+
+	return bb.Bytes(), true, nil
+}
+
+/*
+Restore creates key from a DUMP payload after checking its RDB version and
+checksum. A positive ttl becomes the expiration of the key. Unless replace
+is set an existing key fails with ErrBusyKey.
+*/
+func (s *Store) Restore(key string, payload []byte, ttl time.Duration, replace bool) error {
+	// value, 2 bytes of RDB version, 8 bytes of CRC64
+	if len(payload) < 10 {
+		return ErrBadPayload
+	}
+
+	footer := len(payload) - 10
+
+	version := binary.LittleEndian.Uint16(payload[footer:])
+	checksum := binary.LittleEndian.Uint64(payload[footer+2:])
+
+	if version > RDBVersion || checksum != ^crc64.Update(^uint64(0), crc64Table, payload[:footer+2]) {
+		return ErrBadPayload
+	}
+
+	value, err := DeserializeValue(bufio.NewReader(bytes.NewReader(payload[:footer])))
+	if err != nil {
+		return ErrBadDataFormat
+	}
+
+	if ttl > 0 {
+		expiredAt := time.Now().Add(ttl)
+		value.ExpiredAt = &expiredAt
+	}
+
+	var changed bool
+	defer s.notifyWriteIf(&changed, key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	if _, exists := s.store[key]; exists && !replace {
+		return ErrBusyKey
+	}
+
+	s.store[key] = s.withEncoding(value)
+	s.measure(key)
+	changed = true
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+/*
+dumpRestore moves key from src into a fresh store through DUMP and
+RESTORE and returns that store.
+*/
+func dumpRestore(t *testing.T, src *Store, key string) *Store {
+	t.Helper()
+
+	payload, ok, err := src.Dump(key)
+	if err != nil || !ok {
+		t.Fatalf("Dump(%q) = %v, %v", key, ok, err)
+	}
+
+	dst := NewStore()
+	if err := dst.Restore(key, payload, 0, false); err != nil {
+		t.Fatalf("Restore(%q) = %v", key, err)
+	}
+
+	return dst
+}
+
+func TestDumpRestoreString(t *testing.T) {
+	src := NewStore()
+	src.Set("k", "hello\r\nworld", nil)
+
+	got, err := dumpRestore(t, src, "k").Get("k")
+	if err != nil || got != "hello\r\nworld" {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+}
+
+func TestDumpRestoreList(t *testing.T) {
+	src := NewStore()
+	src.RPush("l", []string{"a", "b", "c"})
+
+	got, _ := dumpRestore(t, src, "l").LRange("l", 0, -1)
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("LRange = %v, want %v", got, want)
+	}
+}
+
+func TestDumpRestoreSet(t *testing.T) {
+	tests := []struct {
+		name     string
+		members  []string
+		encoding Encoding
+	}{
+		{"intset", []string{"1", "2", "3"}, IntsetEncoding},
+		{"listpack", []string{"1", "a"}, ListpackEncoding},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := NewStore()
+			src.SAdd("s", tt.members)
+
+			dst := dumpRestore(t, src, "s")
+
+			got, _ := dst.SMembers("s")
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.members) {
+				t.Fatalf("SMembers = %v, want %v", got, tt.members)
+			}
+
+			if encoding, _ := dst.GetEncoding("s"); encoding != tt.encoding {
+				t.Fatalf("encoding = %s, want %s", encoding, tt.encoding)
+			}
+		})
+	}
+}
+
+func TestDumpRestoreZSet(t *testing.T) {
+	src := NewStore()
+	src.ZAdd("z", []ZMember{{"b", 2.5}, {"a", -1}, {"c", 1e300}})
+
+	got, _ := dumpRestore(t, src, "z").ZRangeByScore("z", ZScoreRange{Min: -1e308, Max: 1e308})
+	want := []ZMember{{"a", -1}, {"b", 2.5}, {"c", 1e300}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ZRangeByScore = %v, want %v", got, want)
+	}
+}
+
+func TestDumpRestoreHashWithFieldTTLs(t *testing.T) {
+	src := NewStore()
+	src.HSet("h", []string{"f1", "v1", "f2", "v2", "f3", "v3"})
+	src.HExpire("h", 100, []string{"f1"})
+	src.HExpire("h", 200, []string{"f2"})
+
+	dst := dumpRestore(t, src, "h")
+
+	for field, want := range map[string]string{"f1": "v1", "f2": "v2", "f3": "v3"} {
+		if got, ok, _ := dst.HGet("h", field); !ok || got != want {
+			t.Fatalf("HGet(%s) = %q, %v", field, got, ok)
+		}
+	}
+
+	ttls, _ := dst.HTTL("h", []string{"f1", "f2", "f3"})
+	if want := []int{100, 200, HashFieldNoTTL}; !reflect.DeepEqual(ttls, want) {
+		t.Fatalf("HTTL = %v, want %v", ttls, want)
+	}
+}
+
+func TestDumpRestoreStream(t *testing.T) {
+	src := NewStore()
+	src.XAdd("x", StreamMessage{ID: "1-1", Fields: []StreamField{{"a", "1"}, {"b", "2"}}})
+	src.XAdd("x", StreamMessage{ID: "2-0", Fields: []StreamField{{"c", "3"}}})
+	src.XGroupCreate("x", "g", "1-1", false)
+	src.XGroupCreateConsumer("x", "g", "alice")
+
+	dst := dumpRestore(t, src, "x")
+
+	got, err := dst.GetStreamsRange("x", [2]string{"-", "+"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []StreamMessage{
+		{ID: "1-1", Fields: []StreamField{{"a", "1"}, {"b", "2"}}},
+		{ID: "2-0", Fields: []StreamField{{"c", "3"}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("XRANGE = %v, want %v", got, want)
+	}
+
+	if lastID, _ := dst.GetLastStreamID("x", ""); lastID != "2-0" {
+		t.Fatalf("LastID = %s, want 2-0", lastID)
+	}
+
+	groups, _ := dst.XInfoGroups("x")
+	if len(groups) != 1 || groups[0].Name != "g" || groups[0].LastDeliveredID != "1-1" || groups[0].Consumers != 1 {
+		t.Fatalf("XInfoGroups = %+v", groups)
+	}
+}
+
+func TestRestoreTTL(t *testing.T) {
+	src := NewStore()
+	src.RPush("l", []string{"a"})
+	payload, _, _ := src.Dump("l")
+
+	dst := NewStore()
+	if err := dst.Restore("l", payload, time.Minute, false); err != nil {
+		t.Fatal(err)
+	}
+
+	remaining, _, hasExpiry := dst.TTLRemaining("l")
+	if !hasExpiry || remaining <= 0 || remaining > time.Minute {
+		t.Fatalf("TTLRemaining = %v, %v", remaining, hasExpiry)
+	}
+}
+
+func TestRestoreRejectsCorruptPayload(t *testing.T) {
+	src := NewStore()
+	src.SAdd("s", []string{"a"})
+	payload, _, _ := src.Dump("s")
+
+	payload[1] ^= 0xff
+
+	if err := NewStore().Restore("s", payload, 0, false); err != ErrBadPayload {
+		t.Fatalf("Restore = %v, want %v", err, ErrBadPayload)
+	}
+}
+
+func TestRDBLengthRoundTrip(t *testing.T) {
+	for _, length := range []int{0, 63, 64, 16383, 16384, 1<<32 - 1, 1 << 32, 1 << 40} {
+		var bb bytes.Buffer
+		WriteRDBLength(&bb, length)
+
+		got, special, err := ReadRDBLength(bufio.NewReader(&bb))
+		if err != nil || special || got != length {
+			t.Fatalf("length %d read back as %d, %v, %v", length, got, special, err)
+		}
+	}
+}
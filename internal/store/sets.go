@@ -0,0 +1,319 @@
+package store
+
+import "strconv"
+
+/*
+SAdd adds members to the set stored at key, creating the set when the key
+does not exist. It returns the number of members that were not already in
+the set.
+*/
+func (s *Store) SAdd(key string, members []string) (int, error) {
+	var changed bool
+	defer s.notifyWriteIf(&changed, key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	value, ok := s.store[key]
+	if !ok {
+		value = Value{
+			ValueData: ValueWithType{
+				Data:     newSet(),
+				DataType: SetType,
+			},
+		}
+	}
+
+	if value.ValueData.DataType != SetType {
+		return 0, ErrWrongType
+	}
+
+	set := value.ValueData.Data.(SetT)
+
+	var added int
+	for _, member := range members {
+		if _, exists := set.Members[member]; exists {
+			continue
+		}
+
+		s.addSetMember(&set, member)
+		s.resize(key, elementSize(member))
+		added++
+	}
+
+	if added == 0 {
+		return 0, nil
+	}
+
+	value.ValueData.Data = set
+	s.store[key] = value
+	changed = true
+
+	return added, nil
+}
+
+/*
+SRem removes members from the set stored at key and returns how many were
+there. The key is deleted once the set is empty.
+*/
+func (s *Store) SRem(key string, members []string) (int, error) {
+	var changed bool
+	defer s.notifyWriteIf(&changed, key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	set, ok, err := s.getSet(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	var removed int
+	for _, member := range members {
+		if _, exists := set.Members[member]; exists {
+			delete(set.Members, member)
+			s.resize(key, -elementSize(member))
+			removed++
+		}
+	}
+
+	if len(set.Members) == 0 {
+		s.remove(key)
+	}
+	changed = removed > 0
+
+	return removed, nil
+}
+
+/*
+SCard returns the number of members of the set stored at key, 0 when the
+key does not exist.
+*/
+func (s *Store) SCard(key string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	set, _, err := s.getSet(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(set.Members), nil
+}
+
+/*
+SIsMember reports whether member belongs to the set stored at key.
+*/
+func (s *Store) SIsMember(key string, member string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	set, _, err := s.getSet(key)
+	if err != nil {
+		return false, err
+	}
+
+	_, exists := set.Members[member]
+
+	return exists, nil
+}
+
+/*
+SMembers returns the members of the set stored at key in no particular
+order, none when the key does not exist.
+*/
+func (s *Store) SMembers(key string) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	set, _, err := s.getSet(key)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]string, 0, len(set.Members))
+	for member := range set.Members {
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+/*
+SetOp returns the members resulting from op over the sets at keys, in no
+particular order. Missing keys count as empty sets.
+*/
+func (s *Store) SetOp(op SetOperation, keys []string) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result, err := s.setOperation(op, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]string, 0, len(result))
+	for member := range result {
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+/*
+SetOpStore computes op over the sets at keys and stores the result at
+destination, replacing whatever was there. An empty result deletes
+destination. It returns the cardinality of the result and whether
+destination was written or deleted. Reading the sources and writing the
+result happen under one lock.
+*/
+func (s *Store) SetOpStore(destination string, op SetOperation, keys []string) (int, bool, error) {
+	var changed bool
+	defer s.notifyWriteIf(&changed, destination)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	members, err := s.setOperation(op, keys)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if len(members) == 0 {
+		_, changed = s.store[destination]
+		s.remove(destination)
+		return 0, changed, nil
+	}
+
+	set := newSet()
+	for member := range members {
+		s.addSetMember(&set, member)
+	}
+
+	s.store[destination] = Value{
+		ValueData: ValueWithType{
+			Data:     set,
+			DataType: SetType,
+		},
+	}
+	s.measure(destination)
+	changed = true
+
+	return len(members), true, nil
+}
+
+/*
+setOperation computes op over the sets at keys into a new set. Missing
+keys count as empty sets. The caller must hold the write lock.
+*/
+func (s *Store) setOperation(op SetOperation, keys []string) (map[string]struct{}, error) {
+	sets := make([]map[string]struct{}, len(keys))
+
+	for i, key := range keys {
+		s.expireIfNeeded(key)
+
+		value, ok := s.store[key]
+		if !ok {
+			continue
+		}
+
+		if value.ValueData.DataType != SetType {
+			return nil, ErrWrongType
+		}
+
+		sets[i] = value.ValueData.Data.(SetT).Members
+	}
+
+	result := make(map[string]struct{})
+
+	switch op {
+	case SetUnion:
+		for _, set := range sets {
+			for member := range set {
+				result[member] = struct{}{}
+			}
+		}
+
+	case SetInter:
+	members:
+		for member := range sets[0] {
+			for _, set := range sets[1:] {
+				if _, ok := set[member]; !ok {
+					continue members
+				}
+			}
+			result[member] = struct{}{}
+		}
+
+	case SetDiff:
+	diff:
+		for member := range sets[0] {
+			for _, set := range sets[1:] {
+				if _, ok := set[member]; ok {
+					continue diff
+				}
+			}
+			result[member] = struct{}{}
+		}
+	}
+
+	return result, nil
+}
+
+/*
+newSet returns an empty set in the intset encoding.
+*/
+func newSet() SetT {
+	return SetT{Members: make(map[string]struct{}), Encoding: IntsetEncoding}
+}
+
+/*
+addSetMember adds member to set, converting an intset to listpack or
+hashtable once it holds a non-integer or more than set-max-intset-entries
+members, and a listpack to hashtable once it exceeds
+set-max-listpack-entries or member is longer than set-max-listpack-value.
+The caller must hold the write lock and store the set back.
+*/
+func (s *Store) addSetMember(set *SetT, member string) {
+	set.Members[member] = struct{}{}
+
+	if set.Encoding == IntsetEncoding {
+		if isIntsetMember(member) && len(set.Members) <= s.setMaxIntsetEntries {
+			return
+		}
+		set.Encoding = ListpackEncoding
+	}
+
+	if set.Encoding == ListpackEncoding &&
+		(len(set.Members) > s.setMaxListpackEntries || len(member) > s.setMaxListpackValue) {
+		set.Encoding = HashtableEncoding
+	}
+}
+
+// isIntsetMember reports whether member is the canonical form of an int64.
+func isIntsetMember(member string) bool {
+	n, err := strconv.ParseInt(member, 10, 64)
+
+	return err == nil && strconv.FormatInt(n, 10) == member
+}
+
+/*
+getSet returns the set stored at key, dropping the key first when it has
+expired. The caller must hold the write lock.
+*/
+func (s *Store) getSet(key string) (SetT, bool, error) {
+	s.expireIfNeeded(key)
+
+	value, ok := s.store[key]
+	if !ok {
+		return SetT{}, false, nil
+	}
+
+	if value.ValueData.DataType != SetType {
+		return SetT{}, false, ErrWrongType
+	}
+
+	return value.ValueData.Data.(SetT), true, nil
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSAddCountsOnlyNewMembers(t *testing.T) {
+	s := NewStore()
+
+	if got, err := s.SAdd("s", []string{"a", "b", "a"}); err != nil || got != 2 {
+		t.Fatalf("SAdd with a repeated member = %d, %v, want 2", got, err)
+	}
+	if got, err := s.SAdd("s", []string{"b", "c"}); err != nil || got != 1 {
+		t.Fatalf("SAdd with an existing member = %d, %v, want 1", got, err)
+	}
+	if got, _ := s.SCard("s"); got != 3 {
+		t.Fatalf("SCard = %d, want 3", got)
+	}
+
+	s.Set("k", "v", nil)
+	if _, err := s.SAdd("k", []string{"a"}); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("SAdd on a string = %v, want ErrWrongType", err)
+	}
+}
+
+func TestSRemDeletesEmptySet(t *testing.T) {
+	s := NewStore()
+	s.SAdd("s", []string{"a", "b"})
+
+	if got, err := s.SRem("s", []string{"a", "missing"}); err != nil || got != 1 {
+		t.Fatalf("SRem = %d, %v, want 1", got, err)
+	}
+	if got, err := s.SRem("s", []string{"b"}); err != nil || got != 1 {
+		t.Fatalf("SRem of the last member = %d, %v, want 1", got, err)
+	}
+	if s.Exists("s") {
+		t.Fatal("removing the last member left the key")
+	}
+	if got, err := s.SRem("s", []string{"a"}); err != nil || got != 0 {
+		t.Fatalf("SRem on a missing key = %d, %v, want 0", got, err)
+	}
+}
+
+func TestSCardAndSIsMember(t *testing.T) {
+	s := NewStore()
+	s.SAdd("s", []string{"a", "b"})
+	s.Set("k", "v", nil)
+
+	if got, err := s.SCard("s"); err != nil || got != 2 {
+		t.Fatalf("SCard = %d, %v, want 2", got, err)
+	}
+	if got, err := s.SCard("missing"); err != nil || got != 0 {
+		t.Fatalf("SCard on a missing key = %d, %v, want 0", got, err)
+	}
+
+	if got, err := s.SIsMember("s", "a"); err != nil || !got {
+		t.Fatalf("SIsMember a = %v, %v, want true", got, err)
+	}
+	if got, err := s.SIsMember("s", "z"); err != nil || got {
+		t.Fatalf("SIsMember z = %v, %v, want false", got, err)
+	}
+	if got, err := s.SIsMember("missing", "a"); err != nil || got {
+		t.Fatalf("SIsMember on a missing key = %v, %v, want false", got, err)
+	}
+
+	for name, fn := range map[string]func() error{
+		"SCard":     func() error { _, err := s.SCard("k"); return err },
+		"SIsMember": func() error { _, err := s.SIsMember("k", "a"); return err },
+		"SRem":      func() error { _, err := s.SRem("k", []string{"a"}); return err },
+	} {
+		if err := fn(); !errors.Is(err, ErrWrongType) {
+			t.Errorf("%s on a string = %v, want ErrWrongType", name, err)
+		}
+	}
+}
+
+func TestSetOpOnThreeSets(t *testing.T) {
+	s := NewStore()
+	s.SAdd("a", []string{"1", "2", "3", "4"})
+	s.SAdd("b", []string{"2", "3", "5"})
+	s.SAdd("c", []string{"3", "4", "5", "6"})
+
+	tests := []struct {
+		op   SetOperation
+		keys []string
+		want []string
+	}{
+		{SetInter, []string{"a", "b", "c"}, []string{"3"}},
+		{SetUnion, []string{"a", "b", "c"}, []string{"1", "2", "3", "4", "5", "6"}},
+		{SetDiff, []string{"a", "b", "c"}, []string{"1"}},
+		{SetDiff, []string{"c", "a", "b"}, []string{"6"}},
+		{SetInter, []string{"a", "missing", "c"}, []string{}},
+		{SetUnion, []string{"missing", "b"}, []string{"2", "3", "5"}},
+		{SetDiff, []string{"a", "missing"}, []string{"1", "2", "3", "4"}},
+	}
+
+	for _, tt := range tests {
+		got, err := s.SetOp(tt.op, tt.keys)
+		sort.Strings(got)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SetOp(%v, %v) = %v, %v, want %v", tt.op, tt.keys, got, err, tt.want)
+		}
+	}
+
+	s.Set("k", "v", nil)
+	if _, err := s.SetOp(SetUnion, []string{"a", "k"}); !errors.Is(err, ErrWrongType) {
+		t.Errorf("SetOp with a string = %v, want ErrWrongType", err)
+	}
+}
+
+func TestSetEncodingLeavesIntset(t *testing.T) {
+	encoding := func(s *Store, key string) Encoding {
+		t.Helper()
+		got, err := s.GetEncoding(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	s := NewStore()
+	s.SetSetMaxEntries(4, 128, 64)
+
+	s.SAdd("s", []string{"1", "-2", "300"})
+	if got := encoding(s, "s"); got != IntsetEncoding {
+		t.Fatalf("encoding of integers = %s, want intset", got)
+	}
+
+	s.SAdd("s", []string{"a"})
+	if got := encoding(s, "s"); got != ListpackEncoding {
+		t.Fatalf("encoding after adding a string = %s, want listpack", got)
+	}
+
+	// like Redis, a set never goes back to an intset
+	s.SRem("s", []string{"a"})
+	if got := encoding(s, "s"); got != ListpackEncoding {
+		t.Fatalf("encoding after removing the string = %s, want listpack", got)
+	}
+
+	// only the canonical form of an integer fits an intset
+	s.SAdd("padded", []string{"01"})
+	if got := encoding(s, "padded"); got != ListpackEncoding {
+		t.Fatalf("encoding of 01 = %s, want listpack", got)
+	}
+
+	s.SAdd("many", []string{"1", "2", "3", "4"})
+	if got := encoding(s, "many"); got != IntsetEncoding {
+		t.Fatalf("encoding at the intset limit = %s, want intset", got)
+	}
+	s.SAdd("many", []string{"5"})
+	if got := encoding(s, "many"); got != ListpackEncoding {
+		t.Fatalf("encoding past the intset limit = %s, want listpack", got)
+	}
+}
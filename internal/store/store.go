@@ -2,24 +2,151 @@ package store
 
 import (
 	"errors"
+	"math"
+	"math/big"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/redis"
 )
 
 func NewStore() *Store {
 	logrus.Info("Creating new store")
 	return &Store{
-		store: make(map[string]Value),
+		store:                  make(map[string]Value),
+		sizes:                  make(map[string]int64),
+		volatile:               make(map[string]struct{}),
+		volatileFields:         make(map[string]struct{}),
+		fieldWrites:            make(map[string]struct{}),
+		waiters:                make(map[string]map[*keyWaiter]struct{}),
+		waiterChans:            make(map[<-chan struct{}]*keyWaiter),
+		listMaxListpackSize:    DefaultListMaxListpackSize,
+		hashMaxListpackEntries: DefaultHashMaxListpackEntries,
+		hashMaxListpackValue:   DefaultHashMaxListpackValue,
+		setMaxIntsetEntries:    DefaultSetMaxIntsetEntries,
+		setMaxListpackEntries:  DefaultSetMaxListpackEntries,
+		setMaxListpackValue:    DefaultSetMaxListpackValue,
+		protoMaxBulkLen:        redis.DefaultProtoMaxBulkLen,
+		freeValue:              clearValue,
 	}
 }
 
-func (s *Store) Set(key string, value string, px *int) {
+/*
+SetListMaxListpackSize sets the number of elements after which
+a list is reported with the quicklist encoding instead of listpack.
+Quicklist nodes are sized by it, so sizes below 1 are rejected.
+*/
+func (s *Store) SetListMaxListpackSize(size int) error {
+	if size < 1 {
+		return ErrListpackSize
+	}
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	s.listMaxListpackSize = size
+
+	return nil
+}
+
+/*
+SetHashMaxListpack sets the number of fields and the field or value length
+after which a hash is converted to the hashtable encoding.
+*/
+func (s *Store) SetHashMaxListpack(entries int, value int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.hashMaxListpackEntries = entries
+	s.hashMaxListpackValue = value
+}
+
+/*
+SetSetMaxEntries sets the number of members after which an integer set
+leaves the intset encoding, and the number of members and the member
+length after which a set is converted to the hashtable encoding.
+*/
+func (s *Store) SetSetMaxEntries(intsetEntries int, listpackEntries int, listpackValue int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.setMaxIntsetEntries = intsetEntries
+	s.setMaxListpackEntries = listpackEntries
+	s.setMaxListpackValue = listpackValue
+}
+
+/*
+SetProtoMaxBulkLen sets the largest string that commands growing a
+value, such as APPEND, may produce.
+*/
+func (s *Store) SetProtoMaxBulkLen(n int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.protoMaxBulkLen = n
+}
+
+/*
+SetWriteHook registers fn to be called with the key after every write.
+It is called after the store lock is released and must be set before
+the store is shared between goroutines.
+*/
+func (s *Store) SetWriteHook(fn func(key string)) {
+	s.writeHook = fn
+}
+
+/*
+notifyExpiredFields runs the write hook for the hashes that lost expired
+fields while the lock was held. It must be called without the lock held.
+*/
+func (s *Store) notifyExpiredFields() {
+	s.mutex.Lock()
+	keys := s.fieldWrites
+	if len(keys) > 0 {
+		s.fieldWrites = make(map[string]struct{})
+	}
+	s.mutex.Unlock()
+
+	if s.writeHook == nil {
+		return
+	}
+
+	for key := range keys {
+		s.writeHook(key)
+	}
+}
+
+/*
+notifyWriteIf runs notifyWrite when *changed is set by the time it is
+called, so a write that failed or left the key as it was neither fires
+the hook nor touches the accounting. It is deferred before the lock is
+taken.
+*/
+func (s *Store) notifyWriteIf(changed *bool, key string) {
+	if *changed {
+		s.notifyWrite(key)
+	}
+}
+
+/*
+notifyWrite refreshes the memory accounting of key and runs the write
+hook. It must be called without the lock held.
+*/
+func (s *Store) notifyWrite(key string) {
+	s.mutex.Lock()
+	s.account(key)
+	s.mutex.Unlock()
+
+	if s.writeHook != nil {
+		s.writeHook(key)
+	}
+}
+
+func (s *Store) Set(key string, value string, px *int) {
 	var expirationTime *time.Time
 	if px != nil {
 		t := time.Now().Add(time.Duration(*px) * time.Millisecond)
@@ -27,68 +154,932 @@ func (s *Store) Set(key string, value string, px *int) {
 		expirationTime = &t
 	}
 
+	s.SetIf(key, value, expirationTime, false, SetAlways)
+}
+
+/*
+SetIf stores a string value when cond holds for the key. The key expires at
+expiredAt, never when it is nil, or keeps the expiration it had when keepTTL
+is set. The existence check and the write happen under one lock. It reports
+whether the value was set.
+*/
+func (s *Store) SetIf(key string, value string, expiredAt *time.Time, keepTTL bool, cond SetCondition) bool {
+	s.mutex.Lock()
+
+	s.expireIfNeeded(key)
+	current, exists := s.store[key]
+
+	if (cond == SetIfNotExists && exists) || (cond == SetIfExists && !exists) {
+		s.mutex.Unlock()
+		return false
+	}
+
+	if keepTTL {
+		expiredAt = current.ExpiredAt
+	}
+
 	s.store[key] = Value{
 		ValueData: ValueWithType{Data: StringT(value), DataType: StringType},
-		ExpiredAt: expirationTime,
+		ExpiredAt: expiredAt,
 	}
+	s.measure(key)
+
+	s.mutex.Unlock()
+	s.notifyWrite(key)
 
 	log.Println("Set handler: ", key, value)
+	return true
 }
 
+/*
+Get returns the string value of a key. A missing key yields ErrNotFound,
+a key that expired just now ErrExpired and a non-string ErrWrongType, so
+callers can tell misses apart for statistics.
+*/
 func (s *Store) Get(key string) (string, error) {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.expireIfNeeded(key) {
+		return "", ErrExpired
+	}
 
 	if value, ok := s.store[key]; !ok {
-		return "", errors.New("key does not exists")
+		return "", ErrNotFound
 	} else {
 		log.Println("Get handler: ", key, value.ValueData)
 		if str, ok := value.ValueData.Data.(StringT); ok {
 			return string(str), nil
 		}
 
-		return "", errors.New("Value is not of type StringT")
+		return "", ErrWrongType
+	}
+}
+
+/*
+MSet stores all pairs under a single lock acquisition, so readers see
+either none or all of them. Existing expirations are discarded like SET.
+*/
+func (s *Store) MSet(pairs map[string]string) {
+	s.mutex.Lock()
+
+	for key, value := range pairs {
+		s.store[key] = Value{
+			ValueData: ValueWithType{Data: StringT(value), DataType: StringType},
+		}
+		s.measure(key)
+	}
+
+	s.mutex.Unlock()
+
+	for key := range pairs {
+		s.notifyWrite(key)
+	}
+}
+
+/*
+MGet returns the string values of keys read under one lock. Missing and
+non-string keys map to nil entries.
+*/
+func (s *Store) MGet(keys []string) []*string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	values := make([]*string, len(keys))
+
+	for i, key := range keys {
+		s.expireIfNeeded(key)
+
+		if str, ok := s.store[key].ValueData.Data.(StringT); ok {
+			value := string(str)
+			values[i] = &value
+		}
+	}
+
+	return values
+}
+
+/*
+GetDel returns the string value of a key and deletes it under the same lock.
+The bool result is false when the key does not exist.
+*/
+func (s *Store) GetDel(key string) (string, bool, error) {
+	s.mutex.Lock()
+
+	s.expireIfNeeded(key)
+
+	value, ok := s.store[key]
+	if !ok {
+		s.mutex.Unlock()
+		return "", false, nil
+	}
+
+	str, isString := value.ValueData.Data.(StringT)
+	if !isString {
+		s.mutex.Unlock()
+		return "", false, ErrWrongType
 	}
+
+	s.remove(key)
+
+	s.mutex.Unlock()
+	s.notifyWrite(key)
+
+	return string(str), true, nil
+}
+
+/*
+SetExpiry replaces the expiration of an existing key. A nil px removes the
+expiration. It reports whether the key exists.
+*/
+func (s *Store) SetExpiry(key string, px *int) bool {
+	if px == nil {
+		return s.setExpiry(key, nil)
+	}
+
+	return s.ExpireAt(key, time.Now().Add(time.Duration(*px)*time.Millisecond))
+}
+
+/*
+ExpireAt makes an existing key expire at the given time. It reports
+whether the key exists.
+*/
+func (s *Store) ExpireAt(key string, at time.Time) bool {
+	return s.setExpiry(key, &at)
 }
 
-func (s *Store) GetType(key string) (Datatype, error) {
+func (s *Store) setExpiry(key string, expiredAt *time.Time) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	value, ok := s.store[key]
+	if !ok {
+		return false
+	}
+
+	value.ExpiredAt = expiredAt
+	s.store[key] = value
+	s.account(key)
+
+	return true
+}
+
+/*
+Persist removes the expiration of a key. It reports whether an expiration
+was removed.
+*/
+func (s *Store) Persist(key string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	value, ok := s.store[key]
+	if !ok || value.ExpiredAt == nil {
+		return false
+	}
+
+	value.ExpiredAt = nil
+	s.store[key] = value
+	s.account(key)
+
+	return true
+}
+
+/*
+GetEx returns the string stored at key and, under the same lock, makes it
+expire at expiredAt, or removes its expiration when persist is set. It
+reports whether the expiration changed, so no write can come between the
+value returned and the deadline set.
+*/
+func (s *Store) GetEx(key string, expiredAt *time.Time, persist bool) (string, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.expireIfNeeded(key) {
+		return "", false, ErrExpired
+	}
+
+	value, ok := s.store[key]
+	if !ok {
+		return "", false, ErrNotFound
+	}
+
+	str, ok := value.ValueData.Data.(StringT)
+	if !ok {
+		return "", false, ErrWrongType
+	}
+
+	switch {
+	case expiredAt != nil:
+		value.ExpiredAt = expiredAt
+	case persist && value.ExpiredAt != nil:
+		value.ExpiredAt = nil
+	default:
+		return string(str), false, nil
+	}
+
+	s.store[key] = value
+	s.account(key)
+
+	return string(str), true, nil
+}
+
+/*
+TTLRemaining returns the remaining time to live of a key, whether the key
+exists and whether it has an expiration at all.
+
+Deadlines set at runtime come from time.Now().Add and keep Go's monotonic
+clock reading, so time.Until measures them on the monotonic clock and a
+wall clock jump does not stretch or shrink a TTL. Deadlines loaded from
+an RDB file are absolute unix times and can only be wall clock based.
+*/
+func (s *Store) TTLRemaining(key string) (time.Duration, bool, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	value, ok := s.store[key]
+	if !ok {
+		return 0, false, false
+	}
+
+	if value.ExpiredAt == nil {
+		return 0, true, false
+	}
+
+	return time.Until(*value.ExpiredAt), true, true
+}
+
+/*
+expireIfNeeded deletes the key when its expiration time has passed.
+The caller must hold the write lock.
+*/
+func (s *Store) expireIfNeeded(key string) bool {
+	value, ok := s.store[key]
+	if !ok || value.ExpiredAt == nil || value.ExpiredAt.After(time.Now()) {
+		return false
+	}
+
+	s.remove(key)
+	s.free(value, s.lazyFreeExpire)
+
+	log.WithField("key", key).Info("Removing expired key from store")
+
+	return true
+}
+
+/*
+liveValue returns the value stored at key after dropping the key when it
+has expired, or when it is a hash whose fields have all expired. TYPE,
+OBJECT ENCODING and DEBUG OBJECT go through it, so they agree with each
+other and with the commands reading the value. The caller must hold the
+write lock.
+*/
+func (s *Store) liveValue(key string) (Value, bool) {
+	s.expireIfNeeded(key)
+
+	value, ok := s.store[key]
+	if !ok {
+		return Value{}, false
+	}
+
+	if value.ValueData.DataType == HashType && s.expireHashFields(key) {
+		return Value{}, false
+	}
+
+	return value, true
+}
+
+func (s *Store) GetType(key string) (ValueType, error) {
+	defer s.notifyExpiredFields()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	value, ok := s.liveValue(key)
+	if !ok {
+		return NoneType, ErrNotFound
+	}
+
+	return value.ValueData.DataType, nil
+}
+
+func (s *Store) GetEncoding(key string) (Encoding, error) {
+	defer s.notifyExpiredFields()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	value, ok := s.liveValue(key)
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	switch value.ValueData.DataType {
+	case StringType:
+		if value.Raw {
+			return RawEncoding, nil
+		}
+		return stringEncoding(string(value.ValueData.Data.(StringT))), nil
+	case ListType:
+		return s.listEncoding(len(value.ValueData.Data.(ListT).Elements)), nil
+	case SetType:
+		return value.ValueData.Data.(SetT).Encoding, nil
+	case ZSetType:
+		return SkiplistEncoding, nil
+	case HashType:
+		if value.ValueData.Data.(HashT).Hashtable {
+			return HashtableEncoding, nil
+		}
+		return ListpackEncoding, nil
+	case StreamType:
+		return StreamEncoding, nil
+	}
+
+	return "", errors.New("encoding is not supported for this type")
+}
+
+func stringEncoding(value string) Encoding {
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return IntEncoding
+	}
+
+	if len(value) <= embstrSizeLimit {
+		return EmbstrEncoding
+	}
+
+	return RawEncoding
+}
+
+func (s *Store) listEncoding(length int) Encoding {
+	if length <= s.listMaxListpackSize {
+		return ListpackEncoding
+	}
+
+	return QuicklistEncoding
+}
+
+/*
+IncrBy adds delta to the integer stored at key, treating a missing key as 0.
+The expiration of an existing key is kept. It backs INCR, DECR, INCRBY and
+DECRBY and fails with ErrWrongType, ErrNotInteger or ErrOverflow.
+*/
+func (s *Store) IncrBy(key string, delta int64) (int64, error) {
+	var changed bool
+	defer s.notifyWriteIf(&changed, key)
+
+	log.WithFields(log.Fields{"key": key, "delta": delta}).Info("Incrementing key in store")
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	v, ok := s.store[key]
+	if !ok {
+		v = Value{ValueData: ValueWithType{Data: StringT("0"), DataType: StringType}}
+	}
+
+	if v.ValueData.DataType != StringType {
+		return 0, ErrWrongType
+	}
+
+	// like Redis, only the canonical form counts as an integer, so "+5",
+	// "012" or "-0" are rejected
+	stored := string(v.ValueData.Data.(StringT))
+	current, err := strconv.ParseInt(stored, 10, 64)
+	if err != nil || strconv.FormatInt(current, 10) != stored {
+		return 0, ErrNotInteger
+	}
+
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+
+	current += delta
+	v.ValueData = ValueWithType{Data: StringT(strconv.FormatInt(current, 10)), DataType: StringType}
+	v.Raw = false
+	s.store[key] = v
+	s.measure(key)
+	changed = true
+
+	return current, nil
+}
+
+/*
+IncrByFloat adds delta to the float stored at key, treating a missing key
+as 0, and stores the result in the form Redis prints it.
+*/
+func (s *Store) IncrByFloat(key string, delta float64) (string, error) {
+	var changed bool
+	defer s.notifyWriteIf(&changed, key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	v, ok := s.store[key]
+	if !ok {
+		v = Value{ValueData: ValueWithType{Data: StringT("0"), DataType: StringType}}
+	}
+
+	if v.ValueData.DataType != StringType {
+		return "", ErrWrongType
+	}
+
+	current, err := strconv.ParseFloat(string(v.ValueData.Data.(StringT)), 64)
+	if err != nil || math.IsNaN(current) || math.IsInf(current, 0) {
+		return "", ErrNotFloat
+	}
+
+	result, ok := addLongDouble(string(v.ValueData.Data.(StringT)), delta)
+	if !ok {
+		return "", ErrNaNOrInfinity
+	}
+
+	v.ValueData = ValueWithType{Data: StringT(result), DataType: StringType}
+	v.Raw = false
+	s.store[key] = v
+	s.measure(key)
+	changed = true
+
+	return result, nil
+}
+
+/*
+addLongDouble adds delta to the decimal current with the 64 bit mantissa of
+a C long double and prints the sum like Redis does, with 17 decimals and the
+trailing zeros trimmed, so 0.1 plus 0.2 gives 0.3. The sum is rejected when
+it does not fit a float64.
+*/
+func addLongDouble(current string, delta float64) (string, bool) {
+	if math.IsInf(delta, 0) {
+		return "", false
+	}
+
+	sum, _, err := big.ParseFloat(current, 10, 64, big.ToNearestEven)
+	if err != nil {
+		return "", false
+	}
+
+	d, _, err := big.ParseFloat(strconv.FormatFloat(delta, 'g', -1, 64), 10, 64, big.ToNearestEven)
+	if err != nil {
+		return "", false
+	}
+
+	sum.Add(sum, d)
+	if f, _ := sum.Float64(); math.IsInf(f, 0) {
+		return "", false
+	}
+
+	result := sum.Text('f', 17)
+	result = strings.TrimRight(strings.TrimRight(result, "0"), ".")
+	if result == "-0" {
+		result = "0"
+	}
+
+	return result, true
+}
+
+/*
+Append appends value to the string stored at key, creating the key when it
+does not exist. It returns the length of the resulting string.
+*/
+func (s *Store) Append(key string, value string) (int, error) {
+	var changed bool
+	defer s.notifyWriteIf(&changed, key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	v, ok := s.store[key]
+	if !ok {
+		v = Value{ValueData: ValueWithType{Data: StringT(""), DataType: StringType}}
+	}
+
+	if v.ValueData.DataType != StringType {
+		return 0, ErrWrongType
+	}
+
+	if int64(len(v.ValueData.Data.(StringT))+len(value)) > s.protoMaxBulkLen {
+		return 0, ErrStringTooLong
+	}
+
+	result := string(v.ValueData.Data.(StringT)) + value
+	v.ValueData = ValueWithType{Data: StringT(result), DataType: StringType}
+	v.Raw = true
+	s.store[key] = v
+	s.resize(key, int64(len(value)))
+	changed = true
+
+	return len(result), nil
+}
+
+/*
+SetRange overwrites the string at key starting at offset, padding with zero
+bytes when offset is past the end. It returns the resulting length. An empty
+value never creates the key.
+*/
+func (s *Store) SetRange(key string, offset int, value string) (int, error) {
+	var changed bool
+	defer s.notifyWriteIf(&changed, key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	v, ok := s.store[key]
+	if !ok {
+		if value == "" {
+			return 0, nil
+		}
+		v = Value{ValueData: ValueWithType{Data: StringT(""), DataType: StringType}}
+	}
+
+	if v.ValueData.DataType != StringType {
+		return 0, ErrWrongType
+	}
+
+	current := []byte(v.ValueData.Data.(StringT))
+	length := len(current)
+
+	if value == "" {
+		return len(current), nil
+	}
+
+	if int64(offset+len(value)) > s.protoMaxBulkLen {
+		return 0, ErrStringTooLong
+	}
+
+	if end := offset + len(value); end > len(current) {
+		current = append(current, make([]byte, end-len(current))...)
+	}
+	copy(current[offset:], value)
+
+	v.ValueData = ValueWithType{Data: StringT(current), DataType: StringType}
+	v.Raw = true
+	s.store[key] = v
+	s.resize(key, int64(len(current)-length))
+	changed = true
+
+	return len(current), nil
+}
+
+/*
+Exists reports whether the key is present and not expired. A hash whose
+fields have all expired is dropped and does not exist, as for TYPE.
+*/
+func (s *Store) Exists(key string) bool {
+	defer s.notifyExpiredFields()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, ok := s.liveValue(key)
+	return ok
+}
+
+/*
+Del removes the key and reports whether it existed. Values of every type
+live in the same map, so streams, lists and hashes are removed and counted
+exactly like strings. A large value is freed in the background when
+lazyfree-lazy-user-del is set.
+*/
+func (s *Store) Del(key string) bool {
+	return s.del(key, false)
+}
+
+/*
+Unlink removes the key like Del, but always frees a large value in the
+background.
+*/
+func (s *Store) Unlink(key string) bool {
+	return s.del(key, true)
+}
+
+func (s *Store) del(key string, lazy bool) bool {
+	var changed bool
+	defer s.notifyWriteIf(&changed, key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	value, ok := s.store[key]
+	if !ok {
+		return false
+	}
+
+	s.remove(key)
+	s.free(value, lazy || s.lazyFreeUserDel)
+	changed = true
+
+	return value.ExpiredAt == nil || value.ExpiredAt.After(time.Now())
+}
+
+/*
+Flush removes every key of the store. The removed values are freed by the
+reclaim goroutine.
+*/
+func (s *Store) Flush() {
+	s.mutex.Lock()
+
+	keys := make([]string, 0, len(s.store))
+	values := make([]Value, 0, len(s.store))
+	for key, value := range s.store {
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+
+	if len(values) > 0 {
+		s.reclaimLater(values)
+	}
+
+	s.store = make(map[string]Value)
+	s.sizes = make(map[string]int64)
+	s.usedMemory = 0
+	s.volatile = make(map[string]struct{})
+	s.volatileFields = make(map[string]struct{})
+
+	s.mutex.Unlock()
+
+	for _, key := range keys {
+		s.notifyWrite(key)
+	}
+}
+
+/*
+Snapshot returns a point-in-time copy of the whole keyspace.
+The read lock is held only while copying, so callers can iterate
+over the result without blocking writers.
+*/
+func (s *Store) Snapshot() map[string]Value {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	if value, ok := s.store[key]; !ok {
-		return "", errors.New("key does not exists")
-	} else {
-		return value.ValueData.DataType, nil
+	snapshot := make(map[string]Value, len(s.store))
+
+	for key, value := range s.store {
+		snapshot[key] = copyValue(value)
+	}
+
+	return snapshot
+}
+
+/*
+ForEach calls fn for every key that is not expired while holding the
+read lock, stopping as soon as fn returns false. fn must not call back
+into the store.
+*/
+func (s *Store) ForEach(fn func(key string, t ValueType) bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	now := time.Now()
+
+	for key, value := range s.store {
+		if value.ExpiredAt != nil && value.ExpiredAt.Before(now) {
+			continue
+		}
+
+		if !fn(key, value.ValueData.DataType) {
+			return
+		}
 	}
 }
 
-func (s *Store) Incr(key string) (int, error) {
-	log.WithFields(log.Fields{"key": key}).Info("Incrementing key in store")
+/*
+Copy duplicates the value stored at source into destination.
+It reports false when source is missing or destination exists
+and replace is not set.
+*/
+func (s *Store) Copy(source string, destination string, replace bool) bool {
+	var changed bool
+	defer s.notifyWriteIf(&changed, destination)
+	defer s.notifyExpiredFields()
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	v, ok := s.store[key]
+	value, ok := s.liveValue(source)
 	if !ok {
-		s.store[key] = Value{
-			ValueWithType{Data: StringT("1"), DataType: StringType},
-			nil,
+		return false
+	}
+
+	s.expireIfNeeded(destination)
+
+	if _, exists := s.store[destination]; exists && !replace {
+		return false
+	}
+
+	s.store[destination] = copyValue(value)
+	s.measure(destination)
+	changed = true
+
+	return true
+}
+
+/*
+CopyTo copies source into destination of another database. The value is
+read and written under separate locks, so the two databases are never
+locked at the same time.
+*/
+func (s *Store) CopyTo(target *Store, source string, destination string, replace bool) bool {
+	s.mutex.Lock()
+	s.expireIfNeeded(source)
+	value, ok := s.store[source]
+	if ok {
+		value = copyValue(value)
+	}
+	s.mutex.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	var changed bool
+	defer target.notifyWriteIf(&changed, destination)
+
+	target.mutex.Lock()
+	defer target.mutex.Unlock()
+
+	target.expireIfNeeded(destination)
+
+	if _, exists := target.store[destination]; exists && !replace {
+		return false
+	}
+
+	target.store[destination] = value
+	target.measure(destination)
+	changed = true
+
+	return true
+}
+
+/*
+Rename moves the value stored at source, with its expiration, to
+destination, overwriting whatever was there. Both keys change under one
+lock, so no reader sees the value under both names or neither. A missing
+source yields ErrNoSuchKey.
+*/
+func (s *Store) Rename(source string, destination string) error {
+	_, err := s.rename(source, destination, false)
+	return err
+}
+
+/*
+RenameNX is Rename that leaves an existing destination alone. It reports
+whether source was renamed.
+*/
+func (s *Store) RenameNX(source string, destination string) (bool, error) {
+	return s.rename(source, destination, true)
+}
+
+func (s *Store) rename(source string, destination string, nx bool) (bool, error) {
+	var changed bool
+	defer s.notifyWriteIf(&changed, source)
+	defer s.notifyWriteIf(&changed, destination)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.expireIfNeeded(source) {
+		return false, ErrNoSuchKey
+	}
+
+	value, ok := s.store[source]
+	if !ok {
+		return false, ErrNoSuchKey
+	}
+
+	s.expireIfNeeded(destination)
+
+	if _, exists := s.store[destination]; exists && nx {
+		return false, nil
+	}
+
+	if source == destination {
+		return true, nil
+	}
+
+	// the value moves as it is, so its size moves along with it
+	size := s.sizes[source] - int64(len(source)) + int64(len(destination))
+
+	s.remove(source)
+	s.store[destination] = value
+	s.setSize(destination, size)
+	changed = true
+
+	s.releaseWaiters(destination)
+
+	return true, nil
+}
+
+/*
+Counts returns the number of keys and of keys with an expiration, as
+reported by INFO keyspace. Keys that have expired but were not reaped yet
+are left out, since no command sees them any more.
+*/
+func (s *Store) Counts() (int, int) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	now := time.Now()
+
+	var keys, expires int
+	for _, value := range s.store {
+		if value.ExpiredAt != nil {
+			if !value.ExpiredAt.After(now) {
+				continue
+			}
+			expires++
 		}
-		return 1, nil
+		keys++
 	}
 
-	intValue, err := strconv.Atoi(string(v.ValueData.Data.(StringT)))
-	if err != nil {
-		return 0, errors.New("Unsupported type")
+	return keys, expires
+}
+
+func copyValue(value Value) Value {
+	if value.ExpiredAt != nil {
+		expiredAt := *value.ExpiredAt
+		value.ExpiredAt = &expiredAt
 	}
 
-	intValue++
-	s.store[key] = Value{
-		ValueData: ValueWithType{Data: StringT(strconv.Itoa(intValue))},
+	switch data := value.ValueData.Data.(type) {
+	case StreamMessages:
+		messages := make([]StreamMessage, len(data.Messages))
+		copy(messages, data.Messages)
+		value.ValueData.Data = StreamMessages{
+			Messages: messages,
+			LastID:   data.LastID,
+			Groups:   copyGroups(data.Groups),
+		}
+
+	case ListT:
+		elements := make([]string, len(data.Elements))
+		copy(elements, data.Elements)
+		value.ValueData.Data = ListT{Elements: elements}
+
+	case SetT:
+		members := make(map[string]struct{}, len(data.Members))
+		for member := range data.Members {
+			members[member] = struct{}{}
+		}
+		value.ValueData.Data = SetT{Members: members, Encoding: data.Encoding}
+
+	case ZSetT:
+		scores := make(map[string]float64, len(data.Scores))
+		for member, score := range data.Scores {
+			scores[member] = score
+		}
+		ranked := make([]ZMember, len(data.Ranked))
+		copy(ranked, data.Ranked)
+		value.ValueData.Data = ZSetT{Scores: scores, Ranked: ranked}
+
+	case HashT:
+		hash := HashT{
+			Fields:    make(map[string]string, len(data.Fields)),
+			ExpiredAt: make(map[string]time.Time, len(data.ExpiredAt)),
+			Hashtable: data.Hashtable,
+		}
+		for field, fieldValue := range data.Fields {
+			hash.Fields[field] = fieldValue
+		}
+		for field, expiredAt := range data.ExpiredAt {
+			hash.ExpiredAt[field] = expiredAt
+		}
+		value.ValueData.Data = hash
 	}
-	return intValue, nil
+
+	return value
 }
 
-func (s *Store) Remove(key string) {
-	delete(s.store, key)
-	log.WithField("key", key).Info("Removing key from store")
+func copyGroups(groups map[string]*ConsumerGroup) map[string]*ConsumerGroup {
+	if groups == nil {
+		return nil
+	}
+
+	copied := make(map[string]*ConsumerGroup, len(groups))
+
+	for name, group := range groups {
+		consumers := make(map[string]*Consumer, len(group.Consumers))
+		for consumerName, consumer := range group.Consumers {
+			c := *consumer
+			consumers[consumerName] = &c
+		}
+
+		copied[name] = &ConsumerGroup{
+			LastDeliveredID: group.LastDeliveredID,
+			Consumers:       consumers,
+		}
+	}
+
+	return copied
 }
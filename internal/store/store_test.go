@@ -0,0 +1,654 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCopyExpiredSource(t *testing.T) {
+	s := NewStore()
+	s.Set("src", "v", nil)
+	expireNow(t, s, "src")
+
+	if s.Copy("src", "dst", false) {
+		t.Fatal("Copy copied an expired source")
+	}
+	if s.Exists("dst") {
+		t.Fatal("Copy created the destination from an expired source")
+	}
+}
+
+func TestCopyOntoExpiredDestination(t *testing.T) {
+	s := NewStore()
+	s.Set("src", "new", nil)
+	s.Set("dst", "old", nil)
+	expireNow(t, s, "dst")
+
+	if !s.Copy("src", "dst", false) {
+		t.Fatal("Copy refused to overwrite an expired destination")
+	}
+	if got, _ := s.Get("dst"); got != "new" {
+		t.Fatalf("dst = %q, want new", got)
+	}
+	if _, _, hasExpiry := s.TTLRemaining("dst"); hasExpiry {
+		t.Fatal("dst kept the expiration of the expired key")
+	}
+}
+
+func TestSnapshotWhileWriting(t *testing.T) {
+	s := NewStore()
+	s.MSet(map[string]string{"a": "0", "b": "0"})
+	s.RPush("l", []string{"0"})
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+
+	const writers = 2
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				v := strconv.Itoa(i)
+				s.MSet(map[string]string{"a": v, "b": v})
+				s.LSet("l", 0, v)
+				s.RPush("l", []string{v})
+				s.LPop("l", 1)
+
+				runtime.Gosched()
+			}
+		}()
+	}
+
+	for i := 0; i < 200; i++ {
+		snapshot := s.Snapshot()
+
+		a, b := snapshot["a"].ValueData.Data, snapshot["b"].ValueData.Data
+		if a != b {
+			t.Fatalf("snapshot holds a = %v and b = %v from different MSETs", a, b)
+		}
+
+		// every writer keeps one element of its own at most
+		list, ok := snapshot["l"].ValueData.Data.(ListT)
+		if !ok || len(list.Elements) < 1 || len(list.Elements) > 1+writers {
+			t.Fatalf("snapshot holds a torn list %+v", snapshot["l"].ValueData.Data)
+		}
+		for _, element := range list.Elements {
+			if _, err := strconv.Atoi(element); err != nil {
+				t.Fatalf("snapshot holds a torn list element %q", element)
+			}
+		}
+
+		// a snapshot is never written to by later commands
+		before := list.Elements[0]
+		runtime.Gosched()
+		if list.Elements[0] != before {
+			t.Fatal("a write reached an element of a taken snapshot")
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+}
+
+func TestGetTypeMatchesWrites(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(s *Store, key string)
+		want  ValueType
+	}{
+		{"Set", func(s *Store, key string) { s.Set(key, "v", nil) }, StringType},
+		{"MSet", func(s *Store, key string) { s.MSet(map[string]string{key: "v"}) }, StringType},
+		{"IncrBy", func(s *Store, key string) { s.IncrBy(key, 1) }, StringType},
+		{"IncrByFloat", func(s *Store, key string) { s.IncrByFloat(key, 1.5) }, StringType},
+		{"Append", func(s *Store, key string) { s.Append(key, "v") }, StringType},
+		{"SetRange", func(s *Store, key string) { s.SetRange(key, 2, "v") }, StringType},
+		{"LPush", func(s *Store, key string) { s.LPush(key, []string{"a"}) }, ListType},
+		{"RPush", func(s *Store, key string) { s.RPush(key, []string{"a"}) }, ListType},
+		{"SAdd", func(s *Store, key string) { s.SAdd(key, []string{"a"}) }, SetType},
+		{"SAdd integers", func(s *Store, key string) { s.SAdd(key, []string{"1"}) }, SetType},
+		{"ZAdd", func(s *Store, key string) { s.ZAdd(key, []ZMember{{Member: "a", Score: 1}}) }, ZSetType},
+		{"ZIncrBy", func(s *Store, key string) { s.ZIncrBy(key, "a", 1) }, ZSetType},
+		{"HSet", func(s *Store, key string) { s.HSet(key, []string{"f", "v"}) }, HashType},
+		{"HIncrBy", func(s *Store, key string) { s.HIncrBy(key, "f", 1) }, HashType},
+		{"XAdd", func(s *Store, key string) { s.XAdd(key, StreamMessage{ID: "1-1"}) }, StreamType},
+		{"XAddAuto", func(s *Store, key string) { s.XAddAuto(key, nil) }, StreamType},
+		{"XGroupCreate MKSTREAM", func(s *Store, key string) { s.XGroupCreate(key, "g", "$", true) }, StreamType},
+		{"Copy", func(s *Store, key string) {
+			s.HSet("source", []string{"f", "v"})
+			s.Copy("source", key, false)
+		}, HashType},
+		{"Rename", func(s *Store, key string) {
+			s.ZAdd("source", []ZMember{{Member: "a", Score: 1}})
+			s.Rename("source", key)
+		}, ZSetType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStore()
+			tt.write(s, "k")
+
+			got, err := s.GetType("k")
+			if err != nil || got != tt.want {
+				t.Fatalf("GetType after %s = %v, %v, want %v", tt.name, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetTypeOfMissingKey(t *testing.T) {
+	s := NewStore()
+
+	if got, err := s.GetType("missing"); !errors.Is(err, ErrNotFound) || got != NoneType || got.String() != "none" {
+		t.Fatalf("GetType(missing) = %v, %v, want none", got, err)
+	}
+}
+
+/*
+BenchmarkKeyIteration compares walking the keyspace with ForEach against
+collecting every key into a slice first, the way KEYS and SCAN used to.
+*/
+func BenchmarkKeyIteration(b *testing.B) {
+	s := NewStore()
+	for i := 0; i < 10_000; i++ {
+		s.Set("key:"+strconv.Itoa(i), "v", nil)
+	}
+
+	b.Run("ForEach/all", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var n int
+			s.ForEach(func(key string, t ValueType) bool {
+				n++
+				return true
+			})
+		}
+	})
+
+	b.Run("Slice/all", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var n int
+			for range keysSlice(s) {
+				n++
+			}
+		}
+	})
+
+	b.Run("ForEach/first10", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var n int
+			s.ForEach(func(key string, t ValueType) bool {
+				n++
+				return n < 10
+			})
+		}
+	})
+
+	b.Run("Slice/first10", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = keysSlice(s)[:10]
+		}
+	})
+}
+
+func keysSlice(s *Store) []string {
+	var keys []string
+	for key := range s.Snapshot() {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func TestForEachStopsEarly(t *testing.T) {
+	s := NewStore()
+	for i := 0; i < 100; i++ {
+		s.Set("key:"+strconv.Itoa(i), "v", nil)
+	}
+	expireNow(t, s, "key:0")
+
+	var all int
+	s.ForEach(func(key string, t ValueType) bool {
+		all++
+		return true
+	})
+	if all != 99 {
+		t.Fatalf("ForEach visited %d keys, want the 99 live ones", all)
+	}
+
+	var visited int
+	s.ForEach(func(key string, t ValueType) bool {
+		visited++
+		return visited < 10
+	})
+	if visited != 10 {
+		t.Fatalf("ForEach visited %d keys after fn returned false at 10", visited)
+	}
+}
+
+func TestMGetNeverSeesPartialMSet(t *testing.T) {
+	const (
+		keys   = 20
+		rounds = 100
+	)
+
+	names := make([]string, keys)
+	for i := range names {
+		names[i] = "key:" + strconv.Itoa(i)
+	}
+
+	s := NewStore()
+	pairs := make(map[string]string, keys)
+	for _, name := range names {
+		pairs[name] = "0"
+	}
+	s.MSet(pairs)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for round := 1; round <= rounds; round++ {
+			pairs := make(map[string]string, keys)
+			for _, name := range names {
+				pairs[name] = strconv.Itoa(round)
+			}
+			s.MSet(pairs)
+			runtime.Gosched()
+		}
+	}()
+
+	for {
+		values := s.MGet(names)
+		for _, value := range values {
+			if value == nil || *value != *values[0] {
+				t.Fatalf("MGET saw a partial MSET: %s and %v", *values[0], value)
+			}
+		}
+
+		select {
+		case <-done:
+			return
+		default:
+			runtime.Gosched()
+		}
+	}
+}
+
+func TestMGetNonStringIsNil(t *testing.T) {
+	s := NewStore()
+	s.Set("string", "v", nil)
+	s.RPush("list", []string{"a"})
+
+	values := s.MGet([]string{"string", "list", "missing"})
+	if values[0] == nil || *values[0] != "v" || values[1] != nil || values[2] != nil {
+		t.Fatalf("MGet = %v, want [v nil nil]", values)
+	}
+}
+
+func TestAddLongDouble(t *testing.T) {
+	tests := []struct {
+		current string
+		delta   float64
+		want    string
+	}{
+		{"0.1", 0.2, "0.3"},
+		{"10.50", 0.1, "10.6"},
+		{"5.0e3", 2.0e2, "5200"},
+		{"0", -0.5, "-0.5"},
+		{"0.5", -0.5, "0"},
+		{"3", 0, "3"},
+	}
+
+	for _, tt := range tests {
+		if got, ok := addLongDouble(tt.current, tt.delta); !ok || got != tt.want {
+			t.Errorf("addLongDouble(%q, %v) = %q, %v, want %q", tt.current, tt.delta, got, ok, tt.want)
+		}
+	}
+
+	if got, ok := addLongDouble("1.7e308", 1.7e308); ok {
+		t.Errorf("addLongDouble past float64 = %q, want it rejected", got)
+	}
+}
+
+func TestGetTellsMissesApart(t *testing.T) {
+	s := NewStore()
+	s.Set("k", "v", nil)
+	s.Set("expired", "v", nil)
+	expireNow(t, s, "expired")
+	s.RPush("l", []string{"a"})
+
+	tests := []struct {
+		key     string
+		wantErr error
+	}{
+		{"k", nil},
+		{"missing", ErrNotFound},
+		{"expired", ErrExpired},
+		{"l", ErrWrongType},
+	}
+
+	for _, tt := range tests {
+		if _, err := s.Get(tt.key); !errors.Is(err, tt.wantErr) {
+			t.Errorf("Get(%q) = %v, want %v", tt.key, err, tt.wantErr)
+		}
+	}
+
+	// once reaped, an expired key is just missing
+	if _, err := s.Get("expired"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get on a reaped key = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetEx(t *testing.T) {
+	s := NewStore()
+	s.Set("k", "v", nil)
+	s.RPush("l", []string{"a"})
+
+	at := time.Now().Add(time.Minute)
+
+	tests := []struct {
+		name        string
+		key         string
+		expiredAt   *time.Time
+		persist     bool
+		wantChanged bool
+		wantTTL     bool
+		wantErr     error
+	}{
+		{name: "no option", key: "k"},
+		{name: "PERSIST without a deadline", key: "k", persist: true},
+		{name: "EX", key: "k", expiredAt: &at, wantChanged: true, wantTTL: true},
+		{name: "no option keeps the deadline", key: "k", wantTTL: true},
+		{name: "PERSIST", key: "k", persist: true, wantChanged: true},
+		{name: "missing key", key: "missing", expiredAt: &at, wantErr: ErrNotFound},
+		{name: "wrong type", key: "l", expiredAt: &at, wantErr: ErrWrongType},
+	}
+
+	for _, tt := range tests {
+		value, changed, err := s.GetEx(tt.key, tt.expiredAt, tt.persist)
+		if !errors.Is(err, tt.wantErr) {
+			t.Fatalf("%s: GetEx error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if tt.wantErr != nil {
+			if changed {
+				t.Fatalf("%s: GetEx reported a change along with %v", tt.name, err)
+			}
+			continue
+		}
+
+		if value != "v" || changed != tt.wantChanged {
+			t.Fatalf("%s: GetEx = %q, changed %v, want v, changed %v", tt.name, value, changed, tt.wantChanged)
+		}
+		if _, _, hasTTL := s.TTLRemaining(tt.key); hasTTL != tt.wantTTL {
+			t.Fatalf("%s: key has a deadline %v, want %v", tt.name, hasTTL, tt.wantTTL)
+		}
+	}
+}
+
+/*
+TestConcurrentAccess hammers one store from many goroutines; it finds
+unguarded map accesses when run with -race.
+*/
+func TestConcurrentAccess(t *testing.T) {
+	const (
+		workers = 8
+		rounds  = 300
+	)
+
+	s := NewStore()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			px := 1000
+			for i := 0; i < rounds; i++ {
+				key := "k" + strconv.Itoa(i%10)
+				value := strconv.Itoa(w)
+
+				s.Set(key, value, &px)
+				if got, err := s.Get(key); err == nil {
+					if _, err := strconv.Atoi(got); err != nil {
+						t.Errorf("Get(%q) = %q, a value no writer stored", key, got)
+						return
+					}
+				}
+				s.Exists(key)
+				s.TTLRemaining(key)
+				s.IncrBy("counter", 1)
+				if i%7 == 0 {
+					s.Del(key)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if got, _ := s.Get("counter"); got != strconv.Itoa(workers*rounds) {
+		t.Fatalf("counter = %s after %d concurrent increments", got, workers*rounds)
+	}
+}
+
+func TestIncrBy(t *testing.T) {
+	tests := []struct {
+		name    string
+		stored  string
+		delta   int64
+		want    int64
+		wantErr error
+	}{
+		{name: "creates a missing key", delta: 5, want: 5},
+		{name: "adds to a stored integer", stored: "10", delta: -3, want: 7},
+		{name: "reaches the maximum", stored: "9223372036854775806", delta: 1, want: math.MaxInt64},
+		{name: "reaches the minimum", stored: "-9223372036854775807", delta: -1, want: math.MinInt64},
+		{name: "overflows", stored: "9223372036854775807", delta: 1, wantErr: ErrOverflow},
+		{name: "underflows", stored: "-9223372036854775808", delta: -1, wantErr: ErrOverflow},
+		{name: "not a number", stored: "ten", delta: 1, wantErr: ErrNotInteger},
+		{name: "a float", stored: "1.5", delta: 1, wantErr: ErrNotInteger},
+		{name: "a plus sign", stored: "+5", delta: 1, wantErr: ErrNotInteger},
+		{name: "a leading zero", stored: "012", delta: 1, wantErr: ErrNotInteger},
+		{name: "negative zero", stored: "-0", delta: 1, wantErr: ErrNotInteger},
+		{name: "spaces", stored: " 5", delta: 1, wantErr: ErrNotInteger},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStore()
+			if tt.stored != "" {
+				s.Set("k", tt.stored, nil)
+			}
+
+			got, err := s.IncrBy("k", tt.delta)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Fatalf("IncrBy = %d, %v, want %d, %v", got, err, tt.want, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if value, _ := s.Get("k"); value != tt.stored {
+					t.Fatalf("a failed IncrBy left %q, want %q", value, tt.stored)
+				}
+			}
+		})
+	}
+}
+
+func TestIncrByWrongTypeAndExpiry(t *testing.T) {
+	s := NewStore()
+	s.RPush("l", []string{"a"})
+	if _, err := s.IncrBy("l", 1); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("IncrBy on a list = %v, want ErrWrongType", err)
+	}
+
+	px := 100000
+	s.Set("k", "1", &px)
+	s.IncrBy("k", 1)
+	if _, _, hasExpiry := s.TTLRemaining("k"); !hasExpiry {
+		t.Fatal("IncrBy dropped the expiration of the key")
+	}
+}
+
+/*
+TestDeadlinesKeepMonotonicClock checks that deadlines set at runtime carry
+a monotonic clock reading. time.Until then ignores the wall clock, so a
+clock jump neither expires keys early nor keeps them around longer.
+*/
+func TestDeadlinesKeepMonotonicClock(t *testing.T) {
+	monotonic := func(deadline time.Time) bool {
+		// only times with a monotonic reading print its offset
+		return strings.Contains(deadline.String(), " m=")
+	}
+
+	s := NewStore()
+	px := 100000
+
+	s.Set("set", "v", &px)
+	s.Set("expire", "v", nil)
+	s.SetExpiry("expire", &px)
+	payload, _, _ := s.Dump("set")
+	s.Restore("restored", payload, time.Minute, false)
+	s.HSet("h", []string{"f", "v"})
+	s.HExpire("h", 100, []string{"f"})
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, key := range []string{"set", "expire", "restored"} {
+		if deadline := s.store[key].ExpiredAt; deadline == nil || !monotonic(*deadline) {
+			t.Errorf("the deadline of %q has no monotonic reading", key)
+		}
+	}
+	if deadline := s.store["h"].ValueData.Data.(HashT).ExpiredAt["f"]; !monotonic(deadline) {
+		t.Error("the deadline of a hash field has no monotonic reading")
+	}
+}
+
+func TestRenameIsAtomicForReaders(t *testing.T) {
+	s := NewStore()
+	px := 100000
+	s.Set("a", "v", &px)
+
+	if err := s.Rename("missing", "b"); !errors.Is(err, ErrNoSuchKey) {
+		t.Fatalf("Rename of a missing key = %v, want ErrNoSuchKey", err)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < 10000; i++ {
+			if i%2 == 0 {
+				s.Rename("a", "b")
+			} else {
+				s.Rename("b", "a")
+			}
+		}
+		close(done)
+	}()
+
+	// the value is always under exactly one of the names, never both or
+	// neither, and keeps its deadline wherever it is
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+
+		if keys, expiring := s.Counts(); keys != 1 || expiring != 1 {
+			t.Fatalf("%d keys, %d expiring during a rename, want 1 and 1", keys, expiring)
+		}
+		for _, key := range []string{"a", "b"} {
+			if got, err := s.Get(key); err == nil && got != "v" {
+				t.Fatalf("GET %s = %q during a rename, want v", key, got)
+			}
+		}
+		runtime.Gosched()
+	}
+	wg.Wait()
+
+	ttl, exists, hasTTL := s.TTLRemaining("a")
+	if !exists || !hasTTL || ttl <= 0 {
+		t.Fatalf("TTL after renaming back = %v, %v, %v, want the deadline kept", ttl, exists, hasTTL)
+	}
+}
+
+func TestWriteHookSkipsFailedAndNoopWrites(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(s *Store)
+		want  bool
+	}{
+		{name: "INCR on a non-integer", write: func(s *Store) { s.IncrBy("str", 1) }},
+		{name: "INCRBYFLOAT on a list", write: func(s *Store) { s.IncrByFloat("list", 1) }},
+		{name: "APPEND on a set", write: func(s *Store) { s.Append("set", "x") }},
+		{name: "SETRANGE with an empty value", write: func(s *Store) { s.SetRange("str", 0, "") }},
+		{name: "SADD on a list", write: func(s *Store) { s.SAdd("list", []string{"a"}) }},
+		{name: "SADD of a present member", write: func(s *Store) { s.SAdd("set", []string{"a"}) }},
+		{name: "SREM of a missing member", write: func(s *Store) { s.SRem("set", []string{"zz"}) }},
+		{name: "RPUSHX on a missing key", write: func(s *Store) { s.RPushX("missing", []string{"a"}) }},
+		{name: "LPOP of a missing key", write: func(s *Store) { s.LPop("missing", 1) }},
+		{name: "LPOP of no element", write: func(s *Store) { s.LPop("list", 0) }},
+		{name: "LSET out of range", write: func(s *Store) { s.LSet("list", 10, "x") }},
+		{name: "HSET on a string", write: func(s *Store) { s.HSet("str", []string{"f", "v"}) }},
+		{name: "HDEL of a missing field", write: func(s *Store) { s.HDel("hash", []string{"zz"}) }},
+		{name: "HEXPIRE of a missing field", write: func(s *Store) { s.HExpire("hash", 10, []string{"zz"}) }},
+		{name: "ZADD of an unchanged score", write: func(s *Store) { s.ZAdd("zset", []ZMember{{Member: "a", Score: 1}}) }},
+		{name: "ZREM of a missing member", write: func(s *Store) { s.ZRem("zset", []string{"zz"}) }},
+		{name: "XADD on a string", write: func(s *Store) { s.XAdd("str", StreamMessage{ID: "1-1"}) }},
+		{name: "DEL of a missing key", write: func(s *Store) { s.Del("missing") }},
+		{name: "COPY onto an existing key", write: func(s *Store) { s.Copy("str", "list", false) }},
+		{name: "SINTERSTORE of nothing into nothing", write: func(s *Store) {
+			s.SetOpStore("missing", SetInter, []string{"set", "other"})
+		}},
+		{name: "INCR", write: func(s *Store) { s.IncrBy("counter", 1) }, want: true},
+		{name: "SADD of a new member", write: func(s *Store) { s.SAdd("set", []string{"b"}) }, want: true},
+		{name: "LPOP", write: func(s *Store) { s.LPop("list", 1) }, want: true},
+		{name: "ZADD re-scoring", write: func(s *Store) { s.ZAdd("zset", []ZMember{{Member: "a", Score: 2}}) }, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStore()
+			s.Set("str", "abc", nil)
+			s.Set("counter", "1", nil)
+			s.RPush("list", []string{"a"})
+			s.SAdd("set", []string{"a"})
+			s.HSet("hash", []string{"f", "v"})
+			s.ZAdd("zset", []ZMember{{Member: "a", Score: 1}})
+
+			var written []string
+			s.SetWriteHook(func(key string) { written = append(written, key) })
+
+			before := s.UsedMemory()
+			tt.write(s)
+
+			if got := len(written) > 0; got != tt.want {
+				t.Fatalf("write hook called with %v, want a call: %v", written, tt.want)
+			}
+			if !tt.want && s.UsedMemory() != before {
+				t.Fatalf("UsedMemory = %d after a write that changed nothing, was %d", s.UsedMemory(), before)
+			}
+		})
+	}
+}
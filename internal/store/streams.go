@@ -3,63 +3,176 @@ package store
 import (
 	"errors"
 	"fmt"
-	"strconv"
-	"strings"
+	"math"
+	"sort"
+	"time"
 )
 
 func (s *Store) XAdd(key string, streamValue StreamMessage) error {
+	var changed bool
+	defer s.notifyWriteIf(&changed, key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.appendStreamMessage(key, streamValue)
+	changed = err == nil
+
+	return err
+}
+
+/*
+XAddAuto adds an entry with an ID generated from the current time. The
+ID is picked and the entry appended under one lock, so concurrent calls
+on the same stream always get unique, strictly increasing IDs. When the
+clock is behind the top of the stream, the top ID's sequence is bumped,
+or its millisecond once the sequence is exhausted.
+*/
+func (s *Store) XAddAuto(key string, fields []StreamField) (string, error) {
+	var changed bool
+	defer s.notifyWriteIf(&changed, key)
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	ms := uint64(time.Now().UnixMilli())
+	var seq uint64
+
+	if value, exists := s.store[key]; exists {
+		if value.ValueData.DataType != StreamType {
+			return "", ErrWrongType
+		}
+
+		lastMs, lastSeq, err := parseID(value.ValueData.Data.(StreamMessages).LastID)
+		switch {
+		case err != nil || ms > lastMs:
+		case lastSeq == math.MaxUint64:
+			// the millisecond is used up, move on to the next one
+			ms, seq = lastMs+1, 0
+		default:
+			ms, seq = lastMs, lastSeq+1
+		}
+	}
+
+	id := fmt.Sprintf("%d-%d", ms, seq)
+
+	if err := s.appendStreamMessage(key, StreamMessage{ID: id, Fields: fields}); err != nil {
+		return "", err
+	}
+	changed = true
+
+	return id, nil
+}
+
+/*
+appendStreamMessage appends an entry to the stream at key, creating the
+stream when needed. The caller must hold the write lock.
+*/
+func (s *Store) appendStreamMessage(key string, streamValue StreamMessage) error {
 	value, exists := s.store[key]
 	if !exists {
 		s.store[key] = Value{
 			ValueData: ValueWithType{
-				Data:     StreamMessages{Messages: []StreamMessage{streamValue}},
+				Data: StreamMessages{
+					Messages: []StreamMessage{streamValue},
+					LastID:   streamValue.ID,
+				},
 				DataType: StreamType,
 			},
 		}
+
+		s.resize(key, streamMessageSize(streamValue))
+		s.releaseWaiters(key)
+
 		return nil
 	}
 
+	if value.ValueData.DataType != StreamType {
+		return ErrWrongType
+	}
+
 	streamMessages := value.ValueData.Data.(StreamMessages)
 	streamMessages.Messages = append(streamMessages.Messages, streamValue)
+	streamMessages.LastID = streamValue.ID
 
 	value.ValueData.Data = streamMessages
 
 	s.store[key] = value
+	s.resize(key, streamMessageSize(streamValue))
+
+	s.releaseWaiters(key)
 
 	return nil
 }
 
+/*
+WatchStreams returns the current top ID of each stream and registers a
+waiter that is released by the next XAdd to one of them. Both happen
+under one lock, so no entry can be added between reading the top IDs
+and waiting.
+*/
+func (s *Store) WatchStreams(keys []string) ([]string, <-chan struct{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	topIDs := make([]string, len(keys))
+
+	for i, key := range keys {
+		topIDs[i] = "0-0"
+
+		if value, ok := s.store[key]; ok && value.ValueData.DataType == StreamType {
+			topIDs[i] = value.ValueData.Data.(StreamMessages).LastID
+		}
+	}
+
+	return topIDs, s.watch(keys)
+}
+
+/*
+GetStreamsRange returns the entries of the stream at key whose IDs lie
+between the two targets, both included. "-" and "+" stand for the lowest
+and highest IDs, and a target without a sequence covers the whole
+millisecond.
+*/
 func (s *Store) GetStreamsRange(
 	key string,
 	rangeTargets [2]string,
 ) ([]StreamMessage, error) {
+	startMs, startSeq, err := parseRangeID(rangeTargets[0], 0)
+	if err != nil {
+		return []StreamMessage{}, err
+	}
+
+	endMs, endSeq, err := parseRangeID(rangeTargets[1], math.MaxUint64)
+	if err != nil {
+		return []StreamMessage{}, err
+	}
+
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
 	if value, ok := s.store[key]; !ok {
 		return []StreamMessage{}, errors.New("key does not exists")
+	} else if value.ValueData.DataType != StreamType {
+		return []StreamMessage{}, ErrWrongType
 	} else {
-
-		var index int
-		var indexTwo int
-
-		if rangeTargets[0] == "-" {
-			index = 0
-		} else {
-			index = binarySearch(value.GetStorable().(StreamMessages), rangeTargets[0])
-		}
-
-		if rangeTargets[1] == "+" {
-			indexTwo = len(value.GetStorable().(StreamMessages).Messages) - 1
-		} else {
-			indexTwo = binarySearch(value.GetStorable().(StreamMessages), rangeTargets[1])
+		messages := value.GetStorable().(StreamMessages).Messages
+
+		// first entry at or after start, first entry after end
+		index := sort.Search(len(messages), func(i int) bool {
+			ms, seq, _ := parseID(messages[i].ID)
+			return !isIDSmallerOrEqual(ms, startMs, seq, startSeq) || (ms == startMs && seq == startSeq)
+		})
+		indexTwo := sort.Search(len(messages), func(i int) bool {
+			ms, seq, _ := parseID(messages[i].ID)
+			return !isIDSmallerOrEqual(ms, endMs, seq, endSeq)
+		})
+
+		if index >= indexTwo {
+			return []StreamMessage{}, nil
 		}
 
-		return GetRangedMessages(value.GetStorable().(StreamMessages), index, indexTwo), nil
-
+		return messages[index:indexTwo], nil
 	}
 }
 
@@ -67,28 +180,27 @@ func (s *Store) GetStreamsExclusive(
 	key string,
 	target string,
 ) ([]StreamMessage, error) {
+	ms, seq, err := parseReadID(target)
+	if err != nil {
+		return []StreamMessage{}, err
+	}
+
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
 	if value, ok := s.store[key]; !ok {
 		return []StreamMessage{}, errors.New("key does not exists")
+	} else if value.ValueData.DataType != StreamType {
+		return []StreamMessage{}, ErrWrongType
 	} else {
+		messages := value.GetStorable().(StreamMessages).Messages
 
-		index := binarySearch(value.GetStorable().(StreamMessages), target)
-		lastIndex := len(value.GetStorable().(StreamMessages).Messages) - 1
+		index := sort.Search(len(messages), func(i int) bool {
+			messageMs, messageSeq, _ := parseID(messages[i].ID)
+			return !isIDSmallerOrEqual(messageMs, ms, messageSeq, seq)
+		})
 
-		if index == lastIndex {
-			return []StreamMessage{}, nil
-		}
-
-		if index == 0 {
-			index++
-		}
-		if index == -1 {
-			index = 0
-		}
-
-		return GetRangedMessages(value.GetStorable().(StreamMessages), index, len(value.GetStorable().(StreamMessages).Messages)-1), nil
+		return messages[index:], nil
 	}
 }
 
@@ -101,40 +213,11 @@ func (s *Store) GetLastStreamID(keyStream string, defaultValue string) (string,
 		return defaultValue, errors.New("key does not exists")
 	}
 
-	id := value.GetStorable().(StreamMessages).Messages[len(value.GetStorable().(StreamMessages).Messages)-1].ID
-
-	return id, nil
-}
-
-func (s *Store) IncrStreamID(keyStream string) (string, error) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	value, ok := s.store[keyStream]
-	if !ok {
-		return "0-1", errors.New("key does not exists")
-	}
-
-	id := value.GetStorable().(StreamMessages).Messages[len(value.GetStorable().(StreamMessages).Messages)-1].ID
-
-	parts := strings.Split(id, "-")
-	lastValue, _ := strconv.Atoi(parts[1])
-	newID := fmt.Sprintf("%s-%d", parts[0], lastValue+1)
-
-	return newID, nil
-}
-
-func (s *Store) CreateNewStreamID(keyStream string, id string) (string, error) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	_, ok := s.store[keyStream]
-	if !ok {
-		return "0-1", errors.New("key does not exists")
+	if value.ValueData.DataType != StreamType {
+		return defaultValue, ErrWrongType
 	}
 
-	parts := strings.Split(id, "-")
-	newID := fmt.Sprintf("%s-%s", parts[0], "0")
+	id := value.GetStorable().(StreamMessages).LastID
 
-	return newID, nil
+	return id, nil
 }
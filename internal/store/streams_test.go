@@ -0,0 +1,252 @@
+package store
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchStreamsReleasedByNextXAdd(t *testing.T) {
+	s := NewStore()
+	s.XAdd("s", StreamMessage{ID: "1-1"})
+
+	topIDs, wait := s.WatchStreams([]string{"s", "missing"})
+	if topIDs[0] != "1-1" || topIDs[1] != "0-0" {
+		t.Fatalf("WatchStreams top IDs = %v, want [1-1 0-0]", topIDs)
+	}
+
+	s.XAdd("s", StreamMessage{ID: "1-2"})
+
+	select {
+	case <-wait:
+	default:
+		t.Fatal("the XADD right after WatchStreams did not release the waiter")
+	}
+}
+
+/*
+TestWatchStreamsAgainstConcurrentXAdd checks that every entry added while
+readers watch is either visible in the top ID they got or releases them.
+*/
+func TestWatchStreamsAgainstConcurrentXAdd(t *testing.T) {
+	const (
+		readers = 8
+		entries = 500
+	)
+
+	s := NewStore()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				topIDs, wait := s.WatchStreams([]string{"s"})
+
+				// an ID added after the top was read must release the waiter
+				if id, _ := s.GetLastStreamID("s", "0-0"); id != topIDs[0] {
+					select {
+					case <-wait:
+					case <-time.After(time.Second):
+						t.Errorf("%s was added after the top %s without releasing the waiter", id, topIDs[0])
+						return
+					}
+					continue
+				}
+
+				s.Unwatch(wait)
+			}
+		}()
+	}
+
+	for i := 1; i <= entries; i++ {
+		if err := s.XAdd("s", StreamMessage{ID: "1-" + strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+}
+
+func TestGetStreamsExclusive(t *testing.T) {
+	s := NewStore()
+	for _, id := range []string{"1-1", "1-2", "5-0", "10-0", "10-3"} {
+		s.XAdd("s", StreamMessage{ID: id})
+	}
+
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"0-0", []string{"1-1", "1-2", "5-0", "10-0", "10-3"}},
+		{"0", []string{"1-1", "1-2", "5-0", "10-0", "10-3"}},
+		{"1-1", []string{"1-2", "5-0", "10-0", "10-3"}},
+		{"1-2", []string{"5-0", "10-0", "10-3"}},
+		{"3-0", []string{"5-0", "10-0", "10-3"}},
+		{"5", []string{"10-0", "10-3"}},
+		{"9-99", []string{"10-0", "10-3"}},
+		{"10-0", []string{"10-3"}},
+		{"10-3", nil},
+		{"11-0", nil},
+	}
+
+	for _, tt := range tests {
+		messages, err := s.GetStreamsExclusive("s", tt.target)
+		if err != nil {
+			t.Fatalf("GetStreamsExclusive(%s) = %v", tt.target, err)
+		}
+
+		var got []string
+		for _, message := range messages {
+			got = append(got, message.ID)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("GetStreamsExclusive(%s) = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+
+	if _, err := s.GetStreamsExclusive("s", "abc"); !errors.Is(err, ErrInvalidStreamID) {
+		t.Fatalf("GetStreamsExclusive(abc) = %v, want ErrInvalidStreamID", err)
+	}
+}
+
+func TestGetStreamsRange(t *testing.T) {
+	s := NewStore()
+	for _, id := range []string{"1-1", "1-2", "5-0", "10-0", "10-3"} {
+		s.XAdd("s", StreamMessage{ID: id})
+	}
+
+	tests := []struct {
+		start, end string
+		want       []string
+	}{
+		{"-", "+", []string{"1-1", "1-2", "5-0", "10-0", "10-3"}},
+		{"1-2", "10-0", []string{"1-2", "5-0", "10-0"}},
+		{"1", "1", []string{"1-1", "1-2"}},
+		{"2-0", "9-0", []string{"5-0"}},
+		{"0", "5", []string{"1-1", "1-2", "5-0"}},
+		{"10", "+", []string{"10-0", "10-3"}},
+		{"10-1", "10-2", nil},
+		{"11", "+", nil},
+		{"5-0", "1-1", nil},
+		{"5-0", "5-0", []string{"5-0"}},
+	}
+
+	for _, tt := range tests {
+		messages, err := s.GetStreamsRange("s", [2]string{tt.start, tt.end})
+		if err != nil {
+			t.Fatalf("GetStreamsRange(%s, %s) = %v", tt.start, tt.end, err)
+		}
+
+		var got []string
+		for _, message := range messages {
+			got = append(got, message.ID)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("GetStreamsRange(%s, %s) = %v, want %v", tt.start, tt.end, got, tt.want)
+		}
+	}
+
+	if _, err := s.GetStreamsRange("s", [2]string{"abc", "+"}); !errors.Is(err, ErrInvalidStreamID) {
+		t.Fatalf("GetStreamsRange(abc, +) = %v, want ErrInvalidStreamID", err)
+	}
+}
+
+func TestXAddWakesOnlyItsStream(t *testing.T) {
+	s := NewStore()
+
+	waits := make([]<-chan struct{}, 10)
+	for i := range waits {
+		_, waits[i] = s.WatchStreams([]string{"s" + strconv.Itoa(i)})
+	}
+
+	if err := s.XAdd("s3", StreamMessage{ID: "1-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, wait := range waits {
+		select {
+		case <-wait:
+			if i != 3 {
+				t.Errorf("an XADD to s3 woke the reader of s%d", i)
+			}
+		default:
+			if i == 3 {
+				t.Error("an XADD to s3 did not wake its reader")
+			}
+		}
+	}
+}
+
+func TestXAddAutoConcurrentIDs(t *testing.T) {
+	const (
+		writers = 8
+		adds    = 200
+	)
+
+	s := NewStore()
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < adds; i++ {
+				if _, err := s.XAddAuto("s", nil); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries, err := s.GetStreamsRange("s", [2]string{"-", "+"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != writers*adds {
+		t.Fatalf("the stream has %d entries, want %d", len(entries), writers*adds)
+	}
+
+	for i := 1; i < len(entries); i++ {
+		ms1, seq1, _ := parseID(entries[i-1].ID)
+		ms2, seq2, _ := parseID(entries[i].ID)
+		if isIDSmallerOrEqual(ms2, ms1, seq2, seq1) {
+			t.Fatalf("entry %d has ID %s after %s", i, entries[i].ID, entries[i-1].ID)
+		}
+	}
+}
+
+func TestXAddAutoBehindTheTop(t *testing.T) {
+	tests := []struct {
+		top  string
+		want string
+	}{
+		{"99999999999999-5", "99999999999999-6"},
+		{"99999999999999-18446744073709551615", "100000000000000-0"},
+	}
+
+	for _, tt := range tests {
+		s := NewStore()
+		s.XAdd("s", StreamMessage{ID: tt.top})
+
+		if got, err := s.XAddAuto("s", nil); err != nil || got != tt.want {
+			t.Errorf("XAddAuto after %s = %q, %v, want %q", tt.top, got, err, tt.want)
+		}
+	}
+}
@@ -3,22 +3,32 @@ package store
 import (
 	"errors"
 	"fmt"
+	"math"
 	"regexp"
-	"sort"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+/*
+reGroupOne checks an explicit "ms-seq" ID against the top of the stream and
+returns it in its canonical form.
+*/
 func reGroupOne(keyStream string, id string, store *Store) (string, error) {
 	logrus.WithFields(logrus.Fields{
 		"id": id,
 	}).Debug("Matches group")
 
-	lastStreamId, err := store.GetLastStreamID(keyStream, id)
+	ms, seq, err := parseID(id)
 	if err != nil {
-		return lastStreamId, nil
+		return "", err
+	}
+	id = fmt.Sprintf("%d-%d", ms, seq)
+
+	lastStreamId, err := store.GetLastStreamID(keyStream, "0-0")
+	if err != nil {
+		lastStreamId = "0-0"
 	}
 
 	logrus.Debug("lastStreamId ", lastStreamId)
@@ -31,58 +41,63 @@ func reGroupOne(keyStream string, id string, store *Store) (string, error) {
 	return id, nil
 }
 
+/*
+reGroupTwo completes an "ms-*" ID with the sequence following the top of
+the stream when it shares its time, 0 otherwise and 1 at time 0.
+*/
 func reGroupTwo(keyStream string, id string, store *Store) (string, error) {
 	logrus.WithField("id", id).Debug("Matches group any sequence")
 
-	newTimestamp, _ := splitID(id)
-
-	defaultValue := fmt.Sprintf("%s-0", newTimestamp)
+	ms, err := strconv.ParseUint(strings.TrimSuffix(id, "-*"), 10, 64)
+	if err != nil {
+		return "", ErrInvalidStreamID
+	}
 
-	if newTimestamp == "0" {
-		defaultValue = "0-1"
+	var seq uint64
+	if ms == 0 {
+		seq = 1
 	}
 
-	lastStreamId, err := store.GetLastStreamID(keyStream, defaultValue)
+	lastStreamId, err := store.GetLastStreamID(keyStream, "0-0")
 	if err != nil {
-		return lastStreamId, nil
+		lastStreamId = "0-0"
 	}
 
-	lastTimestamp, _ := splitID(lastStreamId)
+	lastMs, lastSeq, err := parseID(lastStreamId)
+	if err != nil {
+		return "", err
+	}
 
-	if newTimestamp != lastTimestamp {
-		id, err = store.CreateNewStreamID(keyStream, id)
-		if err != nil {
-			return "", err
-		}
-	} else {
-		id, err = store.IncrStreamID(keyStream)
-		if err != nil {
-			return "", err
+	if ms == lastMs {
+		if lastSeq == math.MaxUint64 {
+			return "", errors.New(
+				"The ID specified in XADD is equal or smaller than the target stream top item",
+			)
 		}
+		seq = lastSeq + 1
 	}
 
-	logrus.Debug("result ID ", id)
-
-	return id, nil
-}
-
-func reGroupThree(id string) (string, error) {
-	logrus.WithField("id", id).Debug("Matches group any")
+	id = fmt.Sprintf("%d-%d", ms, seq)
 
-	timestamp := time.Now().UnixNano() / int64(time.Millisecond)
+	err = compareIDs(id, lastStreamId)
+	if err != nil {
+		return "", err
+	}
 
-	id = fmt.Sprintf("%d-%d", timestamp, 0)
+	logrus.Debug("result ID ", id)
 
 	return id, nil
 }
 
 func FormID(keyStream string, id string, store *Store) (string, error) {
 	logrus.Debug(keyStream, id)
-	logrus.Debug(store.store[keyStream].GetStorable())
+
+	if keyType, err := store.GetType(keyStream); err == nil && keyType != StreamType {
+		return "", ErrWrongType
+	}
 
 	reGroup := regexp.MustCompile(`^\d+-\d+$`)
 	reGroupAnySequence := regexp.MustCompile(`^\d+-\*$`)
-	reGroupAny := regexp.MustCompile(`^\*$`)
 
 	switch {
 	case reGroup.MatchString(id):
@@ -90,30 +105,109 @@ func FormID(keyStream string, id string, store *Store) (string, error) {
 
 	case reGroupAnySequence.MatchString(id):
 		return reGroupTwo(keyStream, id, store)
-
-	case reGroupAny.MatchString(id):
-		return reGroupThree(id)
-		// generate timestamp + lastvalue+1
 	}
 
 	logrus.Info("No match")
 
-	return "", errors.New("ID format is not valid")
+	return "", ErrInvalidStreamID
 }
 
-func splitID(id string) (string, string) {
-	parts := strings.Split(id, "-")
-	return parts[0], parts[1]
+/*
+NormalizeRange resolves Redis style inclusive start/end indexes, where
+negative values count from the end, against a sequence of the given length.
+It returns false when the resulting range is empty.
+*/
+func NormalizeRange(start, end, length int) (int, int, bool) {
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+
+	start = max(start, 0)
+	end = min(end, length-1)
+
+	if length == 0 || start > end {
+		return 0, 0, false
+	}
+
+	return start, end, true
+}
+
+/*
+parseID parses a complete "ms-seq" stream ID into its numeric parts.
+*/
+func parseID(id string) (uint64, uint64, error) {
+	millisecondPart, sequencePart, found := strings.Cut(id, "-")
+	if !found {
+		return 0, 0, ErrInvalidStreamID
+	}
+
+	ms, err := strconv.ParseUint(millisecondPart, 10, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidStreamID
+	}
+
+	seq, err := strconv.ParseUint(sequencePart, 10, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidStreamID
+	}
+
+	return ms, seq, nil
+}
+
+/*
+parseReadID parses an ID given to a reading command, where the sequence
+may be left out and then defaults to 0.
+*/
+func parseReadID(id string) (uint64, uint64, error) {
+	if !strings.Contains(id, "-") {
+		id += "-0"
+	}
+
+	return parseID(id)
+}
+
+/*
+parseRangeID parses a bound of a stream range. "-" and "+" are the lowest
+and highest IDs, and a bound without a sequence gets defaultSeq.
+*/
+func parseRangeID(id string, defaultSeq uint64) (uint64, uint64, error) {
+	switch id {
+	case "-":
+		return 0, 0, nil
+	case "+":
+		return math.MaxUint64, math.MaxUint64, nil
+	}
+
+	if !strings.Contains(id, "-") {
+		ms, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return 0, 0, ErrInvalidStreamID
+		}
+
+		return ms, defaultSeq, nil
+	}
+
+	return parseID(id)
 }
 
 func compareIDs(id1 string, id2 string) error {
-	millisecondPart1, sequencePart1 := splitID(id1)
-	millisecondPart2, sequencePart2 := splitID(id2)
+	millisecondPart1, sequencePart1, err := parseID(id1)
+	if err != nil {
+		return err
+	}
 
-	if id1 == "0-0" {
+	if millisecondPart1 == 0 && sequencePart1 == 0 {
 		return errors.New("The ID specified in XADD must be greater than 0-0")
 	}
 
+	millisecondPart2, sequencePart2, err := parseID(id2)
+	if err != nil {
+		return err
+	}
+
 	if isIDSmallerOrEqual(millisecondPart1, millisecondPart2, sequencePart1, sequencePart2) {
 		return errors.New(
 			"The ID specified in XADD is equal or smaller than the target stream top item",
@@ -123,26 +217,6 @@ func compareIDs(id1 string, id2 string) error {
 	return nil
 }
 
-func isIDSmallerOrEqual(ms1, ms2, seq1, seq2 string) bool {
+func isIDSmallerOrEqual(ms1, ms2, seq1, seq2 uint64) bool {
 	return ms1 < ms2 || (ms1 == ms2 && seq1 <= seq2)
 }
-
-func binarySearch(messages StreamMessages, targetID string) int {
-	i := sort.Search(len(messages.Messages), func(i int) bool {
-		return messages.Messages[i].ID >= targetID
-	})
-
-	if i < len(messages.Messages) && messages.Messages[i].ID == targetID {
-		return i
-	}
-	return -1
-}
-
-func GetRangedMessages(messages StreamMessages, i int, i2 int) []StreamMessage {
-	logrus.Info("Messages length ", len(messages.Messages))
-	logrus.Info("Messages: ", messages.Messages)
-	logrus.Info("i: ", i)
-	logrus.Info("i2: ", i2)
-
-	return messages.Messages[i : i2+1]
-}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFormID(t *testing.T) {
+	tests := []struct {
+		name    string
+		top     string
+		id      string
+		want    string
+		wantErr string
+	}{
+		{name: "explicit ID on a new stream", id: "5-3", want: "5-3"},
+		{name: "explicit ID above the top", top: "5-3", id: "5-4", want: "5-4"},
+		{name: "explicit ID with leading zeros", top: "5-3", id: "05-04", want: "5-4"},
+		{name: "any sequence on a new stream", id: "5-*", want: "5-0"},
+		{name: "any sequence at time zero", id: "0-*", want: "0-1"},
+		{name: "any sequence at the top time", top: "5-3", id: "5-*", want: "5-4"},
+		{name: "any sequence after the top time", top: "5-3", id: "6-*", want: "6-0"},
+		{name: "any sequence with leading zeros", top: "5-3", id: "05-*", want: "5-4"},
+		{
+			name: "any sequence past int64",
+			top:  "5-9223372036854775807",
+			id:   "5-*",
+			want: "5-9223372036854775808",
+		},
+		{
+			name:    "any sequence at the last sequence",
+			top:     "5-18446744073709551615",
+			id:      "5-*",
+			wantErr: "The ID specified in XADD is equal or smaller than the target stream top item",
+		},
+		{
+			name:    "any sequence before the top time",
+			top:     "5-3",
+			id:      "4-*",
+			wantErr: "The ID specified in XADD is equal or smaller than the target stream top item",
+		},
+		{
+			name:    "ID equal to the top",
+			top:     "5-3",
+			id:      "5-3",
+			wantErr: "The ID specified in XADD is equal or smaller than the target stream top item",
+		},
+		{
+			name:    "ID smaller than the top",
+			top:     "5-3",
+			id:      "4-9",
+			wantErr: "The ID specified in XADD is equal or smaller than the target stream top item",
+		},
+		{name: "zero ID", id: "0-0", wantErr: "The ID specified in XADD must be greater than 0-0"},
+		{name: "letters", id: "abc", wantErr: ErrInvalidStreamID.Error()},
+		{name: "missing sequence", id: "5-", wantErr: ErrInvalidStreamID.Error()},
+		{name: "missing time", id: "-3", wantErr: ErrInvalidStreamID.Error()},
+		{name: "empty", id: "", wantErr: ErrInvalidStreamID.Error()},
+		{name: "time only", id: "5", wantErr: ErrInvalidStreamID.Error()},
+		{name: "negative sequence", id: "5--1", wantErr: ErrInvalidStreamID.Error()},
+		{name: "three parts", id: "5-1-1", wantErr: ErrInvalidStreamID.Error()},
+		{name: "any time", id: "*-1", wantErr: ErrInvalidStreamID.Error()},
+		{name: "time past uint64", id: "18446744073709551616-1", wantErr: ErrInvalidStreamID.Error()},
+		{name: "any sequence past uint64", id: "18446744073709551616-*", wantErr: ErrInvalidStreamID.Error()},
+		{name: "sequence past uint64", id: "1-18446744073709551616", wantErr: ErrInvalidStreamID.Error()},
+		{name: "non ASCII digits", id: "٥-1", wantErr: ErrInvalidStreamID.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStore()
+			if tt.top != "" {
+				if err := s.XAdd("s", StreamMessage{ID: tt.top}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := FormID("s", tt.id, s)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("FormID(%q) = %q, %v, want error %q", tt.id, got, err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil || got != tt.want {
+				t.Fatalf("FormID(%q) = %q, %v, want %q", tt.id, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormIDWrongType(t *testing.T) {
+	s := NewStore()
+	s.Set("s", "v", nil)
+
+	if _, err := FormID("s", "5-*", s); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("FormID on a string = %v, want ErrWrongType", err)
+	}
+}
+
+func FuzzFormID(f *testing.F) {
+	for _, id := range []string{
+		"*", "5-*", "5-3", "0-0", "0-*", "4-9", "abc", "5-", "-3", "", "-", "--",
+		"5-18446744073709551615", "18446744073709551616-*", "05-*", "5-*-*",
+	} {
+		f.Add(id)
+	}
+
+	f.Fuzz(func(t *testing.T, id string) {
+		s := NewStore()
+		if err := s.XAdd("s", StreamMessage{ID: "5-3"}); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := FormID("s", id, s)
+		if err != nil {
+			return
+		}
+
+		ms, seq, err := parseID(got)
+		if err != nil {
+			t.Fatalf("FormID(%q) = %q, which does not parse", id, got)
+		}
+		if isIDSmallerOrEqual(ms, 5, seq, 3) {
+			t.Fatalf("FormID(%q) = %q, not above the top 5-3", id, got)
+		}
+	})
+}
+
+func TestNormalizeRange(t *testing.T) {
+	tests := []struct {
+		start, end, length int
+		wantStart, wantEnd int
+		wantOK             bool
+	}{
+		{0, -1, 5, 0, 4, true},
+		{-3, -1, 5, 2, 4, true},
+		{1, 3, 5, 1, 3, true},
+		{0, 100, 5, 0, 4, true},
+		{-100, 1, 5, 0, 1, true},
+		{-100, -100, 5, 0, 0, false},
+		{3, 1, 5, 0, 0, false},
+		{-1, -3, 5, 0, 0, false},
+		{5, 10, 5, 0, 0, false},
+		{0, 0, 1, 0, 0, true},
+		{0, -1, 0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		start, end, ok := NormalizeRange(tt.start, tt.end, tt.length)
+		if start != tt.wantStart || end != tt.wantEnd || ok != tt.wantOK {
+			t.Errorf("NormalizeRange(%d, %d, %d) = %d, %d, %v, want %d, %d, %v",
+				tt.start, tt.end, tt.length, start, end, ok, tt.wantStart, tt.wantEnd, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package store
+
+/*
+WatchKeys registers a waiter that is released by the next write that
+makes one of keys readable for a blocked reader, e.g. a push to a list.
+*/
+func (s *Store) WatchKeys(keys []string) <-chan struct{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.watch(keys)
+}
+
+/*
+Unwatch drops a waiter that was not released, e.g. on timeout.
+*/
+func (s *Store) Unwatch(wait <-chan struct{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if waiter, ok := s.waiterChans[wait]; ok {
+		s.dropWaiter(waiter)
+	}
+}
+
+/*
+watch registers a waiter on keys. The caller must hold the write lock.
+*/
+func (s *Store) watch(keys []string) <-chan struct{} {
+	waiter := &keyWaiter{ch: make(chan struct{}), keys: keys}
+
+	for _, key := range keys {
+		waiters, ok := s.waiters[key]
+		if !ok {
+			waiters = make(map[*keyWaiter]struct{})
+			s.waiters[key] = waiters
+		}
+		waiters[waiter] = struct{}{}
+	}
+	s.waiterChans[waiter.ch] = waiter
+
+	return waiter.ch
+}
+
+/*
+releaseWaiters wakes the readers blocked on key. Readers watching other
+keys are left alone. The caller must hold the write lock.
+*/
+func (s *Store) releaseWaiters(key string) {
+	for waiter := range s.waiters[key] {
+		close(waiter.ch)
+		s.dropWaiter(waiter)
+	}
+}
+
+/*
+dropWaiter unregisters a waiter from every key it watches.
+The caller must hold the write lock.
+*/
+func (s *Store) dropWaiter(waiter *keyWaiter) {
+	for _, key := range waiter.keys {
+		delete(s.waiters[key], waiter)
+
+		if len(s.waiters[key]) == 0 {
+			delete(s.waiters, key)
+		}
+	}
+
+	delete(s.waiterChans, waiter.ch)
+}
@@ -0,0 +1,451 @@
+package store
+
+import (
+	"math"
+	"sort"
+)
+
+/*
+ZAdd sets the score of each member of the sorted set stored at key,
+creating the sorted set when the key does not exist. Members that are
+already present are re-scored. It returns the number of members added and
+the number of present members whose score changed.
+*/
+func (s *Store) ZAdd(key string, members []ZMember) (int, int, error) {
+	var changed bool
+	defer s.notifyWriteIf(&changed, key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expireIfNeeded(key)
+
+	value, ok := s.store[key]
+	if !ok {
+		value = Value{
+			ValueData: ValueWithType{
+				Data:     ZSetT{Scores: make(map[string]float64)},
+				DataType: ZSetType,
+			},
+		}
+	}
+
+	if value.ValueData.DataType != ZSetType {
+		return 0, 0, ErrWrongType
+	}
+
+	zset := value.ValueData.Data.(ZSetT)
+
+	var added, updated int
+	for _, m := range members {
+		_, exists := zset.Scores[m.Member]
+		if !exists {
+			s.resize(key, zsetMemberSize(m.Member))
+			added++
+		}
+		if zset.insert(m.Member, m.Score) {
+			changed = true
+			if exists {
+				updated++
+			}
+		}
+	}
+
+	if !changed {
+		return 0, 0, nil
+	}
+
+	value.ValueData.Data = zset
+	s.store[key] = value
+
+	return added, updated, nil
+}
+
+/*
+ZRem removes members from the sorted set stored at key and returns how
+many were there. The key is deleted once the sorted set is empty.
+*/
+func (s *Store) ZRem(key string, members []string) (int, error) {
+	var changed bool
+	defer s.notifyWriteIf(&changed, key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	zset, ok, err := s.getZSet(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	var removed int
+	for _, member := range members {
+		if _, exists := zset.Scores[member]; exists {
+			zset.remove(member)
+			s.resize(key, -zsetMemberSize(member))
+			removed++
+		}
+	}
+
+	changed = removed > 0
+
+	if len(zset.Scores) == 0 {
+		s.remove(key)
+		return removed, nil
+	}
+
+	value := s.store[key]
+	value.ValueData.Data = zset
+	s.store[key] = value
+
+	return removed, nil
+}
+
+/*
+ZCard returns the number of members of the sorted set stored at key, 0
+when the key does not exist.
+*/
+func (s *Store) ZCard(key string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	zset, _, err := s.getZSet(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(zset.Scores), nil
+}
+
+/*
+ZIncrBy adds delta to the score of member in the sorted set stored at key,
+a missing key or member counting as 0, and returns the new score. Adding
+opposite infinities fails with ErrScoreNaN.
+*/
+func (s *Store) ZIncrBy(key string, member string, delta float64) (float64, error) {
+	var changed bool
+	defer s.notifyWriteIf(&changed, key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	zset, ok, err := s.getZSet(key)
+	if err != nil {
+		return 0, err
+	}
+
+	value := s.store[key]
+	if !ok {
+		zset = ZSetT{Scores: make(map[string]float64)}
+		value = Value{ValueData: ValueWithType{DataType: ZSetType}}
+	}
+
+	current, exists := zset.Scores[member]
+
+	score := current + delta
+	if math.IsNaN(score) {
+		return 0, ErrScoreNaN
+	}
+
+	if !exists {
+		s.resize(key, zsetMemberSize(member))
+	}
+
+	zset.insert(member, score)
+
+	value.ValueData.Data = zset
+	s.store[key] = value
+	changed = true
+
+	return score, nil
+}
+
+/*
+ZScore returns the score of member in the sorted set stored at key. It
+reports false when the key or the member does not exist.
+*/
+func (s *Store) ZScore(key string, member string) (float64, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	zset, _, err := s.getZSet(key)
+	if err != nil {
+		return 0, false, err
+	}
+
+	score, exists := zset.Scores[member]
+
+	return score, exists, nil
+}
+
+/*
+ZRank returns the 0-based position of member in the sorted set stored at
+key, ordered from the lowest score. It reports false when the key or the
+member does not exist.
+*/
+func (s *Store) ZRank(key string, member string) (int, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	zset, _, err := s.getZSet(key)
+	if err != nil {
+		return 0, false, err
+	}
+
+	score, exists := zset.Scores[member]
+	if !exists {
+		return 0, false, nil
+	}
+
+	return zset.rank(ZMember{Member: member, Score: score}), true, nil
+}
+
+/*
+ZRangeByScore returns the members of the sorted set stored at key whose
+score lies within r, ordered by score. A missing key yields no members.
+*/
+func (s *Store) ZRangeByScore(key string, r ZScoreRange) ([]ZMember, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	zset, _, err := s.getZSet(key)
+	if err != nil {
+		return nil, err
+	}
+
+	start := sort.Search(len(zset.Ranked), func(i int) bool {
+		if r.MinExclusive {
+			return zset.Ranked[i].Score > r.Min
+		}
+		return zset.Ranked[i].Score >= r.Min
+	})
+
+	var members []ZMember
+	for _, m := range zset.Ranked[start:] {
+		if m.Score > r.Max || (r.MaxExclusive && m.Score == r.Max) {
+			break
+		}
+		members = append(members, m)
+	}
+
+	return members, nil
+}
+
+/*
+ZSetOpStore computes the union or intersection of the sorted sets at keys
+and stores it at destination, replacing whatever was there. Plain sets
+take part with a score of 1 for every member. Each input score is
+multiplied by the weight of its key before aggregate combines them. An
+empty result deletes destination. It returns the cardinality of the result
+and whether destination was written or deleted.
+*/
+func (s *Store) ZSetOpStore(
+	destination string,
+	op SetOperation,
+	keys []string,
+	weights []float64,
+	aggregate ZAggregate,
+) (int, bool, error) {
+	var changed bool
+	defer s.notifyWriteIf(&changed, destination)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	inputs := make([]map[string]float64, len(keys))
+
+	for i, key := range keys {
+		scores, err := s.getScores(key)
+		if err != nil {
+			return 0, false, err
+		}
+		inputs[i] = scores
+	}
+
+	result := make(map[string]float64)
+
+	for i, scores := range inputs {
+		for member, score := range scores {
+			score = weightedScore(score, weights[i])
+
+			current, seen := result[member]
+			if !seen {
+				if op == SetInter && i > 0 {
+					continue
+				}
+				result[member] = score
+				continue
+			}
+
+			result[member] = aggregateScores(current, score, aggregate)
+		}
+
+		if op == SetInter && i > 0 {
+			for member := range result {
+				if _, ok := scores[member]; !ok {
+					delete(result, member)
+				}
+			}
+		}
+	}
+
+	if len(result) == 0 {
+		_, changed = s.store[destination]
+		s.remove(destination)
+		return 0, changed, nil
+	}
+
+	s.store[destination] = Value{
+		ValueData: ValueWithType{
+			Data:     newZSet(result),
+			DataType: ZSetType,
+		},
+	}
+	s.measure(destination)
+	changed = true
+
+	return len(result), true, nil
+}
+
+/*
+newZSet builds a sorted set, ranking the members of scores.
+*/
+func newZSet(scores map[string]float64) ZSetT {
+	ranked := make([]ZMember, 0, len(scores))
+	for member, score := range scores {
+		ranked = append(ranked, ZMember{Member: member, Score: score})
+	}
+
+	sort.Slice(ranked, func(i, j int) bool { return zMemberLess(ranked[i], ranked[j]) })
+
+	return ZSetT{Scores: scores, Ranked: ranked}
+}
+
+/*
+rank returns the position m has, or would have, in z.Ranked.
+*/
+func (z *ZSetT) rank(m ZMember) int {
+	return sort.Search(len(z.Ranked), func(i int) bool { return !zMemberLess(z.Ranked[i], m) })
+}
+
+/*
+insert sets the score of member, moving it to its new rank. It reports
+whether the sorted set changed.
+*/
+func (z *ZSetT) insert(member string, score float64) bool {
+	if current, exists := z.Scores[member]; exists {
+		if current == score {
+			return false
+		}
+		z.remove(member)
+	}
+
+	m := ZMember{Member: member, Score: score}
+	i := z.rank(m)
+
+	z.Ranked = append(z.Ranked, ZMember{})
+	copy(z.Ranked[i+1:], z.Ranked[i:])
+	z.Ranked[i] = m
+
+	z.Scores[member] = score
+
+	return true
+}
+
+/*
+remove deletes member from the sorted set if it is present.
+*/
+func (z *ZSetT) remove(member string) {
+	score, exists := z.Scores[member]
+	if !exists {
+		return
+	}
+
+	i := z.rank(ZMember{Member: member, Score: score})
+
+	z.Ranked = append(z.Ranked[:i], z.Ranked[i+1:]...)
+
+	delete(z.Scores, member)
+}
+
+// zMemberLess orders by score, ties broken by member like Redis.
+func zMemberLess(a ZMember, b ZMember) bool {
+	if a.Score != b.Score {
+		return a.Score < b.Score
+	}
+
+	return a.Member < b.Member
+}
+
+/*
+getZSet returns the sorted set stored at key, dropping the key first when
+it has expired. The caller must hold the write lock.
+*/
+func (s *Store) getZSet(key string) (ZSetT, bool, error) {
+	s.expireIfNeeded(key)
+
+	value, ok := s.store[key]
+	if !ok {
+		return ZSetT{}, false, nil
+	}
+
+	if value.ValueData.DataType != ZSetType {
+		return ZSetT{}, false, ErrWrongType
+	}
+
+	return value.ValueData.Data.(ZSetT), true, nil
+}
+
+/*
+getScores returns the members of the sorted set or set at key with their
+scores, members of a plain set scoring 1. A missing key yields no members.
+The caller must hold the write lock.
+*/
+func (s *Store) getScores(key string) (map[string]float64, error) {
+	s.expireIfNeeded(key)
+
+	value, ok := s.store[key]
+	if !ok {
+		return nil, nil
+	}
+
+	switch data := value.ValueData.Data.(type) {
+	case ZSetT:
+		return data.Scores, nil
+
+	case SetT:
+		scores := make(map[string]float64, len(data.Members))
+		for member := range data.Members {
+			scores[member] = 1
+		}
+		return scores, nil
+	}
+
+	return nil, ErrWrongType
+}
+
+// weightedScore multiplies like Redis, where 0 * ±inf is 0 rather than NaN.
+func weightedScore(score float64, weight float64) float64 {
+	result := score * weight
+	if math.IsNaN(result) {
+		return 0
+	}
+
+	return result
+}
+
+// aggregateScores combines two scores, a SUM of +inf and -inf being 0.
+func aggregateScores(a float64, b float64, aggregate ZAggregate) float64 {
+	switch aggregate {
+	case AggregateMin:
+		return math.Min(a, b)
+	case AggregateMax:
+		return math.Max(a, b)
+	}
+
+	sum := a + b
+	if math.IsNaN(sum) {
+		return 0
+	}
+
+	return sum
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestZAddAddsAndRescores(t *testing.T) {
+	s := NewStore()
+
+	added, updated, err := s.ZAdd("z", []ZMember{{Member: "a", Score: 3}, {Member: "b", Score: 1}, {Member: "c", Score: 2}})
+	if err != nil || added != 3 || updated != 0 {
+		t.Fatalf("ZAdd of new members = %d added, %d updated, %v, want 3 and 0", added, updated, err)
+	}
+
+	// re-scoring counts as an update, not an addition, and moves the member
+	added, updated, err = s.ZAdd("z", []ZMember{{Member: "a", Score: 0}, {Member: "d", Score: 2}})
+	if err != nil || added != 1 || updated != 1 {
+		t.Fatalf("ZAdd re-scoring a = %d added, %d updated, %v, want 1 and 1", added, updated, err)
+	}
+
+	// the same score again changes nothing
+	added, updated, err = s.ZAdd("z", []ZMember{{Member: "a", Score: 0}})
+	if err != nil || added != 0 || updated != 0 {
+		t.Fatalf("ZAdd of an unchanged score = %d added, %d updated, %v, want 0 and 0", added, updated, err)
+	}
+
+	got, _ := s.ZRangeByScore("z", ZScoreRange{Min: math.Inf(-1), Max: math.Inf(1)})
+	want := []ZMember{{"a", 0}, {"b", 1}, {"c", 2}, {"d", 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("members after re-scoring = %v, want %v", got, want)
+	}
+
+	s.Set("k", "v", nil)
+	if _, _, err := s.ZAdd("k", []ZMember{{Member: "a", Score: 1}}); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("ZAdd on a string = %v, want ErrWrongType", err)
+	}
+}
+
+func TestZRankFollowsScoreOrder(t *testing.T) {
+	s := NewStore()
+	s.ZAdd("z", []ZMember{{Member: "c", Score: 30}})
+	s.ZAdd("z", []ZMember{{Member: "a", Score: 10}})
+	s.ZAdd("z", []ZMember{{Member: "d", Score: -5}, {Member: "b", Score: 10}})
+
+	for want, member := range []string{"d", "a", "b", "c"} {
+		if got, ok, err := s.ZRank("z", member); err != nil || !ok || got != want {
+			t.Fatalf("ZRank %s = %d, %v, %v, want %d", member, got, ok, err, want)
+		}
+	}
+
+	// moving a member shifts the ranks of the ones it passes
+	s.ZAdd("z", []ZMember{{Member: "c", Score: 0}})
+	if got, _, _ := s.ZRank("z", "c"); got != 1 {
+		t.Fatalf("ZRank c after re-scoring = %d, want 1", got)
+	}
+	if got, _, _ := s.ZRank("z", "b"); got != 3 {
+		t.Fatalf("ZRank b after re-scoring c = %d, want 3", got)
+	}
+	if got, ok, _ := s.ZScore("z", "c"); !ok || got != 0 {
+		t.Fatalf("ZScore c = %v, %v, want 0", got, ok)
+	}
+
+	if _, ok, err := s.ZRank("z", "missing"); err != nil || ok {
+		t.Fatalf("ZRank of a missing member = %v, %v, want not found", ok, err)
+	}
+	if _, ok, err := s.ZScore("missing", "a"); err != nil || ok {
+		t.Fatalf("ZScore on a missing key = %v, %v, want not found", ok, err)
+	}
+}
+
+func TestZIncrByKeepsOrdering(t *testing.T) {
+	s := NewStore()
+	s.ZAdd("z", []ZMember{{Member: "a", Score: 1}, {Member: "b", Score: 2}, {Member: "c", Score: 3}})
+
+	// a jumps past c, then c drops below b
+	if got, err := s.ZIncrBy("z", "a", 5); err != nil || got != 6 {
+		t.Fatalf("ZIncrBy a 5 = %v, %v, want 6", got, err)
+	}
+	if got, err := s.ZIncrBy("z", "c", -2.5); err != nil || got != 0.5 {
+		t.Fatalf("ZIncrBy c -2.5 = %v, %v, want 0.5", got, err)
+	}
+	if got, err := s.ZIncrBy("z", "d", 2); err != nil || got != 2 {
+		t.Fatalf("ZIncrBy of a new member = %v, %v, want 2", got, err)
+	}
+
+	got, _ := s.ZRangeByScore("z", ZScoreRange{Min: math.Inf(-1), Max: math.Inf(1)})
+	want := []ZMember{{"c", 0.5}, {"b", 2}, {"d", 2}, {"a", 6}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("members after ZIncrBy = %v, want %v", got, want)
+	}
+	if n, _ := s.ZCard("z"); n != 4 {
+		t.Fatalf("ZCard = %d, want 4", n)
+	}
+
+	if removed, err := s.ZRem("z", []string{"a", "b", "missing"}); err != nil || removed != 2 {
+		t.Fatalf("ZRem a b missing = %d, %v, want 2", removed, err)
+	}
+	if rank, _, _ := s.ZRank("z", "d"); rank != 1 {
+		t.Fatalf("ZRank d after ZRem = %d, want 1", rank)
+	}
+
+	s.ZRem("z", []string{"c", "d"})
+	if s.Exists("z") {
+		t.Fatal("key exists after removing the last member")
+	}
+	if n, err := s.ZCard("z"); err != nil || n != 0 {
+		t.Fatalf("ZCard on the deleted key = %d, %v, want 0", n, err)
+	}
+}
+
+func TestZRangeByScoreBounds(t *testing.T) {
+	s := NewStore()
+	s.ZAdd("z", []ZMember{
+		{Member: "ninf", Score: math.Inf(-1)},
+		{Member: "a", Score: 1},
+		{Member: "b", Score: 2},
+		{Member: "c", Score: 2},
+		{Member: "d", Score: 3},
+		{Member: "pinf", Score: math.Inf(1)},
+	})
+
+	members := func(r ZScoreRange) string {
+		t.Helper()
+		got, err := s.ZRangeByScore("z", r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, m := range got {
+			names = append(names, m.Member)
+		}
+		return strings.Join(names, " ")
+	}
+
+	tests := []struct {
+		name string
+		r    ZScoreRange
+		want string
+	}{
+		{"inclusive", ZScoreRange{Min: 1, Max: 2}, "a b c"},
+		{"exclusive min", ZScoreRange{Min: 1, Max: 2, MinExclusive: true}, "b c"},
+		{"exclusive max", ZScoreRange{Min: 1, Max: 2, MaxExclusive: true}, "a"},
+		{"both exclusive", ZScoreRange{Min: 1, Max: 3, MinExclusive: true, MaxExclusive: true}, "b c"},
+		{"empty exclusive", ZScoreRange{Min: 2, Max: 2, MinExclusive: true}, ""},
+		{"min above max", ZScoreRange{Min: 3, Max: 1}, ""},
+		{"infinities", ZScoreRange{Min: math.Inf(-1), Max: math.Inf(1)}, "ninf a b c d pinf"},
+		{"exclusive infinities", ZScoreRange{Min: math.Inf(-1), Max: math.Inf(1), MinExclusive: true, MaxExclusive: true}, "a b c d"},
+		{"up to +inf", ZScoreRange{Min: 3, Max: math.Inf(1)}, "d pinf"},
+	}
+
+	for _, tt := range tests {
+		if got := members(tt.r); got != tt.want {
+			t.Errorf("%s: ZRangeByScore = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
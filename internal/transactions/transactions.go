@@ -53,6 +53,22 @@ func (t *Transactions) AddConnection(conn net.Conn) {
 	t.Values[conn] = NewTransactionBuffer()
 }
 
+func (t *Transactions) RemoveConnection(conn net.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if transactionBuffer, ok := t.Values[conn]; ok {
+		transactionBuffer.DiscardTransaction()
+	}
+
+	delete(t.Values, conn)
+
+	logrus.WithFields(logrus.Fields{
+		"package":  "transactions",
+		"function": "RemoveConnection",
+	}).Info("Transaction buffer has been removed")
+}
+
 func (t *Transactions) GetTransactionBuffer(conn net.Conn) *TransactionBuffer {
 	t.mu.Lock()
 	defer t.mu.Unlock()
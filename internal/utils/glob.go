@@ -0,0 +1,111 @@
+package utils
+
+/*
+MatchGlob reports whether str matches the glob-style pattern used by
+KEYS, SCAN and PSUBSCRIBE. It supports *, ?, [...] with ranges and
+negation, and backslash escaping.
+*/
+func MatchGlob(pattern string, str string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+
+			if len(pattern) == 1 {
+				return true
+			}
+
+			for i := 0; i <= len(str); i++ {
+				if MatchGlob(pattern[1:], str[i:]) {
+					return true
+				}
+			}
+
+			return false
+
+		case '?':
+			if len(str) == 0 {
+				return false
+			}
+
+			str = str[1:]
+			pattern = pattern[1:]
+
+		case '[':
+			if len(str) == 0 {
+				return false
+			}
+
+			matched, rest := matchClass(pattern[1:], str[0])
+			if !matched {
+				return false
+			}
+
+			str = str[1:]
+			pattern = rest
+
+		case '\\':
+			if len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+
+		default:
+			if len(str) == 0 || pattern[0] != str[0] {
+				return false
+			}
+
+			str = str[1:]
+			pattern = pattern[1:]
+		}
+	}
+
+	return len(str) == 0
+}
+
+/*
+matchClass matches c against the character class that starts right
+after '[' and returns the pattern remaining after the closing ']'.
+*/
+func matchClass(pattern string, c byte) (bool, string) {
+	not := len(pattern) > 0 && pattern[0] == '^'
+	if not {
+		pattern = pattern[1:]
+	}
+
+	matched := false
+
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) >= 2:
+			if pattern[1] == c {
+				matched = true
+			}
+			pattern = pattern[2:]
+
+		case len(pattern) >= 3 && pattern[1] == '-' && pattern[2] != ']':
+			start, end := pattern[0], pattern[2]
+			if start > end {
+				start, end = end, start
+			}
+			if c >= start && c <= end {
+				matched = true
+			}
+			pattern = pattern[3:]
+
+		default:
+			if pattern[0] == c {
+				matched = true
+			}
+			pattern = pattern[1:]
+		}
+	}
+
+	if len(pattern) > 0 {
+		pattern = pattern[1:]
+	}
+
+	return matched != not, pattern
+}
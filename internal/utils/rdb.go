@@ -1,11 +1,20 @@
 package utils
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/store"
 )
 
 const (
@@ -20,47 +29,297 @@ const (
 	opCodeEOF          byte = 255
 )
 
-func sliceIndex(data []byte, sep byte) int {
-	for i, b := range data {
-		if b == sep {
-			return i
+const rdbHeader = "REDIS0011"
+
+func LoadRDB(ctx context.Context, dir string, dbFileName string) {
+	// an empty dir is the working directory, as it is for Redis
+	path := filepath.Join(dir, dbFileName)
+	content, _ := os.ReadFile(path)
+	if len(content) == 0 {
+		logrus.Info("RDB file is empty")
+		return
+	}
+
+	if err := decodeRDB(bufio.NewReader(bytes.NewReader(content)), GetDatabasesObj(ctx)); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"package":  "utils",
+			"function": "LoadRDB",
+			"error":    err,
+		}).Error("Error loading RDB file")
+	}
+}
+
+/*
+SaveRDB writes every database into an RDB file. The file is written next
+to the target and renamed over it, so a failed save leaves the previous
+file in place.
+*/
+func SaveRDB(ctx context.Context, dir string, dbFileName string) error {
+	// an empty dir is the working directory, as it is for Redis
+	path := filepath.Join(dir, dbFileName)
+
+	var bb bytes.Buffer
+
+	if err := encodeRDB(&bb, SnapshotDatabases(ctx)); err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+
+	if err := os.WriteFile(tmpPath, bb.Bytes(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+/*
+ReloadRDB encodes every database as an RDB, empties them and loads the
+encoding back, the way DEBUG RELOAD checks that the dataset survives a
+restart. The encoding is kept in memory, the dump file is left alone.
+*/
+func ReloadRDB(ctx context.Context) error {
+	var bb bytes.Buffer
+
+	if err := encodeRDB(&bb, SnapshotDatabases(ctx)); err != nil {
+		return err
+	}
+
+	databases := GetDatabasesObj(ctx)
+	for _, db := range databases.All() {
+		db.Flush()
+	}
+
+	return decodeRDB(bufio.NewReader(&bb), databases)
+}
+
+/*
+SnapshotDatabases returns a snapshot of every database, indexed like
+the databases themselves.
+*/
+func SnapshotDatabases(ctx context.Context) []map[string]store.Value {
+	databases := GetDatabasesObj(ctx).All()
+
+	snapshots := make([]map[string]store.Value, len(databases))
+	for i, db := range databases {
+		snapshots[i] = db.Snapshot()
+	}
+
+	return snapshots
+}
+
+/*
+encodeRDB writes the snapshots of the databases to w as an RDB file, one
+key at a time, so the encoding is never held in memory as a whole. Each
+non-empty database gets its own SELECTDB section. A value that cannot be
+serialized fails the whole encoding rather than being left out.
+*/
+func encodeRDB(w io.Writer, snapshots []map[string]store.Value) error {
+	if _, err := io.WriteString(w, rdbHeader); err != nil {
+		return err
+	}
+
+	for db, snapshot := range snapshots {
+		if len(snapshot) == 0 {
+			continue
+		}
+
+		if err := encodeRDBDatabase(w, db, snapshot); err != nil {
+			return err
 		}
 	}
-	return -1
+
+	var bb bytes.Buffer
+	bb.WriteByte(opCodeEOF)
+	// a zero checksum tells readers that checksumming is disabled
+	bb.Write(make([]byte, 8))
+
+	_, err := w.Write(bb.Bytes())
+
+	return err
 }
 
-func parseTable(bytes []byte) []byte {
-	start := sliceIndex(bytes, opCodeResizeDB)
-	end := sliceIndex(bytes, opCodeEOF)
-	return bytes[start+1 : end]
+func encodeRDBDatabase(w io.Writer, db int, snapshot map[string]store.Value) error {
+	var bb, entry bytes.Buffer
+	var expires int
+
+	// the resize hint comes before the keys
+	for _, value := range snapshot {
+		if value.ExpiredAt != nil {
+			expires++
+		}
+	}
+
+	bb.WriteByte(opCodeSelectDB)
+	store.WriteRDBLength(&bb, db)
+	bb.WriteByte(opCodeResizeDB)
+	store.WriteRDBLength(&bb, len(snapshot))
+	store.WriteRDBLength(&bb, expires)
+
+	if _, err := w.Write(bb.Bytes()); err != nil {
+		return err
+	}
+
+	for key, value := range snapshot {
+		entry.Reset()
+		if err := store.SerializeValue(&entry, value); err != nil {
+			return fmt.Errorf("encoding key %q: %w", key, err)
+		}
+
+		bb.Reset()
+
+		if value.ExpiredAt != nil {
+			bb.WriteByte(opCodeExpireTimeMs)
+			binary.Write(&bb, binary.LittleEndian, uint64(value.ExpiredAt.UnixMilli()))
+		}
+
+		// the key sits between the type byte and the value
+		bb.WriteByte(entry.Bytes()[0])
+		store.WriteRDBString(&bb, key)
+		bb.Write(entry.Bytes()[1:])
+
+		if _, err := w.Write(bb.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func ReadFile(path string) string {
-	c, _ := os.ReadFile(path)
-	logrus.Debug(c)
-	logrus.Debug(string(c))
-	key := parseTable(c)
-	str := key[4 : 4+key[3]]
-	return string(str)
+/*
+NewRDBReader returns the size of the RDB encoding of snapshots together
+with a reader producing it. The snapshots are a copy of every value, only
+the encoding is spared: it is written into a pipe as the reader is
+consumed instead of being built in memory. Sizing it encodes the same
+snapshots once more, so the size matches the bytes the reader produces
+whatever order the keys come in. Closing the reader early stops the
+encoder.
+*/
+func NewRDBReader(snapshots []map[string]store.Value) (int64, io.ReadCloser, error) {
+	var size countingWriter
+	if err := encodeRDB(&size, snapshots); err != nil {
+		return 0, nil, err
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(encodeRDB(w, snapshots))
+	}()
+
+	return int64(size), r, nil
 }
 
-func LoadRDB(ctx context.Context, dir string, dbFileName string) {
-	path := fmt.Sprintf("%s/%s", dir, dbFileName)
-	content, _ := os.ReadFile(path)
-	if len(content) == 0 {
-		logrus.Info("RDB file is empty")
-		return
+/*
+LoadRDBFrom loads an RDB read from r into the databases, the way a replica
+takes the dataset sent by its master. r is read to the end even when
+decoding stops early.
+*/
+func LoadRDBFrom(ctx context.Context, r io.Reader) error {
+	err := decodeRDB(bufio.NewReader(r), GetDatabasesObj(ctx))
+	io.Copy(io.Discard, r)
+
+	return err
+}
+
+type countingWriter int64
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	*c += countingWriter(len(p))
+	return len(p), nil
+}
+
+/*
+decodeRDB loads an RDB file into databases, each key into the database
+selected by the SELECTDB before it, DB 0 until the first one.
+*/
+func decodeRDB(r *bufio.Reader, databases *store.Databases) error {
+	header := make([]byte, len(rdbHeader))
+	if _, err := io.ReadFull(r, header); err != nil {
+		return err
 	}
 
-	line := parseTable(content)
-	key := string(line[4 : 4+line[3]])
-	value := string(line[5+line[3]:])
+	if string(header[:5]) != "REDIS" {
+		return errors.New("not an RDB file")
+	}
+
+	storeObj, _ := databases.Get(0)
+	var expiredAt *time.Time
+
+	for {
+		opCode, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		switch opCode {
+		case opCodeEOF:
+			return nil
 
-	logrus.WithFields(logrus.Fields{
-		"key":   key,
-		"value": value,
-	}).Debug("LoadRDB")
+		case opCodeAux:
+			if _, err := store.ReadRDBString(r); err != nil {
+				return err
+			}
+			if _, err := store.ReadRDBString(r); err != nil {
+				return err
+			}
 
-	storeObj := GetStoreObj(ctx)
-	storeObj.Set(key, value, nil)
+		case opCodeSelectDB:
+			db, _, err := store.ReadRDBLength(r)
+			if err != nil {
+				return err
+			}
+
+			var ok bool
+			if storeObj, ok = databases.Get(db); !ok {
+				return fmt.Errorf(
+					"RDB selects DB %d but only %d databases are configured",
+					db,
+					databases.Count(),
+				)
+			}
+
+		case opCodeResizeDB:
+			if _, _, err := store.ReadRDBLength(r); err != nil {
+				return err
+			}
+			if _, _, err := store.ReadRDBLength(r); err != nil {
+				return err
+			}
+
+		case opCodeExpireTimeMs:
+			var ms uint64
+			if err := binary.Read(r, binary.LittleEndian, &ms); err != nil {
+				return err
+			}
+			t := time.UnixMilli(int64(ms))
+			expiredAt = &t
+
+		case opCodeExpireTime:
+			var sec uint32
+			if err := binary.Read(r, binary.LittleEndian, &sec); err != nil {
+				return err
+			}
+			t := time.Unix(int64(sec), 0)
+			expiredAt = &t
+
+		default:
+			// anything else is the value type of a key
+			key, err := store.ReadRDBString(r)
+			if err != nil {
+				return err
+			}
+
+			value, err := store.ReadRDBValue(opCode, r)
+			if err != nil {
+				return fmt.Errorf("loading key %q of RDB type %d: %w", key, opCode, err)
+			}
+
+			value.ExpiredAt = expiredAt
+			expiredAt = nil
+
+			if !storeObj.Load(key, value) {
+				logrus.WithField("key", key).Debug("Skipping expired key in RDB")
+			}
+		}
+	}
 }
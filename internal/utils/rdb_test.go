@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/codecrafters-io/redis-starter-go/internal/store"
+)
+
+func TestMain(m *testing.M) {
+	logrus.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func newDatabasesContext(databases *store.Databases) context.Context {
+	storeObj, _ := databases.Get(0)
+
+	ctx := context.WithValue(context.Background(), "store", storeObj)
+	return context.WithValue(ctx, "databases", databases)
+}
+
+func snapshotsOf(databases *store.Databases) []map[string]store.Value {
+	return SnapshotDatabases(newDatabasesContext(databases))
+}
+
+/*
+fillStore writes one key of every type into storeObj.
+*/
+func fillStore(storeObj *store.Store) {
+	px := 60_000
+	storeObj.Set("string", "value", &px)
+	storeObj.RPush("list", []string{"a", "b", "c"})
+	storeObj.SAdd("set", []string{"1", "2", "x"})
+	storeObj.ZAdd("zset", []store.ZMember{{Member: "a", Score: 1.5}, {Member: "b", Score: -2}})
+	storeObj.HSet("hash", []string{"f1", "v1", "f2", "v2"})
+	storeObj.HExpire("hash", 100, []string{"f1"})
+	storeObj.XAdd("stream", store.StreamMessage{
+		ID:     "5-1",
+		Fields: []store.StreamField{{Name: "f", Value: "v"}},
+	})
+	storeObj.XGroupCreate("stream", "group", "0-0", false)
+}
+
+/*
+assertFilled checks that storeObj holds what fillStore wrote.
+*/
+func assertFilled(t *testing.T, storeObj *store.Store) {
+	t.Helper()
+
+	if got, err := storeObj.Get("string"); err != nil || got != "value" {
+		t.Fatalf("string = %q, %v", got, err)
+	}
+	if _, _, hasExpiry := storeObj.TTLRemaining("string"); !hasExpiry {
+		t.Fatal("string lost its expiration")
+	}
+
+	if got, _ := storeObj.LRange("list", 0, -1); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Fatalf("list = %v", got)
+	}
+
+	members, _ := storeObj.SMembers("set")
+	sort.Strings(members)
+	if !reflect.DeepEqual(members, []string{"1", "2", "x"}) {
+		t.Fatalf("set = %v", members)
+	}
+
+	if score, ok, _ := storeObj.ZScore("zset", "b"); !ok || score != -2 {
+		t.Fatalf("zset b = %v, %v", score, ok)
+	}
+
+	if got, ok, _ := storeObj.HGet("hash", "f2"); !ok || got != "v2" {
+		t.Fatalf("hash f2 = %q, %v", got, ok)
+	}
+	if ttls, _ := storeObj.HTTL("hash", []string{"f1", "f2"}); !reflect.DeepEqual(ttls, []int{100, -1}) {
+		t.Fatalf("hash TTLs = %v", ttls)
+	}
+
+	if lastID, _ := storeObj.GetLastStreamID("stream", ""); lastID != "5-1" {
+		t.Fatalf("stream last ID = %s", lastID)
+	}
+	if groups, _ := storeObj.XInfoGroups("stream"); len(groups) != 1 || groups[0].Name != "group" {
+		t.Fatalf("stream groups = %+v", groups)
+	}
+}
+
+func TestRDBRoundTripsEveryType(t *testing.T) {
+	source := store.NewDatabases(1)
+	db0, _ := source.Get(0)
+	fillStore(db0)
+
+	var bb bytes.Buffer
+	if err := encodeRDB(&bb, snapshotsOf(source)); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := store.NewDatabases(1)
+	if err := decodeRDB(bufio.NewReader(&bb), loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	db0, _ = loaded.Get(0)
+	assertFilled(t, db0)
+}
+
+func TestSaveAndLoadRDBKeepsEveryDatabase(t *testing.T) {
+	dir := t.TempDir()
+
+	source := store.NewDatabases(16)
+	for _, index := range []int{0, 5, 15} {
+		db, _ := source.Get(index)
+		fillStore(db)
+		db.Set("db", strconv.Itoa(index), nil)
+	}
+
+	if err := SaveRDB(newDatabasesContext(source), dir, "dump.rdb"); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := store.NewDatabases(16)
+	LoadRDB(newDatabasesContext(loaded), dir, "dump.rdb")
+
+	for index, db := range loaded.All() {
+		switch index {
+		case 0, 5, 15:
+			assertFilled(t, db)
+			if got, _ := db.Get("db"); got != strconv.Itoa(index) {
+				t.Fatalf("DB %d holds the keys of DB %s", index, got)
+			}
+		default:
+			if keys, _ := db.Counts(); keys != 0 {
+				t.Fatalf("DB %d has %d keys, want 0", index, keys)
+			}
+		}
+	}
+}
+
+func TestLoadRDBRejectsDatabaseOutOfRange(t *testing.T) {
+	source := store.NewDatabases(16)
+	db9, _ := source.Get(9)
+	db9.Set("k", "v", nil)
+
+	var bb bytes.Buffer
+	if err := encodeRDB(&bb, snapshotsOf(source)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := decodeRDB(bufio.NewReader(&bb), store.NewDatabases(4)); err == nil {
+		t.Fatal("loading DB 9 into 4 databases did not fail")
+	}
+}
+
+type unknownStorable struct{}
+
+func (unknownStorable) IsStorable() {}
+
+func TestEncodeRDBFailsOnUnserializableValue(t *testing.T) {
+	snapshot := map[string]store.Value{
+		"odd": {ValueData: store.ValueWithType{Data: unknownStorable{}}},
+	}
+
+	if err := encodeRDB(io.Discard, []map[string]store.Value{snapshot}); err == nil {
+		t.Fatal("encodeRDB dropped a value it cannot serialize without an error")
+	}
+}
+
+func TestNewRDBReaderFailsOnUnserializableValue(t *testing.T) {
+	snapshot := map[string]store.Value{
+		"odd": {ValueData: store.ValueWithType{Data: unknownStorable{}}},
+	}
+
+	if _, _, err := NewRDBReader([]map[string]store.Value{snapshot}); err == nil {
+		t.Fatal("NewRDBReader announced a size for a value it cannot serialize")
+	}
+}
+
+func TestLoadRDBSkipsExpiredKeys(t *testing.T) {
+	expiredAt := time.Now().Add(-time.Second)
+	snapshot := map[string]store.Value{
+		"gone": {
+			ValueData: store.ValueWithType{Data: store.StringT("v"), DataType: store.StringType},
+			ExpiredAt: &expiredAt,
+		},
+	}
+
+	var bb bytes.Buffer
+	if err := encodeRDB(&bb, []map[string]store.Value{snapshot}); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := store.NewDatabases(1)
+	if err := decodeRDB(bufio.NewReader(&bb), loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	if db0, _ := loaded.Get(0); db0.Exists("gone") {
+		t.Fatal("expired key was loaded")
+	}
+}
+
+func TestNewRDBReaderStreamsEveryDatabase(t *testing.T) {
+	source := store.NewDatabases(16)
+	for _, index := range []int{0, 2, 7} {
+		db, _ := source.Get(index)
+		fillStore(db)
+		db.Set("db", strconv.Itoa(index), nil)
+	}
+
+	size, reader, err := NewRDBReader(snapshotsOf(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	payload, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(payload)) != size {
+		t.Fatalf("NewRDBReader announced %d bytes and produced %d", size, len(payload))
+	}
+
+	replica := store.NewDatabases(16)
+	if err := LoadRDBFrom(newDatabasesContext(replica), bytes.NewReader(payload)); err != nil {
+		t.Fatal(err)
+	}
+
+	for index, db := range replica.All() {
+		switch index {
+		case 0, 2, 7:
+			assertFilled(t, db)
+			if got, _ := db.Get("db"); got != strconv.Itoa(index) {
+				t.Fatalf("DB %d holds the keys of DB %s", index, got)
+			}
+		default:
+			if keys, _ := db.Counts(); keys != 0 {
+				t.Fatalf("DB %d has %d keys, want 0", index, keys)
+			}
+		}
+	}
+}
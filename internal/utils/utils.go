@@ -8,18 +8,6 @@ import (
 	"github.com/codecrafters-io/redis-starter-go/internal/store"
 )
 
-func GetBlockChObj(ctx context.Context) chan struct{} {
-	blockChFromContext := ctx.Value("blockCh")
-	if blockChFromContext != nil {
-		if blockCh, ok := blockChFromContext.(chan struct{}); !ok {
-			log.Fatalf("Expected chan struct{}, got %T", blockChFromContext)
-		} else {
-			return blockCh
-		}
-	}
-	return nil
-}
-
 func GetClientsObj(ctx context.Context) *clients.Clients {
 	clientsFromContext := ctx.Value("clients")
 	if clientsFromContext != nil {
@@ -32,6 +20,54 @@ func GetClientsObj(ctx context.Context) *clients.Clients {
 	return nil
 }
 
+func GetTrackingObj(ctx context.Context) *clients.Tracking {
+	trackingFromContext := ctx.Value("tracking")
+	if trackingFromContext != nil {
+		if tracking, ok := trackingFromContext.(*clients.Tracking); !ok {
+			log.Fatalf("Expected *clients.Tracking, got %T", trackingFromContext)
+		} else {
+			return tracking
+		}
+	}
+	return nil
+}
+
+func GetPubSubObj(ctx context.Context) *clients.PubSub {
+	pubSubFromContext := ctx.Value("pubsub")
+	if pubSubFromContext != nil {
+		if pubSub, ok := pubSubFromContext.(*clients.PubSub); !ok {
+			log.Fatalf("Expected *clients.PubSub, got %T", pubSubFromContext)
+		} else {
+			return pubSub
+		}
+	}
+	return nil
+}
+
+func GetConnectionsObj(ctx context.Context) *clients.Connections {
+	connectionsFromContext := ctx.Value("connections")
+	if connectionsFromContext != nil {
+		if connections, ok := connectionsFromContext.(*clients.Connections); !ok {
+			log.Fatalf("Expected *clients.Connections, got %T", connectionsFromContext)
+		} else {
+			return connections
+		}
+	}
+	return nil
+}
+
+func GetDatabasesObj(ctx context.Context) *store.Databases {
+	databasesFromContext := ctx.Value("databases")
+	if databasesFromContext != nil {
+		if databases, ok := databasesFromContext.(*store.Databases); !ok {
+			log.Fatalf("Expected *store.Databases, got %T", databasesFromContext)
+		} else {
+			return databases
+		}
+	}
+	return nil
+}
+
 func GetStoreObj(ctx context.Context) *store.Store {
 	storeFromContext := ctx.Value("store")
 